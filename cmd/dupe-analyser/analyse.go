@@ -0,0 +1,66 @@
+// cmd/dupe-analyser/analyse.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// runAnalyse implements the analyse command, which runs a full duplicate
+// analysis without the TUI, as -headless does.
+func runAnalyse(args []string) {
+	o := loadOptions()
+	fs := flag.NewFlagSet("analyse", flag.ExitOnError)
+	o.commonFlags(fs)
+	o.sourceFlags(fs)
+	o.analysisFlags(fs)
+	o.outputFlags(fs)
+	o.reportFlags(fs)
+	o.notifyFlags(fs)
+	o.autoPurgeFlags(fs)
+	o.daemonFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s analyse [flags] [paths...]\n\nFinds duplicate keys and rows across local or GCS paths, prints the report and saves it to the log path. Paths may be given as arguments or with -path.\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	o.resolve(fs)
+	o.addPaths(fs.Args())
+	o.isHeadless = true
+	o.run()
+}
+
+// runValidate implements the validate command, which counts how often the
+// unique key appears across the files without tracking duplicates, as
+// -validate does.
+func runValidate(args []string) {
+	o := loadOptions()
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	o.commonFlags(fs)
+	o.sourceFlags(fs)
+	o.outputFlags(fs)
+	o.notifyFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s validate [flags] [paths...]\n\nStreams every file to check that the -key field exists and count its occurrences, without tracking duplicates. Paths may be given as arguments or with -path.\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	o.resolve(fs)
+	o.addPaths(fs.Args())
+	o.isHeadless = true
+	o.isValidate = true
+	o.run()
+}
+
+// addPaths adds the paths given as arguments to those given with -path.
+func (o *options) addPaths(paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	if o.cfg.Path != "" {
+		paths = append([]string{o.cfg.Path}, paths...)
+	}
+	o.cfg.Path = strings.Join(paths, ",")
+}
@@ -0,0 +1,54 @@
+// cmd/dupe-analyser/bench.go
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"github.com/benjaminwestern/dupe-analyser/internal/bench"
+)
+
+// runBench implements the bench command, which measures analyser throughput
+// on generated data across a range of worker counts.
+func runBench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	cfg := bench.Config{}
+	var workers string
+	fs.IntVar(&cfg.Rows, "rows", 1000000, "Number of rows to generate")
+	fs.IntVar(&cfg.Files, "files", 8, "Number of files to spread the rows across")
+	fs.Float64Var(&cfg.DuplicateRate, "dupes", 0.05, "Fraction of rows that repeat an earlier id (0 to 1)")
+	fs.StringVar(&workers, "workers", "1,2,4,8", "Comma-separated worker counts to measure")
+	fs.StringVar(&cfg.Dir, "dir", "", "Empty directory to generate the data in (defaults to a temporary directory)")
+	fs.BoolVar(&cfg.Keep, "keep", false, "Keep the generated data instead of deleting it afterwards")
+	fs.Uint64Var(&cfg.Seed, "seed", 1, "Random seed, so runs can be compared on identical data")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s bench [flags]\n\nGenerates synthetic NDJSON and reports rows/sec and MB/sec per worker count.\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if cfg.Rows < 1 || cfg.Files < 1 || cfg.DuplicateRate < 0 || cfg.DuplicateRate > 1 {
+		fmt.Println("Error: -rows and -files must be positive and -dupes must be between 0 and 1.")
+		os.Exit(1)
+	}
+	for _, w := range strings.Split(workers, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(w))
+		if err != nil || n < 1 {
+			fmt.Printf("Error: invalid worker count %q in -workers.\n", w)
+			os.Exit(1)
+		}
+		cfg.Workers = append(cfg.Workers, n)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if _, err := bench.Run(ctx, cfg, os.Stdout); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,206 @@
+// cmd/dupe-analyser/config.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/benjaminwestern/dupe-analyser/internal/config"
+)
+
+// resolve fills in the flags not given on the command line from the
+// environment and then the job files, so that flags take precedence over the
+// environment, the environment over the -config file, that over the project
+// file, and the project file over the saved configuration. It exits on an
+// invalid setting.
+func (o *options) resolve(fs *flag.FlagSet) {
+	if err := applyEnv(fs); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := o.applyConfigFiles(fs); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "key" {
+			o.keyIsSet = true
+		}
+	})
+}
+
+// applyEnv sets each flag not already set from its DUPE_ANALYSER_*
+// environment variable, for containers configured by their environment. A
+// -key variable may list several keys separated by commas.
+func applyEnv(fs *flag.FlagSet) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || set[f.Name] {
+			return
+		}
+		name := config.EnvName(f.Name)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		values := []string{value}
+		if f.Name == "key" {
+			values = strings.Split(value, ",")
+		}
+		if setErr := setFlag(fs, f.Name, values); setErr != nil {
+			err = fmt.Errorf("%s: %w", name, setErr)
+		}
+	})
+	return err
+}
+
+// applyConfigFiles loads the -config file, then the project file found by
+// config.FindProjectFile, and sets each flag they name that is not already
+// set, so the -config file takes precedence over the project file.
+func (o *options) applyConfigFiles(fs *flag.FlagSet) error {
+	if o.configPath != "" {
+		file, err := config.LoadFile(o.configPath)
+		if err != nil {
+			return err
+		}
+		if err := applyFile(fs, file, nil); err != nil {
+			return err
+		}
+		o.files = append(o.files, file)
+	}
+	path, err := config.FindProjectFile()
+	if err != nil {
+		return fmt.Errorf("could not look for %s: %w", config.ProjectFileName, err)
+	}
+	if path == "" || o.isConfigPath(path) {
+		return nil
+	}
+	file, err := config.LoadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := applyFile(fs, file, projectSettings); err != nil {
+		return err
+	}
+	o.files = append(o.files, file)
+	return nil
+}
+
+// projectSettings are the settings a project file may make. It is picked up
+// from the working directory or any parent, so it is limited to what is
+// analysed and how it is reported; settings that change or send data, such
+// as -purge.auto, -dedup.out or -notify.webhook, must be given explicitly.
+var projectSettings = map[string]bool{
+	"key":                          true,
+	"path":                         true,
+	"left":                         true,
+	"right":                        true,
+	"scope":                        true,
+	"check.key":                    true,
+	"check.row":                    true,
+	"constraints":                  true,
+	"fuzzy.field":                  true,
+	"fuzzy.threshold":              true,
+	"row.raw":                      true,
+	"max-line-size":                true,
+	"output":                       true,
+	"output.txt":                   true,
+	"output.json":                  true,
+	"output.csv":                   true,
+	"output.html":                  true,
+	"output.md":                    true,
+	"output.sarif":                 true,
+	"output.junit":                 true,
+	"output.ndjson":                true,
+	"output.sqlite":                true,
+	"show.folders":                 true,
+	"show.files":                   true,
+	"report.max-sets":              true,
+	"report.max-locations-per-set": true,
+}
+
+// isConfigPath reports whether path is the -config file, which is only read
+// once when it is also the project file.
+func (o *options) isConfigPath(path string) bool {
+	if o.configPath == "" {
+		return false
+	}
+	abs, err := filepath.Abs(o.configPath)
+	return err == nil && abs == path
+}
+
+// applyFile sets each flag a job file names that is not already set. Settings
+// for flags another command takes are ignored, so one file can serve both
+// analyse and validate. When allowed is not nil, other settings are ignored
+// with a warning.
+func applyFile(fs *flag.FlagSet, file *config.File, allowed map[string]bool) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	known := flag.NewFlagSet("all", flag.ContinueOnError)
+	all := newOptions(&config.Config{})
+	all.legacyFlags(known)
+	all.serveFlags(known)
+	for _, name := range file.Names() {
+		if name == "config" {
+			return fmt.Errorf("config file %s cannot name another config file", file.Path)
+		}
+		if known.Lookup(name) == nil {
+			return fmt.Errorf("unknown setting %s in %s", name, file.Path)
+		}
+		if allowed != nil && !allowed[name] {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring %s in %s; a project file can only set what is analysed and reported.\n", name, file.Path)
+			continue
+		}
+		if fs.Lookup(name) == nil || set[name] {
+			continue
+		}
+		if err := setFlag(fs, name, file.Settings[name]); err != nil {
+			return fmt.Errorf("setting %s in %s: %w", name, file.Path, err)
+		}
+	}
+	return nil
+}
+
+// fileConstraints returns the uniqueness constraints of the job file read first
+// that declares any.
+func (o *options) fileConstraints() []config.ConstraintConfig {
+	for _, f := range o.files {
+		if len(f.Constraints) > 0 {
+			return f.Constraints
+		}
+	}
+	return nil
+}
+
+// fileKeys adds the key bindings of the job files to keys, those of earlier
+// files replacing those of later ones.
+func (o *options) fileKeys(keys map[string][]string) map[string][]string {
+	for i := len(o.files) - 1; i >= 0; i-- {
+		for action, k := range o.files[i].Keys {
+			if keys == nil {
+				keys = make(map[string][]string)
+			}
+			keys[action] = k
+		}
+	}
+	return keys
+}
+
+// setFlag sets a flag from a list of values: the repeatable -key flag is set
+// once per value, and every other flag to the values joined with commas.
+func setFlag(fs *flag.FlagSet, name string, values []string) error {
+	if name != "key" {
+		return fs.Set(name, strings.Join(values, ","))
+	}
+	for _, v := range values {
+		if err := fs.Set(name, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+// cmd/dupe-analyser/debug.go
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	_ "net/http/pprof"
+	"os"
+	"runtime/metrics"
+)
+
+// runtimeMetrics are the runtime/metrics samples published under /debug/vars,
+// chosen to show how the duplicate indexes grow over a run.
+var runtimeMetrics = []string{
+	"/memory/classes/heap/objects:bytes",
+	"/memory/classes/total:bytes",
+	"/gc/heap/objects:objects",
+	"/gc/cycles/total:gc-cycles",
+	"/sched/goroutines:goroutines",
+}
+
+// startDebugServer serves pprof profiles under /debug/pprof/ and expvar
+// metrics, including runtime memory and goroutine counts, under /debug/vars
+// for the life of the process.
+func startDebugServer(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("could not listen on %s: %w", addr, err)
+	}
+	expvar.Publish("runtime", expvar.Func(func() any {
+		samples := make([]metrics.Sample, len(runtimeMetrics))
+		for i, name := range runtimeMetrics {
+			samples[i].Name = name
+		}
+		metrics.Read(samples)
+		values := make(map[string]any, len(samples))
+		for _, s := range samples {
+			switch s.Value.Kind() {
+			case metrics.KindUint64:
+				values[s.Name] = s.Value.Uint64()
+			case metrics.KindFloat64:
+				values[s.Name] = s.Value.Float64()
+			}
+		}
+		return values
+	}))
+
+	fmt.Fprintf(os.Stderr, "Serving pprof and metrics on http://%s/debug/\n", listener.Addr())
+	go func() {
+		if err := http.Serve(listener, nil); err != nil {
+			slog.Error("Debug server stopped", "error", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,49 @@
+// cmd/dupe-analyser/diff.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+)
+
+// runDiff implements the diff command, which compares two JSON reports and
+// prints the duplicates introduced and resolved between them.
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	output := fs.String("output", "txt", "Output format for the diff: 'txt' or 'json'")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s diff [flags] <before.json> <after.json>\n\nCompares two reports saved with -output.json and prints newly introduced and resolved duplicates and the change in each summary figure.\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	before, err := report.LoadJSON(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	after, err := report.LoadJSON(fs.Arg(1))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	diff := report.Diff(fs.Arg(0), before, fs.Arg(1), after)
+	if *output == "json" {
+		jsonDiff, err := diff.ToJSON()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(jsonDiff)
+	} else {
+		fmt.Println(diff.String())
+	}
+}
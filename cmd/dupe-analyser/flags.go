@@ -0,0 +1,293 @@
+// cmd/dupe-analyser/flags.go
+package main
+
+import (
+	"flag"
+	"time"
+
+	"github.com/benjaminwestern/dupe-analyser/internal/config"
+	"github.com/benjaminwestern/dupe-analyser/internal/purge"
+	"github.com/benjaminwestern/dupe-analyser/internal/sink"
+	"github.com/benjaminwestern/dupe-analyser/pkg/analyser"
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+	"github.com/benjaminwestern/dupe-analyser/pkg/source"
+)
+
+// options holds every setting the command line can make. Each command
+// registers only the groups of flags that apply to it on its own flag set.
+type options struct {
+	cfg *config.Config
+
+	// configPath is the -config job file, and files the job files read, in
+	// order of precedence.
+	configPath string
+	files      []*config.File
+
+	isHeadless         bool
+	isValidate         bool
+	outputFormat       string
+	keyIsSet           bool
+	additionalKeys     []string
+	constraintsPath    string
+	sampleRecords      int
+	maxSampleBytes     int
+	indexMode          string
+	indexDir           string
+	bloomPrePass       bool
+	bloomItems         uint64
+	rawRowHash         bool
+	maxMemory          byteSizeFlag
+	maxLineSize        byteSizeFlag
+	chunkSize          byteSizeFlag
+	memoryMap          bool
+	checkpointInterval time.Duration
+	maxDuration        time.Duration
+	resumePath         string
+	cachePath          string
+	shardOutput        bool
+	debugAddr          string
+	enableCsvOutput    bool
+	enableHtmlOutput   bool
+	enableMdOutput     bool
+	enableSarifOutput  bool
+	enableJUnitOutput  bool
+	enableNdjsonOutput bool
+	enableSqliteOutput bool
+	bigQueryTable      string
+	uploadPath         string
+	templatePath       string
+	maxSets            int
+	maxLocationsPerSet int
+	failOnDuplicates   bool
+	failThreshold      string
+	quiet              bool
+	progressFormat     string
+	trace              bool
+	notifyWebhook      string
+	notifyFormat       string
+	notifyEmail        string
+	showFiles          bool
+	maskKeys           string
+	compressReports    bool
+	purgeStrategy      string
+	purgeKeep          string
+	purgePlanPath      string
+	purgeApplyPath     string
+	purgeBackupDir     string
+	purgeGCSBackup     string
+	purgeRestorePath   string
+	dedupOut           string
+	dedupMergeOut      string
+	dedupStrategy      string
+	purgeQuarantine    string
+	retention          report.RetentionPolicy
+	retentionMaxSizeMB int64
+	smtpConfig         sink.SMTPConfig
+	logLevelName       string
+	logFormat          string
+	logMaxSizeMB       int64
+	logMaxFiles        int
+	fuzzyField         string
+	fuzzyThreshold     float64
+	scope              string
+	leftPaths          string
+	rightPaths         string
+	discoverKeys       bool
+	sampleRows         int64
+	profile            bool
+	profileTopN        int
+	watch              bool
+	watchInterval      time.Duration
+	schedule           string
+	scheduleAddr       string
+	filesFrom          string
+	dryRun             bool
+	isServe            bool
+	serveAddr          string
+	serveGRPCAddr      string
+	serveMaxJobs       int
+	serveAllow         string
+}
+
+func newOptions(cfg *config.Config) *options {
+	return &options{
+		cfg:            cfg,
+		outputFormat:   "txt",
+		maxSampleBytes: 4096,
+		indexMode:      analyser.IndexMemory,
+		bloomItems:     10000000,
+		maxLineSize:    byteSizeFlag(source.DefaultMaxLineSize),
+		chunkSize:      byteSizeFlag(analyser.DefaultChunkSize),
+		progressFormat: "text",
+		notifyFormat:   sink.WebhookAuto,
+		purgeKeep:      purge.KeepFirst,
+		purgeBackupDir: purge.BackupDir,
+		dedupStrategy:  purge.KeepFirst,
+		logLevelName:   "info",
+		logFormat:      "text",
+		logMaxSizeMB:   10,
+		logMaxFiles:    5,
+		fuzzyThreshold: 0.9,
+		scope:          analyser.ScopeGlobal,
+		sampleRows:     100000,
+		profileTopN:    5,
+		watchInterval:  time.Minute,
+		serveAddr:      "127.0.0.1:8080",
+		serveMaxJobs:   1,
+	}
+}
+
+// commonFlags registers the logging and debugging flags every run takes.
+func (o *options) commonFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.configPath, "config", o.configPath, "YAML or TOML file of flag settings for a recurring job; flags given on the command line override it")
+	fs.StringVar(&o.cfg.LogPath, "log-path", o.cfg.LogPath, "Directory to save logs and reports")
+	fs.StringVar(&o.logLevelName, "log.level", o.logLevelName, "Minimum level written to analyser.log: debug, info, warn or error")
+	fs.StringVar(&o.logFormat, "log.format", o.logFormat, "Format of analyser.log: text, or json for Cloud Logging structured logs")
+	fs.Int64Var(&o.logMaxSizeMB, "log.max-size", o.logMaxSizeMB, "Size in MB at which analyser.log is rotated; 0 disables rotation")
+	fs.IntVar(&o.logMaxFiles, "log.max-files", o.logMaxFiles, "Number of rotated log files to keep")
+	fs.StringVar(&o.debugAddr, "debug.addr", o.debugAddr, "Serve pprof profiles and runtime metrics on this address, e.g. :6060")
+	fs.BoolVar(&o.quiet, "quiet", o.quiet, "Print nothing but errors and a one-line summary when duplicates or errors are found, for cron jobs (headless only)")
+}
+
+// sourceFlags registers the flags naming the data to read and how to read it.
+func (o *options) sourceFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.cfg.Path, "path", o.cfg.Path, "Comma-separated list of paths to analyse (local or GCS)")
+	fs.StringVar(&o.filesFrom, "files-from", o.filesFrom, "File listing the files to analyse, one local path or gs:// URI per line, or - for stdin, in place of discovering -path (headless only)")
+	fs.BoolVar(&o.dryRun, "dry-run", o.dryRun, "Discover the files and print what would be analysed, per folder, with the effective settings, then exit; with -purge.auto or -dedup.* analyse and print the plan, and with -purge.apply or -purge.restore print what they would change, without changing anything (headless only)")
+	fs.Var(&keyFlag{primary: &o.cfg.Key, extra: &o.additionalKeys}, "key", "JSON key for uniqueness check (repeat to check several keys in one pass)")
+	fs.Var((*workersFlag)(&o.cfg.Workers), "workers", "Number of concurrent workers, or auto to size the pool from the CPU count and read latency")
+	fs.DurationVar(&o.maxDuration, "max-duration", o.maxDuration, "Stop each run after this long, such as 2h, and report what was analysed as a partial report; 0 disables (headless only)")
+	fs.Var(&o.maxLineSize, "max-line-size", "Longest line to analyse, such as 16MiB; longer lines are skipped and reported per file")
+	fs.Var(&o.chunkSize, "chunk-size", "Split local files larger than twice this size into chunks scanned in parallel (0 disables)")
+	fs.BoolVar(&o.memoryMap, "mmap", o.memoryMap, "Memory-map local files instead of reading them through a buffer (headless only)")
+	fs.StringVar(&o.progressFormat, "progress", o.progressFormat, "Progress format on stderr: text, or json for one event object per line (headless only)")
+	fs.BoolVar(&o.trace, "trace", o.trace, "Export OpenTelemetry spans over OTLP/HTTP, configured by the standard OTEL_EXPORTER_OTLP_* variables (headless only)")
+}
+
+// analysisFlags registers the flags choosing the checks of a full analysis and
+// how its indexes are kept.
+func (o *options) analysisFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&o.cfg.CheckKey, "check.key", o.cfg.CheckKey, "Enable duplicate key check")
+	fs.BoolVar(&o.cfg.CheckRow, "check.row", o.cfg.CheckRow, "Enable duplicate row check (hashing)")
+	fs.StringVar(&o.scope, "scope", o.scope, "Duplicate comparison scope for headless mode (global, file or folder)")
+	fs.StringVar(&o.constraintsPath, "constraints", o.constraintsPath, "JSON file declaring additional (optionally composite) uniqueness constraints (headless only)")
+	fs.StringVar(&o.fuzzyField, "fuzzy.field", o.fuzzyField, "JSON field to cluster by similarity to surface probable duplicates (headless only)")
+	fs.Float64Var(&o.fuzzyThreshold, "fuzzy.threshold", o.fuzzyThreshold, "Jaro-Winkler similarity threshold (0-1) for -fuzzy.field")
+	fs.StringVar(&o.leftPaths, "left", o.leftPaths, "Comma-separated left-hand paths for a key comparison against -right (headless only)")
+	fs.StringVar(&o.rightPaths, "right", o.rightPaths, "Comma-separated right-hand paths for a key comparison against -left (headless only)")
+	fs.BoolVar(&o.discoverKeys, "discover-keys", o.discoverKeys, "Profile every top-level field and rank candidate primary keys, then exit (headless only)")
+	fs.Int64Var(&o.sampleRows, "discover.sample", o.sampleRows, "Number of rows to sample for -discover-keys (0 reads everything)")
+	fs.BoolVar(&o.profile, "profile", o.profile, "Profile distinct counts, null rates and top values per field during analysis (headless only)")
+	fs.IntVar(&o.profileTopN, "profile.top", o.profileTopN, "Number of most frequent values to report per field with -profile")
+	fs.StringVar(&o.indexMode, "index", o.indexMode, "Where to hold the duplicate index: memory, disk to spill to temporary files, or sort to merge-sort spilled runs (headless only)")
+	fs.StringVar(&o.indexDir, "index.dir", o.indexDir, "Directory for -index disk/sort temporary files (defaults to the system temp directory)")
+	fs.BoolVar(&o.bloomPrePass, "bloom", o.bloomPrePass, "Read the data twice, using a bloom filter pre-pass so only possibly repeated values are indexed (headless only)")
+	fs.Uint64Var(&o.bloomItems, "bloom.items", o.bloomItems, "Expected number of distinct values per index, used to size the -bloom filters")
+	fs.BoolVar(&o.rawRowHash, "row.raw", o.rawRowHash, "With -check.key=false, hash whitespace-normalised raw lines instead of decoding them (headless only)")
+	fs.Var(&o.maxMemory, "max-memory", "Memory budget such as 4GiB; indexes spill to disk, then reading stops, as it is approached (headless only)")
+	fs.DurationVar(&o.checkpointInterval, "checkpoint", o.checkpointInterval, "Save a resumable checkpoint to the log path at this interval, e.g. 5m (headless only)")
+	fs.StringVar(&o.resumePath, "resume", o.resumePath, "Resume a headless run from a checkpoint file, reusing its original settings")
+	fs.StringVar(&o.cachePath, "cache", o.cachePath, "Fingerprint cache file; unchanged files since the last run are reused instead of re-read (headless only)")
+	fs.BoolVar(&o.shardOutput, "shard", o.shardOutput, "Also list values seen only once, so the JSON report can be combined with other shards by the merge command (headless only)")
+}
+
+// outputFlags registers the flags choosing what is printed and saved by every
+// headless run.
+func (o *options) outputFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.outputFormat, "output", o.outputFormat, "Output format for headless mode (txt or json)")
+	fs.BoolVar(&o.cfg.EnableTxtOutput, "output.txt", o.cfg.EnableTxtOutput, "Enable .txt report output")
+	fs.BoolVar(&o.cfg.EnableJsonOutput, "output.json", o.cfg.EnableJsonOutput, "Enable .json report output")
+}
+
+// reportFlags registers the flags choosing the further report formats a full
+// analysis writes and what they list.
+func (o *options) reportFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&o.cfg.ShowFolderBreakdown, "show.folders", o.cfg.ShowFolderBreakdown, "Show per-folder breakdown table in summary report")
+	fs.BoolVar(&o.showFiles, "show.files", o.showFiles, "Add a per-file table of rows, keys, duplicates, parse errors and processing time to the report (headless only)")
+	fs.BoolVar(&o.enableCsvOutput, "output.csv", o.enableCsvOutput, "Enable .csv report output with one row per duplicate location (headless only)")
+	fs.BoolVar(&o.enableHtmlOutput, "output.html", o.enableHtmlOutput, "Enable a self-contained, filterable .html report (headless only)")
+	fs.BoolVar(&o.enableMdOutput, "output.md", o.enableMdOutput, "Enable .md report output with Markdown summary and folder tables for PR comments (headless only)")
+	fs.BoolVar(&o.enableSarifOutput, "output.sarif", o.enableSarifOutput, "Enable .sarif report output so code scanning tools can annotate duplicates (headless only)")
+	fs.BoolVar(&o.enableJUnitOutput, "output.junit", o.enableJUnitOutput, "Enable .junit.xml output with one test case per uniqueness check for CI test reporters (headless only)")
+	fs.BoolVar(&o.enableNdjsonOutput, "output.ndjson", o.enableNdjsonOutput, "Export every duplicate location as a line-delimited JSON record for warehouse loading (headless only)")
+	fs.BoolVar(&o.enableSqliteOutput, "output.sqlite", o.enableSqliteOutput, "Write the summary, folders and duplicate locations to an indexed SQLite database (headless only)")
+	fs.StringVar(&o.bigQueryTable, "report.bq", o.bigQueryTable, "Stream the summary and duplicate findings into BigQuery as project.dataset.table at the end of the run (headless only)")
+	fs.StringVar(&o.uploadPath, "report.upload", o.uploadPath, "Also copy the saved report files to a gs://bucket/prefix/ folder (headless only)")
+	fs.StringVar(&o.templatePath, "report.template", o.templatePath, "text/template file that renders the text report on stdout and in the .txt files (headless only)")
+	fs.IntVar(&o.maxSets, "report.max-sets", o.maxSets, "Maximum duplicate sets listed per section of the text and HTML reports; 0 lists all (headless only)")
+	fs.BoolVar(&o.compressReports, "report.compress", o.compressReports, "Gzip the details text, JSON, CSV and NDJSON report files, saving them with a .gz suffix (headless only)")
+	fs.StringVar(&o.maskKeys, "report.mask-keys", o.maskKeys, "Replace key values in every report with a salted hash or a partial mask: hash or partial; the hash salt is read from "+report.MaskSaltEnv+" (headless only)")
+	fs.IntVar(&o.maxLocationsPerSet, "report.max-locations-per-set", o.maxLocationsPerSet, "Maximum locations listed per duplicate set in the text and HTML reports; 0 lists all (headless only)")
+	fs.IntVar(&o.sampleRecords, "report.samples", o.sampleRecords, "Embed the JSON of the first N records of each duplicate set in the JSON report (headless only)")
+	fs.IntVar(&o.maxSampleBytes, "report.sample-bytes", o.maxSampleBytes, "Maximum size of an embedded sample record; larger records are replaced with a placeholder")
+	fs.IntVar(&o.retention.KeepLast, "retention.keep", o.retention.KeepLast, "Keep only the reports of this many most recent runs in -log-path; 0 keeps all (headless only)")
+	fs.DurationVar(&o.retention.MaxAge, "retention.max-age", o.retention.MaxAge, "Remove reports older than this, such as 720h; 0 keeps all (headless only)")
+	fs.Int64Var(&o.retentionMaxSizeMB, "retention.max-size", o.retentionMaxSizeMB, "Remove the oldest reports once those in -log-path exceed this many MB; 0 keeps all (headless only)")
+}
+
+// notifyFlags registers the flags that decide the exit code and who is told
+// about the result.
+func (o *options) notifyFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&o.failOnDuplicates, "fail-on-duplicates", o.failOnDuplicates, "Exit with code 3 if any duplicates are found (headless only)")
+	fs.StringVar(&o.failThreshold, "fail-threshold", o.failThreshold, "Exit with code 3 if any check has more repeated records than this count, or percentage of rows such as 0.5% (headless only)")
+	fs.StringVar(&o.notifyWebhook, "notify.webhook", o.notifyWebhook, "URL to post a summary to when the run finishes or fails (headless only)")
+	fs.StringVar(&o.notifyFormat, "notify.format", o.notifyFormat, "Webhook payload: json, slack, or auto to use slack for hooks.slack.com URLs (headless only)")
+	fs.StringVar(&o.notifyEmail, "notify.email", o.notifyEmail, "Comma-separated addresses mailed the summary and reports when the run finishes or fails; needs -smtp.addr and -smtp.from (headless only)")
+	fs.StringVar(&o.smtpConfig.Addr, "smtp.addr", o.smtpConfig.Addr, "SMTP server as host:port for -notify.email")
+	fs.StringVar(&o.smtpConfig.From, "smtp.from", o.smtpConfig.From, "Sender address for -notify.email")
+	fs.StringVar(&o.smtpConfig.Username, "smtp.user", o.smtpConfig.Username, "SMTP username; the password is read from "+smtpPasswordEnv)
+}
+
+// autoPurgeFlags registers the flags that purge, quarantine or write
+// deduplicated copies at the end of a full analysis.
+func (o *options) autoPurgeFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&o.cfg.PurgeIDs, "purge-ids", o.cfg.PurgeIDs, "Enable interactive purging of duplicate IDs")
+	fs.BoolVar(&o.cfg.PurgeRows, "purge-rows", o.cfg.PurgeRows, "Enable interactive purging of duplicate rows")
+	fs.StringVar(&o.purgeStrategy, "purge.auto", o.purgeStrategy, "Purge the sets selected by -purge-ids and -purge-rows without prompting, keeping one record by a keep strategy such as keep-first or keep-newest:updated_at (headless only)")
+	fs.StringVar(&o.purgePlanPath, "purge.plan", o.purgePlanPath, "With -purge.auto, write the records that would be deleted to this JSON plan file instead of purging (headless only)")
+	fs.StringVar(&o.purgeQuarantine, "purge.quarantine", o.purgeQuarantine, "With -purge.auto, move purged records to quarantine files mirroring the input tree under this directory or gs://bucket/prefix/ instead of backing them up (headless only)")
+	fs.StringVar(&o.dedupOut, "dedup.out", o.dedupOut, "Write a copy of every analysed file without its duplicates to this directory or gs://bucket/prefix/, mirroring the input tree and leaving the originals untouched (headless only)")
+	fs.StringVar(&o.dedupMergeOut, "dedup.merge-out", o.dedupMergeOut, "Write one copy of every unique record across all sources to this single NDJSON file or gs:// object (headless only)")
+	fs.StringVar(&o.dedupStrategy, "dedup.keep", o.dedupStrategy, "Keep strategy for -dedup.out and -dedup.merge-out, as for -purge.auto")
+	o.backupFlags(fs)
+}
+
+// backupFlags registers the flags choosing where purged records are backed up.
+func (o *options) backupFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.purgeBackupDir, "purge.backup-dir", o.purgeBackupDir, "Directory that each purge writes its timestamped backup folder and manifest to (headless only)")
+	fs.StringVar(&o.purgeGCSBackup, "purge.gcs-backup", o.purgeGCSBackup, "Back up records purged from GCS objects to this gs://bucket/prefix/ instead of the local backup directory (headless only)")
+}
+
+// daemonFlags registers the flags that keep a full analysis running.
+func (o *options) daemonFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&o.watch, "watch", o.watch, "Keep running, re-analysing whenever files under the paths are added or changed, and report new duplicates (headless only)")
+	fs.DurationVar(&o.watchInterval, "watch.interval", o.watchInterval, "How often -watch lists GCS paths for changes; local paths are watched for file events")
+	fs.StringVar(&o.schedule, "schedule", o.schedule, "Keep running, re-running the analysis on this cron expression, such as \"0 2 * * *\" or @daily (headless only)")
+	fs.StringVar(&o.scheduleAddr, "schedule.addr", o.scheduleAddr, "Serve /healthz and /metrics on this address while -schedule runs, e.g. :8080")
+}
+
+// serveFlags registers the flags of the API server.
+func (o *options) serveFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.serveAddr, "serve.addr", o.serveAddr, "Address to serve the REST API on, or empty to serve only gRPC. Addresses other hosts can reach need a token or -serve.allow")
+	fs.StringVar(&o.serveGRPCAddr, "serve.grpc-addr", o.serveGRPCAddr, "Address to serve the gRPC API on, e.g. 127.0.0.1:9090")
+	fs.IntVar(&o.serveMaxJobs, "serve.max-jobs", o.serveMaxJobs, "Number of jobs run at once; later jobs wait in a queue")
+	fs.StringVar(&o.serveAllow, "serve.allow", o.serveAllow, "Comma-separated local directories and gs:// prefixes that jobs may read (default any path)")
+}
+
+// legacyFlags registers every flag of the flat command line that predates the
+// commands, so that existing scripts keep working.
+func (o *options) legacyFlags(fs *flag.FlagSet) {
+	o.commonFlags(fs)
+	o.sourceFlags(fs)
+	o.analysisFlags(fs)
+	o.outputFlags(fs)
+	o.reportFlags(fs)
+	o.notifyFlags(fs)
+	o.autoPurgeFlags(fs)
+	o.daemonFlags(fs)
+	fs.StringVar(&o.purgeKeep, "purge.keep", o.purgeKeep, "Keep strategy whose choice is pre-selected in each set of the interactive purge")
+	fs.StringVar(&o.purgeApplyPath, "purge.apply", o.purgeApplyPath, "Apply a reviewed plan written by -purge.plan, then exit")
+	fs.StringVar(&o.purgeRestorePath, "purge.restore", o.purgeRestorePath, "Put back the records removed by a purge from the manifest it wrote, then exit")
+	fs.BoolVar(&o.isHeadless, "headless", o.isHeadless, "Run without TUI and print report to stdout")
+	fs.BoolVar(&o.isValidate, "validate", o.isValidate, "Run a key validation test and exit (headless only)")
+}
@@ -6,114 +6,477 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/benjaminwestern/dupe-analyser/internal/config"
 	"github.com/benjaminwestern/dupe-analyser/internal/headless"
+	"github.com/benjaminwestern/dupe-analyser/internal/logging"
+	"github.com/benjaminwestern/dupe-analyser/internal/purge"
+	"github.com/benjaminwestern/dupe-analyser/internal/sink"
 	"github.com/benjaminwestern/dupe-analyser/internal/tui"
+	"github.com/benjaminwestern/dupe-analyser/pkg/analyser"
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+	"github.com/robfig/cron/v3"
 )
 
+// smtpPasswordEnv is the environment variable holding the SMTP password, so
+// it never appears in the process list or shell history.
+const smtpPasswordEnv = "DUPE_ANALYSER_SMTP_PASSWORD"
+
+// keyFlag implements flag.Value for a repeatable -key flag. The first value
+// becomes the primary unique key; any further values are additional keys
+// checked in the same pass.
+type keyFlag struct {
+	primary *string
+	extra   *[]string
+	isSet   bool
+}
+
+func (k *keyFlag) String() string {
+	if k.primary == nil {
+		return ""
+	}
+	return *k.primary
+}
+
+func (k *keyFlag) Set(value string) error {
+	if !k.isSet {
+		*k.primary = value
+		k.isSet = true
+		return nil
+	}
+	*k.extra = append(*k.extra, value)
+	return nil
+}
+
+// workersFlag implements flag.Value for -workers, accepting either a count or
+// "auto" to let the analyser size the pool itself.
+type workersFlag int
+
+func (w *workersFlag) String() string {
+	if *w == analyser.AutoWorkers {
+		return "auto"
+	}
+	return strconv.Itoa(int(*w))
+}
+
+func (w *workersFlag) Set(value string) error {
+	if strings.EqualFold(strings.TrimSpace(value), "auto") {
+		*w = analyser.AutoWorkers
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return fmt.Errorf("invalid worker count %q: use a positive number or auto", value)
+	}
+	*w = workersFlag(n)
+	return nil
+}
+
+// strategyChoices lists the keep strategies in the errors for invalid ones.
+const strategyChoices = "Must be 'keep-first', 'keep-last', 'keep-largest-file', 'keep-newest-file', 'keep-newest:<field>', 'keep-oldest:<field>' or 'keep-fewest-nulls'."
+
+// byteSizeFlag implements flag.Value for sizes such as "512MB" or "4GiB".
+// A bare number is taken as bytes, and both SI (KB) and binary (KiB) suffixes
+// are treated as powers of 1024 to match the sizes shown in reports.
+type byteSizeFlag int64
+
+func (b *byteSizeFlag) String() string {
+	if *b == 0 {
+		return "0"
+	}
+	return strconv.FormatInt(int64(*b), 10)
+}
+
+func (b *byteSizeFlag) Set(value string) error {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	multipliers := []struct {
+		suffix string
+		factor int64
+	}{
+		{"TIB", 1 << 40}, {"GIB", 1 << 30}, {"MIB", 1 << 20}, {"KIB", 1 << 10},
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	}
+	factor := int64(1)
+	for _, m := range multipliers {
+		if strings.HasSuffix(s, m.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, m.suffix))
+			factor = m.factor
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid size %q", value)
+	}
+	*b = byteSizeFlag(n * float64(factor))
+	return nil
+}
+
 // main holds the logic for the application's main entry point.
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "analyse", "analyze":
+			runAnalyse(os.Args[2:])
+			return
+		case "validate":
+			runValidate(os.Args[2:])
+			return
+		case "purge":
+			runPurge(os.Args[2:])
+			return
+		case "report":
+			runReport(os.Args[2:])
+			return
+		case "bench":
+			runBench(os.Args[2:])
+			return
+		case "diff":
+			runDiff(os.Args[2:])
+			return
+		case "merge":
+			runMerge(os.Args[2:])
+			return
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		}
+	}
+
+	o := loadOptions()
+	o.legacyFlags(flag.CommandLine)
+	flag.Usage = usage
+	flag.Parse()
+	o.resolve(flag.CommandLine)
+	if !o.isHeadless && o.cfg.Path == "" && flag.NArg() > 0 {
+		o.cfg.Path = strings.Join(flag.Args(), ",")
+	}
+	o.run()
+}
+
+// loadOptions returns options starting from the saved configuration.
+func loadOptions() *options {
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("Error loading configuration: %v", err)
 	}
+	return newOptions(cfg)
+}
 
-	var isHeadless bool
-	var isValidate bool
-	var outputFormat string
-	var keyIsSet bool
-
-	flag.StringVar(&cfg.Path, "path", cfg.Path, "Comma-separated list of paths to analyse (local or GCS)")
-	flag.StringVar(&cfg.Key, "key", cfg.Key, "JSON key for uniqueness check")
-	flag.IntVar(&cfg.Workers, "workers", cfg.Workers, "Number of concurrent workers")
-	flag.StringVar(&cfg.LogPath, "log-path", cfg.LogPath, "Directory to save logs and reports")
-	flag.BoolVar(&cfg.CheckKey, "check.key", cfg.CheckKey, "Enable duplicate key check")
-	flag.BoolVar(&cfg.CheckRow, "check.row", cfg.CheckRow, "Enable duplicate row check (hashing)")
-	flag.BoolVar(&cfg.ShowFolderBreakdown, "show.folders", cfg.ShowFolderBreakdown, "Show per-folder breakdown table in summary report")
-	flag.BoolVar(&cfg.EnableTxtOutput, "output.txt", cfg.EnableTxtOutput, "Enable .txt report output")
-	flag.BoolVar(&cfg.EnableJsonOutput, "output.json", cfg.EnableJsonOutput, "Enable .json report output")
-	flag.BoolVar(&cfg.PurgeIDs, "purge-ids", cfg.PurgeIDs, "Enable interactive purging of duplicate IDs (local files only)")
-	flag.BoolVar(&cfg.PurgeRows, "purge-rows", cfg.PurgeRows, "Enable interactive purging of duplicate rows (local files only)")
-	flag.BoolVar(&isHeadless, "headless", false, "Run without TUI and print report to stdout")
-	flag.BoolVar(&isValidate, "validate", false, "Run a key validation test and exit (headless only)")
-	flag.StringVar(&outputFormat, "output", "txt", "Output format for headless mode (txt or json)")
-	flag.Parse()
+// usage prints the commands, then the flags of the command line without one,
+// which opens the TUI or runs the mode chosen by -headless and -validate.
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, `Usage: %[1]s <command> [flags] [paths...]
+       %[1]s [flags] [paths...]
+
+Commands:
+  analyse   Run a full duplicate analysis and save its reports
+  validate  Count the unique key across the files
+  purge     Apply a reviewed purge plan or restore a purge from its manifest
+  report    Re-render a saved JSON report in another format
+  diff      Compare two saved JSON reports
+  merge     Combine the JSON reports of sharded runs
+  serve     Run analysis jobs submitted over a REST API
+  bench     Measure throughput over generated data
+
+Run '%[1]s <command> -h' for the flags of a command. Without a command the
+TUI opens, or the -headless and -validate flags below choose a mode.
+
+`, os.Args[0])
+	flag.PrintDefaults()
+}
 
-	flag.Visit(func(f *flag.Flag) {
-		if f.Name == "key" {
-			keyIsSet = true
+// run carries out the parsed options: a purge apply or restore, a headless
+// run, or the TUI.
+func (o *options) run() {
+	var err error
+	var constraints []config.ConstraintConfig
+	if o.constraintsPath != "" {
+		constraints, err = config.LoadConstraints(o.constraintsPath)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
 		}
+	} else {
+		constraints = o.fileConstraints()
+	}
+
+	if o.purgeGCSBackup != "" {
+		if err := purge.ValidGCSFolder(o.purgeGCSBackup); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if err := os.MkdirAll(o.cfg.LogPath, 0755); err != nil {
+		log.Fatalf("failed to create log directory at %s: %v", o.cfg.LogPath, err)
+	}
+	logLevel, err := logging.ParseLevel(o.logLevelName)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if o.logFormat, err = logging.ParseFormat(o.logFormat); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	logFile, err := logging.Setup(logging.Options{
+		Dir:      o.cfg.LogPath,
+		Level:    logLevel,
+		Format:   o.logFormat,
+		MaxSize:  o.logMaxSizeMB << 20,
+		MaxFiles: o.logMaxFiles,
 	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer logFile.Close()
+	slog.Info("Starting dupe-analyser", "args", os.Args[1:])
+	for _, f := range o.files {
+		slog.Info("Read settings from job file", "path", f.Path)
+	}
 
-	isGCSPath := strings.Contains(cfg.Path, "gs://")
-	if isGCSPath && (cfg.PurgeIDs || cfg.PurgeRows) {
-		fmt.Println("Error: Purge functionality is only available for local files, not for GCS paths.")
+	if o.debugAddr != "" {
+		if err := startDebugServer(o.debugAddr); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	isCompare := o.leftPaths != "" || o.rightPaths != ""
+	if isCompare && (o.leftPaths == "" || o.rightPaths == "") {
+		fmt.Println("Error: -left and -right must be provided together.")
+		os.Exit(1)
+	}
+	o.retention.MaxSize = o.retentionMaxSizeMB << 20
+	var emailRecipients []string
+	if o.notifyEmail != "" {
+		if emailRecipients, err = sink.ParseEmailRecipients(o.notifyEmail); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if o.smtpConfig.Addr == "" || o.smtpConfig.From == "" {
+			fmt.Println("Error: -notify.email needs -smtp.addr and -smtp.from.")
+			os.Exit(1)
+		}
+		o.smtpConfig.Password = os.Getenv(smtpPasswordEnv)
+	}
+	if !sink.ValidWebhookFormat(o.notifyFormat) {
+		fmt.Printf("Error: invalid -notify.format %q. Must be 'auto', 'json' or 'slack'.\n", o.notifyFormat)
+		os.Exit(1)
+	}
+	if o.maskKeys != "" && !report.ValidMaskMode(o.maskKeys) {
+		fmt.Printf("Error: invalid -report.mask-keys %q. Must be 'hash' or 'partial'.\n", o.maskKeys)
+		os.Exit(1)
+	}
+	if o.progressFormat != "text" && o.progressFormat != "json" {
+		fmt.Printf("Error: invalid -progress %q. Must be 'text' or 'json'.\n", o.progressFormat)
 		os.Exit(1)
 	}
-	if !isHeadless && cfg.Path == "" && flag.NArg() > 0 {
-		cfg.Path = strings.Join(flag.Args(), ",")
+
+	if o.purgeRestorePath != "" {
+		if code := headless.RestorePurge(context.Background(), o.purgeRestorePath, o.cfg.LogPath, o.quiet, o.dryRun); code != headless.ExitClean {
+			os.Exit(code)
+		}
+		return
+	}
+	if o.purgeApplyPath != "" {
+		applyCfg := &headless.Config{Quiet: o.quiet, DryRun: o.dryRun, Key: o.cfg.Key, LogPath: o.cfg.LogPath, PurgeBackupDir: o.purgeBackupDir, PurgeGCSBackup: o.purgeGCSBackup}
+		if code := headless.ApplyPurgePlan(context.Background(), o.purgeApplyPath, applyCfg); code != headless.ExitClean {
+			os.Exit(code)
+		}
+		return
 	}
 
-	if err := os.MkdirAll(cfg.LogPath, 0755); err != nil {
-		log.Fatalf("failed to create log directory at %s: %v", cfg.LogPath, err)
+	if o.resumePath != "" {
+		if o.dryRun {
+			fmt.Println("Error: -dry-run cannot be used with -resume.")
+			os.Exit(1)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		if code := headless.Run(ctx, &headless.Config{ResumePath: o.resumePath, CheckpointInterval: o.checkpointInterval, MaxDuration: o.maxDuration, OutputFormat: o.outputFormat, Quiet: o.quiet, ProgressFormat: o.progressFormat, MaskSalt: os.Getenv(report.MaskSaltEnv), Trace: o.trace, NotifyWebhook: o.notifyWebhook, NotifyFormat: o.notifyFormat, NotifyEmail: emailRecipients, SMTP: o.smtpConfig}); code != headless.ExitClean {
+			os.Exit(code)
+		}
+		return
 	}
-	logFilePath := filepath.Join(cfg.LogPath, "analyser.log")
-	logFile, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
-	if err != nil {
-		log.Fatalf("failed to open log file at %s: %v", logFilePath, err)
+
+	if o.isServe {
+		if o.filesFrom != "" {
+			fmt.Println("Error: -files-from cannot be used with serve; each job request lists its paths.")
+			os.Exit(1)
+		}
+		if o.dryRun {
+			fmt.Println("Error: -dry-run cannot be used with serve.")
+			os.Exit(1)
+		}
+		o.checkAnalysisFlags()
+		o.serve(o.headlessConfig(constraints, emailRecipients, nil))
+		return
 	}
-	defer logFile.Close()
-	log.SetOutput(logFile)
 
-	if isHeadless || isValidate {
-		if cfg.Path == "" {
+	if o.isHeadless || o.isValidate || isCompare || o.discoverKeys {
+		if o.filesFrom != "" {
+			if o.cfg.Path != "" || isCompare {
+				fmt.Println("Error: -files-from cannot be used with -path, -left or -right; it lists every file to analyse.")
+				os.Exit(1)
+			}
+			if o.watch || o.purgeQuarantine != "" || o.dedupOut != "" {
+				fmt.Println("Error: -files-from cannot be used with -watch, -purge.quarantine or -dedup.out, which need the directories of -path.")
+				os.Exit(1)
+			}
+			if o.filesFrom == "-" && o.schedule != "" {
+				fmt.Println("Error: -schedule needs a -files-from file it can read again for each run, not stdin.")
+				os.Exit(1)
+			}
+		} else if o.cfg.Path == "" && !isCompare {
 			fmt.Println("Error: -path flag is required for headless/validation mode.")
 			os.Exit(1)
 		}
-		if cfg.Key == "" {
+		if o.cfg.Key == "" && !o.discoverKeys {
 			fmt.Println("Error: -key flag is required for validation mode.")
 			os.Exit(1)
 		}
-		if isHeadless && !isValidate && !cfg.CheckKey && !cfg.CheckRow {
+		if o.isHeadless && !o.isValidate && !isCompare && !o.discoverKeys && !o.cfg.CheckKey && !o.cfg.CheckRow {
 			fmt.Println("Error: At least one check (-check.key or -check.row) must be enabled for a full analysis.")
 			os.Exit(1)
 		}
-		if cfg.CheckKey && !keyIsSet {
+		o.checkAnalysisFlags()
+		if o.cfg.CheckKey && !o.keyIsSet && !o.discoverKeys && !o.quiet {
 			fmt.Println("Warning: -key flag not set, defaulting to 'id'.")
 		}
-
-		headlessCfg := &headless.Config{
-			Paths:               cfg.Path,
-			Key:                 cfg.Key,
-			Workers:             cfg.Workers,
-			LogPath:             cfg.LogPath,
-			OutputFormat:        outputFormat,
-			ValidateOnly:        isValidate,
-			CheckKey:            cfg.CheckKey,
-			CheckRow:            cfg.CheckRow,
-			ShowFolderBreakdown: cfg.ShowFolderBreakdown,
-			EnableTxtOutput:     cfg.EnableTxtOutput,
-			EnableJsonOutput:    cfg.EnableJsonOutput,
+		if o.purgeQuarantine != "" && o.purgeStrategy == "" {
+			fmt.Println("Error: -purge.quarantine needs -purge.auto to choose the record kept from each set.")
+			os.Exit(1)
+		}
+		if strings.HasPrefix(o.purgeQuarantine, "gs://") {
+			if err := purge.ValidGCSFolder(o.purgeQuarantine); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if o.purgePlanPath != "" && o.purgeStrategy == "" {
+			fmt.Println("Error: -purge.plan needs -purge.auto to choose the record kept from each set.")
+			os.Exit(1)
+		}
+		if o.dryRun && (isCompare || o.watch || o.schedule != "" || o.purgePlanPath != "") {
+			fmt.Println("Error: -dry-run cannot be used with -left, -right, -watch, -schedule or -purge.plan.")
+			os.Exit(1)
+		}
+		if o.purgeStrategy != "" {
+			if !purge.ValidStrategy(o.purgeStrategy) {
+				fmt.Printf("Error: invalid -purge.auto %q. %s\n", o.purgeStrategy, strategyChoices)
+				os.Exit(1)
+			}
+			if o.isValidate || isCompare || o.discoverKeys {
+				fmt.Println("Error: -purge.auto needs a full duplicate analysis.")
+				os.Exit(1)
+			}
+			if !(o.cfg.PurgeIDs && o.cfg.CheckKey) && !(o.cfg.PurgeRows && o.cfg.CheckRow) {
+				fmt.Println("Error: -purge.auto needs -purge-ids with -check.key, or -purge-rows with -check.row.")
+				os.Exit(1)
+			}
+		}
+		if o.dedupOut != "" || o.dedupMergeOut != "" {
+			if !purge.ValidStrategy(o.dedupStrategy) {
+				fmt.Printf("Error: invalid -dedup.keep %q. %s\n", o.dedupStrategy, strategyChoices)
+				os.Exit(1)
+			}
+			if o.isValidate || isCompare || o.discoverKeys {
+				fmt.Println("Error: -dedup.out and -dedup.merge-out need a full duplicate analysis.")
+				os.Exit(1)
+			}
+			if o.purgeStrategy != "" {
+				fmt.Println("Error: -dedup.out and -dedup.merge-out cannot be used with -purge.auto; they leave the originals untouched.")
+				os.Exit(1)
+			}
+			for _, out := range []string{o.dedupOut, o.dedupMergeOut} {
+				if strings.HasPrefix(out, "gs://") {
+					if err := purge.ValidGCSFolder(out); err != nil {
+						fmt.Printf("Error: %v\n", err)
+						os.Exit(1)
+					}
+				}
+			}
+		}
+		if o.watch {
+			if o.isValidate || isCompare || o.discoverKeys || o.purgeStrategy != "" || o.dedupOut != "" || o.dedupMergeOut != "" {
+				fmt.Println("Error: -watch needs a full duplicate analysis without -purge.auto, -dedup.out or -dedup.merge-out.")
+				os.Exit(1)
+			}
+			if o.watchInterval <= 0 {
+				fmt.Println("Error: -watch.interval must be positive.")
+				os.Exit(1)
+			}
+		}
+		var schedule cron.Schedule
+		if o.schedule != "" {
+			if o.watch {
+				fmt.Println("Error: -schedule and -watch cannot be used together.")
+				os.Exit(1)
+			}
+			if schedule, err = headless.ParseSchedule(o.schedule); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
 		}
+		var threshold *headless.FailThreshold
+		if o.failThreshold != "" {
+			if threshold, err = headless.ParseFailThreshold(o.failThreshold); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		} else if o.failOnDuplicates {
+			threshold = &headless.FailThreshold{}
+		}
+
+		headlessCfg := o.headlessConfig(constraints, emailRecipients, threshold)
 
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
-		headless.Run(ctx, headlessCfg)
+		if schedule != nil {
+			if code := headless.Schedule(ctx, headlessCfg, schedule, o.scheduleAddr); code != headless.ExitClean {
+				os.Exit(code)
+			}
+			return
+		}
+		if o.watch {
+			if code := headless.Watch(ctx, headlessCfg, o.watchInterval); code != headless.ExitClean {
+				os.Exit(code)
+			}
+			return
+		}
+		if code := headless.Run(ctx, headlessCfg); code != headless.ExitClean {
+			os.Exit(code)
+		}
 		return
 	}
 
-	if !cfg.CheckKey && !cfg.CheckRow {
+	if !o.cfg.CheckKey && !o.cfg.CheckRow {
 		fmt.Println("Error: At least one check (-check.key or -check.row) must be enabled.")
 		os.Exit(1)
 	}
 
-	currentConfig := cfg
+	if !purge.ValidStrategy(o.purgeKeep) {
+		fmt.Printf("Error: invalid -purge.keep %q. %s\n", o.purgeKeep, strategyChoices)
+		os.Exit(1)
+	}
+
+	keys, err := config.LoadKeys(config.KeysPath)
+	if err != nil {
+		log.Fatalf("Error loading key bindings: %v", err)
+	}
+	keys = o.fileKeys(keys)
+
+	currentConfig := o.cfg
 	for {
-		finalConfig, shouldRestart, startNew, err := tui.Run(currentConfig)
+		finalConfig, shouldRestart, startNew, err := tui.Run(currentConfig, tui.Options{KeepStrategy: o.purgeKeep, Keys: keys})
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error running application: %v\n", err)
 			os.Exit(1)
@@ -127,10 +490,111 @@ func main() {
 			if loadErr != nil {
 				log.Fatalf("Error reloading configuration for new job: %v", loadErr)
 			}
-			newCfg.LogPath = cfg.LogPath
+			newCfg.LogPath = o.cfg.LogPath
 			currentConfig = newCfg
 		} else {
 			currentConfig = finalConfig
 		}
 	}
 }
+
+// checkAnalysisFlags exits with an error when a setting of the analysis
+// engine is out of range.
+func (o *options) checkAnalysisFlags() {
+	if o.maxDuration < 0 {
+		fmt.Println("Error: -max-duration must not be negative.")
+		os.Exit(1)
+	}
+	if o.fuzzyThreshold <= 0 || o.fuzzyThreshold > 1 {
+		fmt.Println("Error: -fuzzy.threshold must be greater than 0 and at most 1.")
+		os.Exit(1)
+	}
+	if !analyser.ValidScope(o.scope) {
+		fmt.Printf("Error: invalid -scope %q. Must be 'global', 'file' or 'folder'.\n", o.scope)
+		os.Exit(1)
+	}
+	if !analyser.ValidIndexMode(o.indexMode) {
+		fmt.Printf("Error: invalid -index %q. Must be 'memory', 'disk' or 'sort'.\n", o.indexMode)
+		os.Exit(1)
+	}
+}
+
+// headlessConfig returns the settings of a headless run made by the options.
+func (o *options) headlessConfig(constraints []config.ConstraintConfig, emailRecipients []string, threshold *headless.FailThreshold) *headless.Config {
+	return &headless.Config{
+		Paths:               o.cfg.Path,
+		FilesFrom:           o.filesFrom,
+		DryRun:              o.dryRun,
+		Key:                 o.cfg.Key,
+		AdditionalKeys:      o.additionalKeys,
+		Constraints:         constraints,
+		Workers:             o.cfg.Workers,
+		LogPath:             o.cfg.LogPath,
+		OutputFormat:        o.outputFormat,
+		ValidateOnly:        o.isValidate,
+		CheckKey:            o.cfg.CheckKey,
+		CheckRow:            o.cfg.CheckRow,
+		ShowFolderBreakdown: o.cfg.ShowFolderBreakdown,
+		ShowFileBreakdown:   o.showFiles,
+		EnableTxtOutput:     o.cfg.EnableTxtOutput,
+		EnableJsonOutput:    o.cfg.EnableJsonOutput,
+		EnableCsvOutput:     o.enableCsvOutput,
+		EnableHtmlOutput:    o.enableHtmlOutput,
+		EnableMdOutput:      o.enableMdOutput,
+		EnableSarifOutput:   o.enableSarifOutput,
+		EnableJUnitOutput:   o.enableJUnitOutput,
+		EnableNdjsonOutput:  o.enableNdjsonOutput,
+		EnableSqliteOutput:  o.enableSqliteOutput,
+		BigQueryTable:       o.bigQueryTable,
+		UploadPath:          o.uploadPath,
+		TemplatePath:        o.templatePath,
+		MaxSets:             o.maxSets,
+		MaxLocationsPerSet:  o.maxLocationsPerSet,
+		FailThreshold:       threshold,
+		Quiet:               o.quiet,
+		ProgressFormat:      o.progressFormat,
+		Trace:               o.trace,
+		NotifyWebhook:       o.notifyWebhook,
+		NotifyFormat:        o.notifyFormat,
+		NotifyEmail:         emailRecipients,
+		SMTP:                o.smtpConfig,
+		Retention:           o.retention,
+		CompressReports:     o.compressReports,
+		PurgeStrategy:       o.purgeStrategy,
+		PurgePlanPath:       o.purgePlanPath,
+		PurgeBackupDir:      o.purgeBackupDir,
+		PurgeQuarantine:     o.purgeQuarantine,
+		DedupOut:            o.dedupOut,
+		DedupStrategy:       o.dedupStrategy,
+		DedupMergeOut:       o.dedupMergeOut,
+		PurgeGCSBackup:      o.purgeGCSBackup,
+		PurgeIDs:            o.cfg.PurgeIDs && o.cfg.CheckKey,
+		PurgeRows:           o.cfg.PurgeRows && o.cfg.CheckRow,
+		MaskKeys:            o.maskKeys,
+		MaskSalt:            os.Getenv(report.MaskSaltEnv),
+		FuzzyField:          o.fuzzyField,
+		FuzzyThreshold:      o.fuzzyThreshold,
+		Scope:               o.scope,
+		LeftPaths:           o.leftPaths,
+		RightPaths:          o.rightPaths,
+		DiscoverKeys:        o.discoverKeys,
+		SampleRows:          o.sampleRows,
+		Profile:             o.profile,
+		ProfileTopN:         o.profileTopN,
+		SampleRecords:       o.sampleRecords,
+		MaxSampleBytes:      o.maxSampleBytes,
+		IndexMode:           o.indexMode,
+		IndexDir:            o.indexDir,
+		BloomPrePass:        o.bloomPrePass,
+		BloomExpectedItems:  o.bloomItems,
+		RawRowHash:          o.rawRowHash,
+		MaxMemory:           int64(o.maxMemory),
+		MaxLineSize:         int(o.maxLineSize),
+		ChunkSize:           int64(o.chunkSize),
+		MemoryMap:           o.memoryMap,
+		CheckpointInterval:  o.checkpointInterval,
+		MaxDuration:         o.maxDuration,
+		CachePath:           o.cachePath,
+		ShardOutput:         o.shardOutput,
+	}
+}
@@ -0,0 +1,78 @@
+// cmd/dupe-analyser/merge.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+)
+
+// runMerge implements the merge command, which combines the JSON reports of
+// several sharded runs into one report.
+func runMerge(args []string) {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	out := fs.String("out", "", "File to write the merged JSON report to")
+	output := fs.String("output", "txt", "Output format for the merged report on stdout: 'txt' or 'json'")
+	checkKey := fs.Bool("check.key", true, "Include duplicate keys in the text output")
+	checkRow := fs.Bool("check.row", true, "Include duplicate rows in the text output")
+	showFolders := fs.Bool("show.folders", false, "Show per-folder breakdown table in the text output")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s merge [flags] <report.json> <report.json>...\n\nCombines reports saved with -output.json by parallel runs over parts of a dataset into one report, joining duplicate sets that span shards.\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	reports := make([]*report.AnalysisReport, fs.NArg())
+	for i, path := range fs.Args() {
+		rep, err := report.LoadJSON(path)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		reports[i] = rep
+	}
+	merged, warnings, err := report.Merge(reports)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s.\n", w)
+	}
+
+	if *out != "" {
+		if err := writeMergedReport(*out, merged); err != nil {
+			fmt.Printf("Error writing %s: %v\n", *out, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Merged %d report(s) into %s\n", len(reports), *out)
+	}
+	if *output == "json" {
+		err = merged.WriteJSON(os.Stdout)
+	} else {
+		err = merged.WriteText(os.Stdout, true, *checkKey, *checkRow, *showFolders)
+	}
+	fmt.Println()
+	if err != nil {
+		fmt.Printf("Error writing report: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func writeMergedReport(path string, rep *report.AnalysisReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := rep.WriteJSON(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
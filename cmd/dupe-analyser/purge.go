@@ -0,0 +1,46 @@
+// cmd/dupe-analyser/purge.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runPurge implements the purge command, whose apply subcommand applies a plan
+// written by -purge.plan and whose restore subcommand puts back the records a
+// purge removed.
+func runPurge(args []string) {
+	o := loadOptions()
+	fs := flag.NewFlagSet("purge", flag.ExitOnError)
+	o.commonFlags(fs)
+	o.backupFlags(fs)
+	fs.StringVar(&o.cfg.Key, "key", o.cfg.Key, "JSON key recorded in the manifest of an applied plan")
+	fs.BoolVar(&o.dryRun, "dry-run", o.dryRun, "Print the records the plan would remove or the restore would put back, then exit without changing anything")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %[1]s purge apply [flags] <plan.json>\n       %[1]s purge restore [flags] <manifest.json>\n\nApplies a purge plan reviewed after 'analyse -purge.auto -purge.plan', refusing it if a file has changed since, or restores the records removed by a purge from the manifest it wrote.\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if len(args) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	action := args[0]
+	fs.Parse(args[1:])
+	o.resolve(fs)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	switch action {
+	case "apply":
+		o.purgeApplyPath = fs.Arg(0)
+	case "restore":
+		o.purgeRestorePath = fs.Arg(0)
+	default:
+		fmt.Fprintf(fs.Output(), "Unknown purge subcommand %q.\n\n", action)
+		fs.Usage()
+		os.Exit(2)
+	}
+	o.run()
+}
@@ -0,0 +1,69 @@
+// cmd/dupe-analyser/report.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/template"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+)
+
+// runReport implements the report command, which renders a JSON report saved
+// by an earlier run in another format.
+func runReport(args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	format := fs.String("format", "txt", "Format to render: txt, json, csv, html, md, sarif, junit, ndjson or sql")
+	out := fs.String("out", "", "File to write the report to instead of stdout")
+	templatePath := fs.String("template", "", "text/template file to render the text report with (txt only)")
+	showFolders := fs.Bool("show.folders", false, "Show per-folder breakdown table in the txt and md formats")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s report [flags] <report.json>\n\nRenders a report saved with -output.json in another format, so the analysis does not have to be run again.\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if !report.ValidFormat(*format) {
+		fmt.Printf("Error: invalid -format %q. Must be 'txt', 'json', 'csv', 'html', 'md', 'sarif', 'junit', 'ndjson' or 'sql'.\n", *format)
+		os.Exit(1)
+	}
+
+	rep, err := report.LoadJSON(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	checkKey, checkRow := rep.ChecksRun()
+	var tmpl *template.Template
+	if *templatePath != "" && *format == "txt" {
+		if tmpl, err = report.LoadTemplate(*templatePath); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	var f *os.File
+	if *out != "" {
+		if f, err = os.Create(*out); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		w = f
+	}
+	err = rep.Render(w, *format, tmpl, checkKey, checkRow, *showFolders)
+	if f != nil {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Printf("Error writing report: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,77 @@
+// cmd/dupe-analyser/serve.go
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/benjaminwestern/dupe-analyser/internal/headless"
+	"github.com/benjaminwestern/dupe-analyser/internal/server"
+	"github.com/benjaminwestern/dupe-analyser/internal/telemetry"
+)
+
+// runServe implements the serve command, which runs analysis jobs submitted
+// over a REST API. The analysis flags set the defaults of every job.
+func runServe(args []string) {
+	o := loadOptions()
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	o.commonFlags(fs)
+	o.sourceFlags(fs)
+	o.analysisFlags(fs)
+	o.outputFlags(fs)
+	o.reportFlags(fs)
+	o.serveFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s serve [flags]\n\nServes a REST API, and a gRPC API with -serve.grpc-addr, to submit analysis jobs, follow their progress, fetch their reports and cancel them. The other flags set the defaults of every job. Requests must carry the bearer token in %s when it is set.\n\n", os.Args[0], server.TokenEnv)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	o.resolve(fs)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	o.isServe = true
+	o.run()
+}
+
+// serve runs the API server with base as the settings of every job until
+// the process is interrupted.
+func (o *options) serve(base *headless.Config) {
+	var allow []string
+	for _, p := range strings.Split(o.serveAllow, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			allow = append(allow, p)
+		}
+	}
+	srv, err := server.New(*base, server.Options{MaxJobs: o.serveMaxJobs, Allow: allow, Token: os.Getenv(server.TokenEnv)})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if o.trace {
+		shutdown, err := telemetry.StartTracing(ctx)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer func() {
+			flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			_ = shutdown(flushCtx)
+		}()
+	}
+	if err := srv.Serve(ctx, o.serveAddr, o.serveGRPCAddr); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
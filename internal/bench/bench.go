@@ -0,0 +1,156 @@
+// internal/bench/bench.go
+package bench
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/analyser"
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+	"github.com/benjaminwestern/dupe-analyser/pkg/source"
+)
+
+// Config holds the settings for a benchmark run.
+type Config struct {
+	Rows          int
+	Files         int
+	DuplicateRate float64
+	Workers       []int
+	Dir           string
+	Keep          bool
+	Seed          uint64
+}
+
+// Result is the measurement for a single worker count.
+type Result struct {
+	Workers    int
+	Elapsed    time.Duration
+	RowsPerSec float64
+	MBPerSec   float64
+	Duplicates int
+}
+
+// Run generates a synthetic NDJSON dataset, analyses it once per worker count
+// with both the key and row checks enabled, and writes a table of throughput
+// to w. Each run is also checked against the number of duplicate keys the
+// generator planted, so a correctness regression fails the benchmark rather
+// than just looking fast.
+func Run(ctx context.Context, cfg Config, w io.Writer) ([]Result, error) {
+	dir := cfg.Dir
+	if dir == "" {
+		var err error
+		if dir, err = os.MkdirTemp("", "dupe-analyser-bench-"); err != nil {
+			return nil, fmt.Errorf("could not create benchmark directory: %w", err)
+		}
+		if !cfg.Keep {
+			defer os.RemoveAll(dir)
+		}
+	}
+
+	fmt.Fprintf(w, "Generating %d rows across %d files in %s (%.1f%% duplicates)...\n", cfg.Rows, cfg.Files, dir, cfg.DuplicateRate*100)
+	expected, err := Generate(dir, cfg.Rows, cfg.Files, cfg.DuplicateRate, cfg.Seed)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := source.Discover(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	totalBytes := source.TotalSize(sources)
+	fmt.Fprintf(w, "Dataset: %s, %d duplicated ids\n\n", report.HumanSize(totalBytes), expected)
+
+	var results []Result
+	for _, workers := range cfg.Workers {
+		eng := analyser.New("id", workers, true, true, false)
+		start := time.Now()
+		rep := eng.Run(ctx, sources)
+		elapsed := time.Since(start)
+		eng.Close()
+		if ctx.Err() != nil {
+			return results, ctx.Err()
+		}
+		if got := rep.Summary.UniqueKeysDuplicated; got != expected {
+			return results, fmt.Errorf("with %d workers found %d duplicated ids, expected %d", workers, got, expected)
+		}
+		results = append(results, Result{
+			Workers:    workers,
+			Elapsed:    elapsed,
+			RowsPerSec: float64(rep.Summary.TotalRowsProcessed) / elapsed.Seconds(),
+			MBPerSec:   float64(totalBytes) / (1024 * 1024) / elapsed.Seconds(),
+			Duplicates: rep.Summary.UniqueKeysDuplicated,
+		})
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Workers\tElapsed\tRows/sec\tMB/sec\t")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%d\t%s\t%.0f\t%.1f\t\n", r.Workers, r.Elapsed.Round(time.Millisecond), r.RowsPerSec, r.MBPerSec)
+	}
+	return results, tw.Flush()
+}
+
+// Generate writes rows of synthetic NDJSON spread evenly over files in dir and
+// returns the number of distinct ids that occur more than once. Each row is a
+// fresh id unless, with probability duplicateRate, it repeats an earlier one.
+// The same seed always produces the same dataset.
+func Generate(dir string, rows, files int, duplicateRate float64, seed uint64) (int, error) {
+	if files < 1 {
+		files = 1
+	}
+	rng := rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15))
+	counts := make([]int, 0, rows)
+	for f := 0; f < files; f++ {
+		path := filepath.Join(dir, fmt.Sprintf("bench-%03d.ndjson", f))
+		if err := writeFile(path, rows/files+boolToInt(f < rows%files), func(bw *bufio.Writer) {
+			id := len(counts)
+			if len(counts) > 0 && rng.Float64() < duplicateRate {
+				id = rng.IntN(len(counts))
+				counts[id]++
+			} else {
+				counts = append(counts, 1)
+			}
+			fmt.Fprintf(bw, `{"id":"id-%d","name":"user %d","value":%d,"active":%t,"tags":["a","b"]}`+"\n",
+				id, rng.IntN(100000), rng.IntN(1000000), rng.IntN(2) == 0)
+		}); err != nil {
+			return 0, err
+		}
+	}
+
+	duplicated := 0
+	for _, c := range counts {
+		if c > 1 {
+			duplicated++
+		}
+	}
+	return duplicated, nil
+}
+
+func writeFile(path string, rows int, writeRow func(*bufio.Writer)) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create %s: %w", path, err)
+	}
+	bw := bufio.NewWriter(f)
+	for i := 0; i < rows; i++ {
+		writeRow(bw)
+	}
+	if err := bw.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("could not write %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,58 @@
+// internal/config/constraints.go
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ConstraintConfig declares a uniqueness constraint over one or more fields,
+// mirroring a unique index on the destination table.
+type ConstraintConfig struct {
+	Name   string   `json:"name"`
+	Fields []string `json:"fields"`
+}
+
+// constraintsFile is the on-disk layout of a constraints file.
+type constraintsFile struct {
+	Constraints []ConstraintConfig `json:"constraints"`
+}
+
+// LoadConstraints reads a JSON file of uniqueness constraints. Constraints
+// without a name are named after their fields joined with '+'.
+func LoadConstraints(path string) ([]ConstraintConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read constraints file %s: %w", path, err)
+	}
+	var file constraintsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("could not parse constraints file %s: %w", path, err)
+	}
+	if err := CheckConstraints(file.Constraints, path); err != nil {
+		return nil, err
+	}
+	return file.Constraints, nil
+}
+
+// CheckConstraints names the constraints without a name and rejects those
+// without fields or with a name already used. path names where they came
+// from in its errors.
+func CheckConstraints(constraints []ConstraintConfig, path string) error {
+	seen := make(map[string]bool)
+	for i, c := range constraints {
+		if len(c.Fields) == 0 {
+			return fmt.Errorf("constraint %d in %s has no fields", i+1, path)
+		}
+		if c.Name == "" {
+			constraints[i].Name = strings.Join(c.Fields, "+")
+		}
+		if seen[constraints[i].Name] {
+			return fmt.Errorf("duplicate constraint name %q in %s", constraints[i].Name, path)
+		}
+		seen[constraints[i].Name] = true
+	}
+	return nil
+}
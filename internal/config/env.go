@@ -0,0 +1,14 @@
+// internal/config/env.go
+package config
+
+import "strings"
+
+// EnvPrefix starts the name of every environment variable read as a setting.
+const EnvPrefix = "DUPE_ANALYSER_"
+
+// EnvName returns the environment variable for a flag: its name in upper case
+// with dots and dashes replaced by underscores, after EnvPrefix. -check.row is
+// read from DUPE_ANALYSER_CHECK_ROW and -log-path from DUPE_ANALYSER_LOG_PATH.
+func EnvName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(flagName))
+}
@@ -0,0 +1,200 @@
+// internal/config/file.go
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// File is a job definition read with -config. Its settings are keyed by flag
+// name without the leading dash, and nested tables are joined with dots, so
+// that
+//
+//	check:
+//	  row: false
+//
+// sets -check.row. Lists are passed to repeatable flags such as -key one item
+// at a time and joined with commas for the rest, such as -path. The
+// constraints and keys tables declare uniqueness constraints and TUI key
+// bindings in place of their own files.
+type File struct {
+	Path        string
+	Settings    map[string][]string
+	Constraints []ConstraintConfig
+	Keys        map[string][]string
+}
+
+// LoadFile reads a YAML (.yaml or .yml) or TOML (.toml) job file.
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read config file %s: %w", path, err)
+	}
+	raw := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("could not parse config file %s: %w", path, err)
+		}
+	case ".toml":
+		if err := toml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("could not parse config file %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("config file %s must end in .yaml, .yml or .toml", path)
+	}
+
+	f := &File{Path: path, Settings: make(map[string][]string)}
+	if c, ok := raw["constraints"].([]any); ok {
+		delete(raw, "constraints")
+		if f.Constraints, err = parseConstraints(c, path); err != nil {
+			return nil, err
+		}
+	}
+	if k, ok := raw["keys"]; ok {
+		delete(raw, "keys")
+		if f.Keys, err = parseKeys(k, path); err != nil {
+			return nil, err
+		}
+	}
+	if err := flatten(f.Settings, "", raw, path); err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(path)
+	for _, name := range sourceSettings {
+		for i, value := range f.Settings[name] {
+			paths := strings.Split(value, ",")
+			for j, p := range paths {
+				p = strings.TrimSpace(p)
+				if p != "" && p != "-" && !strings.HasPrefix(p, "gs://") && !filepath.IsAbs(p) {
+					paths[j] = filepath.Join(dir, p)
+				}
+			}
+			f.Settings[name][i] = strings.Join(paths, ",")
+		}
+	}
+	return f, nil
+}
+
+// sourceSettings are the settings whose relative local paths are taken from
+// the directory of the job file, so that a project file works from any
+// directory beneath it.
+var sourceSettings = []string{"path", "files-from", "left", "right"}
+
+// Names returns the names of the settings in the file in sorted order.
+func (f *File) Names() []string {
+	names := make([]string, 0, len(f.Settings))
+	for name := range f.Settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// flatten adds the settings in table to settings, prefixing nested names with
+// the names of the tables holding them.
+func flatten(settings map[string][]string, prefix string, table map[string]any, path string) error {
+	for name, value := range table {
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		switch v := value.(type) {
+		case map[string]any:
+			if err := flatten(settings, name, v, path); err != nil {
+				return err
+			}
+		case []any:
+			values := make([]string, len(v))
+			for i, item := range v {
+				s, err := scalar(item)
+				if err != nil {
+					return fmt.Errorf("setting %s in %s: %w", name, path, err)
+				}
+				values[i] = s
+			}
+			settings[name] = values
+		default:
+			s, err := scalar(v)
+			if err != nil {
+				return fmt.Errorf("setting %s in %s: %w", name, path, err)
+			}
+			settings[name] = []string{s}
+		}
+	}
+	return nil
+}
+
+// scalar renders a single value as a flag would be given it.
+func scalar(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", value)
+	}
+}
+
+// parseConstraints reads the constraints table of a job file, which has the
+// layout of a constraints file.
+func parseConstraints(items []any, path string) ([]ConstraintConfig, error) {
+	constraints := make([]ConstraintConfig, len(items))
+	for i, item := range items {
+		table, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("constraint %d in %s is not a table", i+1, path)
+		}
+		if name, ok := table["name"]; ok {
+			s, err := scalar(name)
+			if err != nil {
+				return nil, fmt.Errorf("constraint %d in %s: %w", i+1, path, err)
+			}
+			constraints[i].Name = s
+		}
+		fields, _ := table["fields"].([]any)
+		for _, field := range fields {
+			s, err := scalar(field)
+			if err != nil {
+				return nil, fmt.Errorf("constraint %d in %s: %w", i+1, path, err)
+			}
+			constraints[i].Fields = append(constraints[i].Fields, s)
+		}
+	}
+	if err := CheckConstraints(constraints, path); err != nil {
+		return nil, err
+	}
+	return constraints, nil
+}
+
+// parseKeys reads the keys table of a job file, which maps TUI actions to a
+// key or a list of keys.
+func parseKeys(value any, path string) (map[string][]string, error) {
+	table, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("keys in %s is not a table", path)
+	}
+	keys := make(map[string][]string, len(table))
+	for action, v := range table {
+		list, ok := v.([]any)
+		if !ok {
+			list = []any{v}
+		}
+		for _, item := range list {
+			s, err := scalar(item)
+			if err != nil {
+				return nil, fmt.Errorf("keys for %s in %s: %w", action, path, err)
+			}
+			keys[action] = append(keys[action], s)
+		}
+	}
+	return keys, nil
+}
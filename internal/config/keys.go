@@ -0,0 +1,30 @@
+// internal/config/keys.go
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// KeysPath is the file read for TUI key bindings, next to the config file.
+const KeysPath = "config/keys.json"
+
+// LoadKeys reads a JSON object mapping TUI actions to the keys that trigger
+// them, such as {"quit": ["ctrl+q"]}. A missing file is not an error: the
+// result is nil and the TUI keeps its default keys.
+func LoadKeys(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read keys file %s: %w", path, err)
+	}
+	var keys map[string][]string
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("could not parse keys file %s: %w", path, err)
+	}
+	return keys, nil
+}
@@ -0,0 +1,36 @@
+// internal/config/project.go
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ProjectFileName is the job file looked for in the working directory and its
+// parents, so that a dataset repository can set its own key and paths.
+const ProjectFileName = ".dupe-analyser.yaml"
+
+// FindProjectFile returns the path of the ProjectFileName nearest the working
+// directory, or "" if neither it nor any parent has one.
+func FindProjectFile() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, ProjectFileName)
+		info, err := os.Stat(path)
+		if err == nil && !info.IsDir() {
+			return path, nil
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,165 @@
+// internal/headless/dryrun.go
+package headless
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/benjaminwestern/dupe-analyser/internal/purge"
+	"github.com/benjaminwestern/dupe-analyser/pkg/analyser"
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+	"github.com/benjaminwestern/dupe-analyser/pkg/source"
+)
+
+// folderScope is the files and bytes a dry run found in one folder.
+type folderScope struct {
+	files int
+	bytes int64
+}
+
+// printDryRun writes what a run of cfg would analyse: the discovered files
+// and their size, per folder, and the settings the analysis would use.
+// paths describes where the files were found.
+func printDryRun(w io.Writer, cfg *Config, paths string, sources []source.InputSource) error {
+	folders := make(map[string]*folderScope)
+	for _, src := range sources {
+		f, ok := folders[src.Dir()]
+		if !ok {
+			f = &folderScope{}
+			folders[src.Dir()] = f
+		}
+		f.files++
+		f.bytes += src.Size()
+	}
+
+	fmt.Fprintf(w, "\nDry run: nothing was analysed. %d file(s) holding %s would be analysed from %s.\n\n",
+		len(sources), report.HumanSize(source.TotalSize(sources)), paths)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Folder\tFiles\tSize")
+	for _, dir := range slices.Sorted(maps.Keys(folders)) {
+		f := folders[dir]
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", dir, f.files, report.HumanSize(f.bytes))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "\nSettings:")
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, s := range cfg.effectiveSettings() {
+		fmt.Fprintf(tw, "  %s\t%s\n", s[0], s[1])
+	}
+	return tw.Flush()
+}
+
+// effectiveSettings returns the name and value of each setting that shapes
+// the analysis of cfg, for a dry run to show.
+func (cfg *Config) effectiveSettings() [][2]string {
+	mode := "full analysis"
+	if cfg.DiscoverKeys {
+		mode = "candidate key discovery"
+	} else if cfg.ValidateOnly {
+		mode = "key validation"
+	}
+	settings := [][2]string{{"Mode", mode}}
+	if !cfg.DiscoverKeys {
+		keys := append([]string{cfg.Key}, cfg.AdditionalKeys...)
+		settings = append(settings, [2]string{"Keys", strings.Join(keys, ", ")})
+		for _, c := range cfg.Constraints {
+			settings = append(settings, [2]string{"Constraint", c.Name + " (" + strings.Join(c.Fields, ", ") + ")"})
+		}
+	}
+	if !cfg.DiscoverKeys && !cfg.ValidateOnly {
+		var checks []string
+		if cfg.CheckKey {
+			checks = append(checks, "key")
+		}
+		if cfg.CheckRow {
+			checks = append(checks, "row")
+		}
+		scope := cfg.Scope
+		if scope == "" {
+			scope = analyser.ScopeGlobal
+		}
+		settings = append(settings, [2]string{"Checks", strings.Join(checks, ", ")}, [2]string{"Scope", scope})
+		if cfg.FuzzyField != "" {
+			settings = append(settings, [2]string{"Fuzzy field", fmt.Sprintf("%s at %.2f", cfg.FuzzyField, cfg.FuzzyThreshold)})
+		}
+	}
+	workers := "auto"
+	if cfg.Workers > analyser.AutoWorkers {
+		workers = fmt.Sprint(cfg.Workers)
+	}
+	settings = append(settings, [2]string{"Workers", workers})
+	index := cfg.IndexMode
+	if index == "" {
+		index = analyser.IndexMemory
+	}
+	if cfg.BloomPrePass {
+		index += " with a bloom pre-pass"
+	}
+	settings = append(settings, [2]string{"Index", index})
+	if cfg.MaxMemory > 0 {
+		settings = append(settings, [2]string{"Max memory", report.HumanSize(cfg.MaxMemory)})
+	}
+	if cfg.MaxDuration > 0 {
+		settings = append(settings, [2]string{"Max duration", cfg.MaxDuration.String()})
+	}
+	reports := "none"
+	if exts := cfg.saveOptions(nil).Extensions(); len(exts) > 0 {
+		reports = strings.Join(exts, ", ") + " in " + cfg.LogPath
+	}
+	settings = append(settings, [2]string{"Reports", reports})
+	if cfg.UploadPath != "" {
+		settings = append(settings, [2]string{"Upload", cfg.UploadPath})
+	}
+	if cfg.BigQueryTable != "" {
+		settings = append(settings, [2]string{"BigQuery", cfg.BigQueryTable})
+	}
+	return settings
+}
+
+// printPlan writes the records plan would remove from each file, for a dry
+// run of a purge or deduplicated output. what names the change, such as
+// "purge".
+func printPlan(w io.Writer, what string, plan purge.Plan) error {
+	if len(plan) == 0 {
+		fmt.Fprintf(w, "Dry run: no duplicates, so the %s would change nothing.\n", what)
+		return nil
+	}
+	fmt.Fprintf(w, "Dry run: the %s would leave out %d record(s) from %d file(s). Nothing was changed.\n\n", what, plan.Records(), len(plan))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "File\tRecords")
+	for _, file := range slices.Sorted(maps.Keys(plan)) {
+		fmt.Fprintf(tw, "%s\t%d\n", file, len(plan[file]))
+	}
+	return tw.Flush()
+}
+
+// printRestore writes the records a restore from manifest would put back in
+// each file, for a dry run of -purge.restore.
+func printRestore(w io.Writer, manifest *purge.Manifest) error {
+	files := make(map[string]int)
+	records := 0
+	for _, rec := range manifest.Records {
+		if !rec.Restored {
+			files[rec.File]++
+			records++
+		}
+	}
+	if records == 0 {
+		fmt.Fprintln(w, "Dry run: nothing to restore; every record in the manifest has already been restored.")
+		return nil
+	}
+	fmt.Fprintf(w, "Dry run: the restore would put back %d record(s) to %d file(s). Nothing was changed.\n\n", records, len(files))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "File\tRecords")
+	for _, file := range slices.Sorted(maps.Keys(files)) {
+		fmt.Fprintf(tw, "%s\t%d\n", file, files[file])
+	}
+	return tw.Flush()
+}
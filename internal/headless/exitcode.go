@@ -0,0 +1,79 @@
+// internal/headless/exitcode.go
+package headless
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+)
+
+// Exit codes returned by Run. Code 2 is left to the flag package for usage
+// errors.
+const (
+	// ExitClean means the run completed and stayed within any duplicate budget.
+	ExitClean = 0
+	// ExitError means the run failed or could not read every file.
+	ExitError = 1
+	// ExitDuplicates means duplication exceeded the budget set with
+	// -fail-on-duplicates or -fail-threshold.
+	ExitDuplicates = 3
+)
+
+// FailThreshold is the duplication budget of a run: the number of records per
+// check that may repeat an earlier one, or that number as a percentage of the
+// rows processed.
+type FailThreshold struct {
+	Count     int
+	Percent   float64
+	IsPercent bool
+}
+
+// ParseFailThreshold parses a count such as "100" or a percentage such as
+// "0.5%".
+func ParseFailThreshold(s string) (*FailThreshold, error) {
+	s = strings.TrimSpace(s)
+	if pct, ok := strings.CutSuffix(s, "%"); ok {
+		p, err := strconv.ParseFloat(strings.TrimSpace(pct), 64)
+		if err != nil || p < 0 || p > 100 {
+			return nil, fmt.Errorf("invalid -fail-threshold %q: percentage must be between 0%% and 100%%", s)
+		}
+		return &FailThreshold{Percent: p, IsPercent: true}, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid -fail-threshold %q: must be a non-negative count or a percentage such as 0.5%%", s)
+	}
+	return &FailThreshold{Count: n}, nil
+}
+
+func (t *FailThreshold) String() string {
+	if t.IsPercent {
+		return strconv.FormatFloat(t.Percent, 'f', -1, 64) + "%"
+	}
+	return strconv.Itoa(t.Count)
+}
+
+// exceeded lists the checks whose repeated records are over the threshold.
+func (t *FailThreshold) exceeded(rep *report.AnalysisReport, checkKey, checkRow bool) []string {
+	excess := rep.ExcessOccurrences(checkKey, checkRow)
+	checks := make([]string, 0, len(excess))
+	for check := range excess {
+		checks = append(checks, check)
+	}
+	sort.Strings(checks)
+	var over []string
+	for _, check := range checks {
+		n := excess[check]
+		if t.IsPercent {
+			if rows := rep.Summary.TotalRowsProcessed; rows > 0 && float64(n)*100/float64(rows) > t.Percent {
+				over = append(over, fmt.Sprintf("%s: %d repeated records (%.2f%% of %d rows)", check, n, float64(n)*100/float64(rows), rows))
+			}
+		} else if n > t.Count {
+			over = append(over, fmt.Sprintf("%s: %d repeated records", check, n))
+		}
+	}
+	return over
+}
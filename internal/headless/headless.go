@@ -3,19 +3,45 @@ package headless
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"text/template"
 	"time"
 
-	"github.com/benjaminwestern/dupe-analyser/internal/analyser"
-	"github.com/benjaminwestern/dupe-analyser/internal/report"
-	"github.com/benjaminwestern/dupe-analyser/internal/source"
+	"github.com/benjaminwestern/dupe-analyser/internal/config"
+	"github.com/benjaminwestern/dupe-analyser/internal/sink"
+	"github.com/benjaminwestern/dupe-analyser/internal/telemetry"
+	"github.com/benjaminwestern/dupe-analyser/pkg/analyser"
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+	"github.com/benjaminwestern/dupe-analyser/pkg/source"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
+// progressInterval is how often headless runs print a progress line.
+const progressInterval = 5 * time.Second
+
+// traceFlushTimeout bounds how long exiting waits for spans to be exported.
+const traceFlushTimeout = 10 * time.Second
+
+var tracer = otel.Tracer("github.com/benjaminwestern/dupe-analyser/internal/headless")
+
 // Config holds the settings required for a headless run.
 type Config struct {
 	Paths               string
 	Key                 string
+	AdditionalKeys      []string
+	Constraints         []config.ConstraintConfig
 	Workers             int
 	LogPath             string
 	OutputFormat        string
@@ -23,54 +49,770 @@ type Config struct {
 	CheckKey            bool
 	CheckRow            bool
 	ShowFolderBreakdown bool
-	EnableTxtOutput     bool
-	EnableJsonOutput    bool
+	// ShowFileBreakdown adds a per-file table of rows, keys, duplicates,
+	// parse errors and processing time to the report.
+	ShowFileBreakdown  bool
+	EnableTxtOutput    bool
+	EnableJsonOutput   bool
+	EnableCsvOutput    bool
+	EnableHtmlOutput   bool
+	EnableMdOutput     bool
+	EnableSarifOutput  bool
+	EnableJUnitOutput  bool
+	EnableNdjsonOutput bool
+	EnableSqliteOutput bool
+	FuzzyField         string
+	FuzzyThreshold     float64
+	Scope              string
+	LeftPaths          string
+	RightPaths         string
+	DiscoverKeys       bool
+	SampleRows         int64
+	Profile            bool
+	ProfileTopN        int
+	SampleRecords      int
+	MaxSampleBytes     int
+	IndexMode          string
+	IndexDir           string
+	BloomPrePass       bool
+	BloomExpectedItems uint64
+	RawRowHash         bool
+	MaxMemory          int64
+	MaxLineSize        int
+	ChunkSize          int64
+	MemoryMap          bool
+	CheckpointInterval time.Duration
+	CachePath          string
+	ShardOutput        bool
+	TemplatePath       string
+	MaxSets            int
+	MaxLocationsPerSet int
+	// MaxDuration, when positive, stops discovery and analysis once the run
+	// has taken this long, leaving a partial report. A resumed run takes it
+	// from its own flags rather than the checkpoint.
+	MaxDuration time.Duration `json:"-"`
+	// FilesFrom, when set, names a file listing the files to analyse, one
+	// per line, or "-" for standard input. The listed files are analysed in
+	// place of discovering the files under Paths.
+	FilesFrom string
+	// DryRun changes nothing. Without PurgeStrategy, DedupOut or
+	// DedupMergeOut the run stops after discovery and prints what would be
+	// analysed; with them the analysis runs, but the purge or output is only
+	// printed as a plan. A dry run sends no notifications.
+	DryRun bool `json:"-"`
+	// FailThreshold, when set, makes Run return ExitDuplicates when any check
+	// has more repeated records than it allows.
+	FailThreshold *FailThreshold
+	// Quiet suppresses everything but errors and, unless the run was clean,
+	// a one-line summary.
+	Quiet bool
+	// ProgressFormat is "text" for human-readable progress lines on stderr or
+	// "json" for one progress event object per line.
+	ProgressFormat string
+	// BigQueryTable, when set, is the project.dataset.table that findings are
+	// streamed into at the end of the run.
+	BigQueryTable string
+	// Retention limits the reports kept in LogPath.
+	Retention report.RetentionPolicy
+	// PurgeStrategy, when set, removes the duplicates from their files after
+	// the reports are saved, keeping one record of each set chosen by this
+	// purge keep strategy. PurgeIDs and PurgeRows select the sets purged.
+	PurgeStrategy string
+	PurgeIDs      bool
+	PurgeRows     bool
+	// PurgePlanPath, when set, makes the purge write its plan to this file
+	// for review instead of changing anything.
+	PurgePlanPath string
+	// PurgeQuarantine, when set, is the local directory or gs://bucket/prefix/
+	// that purged records are moved to, appended to a file at the same
+	// relative path as the file they came from, instead of being backed up.
+	PurgeQuarantine string
+	// PurgeBackupDir is the directory each purge's timestamped backup
+	// folder and manifest go in, purge.BackupDir when empty.
+	PurgeBackupDir string
+	// PurgeGCSBackup, when set, is the gs://bucket/prefix/ that records
+	// purged from GCS objects are backed up to instead of the local backup
+	// directory.
+	PurgeGCSBackup string
+	// DedupOut, when set, is the local directory or gs://bucket/prefix/ that
+	// a copy of every analysed file, without its duplicates, is written to,
+	// keeping one record of each set chosen by the DedupStrategy keep
+	// strategy. The originals are not changed.
+	DedupOut      string
+	DedupStrategy string
+	// DedupMergeOut, when set, is the local file or gs:// object that one
+	// copy of every unique record across all sources is written to, again
+	// chosen by DedupStrategy.
+	DedupMergeOut string
+	// CompressReports gzips the larger report files as they are saved.
+	CompressReports bool
+	// MaskKeys, when set, is the report.MaskHash or report.MaskPartial mode
+	// used to replace key values in every report output. MaskSalt salts the
+	// hashes and is not saved in checkpoints.
+	MaskKeys string
+	MaskSalt string `json:"-"`
+	// UploadPath, when set, is the gs:// folder that the saved report files
+	// are copied to.
+	UploadPath string
+	// ResumePath, when set, restores a checkpoint and replaces every other
+	// setting with the ones saved in it.
+	ResumePath string `json:"-"`
+	// Trace exports OpenTelemetry spans for discovery, each file and report
+	// generation over OTLP.
+	Trace bool `json:"-"`
+	// NotifyWebhook, when set, is posted a summary when the run finishes or
+	// fails, in NotifyFormat. It is not saved in checkpoints, as webhook URLs
+	// usually embed a secret.
+	NotifyWebhook string `json:"-"`
+	NotifyFormat  string `json:"-"`
+	// NotifyEmail, when set, is mailed the same summary through SMTP, with
+	// the text summary and JSON report attached.
+	NotifyEmail []string        `json:"-"`
+	SMTP        sink.SMTPConfig `json:"-"`
+	// Started, when set, is called once the files are discovered and the
+	// analysis begins, with a function that returns the progress of the run
+	// so far.
+	Started func(progress func() ProgressEvent) `json:"-"`
+	// Allowed, when set, must accept every file discovered, or the run fails
+	// before any is read.
+	Allowed func(path string) bool `json:"-"`
+}
+
+// outcome is what a run produced, for reporting it once the run is over.
+type outcome struct {
+	paths      string
+	report     *report.AnalysisReport
+	reportBase string
+}
+
+// Run executes the full analysis in headless (non-interactive) mode and
+// returns the process exit code. An interrupt or SIGTERM stops the analysis
+// early with a partial report, and the exit code ExitError.
+func Run(ctx context.Context, cfg *Config) int {
+	ctx, stop := interruptible(ctx)
+	defer stop()
+	if cfg.Trace {
+		stopTracing, err := startTracing(ctx)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return ExitError
+		}
+		defer stopTracing()
+	}
+	_, code := runAndNotify(ctx, cfg)
+	return code
+}
+
+// Result is what a run produced. Report is nil when the run failed before
+// analysing anything, and ReportBase is empty when no report files were
+// saved. Status names the result as ok, duplicates, over-budget or error.
+type Result struct {
+	Report     *report.AnalysisReport
+	ReportBase string
+	ExitCode   int
+	Status     string
+}
+
+// RunResult executes the analysis in cfg like Run and returns its report
+// along with its exit code, for callers that serve the report themselves.
+// Unlike Run, it leaves tracing to the caller.
+func RunResult(ctx context.Context, cfg *Config) Result {
+	res, code := runAndNotify(ctx, cfg)
+	return Result{Report: res.report, ReportBase: res.reportBase, ExitCode: code, Status: runStatus(res.report, code)}
+}
+
+// runAndNotify executes one run in its own span, sends the configured
+// notifications about it, and returns what it produced with its exit code.
+func runAndNotify(ctx context.Context, cfg *Config) (*outcome, int) {
+	ctx, span := tracer.Start(ctx, "headless run")
+	defer span.End()
+	res := &outcome{paths: cfg.Paths}
+	code := run(ctx, cfg, res)
+	span.SetAttributes(attribute.Int("exit_code", code))
+	if code == ExitError {
+		span.SetStatus(codes.Error, "run failed")
+	}
+	return res, notify(cfg, res, code)
+}
+
+// startTracing starts exporting spans and returns a function that delivers
+// the buffered spans and stops.
+func startTracing(ctx context.Context) (func(), error) {
+	shutdown, err := telemetry.StartTracing(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		// The run context may already be cancelled, but buffered spans
+		// should still be delivered.
+		flushCtx, cancel := context.WithTimeout(context.Background(), traceFlushTimeout)
+		defer cancel()
+		if err := shutdown(flushCtx); err != nil {
+			fmt.Printf("Error exporting traces: %v\n", err)
+		}
+	}, nil
+}
+
+// notify sends the configured notifications about a finished run and returns
+// the exit code, which becomes ExitError if one could not be delivered.
+func notify(cfg *Config, res *outcome, code int) int {
+	if cfg.DryRun || cfg.NotifyWebhook == "" && len(cfg.NotifyEmail) == 0 {
+		return code
+	}
+	n := sink.NewNotification(runStatus(res.report, code), code, res.paths, res.report, res.reportBase)
+	if cfg.NotifyWebhook != "" {
+		// The run context may have been cancelled, which is itself worth
+		// notifying about.
+		if err := sink.PostWebhook(context.Background(), cfg.NotifyWebhook, cfg.NotifyFormat, n); err != nil {
+			fmt.Printf("Error sending notification: %v\n", err)
+			code = ExitError
+		}
+	}
+	if len(cfg.NotifyEmail) > 0 {
+		if err := sink.SendEmail(cfg.SMTP, cfg.NotifyEmail, n); err != nil {
+			fmt.Printf("Error sending email: %v\n", err)
+			code = ExitError
+		}
+	}
+	return code
 }
 
-// Run executes the full analysis in headless (non-interactive) mode.
-func Run(ctx context.Context, cfg *Config) {
-	if cfg.ValidateOnly {
-		fmt.Println("Running in Key Validation Mode...")
+func run(ctx context.Context, cfg *Config, res *outcome) int {
+	var checkpoint *analyser.Checkpoint
+	if cfg.ResumePath != "" {
+		var err error
+		checkpoint, cfg, err = loadCheckpoint(cfg)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return ExitError
+		}
+		res.paths = cfg.Paths
+		fmt.Fprintf(cfg.stdout(), "Resuming checkpoint %s from %s (%d files already complete).\n", checkpoint.Path, checkpoint.CreatedAt.Format(time.RFC3339), checkpoint.FilesCompleted)
+	}
+	var masker *report.KeyMasker
+	if cfg.MaskKeys != "" {
+		var err error
+		if masker, err = report.NewKeyMasker(cfg.MaskKeys, cfg.MaskSalt); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return ExitError
+		}
+	}
+	// analysisCtx bounds discovery and analysis by MaxDuration. Saving and
+	// delivering the report use ctx, so a stopped run still reports.
+	analysisCtx := ctx
+	if cfg.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		analysisCtx, cancel = context.WithTimeout(ctx, cfg.MaxDuration)
+		defer cancel()
+	}
+	if cfg.LeftPaths != "" || cfg.RightPaths != "" {
+		res.paths = "left: " + cfg.LeftPaths + "; right: " + cfg.RightPaths
+		return runComparison(analysisCtx, cfg, masker)
+	}
+	out := cfg.stdout()
+	if cfg.DiscoverKeys {
+		fmt.Fprintln(out, "Running in Candidate Key Discovery Mode...")
+	} else if cfg.ValidateOnly {
+		fmt.Fprintln(out, "Running in Key Validation Mode...")
 	} else {
-		fmt.Println("Running in headless mode...")
+		fmt.Fprintln(out, "Running in headless mode...")
 	}
 	startTime := time.Now()
 
-	pathStrings := strings.Split(cfg.Paths, ",")
-	for i, p := range pathStrings {
-		pathStrings[i] = strings.TrimSpace(p)
+	var bqTable sink.BigQueryTable
+	if cfg.BigQueryTable != "" {
+		var err error
+		if bqTable, err = sink.ParseBigQueryTable(cfg.BigQueryTable); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return ExitError
+		}
+	}
+	var tmpl *template.Template
+	if cfg.TemplatePath != "" {
+		var err error
+		if tmpl, err = report.LoadTemplate(cfg.TemplatePath); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return ExitError
+		}
+	}
+	var uploadDest sink.UploadDestination
+	if cfg.UploadPath != "" {
+		var err error
+		if uploadDest, err = sink.ParseUploadDestination(cfg.UploadPath); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return ExitError
+		}
 	}
 
-	sources, err := source.DiscoverAll(ctx, pathStrings)
-	if err != nil {
-		fmt.Printf("Error discovering sources: %v\n", err)
-		return
+	var pathStrings []string
+	var sources []source.InputSource
+	var err error
+	if cfg.FilesFrom != "" {
+		if pathStrings, err = source.ReadList(cfg.FilesFrom); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return ExitError
+		}
+		res.paths = "files listed in " + cfg.FilesFrom
+		if sources, err = source.FromList(analysisCtx, pathStrings); err != nil {
+			fmt.Printf("Error reading listed files: %v\n", err)
+			return ExitError
+		}
+		fmt.Fprintf(out, "Read %d files to analyse from %s.\n", len(sources), cfg.FilesFrom)
+	} else {
+		pathStrings = splitPaths(cfg.Paths)
+		if sources, err = source.DiscoverAll(analysisCtx, pathStrings); err != nil {
+			fmt.Printf("Error discovering sources: %v\n", err)
+			return ExitError
+		}
+		fmt.Fprintf(out, "Discovered %d files to analyse across %d path(s).\n", len(sources), len(pathStrings))
+	}
+	if cfg.Allowed != nil {
+		for _, src := range sources {
+			if !cfg.Allowed(src.Path()) {
+				fmt.Printf("Error: %s is not under an allowed path.\n", src.Path())
+				return ExitError
+			}
+		}
+	}
+	if cfg.DryRun && cfg.PurgeStrategy == "" && cfg.DedupOut == "" && cfg.DedupMergeOut == "" {
+		if err := printDryRun(os.Stdout, cfg, res.paths, sources); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return ExitError
+		}
+		return ExitClean
 	}
-	fmt.Printf("Discovered %d files to analyse across %d path(s).\n", len(sources), len(pathStrings))
 
-	eng := analyser.New(cfg.Key, cfg.Workers, cfg.CheckKey, cfg.CheckRow, cfg.ValidateOnly)
-	finalReport := eng.Run(ctx, sources)
+	var eng *analyser.Analyser
+	if cfg.DiscoverKeys {
+		eng = analyser.New("", cfg.Workers, false, false, false)
+		eng.DiscoverKeys = true
+		eng.SampleRows = cfg.SampleRows
+	} else {
+		eng = analyser.New(cfg.Key, cfg.Workers, cfg.CheckKey, cfg.CheckRow, cfg.ValidateOnly)
+		eng.SetAdditionalKeys(cfg.AdditionalKeys)
+		for _, c := range cfg.Constraints {
+			eng.AddConstraint(c.Name, c.Fields)
+		}
+		eng.Profile = cfg.Profile
+		eng.ProfileTopN = cfg.ProfileTopN
+		eng.SampleRecords = cfg.SampleRecords
+		eng.MaxSampleBytes = cfg.MaxSampleBytes
+		eng.FileBreakdown = cfg.ShowFileBreakdown
+	}
+	if cfg.IndexMode != "" {
+		eng.IndexMode = cfg.IndexMode
+		eng.IndexDir = cfg.IndexDir
+	}
+	eng.BloomPrePass = cfg.BloomPrePass
+	eng.BloomExpectedItems = cfg.BloomExpectedItems
+	eng.RawRowHash = cfg.RawRowHash
+	eng.MaxMemory = cfg.MaxMemory
+	if cfg.MaxLineSize > 0 {
+		eng.MaxLineSize = cfg.MaxLineSize
+	}
+	eng.ChunkSize = cfg.ChunkSize
+	eng.MemoryMap = cfg.MemoryMap
+	defer eng.Close()
+	eng.FuzzyField = cfg.FuzzyField
+	eng.FuzzyThreshold = cfg.FuzzyThreshold
+	if cfg.Scope != "" {
+		eng.Scope = cfg.Scope
+	}
+	if cfg.CachePath != "" {
+		if err := eng.CheckpointSupported(); err != nil {
+			fmt.Printf("Error: -cache cannot be used here: %v\n", err)
+			return ExitError
+		}
+		eng.CachePath = cfg.CachePath
+	}
+	if cfg.ShardOutput {
+		if eng.Scope != analyser.ScopeGlobal || cfg.BloomPrePass || cfg.ValidateOnly || cfg.DiscoverKeys {
+			fmt.Println("Error: -shard needs a global-scope duplicate analysis without -bloom.")
+			return ExitError
+		}
+		if masker != nil && (cfg.MaskKeys != report.MaskHash || cfg.MaskSalt == "") {
+			fmt.Printf("Error: -shard with -report.mask-keys needs hash masking and a salt in %s, so the shards can be merged.\n", report.MaskSaltEnv)
+			return ExitError
+		}
+		eng.ShardOutput = true
+	}
+	if checkpoint != nil || cfg.CheckpointInterval > 0 {
+		if err := configureCheckpoints(eng, cfg, checkpoint); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return ExitError
+		}
+	}
+	if cfg.Started != nil {
+		totalBytes := source.TotalSize(sources)
+		cfg.Started(func() ProgressEvent {
+			return progressEvent(eng, len(sources), totalBytes, startTime, time.Now())
+		})
+	}
+	progressCtx, stopProgress := context.WithCancel(ctx)
+	if cfg.ProgressFormat == "json" {
+		go printProgressEvents(progressCtx, eng, sources, startTime)
+	} else if !cfg.Quiet {
+		go printProgress(progressCtx, eng, sources, startTime)
+	}
+	finalReport := eng.Run(analysisCtx, sources)
+	res.report = finalReport
+	stopProgress()
+	if errors.Is(analysisCtx.Err(), context.DeadlineExceeded) {
+		fmt.Fprintf(out, "Stopped after -max-duration %s; saving the partial report.\n", cfg.MaxDuration)
+		slog.Warn("Run stopped at its maximum duration", "max_duration", cfg.MaxDuration)
+	}
 
 	finalReport.Summary.TotalElapsedTime = time.Since(startTime).Round(time.Second).String()
-	filenameBase := report.SaveAndLog(finalReport, cfg.LogPath, cfg.EnableTxtOutput, cfg.EnableJsonOutput, cfg.CheckKey, cfg.CheckRow, cfg.ShowFolderBreakdown)
+	finalReport.Summary.Inputs = pathStrings
+	finalReport.Limits = report.DetailLimits{MaxSets: cfg.MaxSets, MaxLocationsPerSet: cfg.MaxLocationsPerSet}
+	if masker != nil {
+		finalReport.MaskKeys(masker)
+	}
+	saveOpts := cfg.saveOptions(tmpl)
+	_, saveSpan := tracer.Start(ctx, "save reports")
+	filenameBase := report.SaveAndLog(finalReport, cfg.LogPath, saveOpts)
+	saveSpan.End()
+	if len(saveOpts.Extensions()) > 0 {
+		res.reportBase = filenameBase
+	}
 
-	if !cfg.ValidateOnly && (cfg.EnableTxtOutput || cfg.EnableJsonOutput) {
-		var parts []string
-		if cfg.EnableTxtOutput {
-			parts = append(parts, ".txt")
+	if exts := saveOpts.Extensions(); !cfg.ValidateOnly && len(exts) > 0 {
+		fmt.Fprintf(out, "Analysis complete. Reports saved with base name '%s' and extension(s): %s\n", filenameBase, strings.Join(exts, ", "))
+	} else if !cfg.ValidateOnly {
+		fmt.Fprintln(out, "Analysis complete. No report files were generated as per configuration.")
+	}
+	exitCode := ExitClean
+	// An interrupted run has a cancelled context, but its partial report
+	// should still be delivered.
+	sinkCtx := context.WithoutCancel(ctx)
+	if cfg.UploadPath != "" {
+		uploaded, err := sink.UploadReports(sinkCtx, uploadDest, filenameBase)
+		if err != nil {
+			fmt.Printf("Error uploading reports: %v\n", err)
+			exitCode = ExitError
 		}
-		if cfg.EnableJsonOutput {
-			parts = append(parts, ".json")
+		if len(uploaded) > 0 {
+			fmt.Fprintf(out, "Uploaded %d report file(s) to %s\n", len(uploaded), uploadDest)
 		}
-		fmt.Printf("Analysis complete. Reports saved with base name '%s' and extension(s): %s\n", filenameBase, strings.Join(parts, ", "))
-	} else if !cfg.ValidateOnly {
-		fmt.Println("Analysis complete. No report files were generated as per configuration.")
+	}
+	if cfg.BigQueryTable != "" {
+		if err := sink.WriteBigQuery(sinkCtx, bqTable, finalReport, cfg.Paths, cfg.CheckKey, cfg.CheckRow); err != nil {
+			fmt.Printf("Error writing results to BigQuery: %v\n", err)
+			exitCode = ExitError
+		} else {
+			fmt.Fprintf(out, "Results written to BigQuery tables %s and %s_summary.\n", bqTable, bqTable.Table)
+		}
+	}
+
+	// The report is streamed rather than built as a string, as the details of
+	// a large run can be far bigger than the memory left after analysis.
+	if cfg.Quiet {
+		// Nothing but the summary line is written.
+	} else if cfg.OutputFormat == "json" {
+		err = finalReport.WriteJSON(os.Stdout)
+		fmt.Println()
+	} else {
+		fmt.Println()
+		err = saveOpts.WriteText(os.Stdout, finalReport, true)
+		fmt.Println()
+	}
+	if err != nil {
+		fmt.Printf("Error writing report: %v\n", err)
+		exitCode = ExitError
+	}
+	// A partial report may have missed duplicates, so nothing is purged or
+	// deduplicated from it.
+	partial := finalReport.Summary.IsPartialReport
+	if cfg.PurgeStrategy != "" && exitCode == ExitClean && !partial {
+		exitCode = autoPurge(ctx, cfg, finalReport, pathStrings)
+	}
+	if (cfg.DedupOut != "" || cfg.DedupMergeOut != "") && exitCode == ExitClean && !partial {
+		exitCode = dedupOutputs(ctx, cfg, finalReport, pathStrings, sources)
+	}
+	exitCode = runExitCode(cfg, finalReport, exitCode)
+	if cfg.Quiet {
+		printSummaryLine(finalReport, exitCode, res.reportBase)
+	}
+	return exitCode
+}
+
+// saveOptions returns the report files and settings cfg saves, rendering
+// the text report with tmpl when it is set.
+func (cfg *Config) saveOptions(tmpl *template.Template) report.SaveOptions {
+	return report.SaveOptions{
+		Txt:                 cfg.EnableTxtOutput,
+		JSON:                cfg.EnableJsonOutput,
+		CSV:                 cfg.EnableCsvOutput,
+		HTML:                cfg.EnableHtmlOutput,
+		Markdown:            cfg.EnableMdOutput,
+		SARIF:               cfg.EnableSarifOutput,
+		JUnit:               cfg.EnableJUnitOutput,
+		NDJSON:              cfg.EnableNdjsonOutput,
+		SQLite:              cfg.EnableSqliteOutput,
+		CheckKey:            cfg.CheckKey,
+		CheckRow:            cfg.CheckRow,
+		ShowFolderBreakdown: cfg.ShowFolderBreakdown,
+		Template:            tmpl,
+		Retention:           cfg.Retention,
+		Compress:            cfg.CompressReports,
+	}
+}
+
+// stdout is where progress messages and the report go: standard output, or
+// nowhere for quiet runs.
+func (cfg *Config) stdout() io.Writer {
+	if cfg.Quiet {
+		return io.Discard
+	}
+	return os.Stdout
+}
+
+// runStatus names the result of a run: error, over-budget, duplicates or ok.
+// rep is nil when the run failed before analysing anything.
+func runStatus(rep *report.AnalysisReport, exitCode int) string {
+	switch exitCode {
+	case ExitError:
+		return "error"
+	case ExitDuplicates:
+		return "over-budget"
+	}
+	if rep != nil {
+		for _, n := range rep.ExcessOccurrences(true, true) {
+			if n > 0 {
+				return "duplicates"
+			}
+		}
+	}
+	return "ok"
+}
+
+// printSummaryLine prints the one-line key=value summary of a quiet run. A
+// clean run without duplicates prints nothing, so cron only mails when there
+// is something to look at.
+func printSummaryLine(rep *report.AnalysisReport, exitCode int, reportBase string) {
+	status := runStatus(rep, exitCode)
+	if status == "ok" {
+		return
+	}
+	s := rep.Summary
+	line := fmt.Sprintf("dupe-analyser status=%s files=%d/%d rows=%d key=%s duplicate_keys=%d duplicate_rows=%d elapsed=%s",
+		status, s.FilesProcessed, s.TotalFiles, s.TotalRowsProcessed, s.UniqueKey, s.UniqueKeysDuplicated, s.DuplicateRowInstances, s.TotalElapsedTime)
+	for _, key := range slices.Sorted(maps.Keys(s.AdditionalKeys)) {
+		line += fmt.Sprintf(" duplicate_keys.%s=%d", key, s.AdditionalKeys[key].UniqueKeysDuplicated)
+	}
+	if reportBase != "" {
+		line += " report=" + reportBase
+	}
+	fmt.Println(line)
+}
+
+// runExitCode decides the exit code of a finished run. A run that stopped
+// early or could not read every file is an error, even if its findings are
+// within the duplicate budget, as they may be incomplete.
+func runExitCode(cfg *Config, rep *report.AnalysisReport, exitCode int) int {
+	s := rep.Summary
+	if s.IsPartialReport {
+		fmt.Println("Error: the analysis did not finish, so the report is partial.")
+		return ExitError
+	}
+	// Key discovery samples rows, so it stops reading files on purpose.
+	if !cfg.DiscoverKeys && int(s.FilesProcessed) < s.TotalFiles {
+		fmt.Printf("Error: %d of %d file(s) could not be read; see analyser.log.\n", s.TotalFiles-int(s.FilesProcessed), s.TotalFiles)
+		return ExitError
+	}
+	if exitCode != ExitClean || cfg.FailThreshold == nil || cfg.ValidateOnly || cfg.DiscoverKeys {
+		return exitCode
+	}
+	if over := cfg.FailThreshold.exceeded(rep, cfg.CheckKey, cfg.CheckRow); len(over) > 0 {
+		fmt.Fprintf(cfg.stdout(), "Duplicate budget of %s exceeded: %s\n", cfg.FailThreshold, strings.Join(over, "; "))
+		return ExitDuplicates
+	}
+	return ExitClean
+}
+
+// runComparison reconciles the keys of the left and right path sets,
+// masking the key values when masker is set.
+func runComparison(ctx context.Context, cfg *Config, masker *report.KeyMasker) int {
+	out := cfg.stdout()
+	fmt.Fprintln(out, "Running in dataset comparison mode...")
+	startTime := time.Now()
+
+	left, err := source.DiscoverAll(ctx, splitPaths(cfg.LeftPaths))
+	if err != nil {
+		fmt.Printf("Error discovering left sources: %v\n", err)
+		return ExitError
+	}
+	right, err := source.DiscoverAll(ctx, splitPaths(cfg.RightPaths))
+	if err != nil {
+		fmt.Printf("Error discovering right sources: %v\n", err)
+		return ExitError
+	}
+	fmt.Fprintf(out, "Discovered %d left file(s) and %d right file(s) to compare on key '%s'.\n", len(left), len(right), cfg.Key)
+
+	comparison := analyser.Compare(ctx, cfg.Key, cfg.Workers, left, right)
+	comparison.Summary.TotalElapsedTime = time.Since(startTime).Round(time.Second).String()
+	if masker != nil {
+		comparison.MaskKeys(masker)
+	}
+
+	if cfg.EnableTxtOutput || cfg.EnableJsonOutput {
+		filenameBase := comparison.SaveAndLog(cfg.LogPath, cfg.EnableTxtOutput, cfg.EnableJsonOutput, cfg.CompressReports, cfg.Retention)
+		fmt.Fprintf(out, "Comparison complete. Reports saved with base name '%s'.\n", filenameBase)
 	}
 
 	if cfg.OutputFormat == "json" {
-		jsonReport, _ := finalReport.ToJSON()
-		fmt.Println(jsonReport)
+		jsonReport, _ := comparison.ToJSON()
+		fmt.Fprintln(out, jsonReport)
 	} else {
-		fmt.Println("\n" + finalReport.String(true, cfg.CheckKey, cfg.CheckRow, cfg.ShowFolderBreakdown))
+		fmt.Fprintln(out, "\n"+comparison.String(true))
+	}
+	if comparison.Summary.IsPartialReport {
+		return ExitError
+	}
+	return ExitClean
+}
+
+// splitPaths splits a comma-separated path list and trims each entry.
+func splitPaths(paths string) []string {
+	pathStrings := strings.Split(paths, ",")
+	for i, p := range pathStrings {
+		pathStrings[i] = strings.TrimSpace(p)
+	}
+	return pathStrings
+}
+
+// printProgress writes a progress line to stderr every progressInterval until
+// ctx is done. Percent and ETA are derived from bytes read, and stderr keeps
+// the lines out of any JSON report written to stdout.
+func printProgress(ctx context.Context, eng *analyser.Analyser, sources []source.InputSource, startTime time.Time) {
+	totalBytes := source.TotalSize(sources)
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		processedBytes := eng.ProcessedBytes.Load()
+		percent, eta := analyser.EstimateProgress(processedBytes, totalBytes, time.Since(startTime))
+		etaStr := "unknown"
+		if eta > 0 {
+			etaStr = eta.Round(time.Second).String()
+		}
+		fmt.Fprintf(os.Stderr, "Progress: %.1f%% | %d of %d files | %s of %s | ETA: %s\n",
+			percent*100, eng.ProcessedFiles.Load(), len(sources), report.HumanSize(processedBytes), report.HumanSize(totalBytes), etaStr)
+	}
+}
+
+// ProgressEvent is a progress update written as a JSON line to stderr by
+// -progress json, for orchestrators that surface the state of long runs, and
+// returned by the function passed to Config.Started.
+type ProgressEvent struct {
+	Event          string  `json:"event"`
+	Time           string  `json:"time"`
+	ElapsedSeconds float64 `json:"elapsedSeconds"`
+	FilesDone      int32   `json:"filesDone"`
+	FilesTotal     int     `json:"filesTotal"`
+	BytesDone      int64   `json:"bytesDone"`
+	BytesTotal     int64   `json:"bytesTotal"`
+	Rows           int64   `json:"rows"`
+	Duplicates     *int64  `json:"duplicates,omitempty"`
+	Percent        float64 `json:"percent"`
+	ETASeconds     *int64  `json:"etaSeconds,omitempty"`
+}
+
+// printProgressEvents writes a ProgressEvent to stderr every progressInterval
+// until ctx is cancelled.
+func printProgressEvents(ctx context.Context, eng *analyser.Analyser, sources []source.InputSource, startTime time.Time) {
+	totalBytes := source.TotalSize(sources)
+	enc := json.NewEncoder(os.Stderr)
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			_ = enc.Encode(progressEvent(eng, len(sources), totalBytes, startTime, now))
+		}
+	}
+}
+
+// progressEvent returns the progress of eng at now, for a run of filesTotal
+// files holding totalBytes that started at startTime. Duplicates, the records
+// so far that repeat an earlier key or row, is left out when the index cannot
+// count them.
+func progressEvent(eng *analyser.Analyser, filesTotal int, totalBytes int64, startTime, now time.Time) ProgressEvent {
+	elapsed := now.Sub(startTime)
+	processedBytes := eng.ProcessedBytes.Load()
+	percent, eta := analyser.EstimateProgress(processedBytes, totalBytes, elapsed)
+	event := ProgressEvent{
+		Event:          "progress",
+		Time:           now.UTC().Format(time.RFC3339),
+		ElapsedSeconds: elapsed.Round(time.Millisecond).Seconds(),
+		FilesDone:      eng.ProcessedFiles.Load(),
+		FilesTotal:     filesTotal,
+		BytesDone:      processedBytes,
+		BytesTotal:     totalBytes,
+		Rows:           eng.TotalRows.Load(),
+		Percent:        math.Round(percent*1000) / 10,
+	}
+	if eng.CountsRepeats() {
+		duplicates := eng.RepeatedRecords.Load()
+		event.Duplicates = &duplicates
+	}
+	if eta > 0 {
+		seconds := int64(eta.Round(time.Second).Seconds())
+		event.ETASeconds = &seconds
+	}
+	return event
+}
+
+// loadCheckpoint reads the checkpoint named by cfg.ResumePath and returns it
+// with the run settings saved inside it. The output format and a newly given
+// checkpoint interval still apply to the resumed run.
+func loadCheckpoint(cfg *Config) (*analyser.Checkpoint, *Config, error) {
+	checkpoint, err := analyser.LoadCheckpoint(cfg.ResumePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	saved := &Config{}
+	if err := json.Unmarshal(checkpoint.Meta, saved); err != nil {
+		return nil, nil, fmt.Errorf("could not read settings from checkpoint %s: %w", cfg.ResumePath, err)
+	}
+	saved.ResumePath = cfg.ResumePath
+	if cfg.OutputFormat != "" {
+		saved.OutputFormat = cfg.OutputFormat
+	}
+	if cfg.CheckpointInterval > 0 {
+		saved.CheckpointInterval = cfg.CheckpointInterval
+	}
+	saved.Quiet = cfg.Quiet
+	saved.ProgressFormat = cfg.ProgressFormat
+	saved.MaxDuration = cfg.MaxDuration
+	saved.MaskSalt = cfg.MaskSalt
+	return checkpoint, saved, nil
+}
+
+// configureCheckpoints enables periodic checkpoints on the engine and, when
+// resuming, restores the saved state. A resumed run keeps writing to the
+// checkpoint it was started from.
+func configureCheckpoints(eng *analyser.Analyser, cfg *Config, checkpoint *analyser.Checkpoint) error {
+	if err := eng.CheckpointSupported(); err != nil {
+		return err
+	}
+	meta, err := json.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("could not save settings in checkpoint: %w", err)
+	}
+	eng.CheckpointMeta = meta
+	eng.CheckpointInterval = cfg.CheckpointInterval
+	if checkpoint == nil {
+		eng.CheckpointPath = filepath.Join(cfg.LogPath, "checkpoint-"+time.Now().Format("2006-01-02_15-04-05")+".gob")
+		fmt.Fprintf(cfg.stdout(), "Writing checkpoints every %s to %s\n", cfg.CheckpointInterval, eng.CheckpointPath)
+		return nil
 	}
+	eng.CheckpointPath = checkpoint.Path
+	return eng.Restore(checkpoint)
 }
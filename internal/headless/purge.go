@@ -0,0 +1,243 @@
+// internal/headless/purge.go
+package headless
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/benjaminwestern/dupe-analyser/internal/purge"
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+	"github.com/benjaminwestern/dupe-analyser/pkg/source"
+)
+
+// autoPurge removes the duplicates found in rep from their files, keeping one
+// record of each set chosen by cfg.PurgeStrategy. With cfg.PurgePlanPath it
+// only writes the plan for review. With cfg.PurgeQuarantine the removed
+// records are moved to files mirroring roots under it. With cfg.DryRun it
+// only prints the plan.
+func autoPurge(ctx context.Context, cfg *Config, rep *report.AnalysisReport, roots []string) int {
+	out := cfg.stdout()
+	decisions, err := purge.Decide(ctx, rep, cfg.PurgeStrategy, cfg.PurgeIDs, cfg.PurgeRows)
+	if err != nil {
+		fmt.Printf("Error: cannot purge: %v\n", err)
+		return ExitError
+	}
+	plan := purge.NewPlan(decisions)
+	opts := cfg.purgeOptions()
+	if cfg.PurgeQuarantine != "" {
+		opts.QuarantineDir = cfg.PurgeQuarantine
+		if opts.Quarantine, err = purge.MirrorPaths(roots, slices.Sorted(maps.Keys(plan)), cfg.PurgeQuarantine); err != nil {
+			fmt.Printf("Error: cannot quarantine: %v\n", err)
+			return ExitError
+		}
+	}
+	if cfg.DryRun {
+		what := "purge with " + cfg.PurgeStrategy
+		if cfg.PurgeQuarantine != "" {
+			what = "quarantine into " + cfg.PurgeQuarantine + " with " + cfg.PurgeStrategy
+		}
+		if err := printPlan(os.Stdout, what, plan); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return ExitError
+		}
+		return ExitClean
+	}
+	if cfg.PurgePlanPath != "" {
+		saved, err := purge.NewSavedPlan(ctx, cfg.PurgeStrategy, decisions, opts)
+		if err == nil {
+			err = saved.Save(cfg.PurgePlanPath)
+		}
+		if err != nil {
+			fmt.Printf("Error: could not write purge plan: %v\n", err)
+			return ExitError
+		}
+		fmt.Fprintf(out, "Purge plan to delete %d record(s) from %d file(s) written to %s. Nothing was changed; apply it with -purge.apply.\n",
+			saved.Records, len(saved.Files), cfg.PurgePlanPath)
+		return ExitClean
+	}
+	return applyPurge(ctx, out, plan, cfg.PurgeStrategy, opts)
+}
+
+// dedupOutputs writes the deduplicated copies of cfg.DedupOut and the merged
+// file of cfg.DedupMergeOut, leaving out the duplicates found in rep. Both
+// cover the primary key's duplicate sets and the duplicate row sets that
+// were checked. With cfg.DryRun it only prints what would be left out.
+func dedupOutputs(ctx context.Context, cfg *Config, rep *report.AnalysisReport, roots []string, sources []source.InputSource) int {
+	decisions, err := purge.Decide(ctx, rep, cfg.DedupStrategy, cfg.CheckKey, cfg.CheckRow)
+	if err != nil {
+		fmt.Printf("Error: cannot write deduplicated output: %v\n", err)
+		return ExitError
+	}
+	plan := purge.NewPlan(decisions)
+	if cfg.DryRun {
+		var dests []string
+		for _, dest := range []string{cfg.DedupOut, cfg.DedupMergeOut} {
+			if dest != "" {
+				dests = append(dests, dest)
+			}
+		}
+		what := "deduplicated output to " + strings.Join(dests, " and ") + " with " + cfg.DedupStrategy
+		if err := printPlan(os.Stdout, what, plan); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return ExitError
+		}
+		return ExitClean
+	}
+	files := make([]string, len(sources))
+	for i, src := range sources {
+		files[i] = src.Path()
+	}
+	code := ExitClean
+	if cfg.DedupOut != "" {
+		code = dedupCopies(ctx, cfg, plan, roots, files)
+	}
+	if cfg.DedupMergeOut != "" {
+		if c := dedupMerge(ctx, cfg, plan, files); c != ExitClean {
+			code = c
+		}
+	}
+	return code
+}
+
+// dedupMerge writes one copy of every unique record in files to
+// cfg.DedupMergeOut.
+func dedupMerge(ctx context.Context, cfg *Config, plan purge.Plan, files []string) int {
+	result, err := purge.WriteMerged(ctx, plan, files, cfg.DedupMergeOut)
+	if err != nil {
+		fmt.Printf("Error: could not write %s: %v\n", cfg.DedupMergeOut, err)
+		return ExitError
+	}
+	slog.Info("Wrote merged deduplicated file", "output", cfg.DedupMergeOut, "strategy", cfg.DedupStrategy, "files", result.FilesWritten, "records", result.RecordsWritten, "dropped", result.RecordsDropped)
+	fmt.Fprintf(cfg.stdout(), "Merged %d record(s) from %d file(s) into %s with %s, leaving out %d duplicate record(s).\n",
+		result.RecordsWritten, result.FilesWritten, cfg.DedupMergeOut, cfg.DedupStrategy, result.RecordsDropped)
+	return ExitClean
+}
+
+// dedupCopies writes a copy of every analysed file to cfg.DedupOut without
+// the records in plan.
+func dedupCopies(ctx context.Context, cfg *Config, plan purge.Plan, roots, files []string) int {
+	out := cfg.stdout()
+	copies, err := purge.MirrorPaths(roots, files, cfg.DedupOut)
+	if err != nil {
+		fmt.Printf("Error: cannot write deduplicated copies: %v\n", err)
+		return ExitError
+	}
+	result, err := purge.WriteCopies(ctx, plan, copies)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return ExitError
+	}
+	slog.Info("Wrote deduplicated copies", "output", cfg.DedupOut, "strategy", cfg.DedupStrategy, "files", result.FilesWritten, "dropped", result.RecordsDropped, "failed", result.FilesFailed)
+	fmt.Fprintf(out, "Wrote %d deduplicated file(s) to %s with %s, leaving out %d duplicate record(s). The originals were not changed.\n",
+		result.FilesWritten, cfg.DedupOut, cfg.DedupStrategy, result.RecordsDropped)
+	if result.FilesFailed > 0 {
+		fmt.Printf("Error: %d file(s) could not be copied; see analyser.log.\n", result.FilesFailed)
+		return ExitError
+	}
+	return ExitClean
+}
+
+// purgeOptions returns where a purge of this run backs up removed records.
+func (cfg *Config) purgeOptions() purge.Options {
+	return purge.Options{BackupDir: cfg.PurgeBackupDir, GCSBackup: cfg.PurgeGCSBackup, Key: cfg.Key, AuditLogPath: cfg.LogPath}
+}
+
+// ApplyPurgePlan carries out a plan written by -purge.plan, refusing to
+// touch anything if a planned file has changed since. Only Quiet, DryRun,
+// Key, LogPath and the purge backup locations of cfg are used; with DryRun
+// the plan is only printed.
+func ApplyPurgePlan(ctx context.Context, path string, cfg *Config) int {
+	saved, err := purge.LoadPlan(path)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return ExitError
+	}
+	if err := saved.Check(ctx); err != nil {
+		fmt.Printf("Error: not applying %s: %v\n", path, err)
+		return ExitError
+	}
+	opts := cfg.purgeOptions()
+	opts.QuarantineDir, opts.Quarantine = saved.Quarantine()
+	if cfg.DryRun {
+		what := "plan " + path + " with " + saved.Strategy
+		if opts.QuarantineDir != "" {
+			what += ", quarantining into " + opts.QuarantineDir + ","
+		}
+		if err := printPlan(os.Stdout, what, saved.Plan()); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return ExitError
+		}
+		return ExitClean
+	}
+	return applyPurge(ctx, cfg.stdout(), saved.Plan(), saved.Strategy, opts)
+}
+
+// applyPurge deletes the planned records and reports what changed.
+func applyPurge(ctx context.Context, out io.Writer, plan purge.Plan, strategy string, opts purge.Options) int {
+	if len(plan) == 0 {
+		fmt.Fprintln(out, "No duplicates to purge.")
+		return ExitClean
+	}
+	opts.Strategy = strategy
+	result, err := purge.Apply(ctx, plan, opts)
+	slog.Info("Purged duplicates", "strategy", strategy, "files", result.FilesModified, "records", result.RecordsDeleted, "failed", result.FilesFailed)
+	if result.RecordsDeleted > 0 && opts.QuarantineDir != "" {
+		fmt.Fprintf(out, "Moved %d duplicate record(s) from %d file(s) to quarantine files under %s with %s.\n",
+			result.RecordsDeleted, result.FilesModified, opts.QuarantineDir, strategy)
+	} else if result.RecordsDeleted > 0 {
+		fmt.Fprintf(out, "Purged %d record(s) from %d file(s) with %s. Removed records were backed up to %s.\n",
+			result.RecordsDeleted, result.FilesModified, strategy, strings.Join(result.Backups, " and "))
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return ExitError
+	}
+	if result.Manifest != "" {
+		fmt.Fprintf(out, "Undo with -purge.restore %s\n", result.Manifest)
+	}
+	if result.FilesFailed > 0 {
+		fmt.Printf("Error: %d file(s) could not be purged; see analyser.log.\n", result.FilesFailed)
+		return ExitError
+	}
+	return ExitClean
+}
+
+// RestorePurge puts back the records of a purge from the manifest it wrote.
+// The restore is recorded in the audit log in logPath. With dryRun the
+// records still to restore are only printed.
+func RestorePurge(ctx context.Context, manifestPath, logPath string, quiet, dryRun bool) int {
+	if dryRun {
+		manifest, err := purge.LoadManifest(manifestPath)
+		if err == nil {
+			err = printRestore(os.Stdout, manifest)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return ExitError
+		}
+		return ExitClean
+	}
+	out := (&Config{Quiet: quiet}).stdout()
+	result, err := purge.Restore(ctx, manifestPath, logPath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return ExitError
+	}
+	slog.Info("Restored purged records", "manifest", manifestPath, "files", result.FilesRestored, "records", result.RecordsRestored, "failed", result.FilesFailed)
+	if result.RecordsRestored == 0 && result.FilesFailed == 0 {
+		fmt.Fprintln(out, "Nothing to restore; every record in the manifest has already been restored.")
+		return ExitClean
+	}
+	fmt.Fprintf(out, "Restored %d record(s) to %d file(s).\n", result.RecordsRestored, result.FilesRestored)
+	if result.FilesFailed > 0 {
+		fmt.Printf("Error: %d file(s) could not be restored; see analyser.log. Run the restore again to retry them.\n", result.FilesFailed)
+		return ExitError
+	}
+	return ExitClean
+}
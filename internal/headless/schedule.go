@@ -0,0 +1,256 @@
+// internal/headless/schedule.go
+package headless
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+	"github.com/robfig/cron/v3"
+)
+
+// ParseSchedule parses a standard five-field cron expression such as
+// "0 2 * * *", a descriptor such as "@daily" or "@every 6h", optionally
+// prefixed with CRON_TZ=<zone> to evaluate it in a time zone.
+func ParseSchedule(spec string) (cron.Schedule, error) {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -schedule %q: %w", spec, err)
+	}
+	return schedule, nil
+}
+
+// Schedule runs the analysis in cfg each time schedule comes round, until ctx
+// is cancelled or the process is interrupted, so one long-lived process
+// replaces an external cron job. An interrupt during a run ends it with a
+// partial report, as Run does. Each run saves its own timestamped reports and
+// sends its own notifications. A run that overruns the next time is not
+// overlapped: the next run starts at the first time after it finishes. When
+// addr is set, /healthz and /metrics are served on it for the life of the
+// process.
+func Schedule(ctx context.Context, cfg *Config, schedule cron.Schedule, addr string) int {
+	ctx, stop := interruptible(ctx)
+	defer stop()
+	if cfg.Trace {
+		stopTracing, err := startTracing(ctx)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return ExitError
+		}
+		defer stopTracing()
+	}
+	status := &daemonStatus{started: time.Now(), runs: make(map[string]int)}
+	if addr != "" {
+		stop, err := status.serve(addr)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return ExitError
+		}
+		defer stop()
+	}
+
+	for {
+		next := schedule.Next(time.Now())
+		status.scheduled(next)
+		fmt.Fprintf(cfg.stdout(), "Next run at %s\n", next.Format(time.RFC3339))
+		slog.Info("Waiting for the next scheduled run", "at", next)
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ExitClean
+		case <-timer.C:
+		}
+
+		status.begin()
+		res, code := runAndNotify(ctx, cfg)
+		status.end(res.report, res.reportBase, code)
+		if ctx.Err() != nil {
+			return code
+		}
+		slog.Info("Scheduled run complete", "exit_code", code, "report", res.reportBase)
+	}
+}
+
+// daemonStatus is the state of a scheduled process, served by /healthz and
+// /metrics.
+type daemonStatus struct {
+	mu          sync.Mutex
+	started     time.Time
+	next        time.Time
+	running     bool
+	lastStart   time.Time
+	lastEnd     time.Time
+	lastCode    int
+	lastStatus  string
+	lastReport  string
+	lastSummary *report.SummaryReport
+	// runs counts the finished runs by the status names of runStatus.
+	runs map[string]int
+}
+
+func (s *daemonStatus) scheduled(next time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next = next
+}
+
+func (s *daemonStatus) begin() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = true
+	s.lastStart = time.Now()
+}
+
+func (s *daemonStatus) end(rep *report.AnalysisReport, reportBase string, code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = false
+	s.lastEnd = time.Now()
+	s.lastCode = code
+	s.lastStatus = runStatus(rep, code)
+	s.lastReport = reportBase
+	s.lastSummary = nil
+	if rep != nil {
+		summary := rep.Summary
+		s.lastSummary = &summary
+	}
+	s.runs[s.lastStatus]++
+}
+
+// serve starts serving /healthz and /metrics on addr and returns a function
+// that stops the server.
+func (s *daemonStatus) serve(addr string) (func(), error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("could not listen on %s: %w", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	fmt.Fprintf(os.Stderr, "Serving health and metrics on http://%s/\n", listener.Addr())
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Health server stopped", "error", err)
+		}
+	}()
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+	}, nil
+}
+
+// healthResponse is the body of /healthz.
+type healthResponse struct {
+	Status     string `json:"status"`
+	Running    bool   `json:"running"`
+	NextRun    string `json:"nextRun,omitempty"`
+	LastRun    string `json:"lastRun,omitempty"`
+	LastStatus string `json:"lastStatus,omitempty"`
+	LastReport string `json:"lastReport,omitempty"`
+}
+
+// handleHealth reports the process as healthy unless its last run failed, so
+// that a failing job shows up in uptime checks while the process keeps
+// retrying on schedule.
+func (s *daemonStatus) handleHealth(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	resp := healthResponse{Status: "ok", Running: s.running, LastStatus: s.lastStatus, LastReport: s.lastReport}
+	if !s.next.IsZero() && !s.running {
+		resp.NextRun = s.next.Format(time.RFC3339)
+	}
+	if !s.lastEnd.IsZero() {
+		resp.LastRun = s.lastEnd.Format(time.RFC3339)
+	}
+	failed := !s.lastEnd.IsZero() && s.lastCode == ExitError
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if failed {
+		resp.Status = "failing"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// handleMetrics writes the state of the process in the Prometheus text
+// format.
+func (s *daemonStatus) handleMetrics(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	seconds := func(t time.Time) float64 {
+		if t.IsZero() {
+			return 0
+		}
+		return float64(t.UnixNano()) / 1e9
+	}
+	boolValue := func(b bool) int {
+		if b {
+			return 1
+		}
+		return 0
+	}
+
+	metric("dupe_analyser_start_time_seconds", "gauge", "Time the process started, in seconds since the epoch.")
+	fmt.Fprintf(w, "dupe_analyser_start_time_seconds %.3f\n", seconds(s.started))
+	metric("dupe_analyser_running", "gauge", "Whether a scheduled run is in progress.")
+	fmt.Fprintf(w, "dupe_analyser_running %d\n", boolValue(s.running))
+	metric("dupe_analyser_next_run_timestamp_seconds", "gauge", "Time of the next scheduled run, in seconds since the epoch.")
+	fmt.Fprintf(w, "dupe_analyser_next_run_timestamp_seconds %.3f\n", seconds(s.next))
+	metric("dupe_analyser_runs_total", "counter", "Finished runs by status: ok, duplicates, over-budget or error.")
+	statuses := make([]string, 0, len(s.runs))
+	for status := range s.runs {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		fmt.Fprintf(w, "dupe_analyser_runs_total{status=%q} %d\n", status, s.runs[status])
+	}
+	if s.lastEnd.IsZero() {
+		return
+	}
+	metric("dupe_analyser_last_run_timestamp_seconds", "gauge", "Time the last run finished, in seconds since the epoch.")
+	fmt.Fprintf(w, "dupe_analyser_last_run_timestamp_seconds %.3f\n", seconds(s.lastEnd))
+	metric("dupe_analyser_last_run_duration_seconds", "gauge", "How long the last run took.")
+	fmt.Fprintf(w, "dupe_analyser_last_run_duration_seconds %.3f\n", s.lastEnd.Sub(s.lastStart).Seconds())
+	metric("dupe_analyser_last_run_exit_code", "gauge", "Exit code of the last run.")
+	fmt.Fprintf(w, "dupe_analyser_last_run_exit_code %d\n", s.lastCode)
+	if s.lastSummary == nil {
+		return
+	}
+	sum := s.lastSummary
+	metric("dupe_analyser_last_run_files", "gauge", "Files analysed by the last run.")
+	fmt.Fprintf(w, "dupe_analyser_last_run_files %d\n", sum.FilesProcessed)
+	metric("dupe_analyser_last_run_rows", "gauge", "Rows processed by the last run.")
+	fmt.Fprintf(w, "dupe_analyser_last_run_rows %d\n", sum.TotalRowsProcessed)
+	metric("dupe_analyser_last_run_bytes", "gauge", "Bytes read by the last run.")
+	fmt.Fprintf(w, "dupe_analyser_last_run_bytes %d\n", sum.ProcessedDataSizeBytes)
+	metric("dupe_analyser_last_run_duplicate_keys", "gauge", "Key values duplicated in the last run.")
+	fmt.Fprintf(w, "dupe_analyser_last_run_duplicate_keys{key=%q} %d\n", sum.UniqueKey, sum.UniqueKeysDuplicated)
+	keys := make([]string, 0, len(sum.AdditionalKeys))
+	for key := range sum.AdditionalKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "dupe_analyser_last_run_duplicate_keys{key=%q} %d\n", key, sum.AdditionalKeys[key].UniqueKeysDuplicated)
+	}
+	metric("dupe_analyser_last_run_duplicate_rows", "gauge", "Duplicate row instances in the last run.")
+	fmt.Fprintf(w, "dupe_analyser_last_run_duplicate_rows %d\n", sum.DuplicateRowInstances)
+}
@@ -0,0 +1,37 @@
+// internal/headless/signal.go
+package headless
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// interruptible returns a context that the first SIGINT or SIGTERM cancels,
+// so that the run stops reading, then saves and prints the partial report it
+// has built and exits with ExitError. Handling stops after that signal, so a
+// second one kills the process as usual. stop releases the signals.
+func interruptible(parent context.Context) (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(parent)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			signal.Stop(signals)
+			fmt.Fprintf(os.Stderr, "\nReceived %s; stopping to write a partial report. Send it again to quit at once.\n", sig)
+			slog.Warn("Interrupted; writing a partial report", "signal", sig.String())
+			cancel()
+		case <-done:
+		}
+	}()
+	return ctx, func() {
+		close(done)
+		signal.Stop(signals)
+		cancel()
+	}
+}
@@ -0,0 +1,222 @@
+// internal/headless/watch.go
+package headless
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+	"github.com/benjaminwestern/dupe-analyser/pkg/source"
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchSettle is how long watch mode waits after the last change to a local
+// file before analysing, so that files still being written are read whole.
+const watchSettle = 2 * time.Second
+
+// Watch runs the analysis in cfg, then runs it again whenever a file under its
+// paths is added or changed, until ctx is cancelled or the process is
+// interrupted. Local directories are watched through fsnotify and GCS paths
+// are listed every pollInterval. A fingerprint cache keeps the index of
+// unchanged files between runs, so each run only reads what changed. After the
+// first run, which is reported and notified as usual, a run prints and
+// notifies only when it introduces new duplicate sets.
+func Watch(ctx context.Context, cfg *Config, pollInterval time.Duration) int {
+	ctx, stop := interruptible(ctx)
+	defer stop()
+	if cfg.Trace {
+		stopTracing, err := startTracing(ctx)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return ExitError
+		}
+		defer stopTracing()
+	}
+	if cfg.CachePath == "" {
+		cfg.CachePath = filepath.Join(cfg.LogPath, "watch-cache.gob")
+	}
+	paths := splitPaths(cfg.Paths)
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		fmt.Printf("Error: could not watch for changes: %v\n", err)
+		return ExitError
+	}
+	defer watcher.Close()
+	watched := newWatchSet(watcher)
+	var gcs bool
+	for _, p := range paths {
+		if strings.HasPrefix(p, "gs://") {
+			gcs = true
+			continue
+		}
+		if err := watched.add(p); err != nil {
+			fmt.Printf("Error: could not watch %s: %v\n", p, err)
+			return ExitError
+		}
+	}
+
+	out := cfg.stdout()
+	var previous *report.AnalysisReport
+	for {
+		fingerprints, err := discoverFingerprints(ctx, paths)
+		if err != nil && previous == nil {
+			fmt.Printf("Error discovering sources: %v\n", err)
+			return ExitError
+		}
+		runCfg := *cfg
+		if previous != nil {
+			// Later runs are summarised by what they changed rather than
+			// printing the whole report again.
+			runCfg.Quiet = true
+			runCfg.NotifyWebhook, runCfg.NotifyEmail = "", nil
+		}
+		res := &outcome{paths: cfg.Paths}
+		code := run(ctx, &runCfg, res)
+		if ctx.Err() != nil {
+			return code
+		}
+		switch {
+		case res.report == nil:
+			if previous == nil {
+				return notify(cfg, res, code)
+			}
+			slog.Error("Watch run failed; waiting for the next change", "exit_code", code)
+		case previous == nil:
+			notify(cfg, res, code)
+			previous = res.report
+		default:
+			diff := report.Diff("previous run", previous, "this run", res.report)
+			if len(diff.Introduced) > 0 {
+				fmt.Fprintf(out, "%s: %d new duplicate set(s) introduced.\n", time.Now().Format(time.TimeOnly), len(diff.Introduced))
+				fmt.Fprintln(out, diff.String())
+				notify(cfg, res, code)
+			} else {
+				fmt.Fprintf(out, "%s: re-analysed after changes; no new duplicates.\n", time.Now().Format(time.TimeOnly))
+			}
+			slog.Info("Watch run complete", "introduced", len(diff.Introduced), "resolved", len(diff.Resolved), "report", res.reportBase)
+			previous = res.report
+		}
+
+		fmt.Fprintln(out, "Watching for changes...")
+		if err := waitForChange(ctx, watched, paths, gcs, pollInterval, fingerprints); err != nil {
+			return ExitClean
+		}
+	}
+}
+
+// watchSet is what watch mode follows: the directory trees of the paths, and
+// the paths that are single files, which are followed through their parent
+// directory as fsnotify loses a file replaced by a rename.
+type watchSet struct {
+	watcher *fsnotify.Watcher
+	dirs    map[string]bool
+	files   map[string]bool
+}
+
+func newWatchSet(watcher *fsnotify.Watcher) *watchSet {
+	return &watchSet{watcher: watcher, dirs: make(map[string]bool), files: make(map[string]bool)}
+}
+
+// add watches path: every directory beneath it when it is a directory, or
+// its parent directory when it is a file.
+func (w *watchSet) add(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return w.addTree(path)
+	}
+	path = filepath.Clean(path)
+	w.files[path] = true
+	return w.watcher.Add(filepath.Dir(path))
+}
+
+// addTree adds dir and every directory beneath it to the watcher, as
+// fsnotify only reports changes to the direct contents of a watched
+// directory.
+func (w *watchSet) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			w.dirs[filepath.Clean(path)] = true
+			return w.watcher.Add(path)
+		}
+		return nil
+	})
+}
+
+// follows reports whether name is a watched file or lies in a watched
+// directory tree, rather than being a sibling of a watched file.
+func (w *watchSet) follows(name string) bool {
+	return w.files[name] || w.dirs[filepath.Dir(name)]
+}
+
+// discoverFingerprints lists the files under paths with their fingerprints,
+// which change whenever their content does.
+func discoverFingerprints(ctx context.Context, paths []string) (map[string]string, error) {
+	sources, err := source.DiscoverAll(ctx, paths)
+	if err != nil {
+		return nil, err
+	}
+	fingerprints := make(map[string]string, len(sources))
+	for _, src := range sources {
+		fingerprints[src.Path()] = src.Fingerprint()
+	}
+	return fingerprints, nil
+}
+
+// waitForChange blocks until the files under paths differ from last, checking
+// after local changes have settled and, when gcs is set, every pollInterval.
+// It returns ctx's error once ctx is done.
+func waitForChange(ctx context.Context, watched *watchSet, paths []string, gcs bool, pollInterval time.Duration, last map[string]string) error {
+	var poll <-chan time.Time
+	if gcs {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+	var settle <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event := <-watched.watcher.Events:
+			if !watched.follows(event.Name) {
+				continue
+			}
+			if event.Has(fsnotify.Create) && watched.dirs[filepath.Dir(event.Name)] {
+				// New directories are watched too; a path that is not a
+				// directory is simply not added.
+				_ = watched.addTree(event.Name)
+			}
+			if !event.Has(fsnotify.Chmod) {
+				settle = time.After(watchSettle)
+			}
+			continue
+		case err := <-watched.watcher.Errors:
+			slog.Warn("Error watching for changes", "error", err)
+			continue
+		case <-settle:
+			settle = nil
+		case <-poll:
+		}
+		current, err := discoverFingerprints(ctx, paths)
+		if err != nil {
+			slog.Warn("Could not list files while watching", "error", err)
+			continue
+		}
+		if !maps.Equal(current, last) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// internal/logging/logging.go
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+)
+
+// FileName is the name of the log file written in the log directory.
+const FileName = "analyser.log"
+
+// Log formats accepted by ParseFormat.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Options configures the program-wide logger.
+type Options struct {
+	Dir    string
+	Level  slog.Level
+	Format string
+	// MaxSize is the size in bytes at which the log file is rotated. Zero
+	// lets it grow without limit.
+	MaxSize int64
+	// MaxFiles is the number of rotated files kept beside the current one.
+	MaxFiles int
+}
+
+// ParseLevel parses debug, info, warn or error, in any case.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q, must be debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+// ParseFormat validates a log format name.
+func ParseFormat(s string) (string, error) {
+	switch f := strings.ToLower(s); f {
+	case FormatText, FormatJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("invalid log format %q, must be text or json", s)
+}
+
+// Setup opens the log file in opts.Dir, appending to any earlier runs, and
+// makes a slog logger writing to it the default. Messages from the standard
+// log package are routed through the same logger at info level. Warnings and
+// errors are also kept for Recent. The returned closer closes the log file.
+func Setup(opts Options) (io.Closer, error) {
+	w, err := openRotatingFile(filepath.Join(opts.Dir, FileName), opts.MaxSize, opts.MaxFiles)
+	if err != nil {
+		return nil, err
+	}
+	handlerOpts := &slog.HandlerOptions{Level: opts.Level}
+	var handler slog.Handler
+	if opts.Format == FormatJSON {
+		handlerOpts.ReplaceAttr = cloudLoggingAttr
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	} else {
+		handler = slog.NewTextHandler(w, handlerOpts)
+	}
+	slog.SetDefault(slog.New(&recentHandler{Handler: handler}))
+	return w, nil
+}
+
+// cloudLoggingAttr renames the level and message of JSON records to the
+// severity and message fields that Cloud Logging recognises in structured
+// logs.
+func cloudLoggingAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return a
+	}
+	switch a.Key {
+	case slog.LevelKey:
+		level, _ := a.Value.Any().(slog.Level)
+		var severity string
+		switch {
+		case level >= slog.LevelError:
+			severity = "ERROR"
+		case level >= slog.LevelWarn:
+			severity = "WARNING"
+		case level >= slog.LevelInfo:
+			severity = "INFO"
+		default:
+			severity = "DEBUG"
+		}
+		return slog.String("severity", severity)
+	case slog.MessageKey:
+		a.Key = "message"
+	}
+	return a
+}
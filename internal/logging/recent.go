@@ -0,0 +1,110 @@
+// internal/logging/recent.go
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// recentCapacity is the number of warnings and errors kept for Recent.
+const recentCapacity = 100
+
+// Entry is a warning or error kept for display while a run is in progress.
+type Entry struct {
+	// Seq numbers the entries logged since the program started, from 1.
+	Seq   uint64
+	Time  time.Time
+	Level slog.Level
+	// Message holds the log message followed by its attributes as
+	// key=value.
+	Message string
+}
+
+// String renders the entry as one line with its time and level.
+func (e Entry) String() string {
+	return fmt.Sprintf("%s %-5s %s", e.Time.Format("15:04:05"), e.Level, e.Message)
+}
+
+// recent holds the last warnings and errors logged, oldest first.
+var recent struct {
+	sync.Mutex
+	entries []Entry
+	seq     uint64
+}
+
+// Recent returns up to n of the last warnings and errors logged after the
+// entry numbered after, oldest first, and the number logged after it in all.
+// Pass LastSeq at the start of a run to see only that run's entries.
+func Recent(after uint64, n int) ([]Entry, int) {
+	recent.Lock()
+	defer recent.Unlock()
+	var entries []Entry
+	for i := len(recent.entries) - 1; i >= 0 && len(entries) < n && recent.entries[i].Seq > after; i-- {
+		entries = append(entries, recent.entries[i])
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, int(recent.seq - min(after, recent.seq))
+}
+
+// LastSeq returns the number of the last warning or error logged.
+func LastSeq() uint64 {
+	recent.Lock()
+	defer recent.Unlock()
+	return recent.seq
+}
+
+func keep(r slog.Record, attrs []slog.Attr) {
+	var b strings.Builder
+	b.WriteString(r.Message)
+	write := func(a slog.Attr) bool {
+		fmt.Fprintf(&b, " %s=%v", a.Key, a.Value)
+		return true
+	}
+	for _, a := range attrs {
+		write(a)
+	}
+	r.Attrs(write)
+
+	recent.Lock()
+	defer recent.Unlock()
+	recent.seq++
+	if len(recent.entries) == recentCapacity {
+		recent.entries = append(recent.entries[:0], recent.entries[1:]...)
+	}
+	recent.entries = append(recent.entries, Entry{Seq: recent.seq, Time: r.Time, Level: r.Level, Message: b.String()})
+}
+
+// recentHandler keeps the warnings and errors passing through it for Recent,
+// whatever the level of the handler it wraps.
+type recentHandler struct {
+	slog.Handler
+	attrs []slog.Attr
+}
+
+func (h *recentHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelWarn || h.Handler.Enabled(ctx, level)
+}
+
+func (h *recentHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelWarn {
+		keep(r, h.attrs)
+	}
+	if !h.Handler.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *recentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &recentHandler{Handler: h.Handler.WithAttrs(attrs), attrs: append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...)}
+}
+
+func (h *recentHandler) WithGroup(name string) slog.Handler {
+	return &recentHandler{Handler: h.Handler.WithGroup(name), attrs: h.attrs}
+}
@@ -0,0 +1,78 @@
+// internal/logging/rotate.go
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// rotatingFile is an append-only log file that is renamed to <path>.1 once
+// it would grow past maxSize, shifting older files up to <path>.<maxFiles>
+// and deleting the oldest.
+type rotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+func openRotatingFile(path string, maxSize int64, maxFiles int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		return fmt.Errorf("failed to open log file at %s: %w", r.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open log file at %s: %w", r.path, err)
+	}
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the rotated files up by one and starts a new log file. If a
+// rename fails, logging carries on in the existing file rather than stopping.
+func (r *rotatingFile) rotate() error {
+	_ = r.file.Close()
+	if r.maxFiles > 0 {
+		_ = os.Remove(fmt.Sprintf("%s.%d", r.path, r.maxFiles))
+		for i := r.maxFiles - 1; i >= 1; i-- {
+			_ = os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+		_ = os.Rename(r.path, r.path+".1")
+	} else {
+		_ = os.Remove(r.path)
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
@@ -0,0 +1,121 @@
+// internal/purge/audit.go
+package purge
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+)
+
+// AuditLogName is the file in the log path that every purge and restore
+// appends an AuditRecord to.
+const AuditLogName = "purge-audit.jsonl"
+
+// Audit actions.
+const (
+	AuditPurge      = "purge"
+	AuditQuarantine = "quarantine"
+	AuditRestore    = "restore"
+)
+
+// AuditRecord describes one purge or restore for compliance review. Key
+// values are hashed as by -report.mask-keys hash, salted with
+// report.MaskSaltEnv. Unlike in reports, an unset salt is left empty rather
+// than made random, so the records of separate runs can always be matched.
+type AuditRecord struct {
+	Time        time.Time   `json:"time"`
+	Action      string      `json:"action"`
+	User        string      `json:"user"`
+	Host        string      `json:"host"`
+	Strategy    string      `json:"strategy,omitempty"`
+	Manifest    string      `json:"manifest,omitempty"`
+	Records     int         `json:"records"`
+	FilesFailed int         `json:"filesFailed,omitempty"`
+	Files       []AuditFile `json:"files"`
+}
+
+// AuditFile lists the records removed from, or restored to, one file.
+type AuditFile struct {
+	Path    string   `json:"path"`
+	Records int      `json:"records"`
+	Keys    []string `json:"keys,omitempty"`
+}
+
+// newAuditRecord summarises records, which are in file order, for the audit
+// log.
+func newAuditRecord(action, strategy, manifest string, records []ManifestRecord, filesFailed int) *AuditRecord {
+	salt := []byte(os.Getenv(report.MaskSaltEnv))
+	rec := &AuditRecord{
+		Time:        time.Now(),
+		Action:      action,
+		User:        currentUser(),
+		Strategy:    strategy,
+		Manifest:    manifest,
+		Records:     len(records),
+		FilesFailed: filesFailed,
+		Files:       []AuditFile{},
+	}
+	rec.Host, _ = os.Hostname()
+	for _, r := range records {
+		if n := len(rec.Files); n == 0 || rec.Files[n-1].Path != r.File {
+			rec.Files = append(rec.Files, AuditFile{Path: r.File})
+		}
+		f := &rec.Files[len(rec.Files)-1]
+		f.Records++
+		if r.Key != "" {
+			mac := hmac.New(sha256.New, salt)
+			mac.Write([]byte(r.Key))
+			f.Keys = append(f.Keys, "sha256:"+hex.EncodeToString(mac.Sum(nil))[:16])
+		}
+	}
+	return rec
+}
+
+// currentUser returns the name of the user running the process.
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// appendAudit appends rec as one JSON line to the audit log in logPath,
+// syncing it to disk.
+func appendAudit(logPath string, rec *AuditRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(logPath, 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(logPath, AuditLogName), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return syncedFile{f}.Close()
+}
+
+// audit appends the audit record of an action to the audit log in logPath,
+// if it is set.
+func audit(logPath, action, strategy, manifest string, records []ManifestRecord, filesFailed int) error {
+	if logPath == "" {
+		return nil
+	}
+	if err := appendAudit(logPath, newAuditRecord(action, strategy, manifest, records, filesFailed)); err != nil {
+		return fmt.Errorf("could not write the audit log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,300 @@
+// internal/purge/dedup.go
+package purge
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/storage"
+)
+
+// MirrorPaths maps each file to the same place under dest that it has under
+// the root, a local directory or gs:// prefix, it was found in. dest may be a
+// local directory or a gs://bucket/prefix/ folder. It fails if two files
+// would share a copy or a copy would replace its original.
+func MirrorPaths(roots, files []string, dest string) (map[string]string, error) {
+	roots = slices.Clone(roots)
+	for i, root := range roots {
+		if _, _, ok := splitGCSPath(root); !ok {
+			abs, err := filepath.Abs(root)
+			if err != nil {
+				return nil, err
+			}
+			roots[i] = abs
+		}
+	}
+	if _, _, ok := splitGCSPath(dest); !ok {
+		abs, err := filepath.Abs(dest)
+		if err != nil {
+			return nil, err
+		}
+		dest = abs
+	}
+	copies := make(map[string]string, len(files))
+	sources := make(map[string]string, len(files))
+	for _, file := range files {
+		rel, ok := relativeToRoot(roots, file)
+		if !ok {
+			return nil, fmt.Errorf("%s is not under any of the analysed paths", file)
+		}
+		var out string
+		if _, _, ok := splitGCSPath(dest); ok {
+			out = strings.TrimSuffix(dest, "/") + "/" + filepath.ToSlash(rel)
+		} else {
+			out = filepath.Join(dest, filepath.FromSlash(rel))
+		}
+		if out == file {
+			return nil, fmt.Errorf("the copy of %s would replace it; choose an output folder outside the analysed paths", file)
+		}
+		if other, ok := sources[out]; ok {
+			return nil, fmt.Errorf("%s and %s would both be copied to %s", other, file, out)
+		}
+		sources[out] = file
+		copies[file] = out
+	}
+	return copies, nil
+}
+
+// relativeToRoot returns the path of file under the longest of roots that
+// contains it.
+func relativeToRoot(roots []string, file string) (string, bool) {
+	best, found := "", false
+	for _, root := range roots {
+		var rel string
+		if _, _, ok := splitGCSPath(root); ok {
+			if !strings.HasPrefix(file, root) {
+				continue
+			}
+			rel = strings.TrimLeft(strings.TrimPrefix(file, root), "/")
+			if rel == "" {
+				rel = path.Base(file)
+			}
+		} else {
+			r, err := filepath.Rel(root, file)
+			if err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+				continue
+			}
+			rel = r
+		}
+		if !found || len(rel) < len(best) {
+			best, found = rel, true
+		}
+	}
+	return best, found
+}
+
+// CopyResult counts what WriteCopies and WriteMerged wrote.
+type CopyResult struct {
+	FilesWritten   int
+	RecordsWritten int
+	RecordsDropped int
+	// FilesFailed counts the files that could not be copied; the reasons are
+	// logged.
+	FilesFailed int
+}
+
+// WriteCopies writes each file in copies, without the records in plan, to
+// the path it maps to, leaving the originals untouched. Files without
+// duplicates are copied whole.
+func WriteCopies(ctx context.Context, plan Plan, copies map[string]string) (CopyResult, error) {
+	gcs := &gcsClient{}
+	defer gcs.Close()
+	var result CopyResult
+	for file, out := range copies {
+		written, dropped, err := writeCopy(ctx, gcs, file, out, plan[file])
+		if err != nil {
+			slog.Error("Dedup: could not write copy", "path", file, "output", out, "error", err)
+			result.FilesFailed++
+			continue
+		}
+		result.FilesWritten++
+		result.RecordsWritten += written
+		result.RecordsDropped += dropped
+	}
+	return result, nil
+}
+
+func writeCopy(ctx context.Context, gcs *gcsClient, file, out string, lines map[int]bool) (int, int, error) {
+	w, err := createOutput(ctx, gcs, out)
+	if err != nil {
+		return 0, 0, err
+	}
+	bw := bufio.NewWriterSize(w, 1<<20)
+	written, dropped, err := copyRecords(ctx, gcs, file, bw, lines)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		w.Abort()
+		return 0, 0, err
+	}
+	if err := w.Close(); err != nil {
+		return 0, 0, fmt.Errorf("could not write %s: %w", out, err)
+	}
+	return written, dropped, nil
+}
+
+// WriteMerged writes the records of files, in path order and without the
+// records in plan, to the single file or GCS object out. Blank lines are
+// left out. Nothing is written unless every file is read in full.
+func WriteMerged(ctx context.Context, plan Plan, files []string, out string) (CopyResult, error) {
+	if _, _, ok := splitGCSPath(out); !ok {
+		abs, err := filepath.Abs(out)
+		if err != nil {
+			return CopyResult{}, err
+		}
+		out = abs
+	}
+	if slices.Contains(files, out) {
+		return CopyResult{}, fmt.Errorf("%s is one of the analysed files; choose another output", out)
+	}
+	gcs := &gcsClient{}
+	defer gcs.Close()
+	w, err := createOutput(ctx, gcs, out)
+	if err != nil {
+		return CopyResult{}, err
+	}
+	bw := bufio.NewWriterSize(w, 1<<20)
+	var result CopyResult
+	for _, file := range slices.Sorted(slices.Values(files)) {
+		written, dropped, err := copyRecords(ctx, gcs, file, bw, plan[file])
+		if err != nil {
+			w.Abort()
+			return CopyResult{}, fmt.Errorf("%s: %w", file, err)
+		}
+		result.FilesWritten++
+		result.RecordsWritten += written
+		result.RecordsDropped += dropped
+	}
+	if err := bw.Flush(); err != nil {
+		w.Abort()
+		return CopyResult{}, err
+	}
+	if err := w.Close(); err != nil {
+		return CopyResult{}, err
+	}
+	return result, nil
+}
+
+// copyRecords writes the non-blank lines of file other than the numbered
+// ones to w, returning how many it wrote and left out.
+func copyRecords(ctx context.Context, gcs *gcsClient, file string, w io.Writer, lines map[int]bool) (int, int, error) {
+	r, err := openFile(ctx, gcs, file)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer r.Close()
+	kept := &recordWriter{w: w}
+	dropped, err := filterLines(r, kept, lines, func() (io.Writer, error) { return io.Discard, nil })
+	if err != nil {
+		return 0, 0, err
+	}
+	return kept.records, len(dropped), nil
+}
+
+// recordWriter passes on the lines filterLines keeps, except blank ones,
+// counting them. A file's last line is ended with "\n" if it has no ending,
+// so it does not run into the next file's first.
+type recordWriter struct {
+	w       io.Writer
+	records int
+}
+
+func (r *recordWriter) Write(p []byte) (int, error) {
+	if len(bytes.TrimSpace(p)) == 0 {
+		return len(p), nil
+	}
+	r.records++
+	if !bytes.HasSuffix(p, []byte("\n")) {
+		if _, err := r.w.Write(append(p, '\n')); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	return r.w.Write(p)
+}
+
+// output is a file being written in full, which only appears at its path
+// once Close succeeds.
+type output interface {
+	io.WriteCloser
+	// Abort discards what was written.
+	Abort()
+}
+
+// createOutput creates a local file or GCS object at p, replacing any there.
+func createOutput(ctx context.Context, gcs *gcsClient, p string) (output, error) {
+	bucket, object, ok := splitGCSPath(p)
+	if ok {
+		client, err := gcs.get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		wctx, cancel := context.WithCancel(ctx)
+		w := client.Bucket(bucket).Object(object).NewWriter(wctx)
+		w.ContentType = "application/x-ndjson"
+		return &objectOutput{Writer: w, cancel: cancel}, nil
+	}
+	dir := filepath.Dir(p)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(p)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return &fileOutput{File: tmp, path: p}, nil
+}
+
+// fileOutput writes to a temporary file that is renamed into place on Close.
+type fileOutput struct {
+	*os.File
+	path string
+}
+
+func (f *fileOutput) Close() error {
+	if err := (syncedFile{f.File}).Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), f.path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+func (f *fileOutput) Abort() {
+	f.File.Close()
+	os.Remove(f.Name())
+}
+
+// objectOutput writes a GCS object, which is only created if Close succeeds.
+type objectOutput struct {
+	*storage.Writer
+	cancel context.CancelFunc
+}
+
+func (o *objectOutput) Close() error {
+	defer o.cancel()
+	return o.Writer.Close()
+}
+
+func (o *objectOutput) Abort() {
+	o.cancel()
+	o.Writer.Close()
+}
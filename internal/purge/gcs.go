@@ -0,0 +1,142 @@
+// internal/purge/gcs.go
+package purge
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// splitGCSPath splits a gs://bucket/object path. ok is false for local paths.
+func splitGCSPath(p string) (bucket, object string, ok bool) {
+	rest, ok := strings.CutPrefix(p, "gs://")
+	if !ok {
+		return "", "", false
+	}
+	bucket, object, _ = strings.Cut(rest, "/")
+	return bucket, object, true
+}
+
+// ValidGCSFolder checks that uri is a gs://bucket/prefix/ folder.
+func ValidGCSFolder(uri string) error {
+	bucket, _, ok := splitGCSPath(uri)
+	if !ok || bucket == "" {
+		return fmt.Errorf("invalid GCS location %q: must be gs://bucket/prefix/", uri)
+	}
+	return nil
+}
+
+// gcsClient creates the storage client the first time a GCS object is
+// touched, so purges of local files never need credentials.
+type gcsClient struct {
+	once   sync.Once
+	client *storage.Client
+	err    error
+}
+
+func (g *gcsClient) get(ctx context.Context) (*storage.Client, error) {
+	g.once.Do(func() {
+		g.client, g.err = storage.NewClient(ctx)
+		if g.err != nil {
+			g.err = fmt.Errorf("failed to create GCS client: %w. Ensure you are authenticated", g.err)
+		}
+	})
+	return g.client, g.err
+}
+
+func (g *gcsClient) Close() {
+	if g.client != nil {
+		g.client.Close()
+	}
+}
+
+// fileState is what a purge notes about a file to tell whether it changed.
+// Generation is only set for GCS objects.
+type fileState struct {
+	Size       int64
+	ModTime    time.Time
+	Generation int64
+}
+
+// statFile returns the state of a local file or GCS object.
+func statFile(ctx context.Context, gcs *gcsClient, p string) (fileState, error) {
+	bucket, object, ok := splitGCSPath(p)
+	if !ok {
+		return statLocal(p)
+	}
+	client, err := gcs.get(ctx)
+	if err != nil {
+		return fileState{}, err
+	}
+	attrs, err := client.Bucket(bucket).Object(object).Attrs(ctx)
+	if err != nil {
+		return fileState{}, fmt.Errorf("could not read %s: %w", p, err)
+	}
+	return fileState{Size: attrs.Size, ModTime: attrs.Updated, Generation: attrs.Generation}, nil
+}
+
+// rewriteObject replaces the content of a GCS object. The new content is
+// streamed to a temporary object beside the original, which is then copied
+// over it on the condition that the original is still the generation that was
+// read, so a concurrent writer is never overwritten. The content type and
+// metadata of the object are kept.
+func rewriteObject(ctx context.Context, gcs *gcsClient, p string, transform func(r io.Reader, w io.Writer) error) error {
+	client, err := gcs.get(ctx)
+	if err != nil {
+		return err
+	}
+	bucketName, objectName, _ := splitGCSPath(p)
+	bucket := client.Bucket(bucketName)
+	original := bucket.Object(objectName)
+	attrs, err := original.Attrs(ctx)
+	if err != nil {
+		return err
+	}
+	r, err := original.Generation(attrs.Generation).NewReader(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	tmp := bucket.Object(fmt.Sprintf("%s.purge-%d.tmp", objectName, time.Now().UnixNano()))
+	tw := tmp.NewWriter(ctx)
+	tw.ContentType = attrs.ContentType
+	if err := transform(r, tw); err != nil {
+		tw.Close()
+		tmp.Delete(ctx)
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	defer tmp.Delete(ctx)
+
+	copier := original.If(storage.Conditions{GenerationMatch: attrs.Generation}).CopierFrom(tmp)
+	copier.ContentType = attrs.ContentType
+	copier.Metadata = attrs.Metadata
+	if _, err := copier.Run(ctx); err != nil {
+		return fmt.Errorf("could not replace object, it may have changed since it was read: %w", err)
+	}
+	return nil
+}
+
+// createGCSBackup creates the named backup object under the gs://bucket/prefix/
+// folder, returning it with its path.
+func createGCSBackup(ctx context.Context, gcs *gcsClient, folder, name string) (io.WriteCloser, string, error) {
+	client, err := gcs.get(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	bucket, prefix, _ := splitGCSPath(folder)
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	w := client.Bucket(bucket).Object(prefix + name).NewWriter(ctx)
+	w.ContentType = "application/x-ndjson"
+	return w, "gs://" + bucket + "/" + prefix + name, nil
+}
@@ -0,0 +1,110 @@
+// internal/purge/lines.go
+package purge
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// lineReader reads the lines of a file along with their line endings, so a
+// rewrite can keep a file's CRLF or LF endings byte for byte.
+type lineReader struct {
+	r    *bufio.Reader
+	line []byte
+	err  error
+	// finalNewline is false once a last line without an ending has been read.
+	finalNewline bool
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	return &lineReader{r: bufio.NewReaderSize(r, 64*1024), finalNewline: true}
+}
+
+// next reads the next line, reporting false at the end of the file or on an
+// error, which err returns.
+func (l *lineReader) next() bool {
+	line, err := l.r.ReadBytes('\n')
+	if len(line) > maxLineSize {
+		l.err = fmt.Errorf("line longer than %d bytes", maxLineSize)
+		return false
+	}
+	if err != nil && err != io.EOF {
+		l.err = err
+		return false
+	}
+	if len(line) == 0 {
+		return false
+	}
+	l.line = line
+	l.finalNewline = line[len(line)-1] == '\n'
+	return true
+}
+
+// raw returns the current line with its ending.
+func (l *lineReader) raw() []byte { return l.line }
+
+// text returns the current line without its ending.
+func (l *lineReader) text() string { return string(trimEOL(l.line)) }
+
+// eol returns the ending of the current line: "\r\n", "\n", or "" for a
+// last line without one.
+func (l *lineReader) eol() string { return string(l.line[len(trimEOL(l.line)):]) }
+
+func trimEOL(line []byte) []byte {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	return bytes.TrimSuffix(line, []byte("\r"))
+}
+
+// lineWriter writes lines that carry their own endings, holding back the
+// last so that a copy of a file that did not end with a newline does not
+// either, even when the file's last line is left out.
+type lineWriter struct {
+	w       io.Writer
+	pending []byte
+	// eol ends a held-back line without an ending when another line follows
+	// it. It is the first ending seen, "\n" until then.
+	eol string
+}
+
+func (lw *lineWriter) write(line []byte) error {
+	if err := lw.flush(true); err != nil {
+		return err
+	}
+	if lw.eol == "" {
+		if text := trimEOL(line); len(text) < len(line) {
+			lw.eol = string(line[len(text):])
+		}
+	}
+	lw.pending = line
+	return nil
+}
+
+// flush writes the held-back line, ending it if newline is set and removing
+// its ending if not.
+func (lw *lineWriter) flush(newline bool) error {
+	if lw.pending == nil {
+		return nil
+	}
+	line := lw.pending
+	lw.pending = nil
+	text := trimEOL(line)
+	switch {
+	case !newline:
+		line = text
+	case len(text) == len(line):
+		eol := lw.eol
+		if eol == "" {
+			eol = "\n"
+		}
+		line = append(line, eol...)
+	}
+	_, err := lw.w.Write(line)
+	return err
+}
+
+// close writes the last line, ending it only if finalNewline is set.
+func (lw *lineWriter) close(finalNewline bool) error {
+	return lw.flush(finalNewline)
+}
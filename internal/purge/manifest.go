@@ -0,0 +1,267 @@
+// internal/purge/manifest.go
+package purge
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// ManifestName is the file in the backup folder of each purge that its
+// manifest is saved to.
+const ManifestName = "manifest.json"
+
+// ManifestVersion is the layout version of saved manifests.
+const ManifestVersion = 1
+
+// Manifest records where every purged record came from and where it was
+// backed up, so that Restore can put it back. KeyField is the unique key
+// whose values are noted.
+type Manifest struct {
+	Version   int              `json:"version"`
+	CreatedAt time.Time        `json:"createdAt"`
+	KeyField  string           `json:"keyField,omitempty"`
+	Records   []ManifestRecord `json:"records"`
+}
+
+// ManifestRecord describes one purged record: line Line of File, with the
+// value Key of the unique key if it had one and the Hash of the line, backed
+// up as line BackupLine of Backup. EOL is the line's ending, "\r\n" or "\n",
+// and is empty for a last line without one. Restored is set once Restore has
+// put it back.
+type ManifestRecord struct {
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	Key        string `json:"key,omitempty"`
+	Hash       string `json:"hash"`
+	Backup     string `json:"backup"`
+	BackupLine int    `json:"backupLine"`
+	EOL        string `json:"eol,omitempty"`
+	Restored   bool   `json:"restored,omitempty"`
+}
+
+// keyValue returns the value of the field key in the JSON line, formatted as
+// the analyser formats key values, or "" if it has none.
+func keyValue(line, key string) string {
+	if key == "" {
+		return ""
+	}
+	var data map[string]any
+	if json.Unmarshal([]byte(line), &data) != nil || data[key] == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", data[key])
+}
+
+// lineHash returns the SHA-256 of a line, which Restore checks the backed-up
+// copy against.
+func lineHash(line string) string {
+	sum := sha256.Sum256([]byte(line))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// save writes the manifest to path, with its records in file and line order.
+func (m *Manifest) save(path string) error {
+	slices.SortFunc(m.Records, func(a, b ManifestRecord) int {
+		if c := cmp.Compare(a.File, b.File); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Line, b.Line)
+	})
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal purge manifest: %w", err)
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// LoadManifest reads a manifest written by Apply.
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("could not read purge manifest %s: %w", path, err)
+	}
+	if m.Version != ManifestVersion {
+		return nil, fmt.Errorf("purge manifest %s has version %d; this build restores version %d", path, m.Version, ManifestVersion)
+	}
+	return m, nil
+}
+
+// RestoreResult counts what Restore put back.
+type RestoreResult struct {
+	FilesRestored   int
+	RecordsRestored int
+	// FilesFailed counts the files whose records could not be restored; the
+	// reasons are logged.
+	FilesFailed int
+}
+
+// Restore re-inserts the records of the manifest at path into their files at
+// the line numbers they were purged from. A file that has changed since the
+// purge gets its records at the same line numbers, or at its end if it is now
+// shorter. Restored records are marked in the manifest, so running Restore
+// again only retries the files that failed. The restore is recorded in the
+// audit log of auditLogPath when it is set.
+func Restore(ctx context.Context, path, auditLogPath string) (RestoreResult, error) {
+	m, err := LoadManifest(path)
+	if err != nil {
+		return RestoreResult{}, err
+	}
+	gcs := &gcsClient{}
+	defer gcs.Close()
+
+	byFile := make(map[string][]int)
+	var files []string
+	for i, rec := range m.Records {
+		if rec.Restored {
+			continue
+		}
+		if _, ok := byFile[rec.File]; !ok {
+			files = append(files, rec.File)
+		}
+		byFile[rec.File] = append(byFile[rec.File], i)
+	}
+	slices.Sort(files)
+
+	var result RestoreResult
+	var restored []ManifestRecord
+	backups := make(map[string][]string)
+	for _, file := range files {
+		indexes := byFile[file]
+		inserts := make([]insertedLine, 0, len(indexes))
+		for _, i := range indexes {
+			rec := m.Records[i]
+			lines, ok := backups[rec.Backup]
+			if !ok {
+				if lines, err = readLines(ctx, gcs, rec.Backup); err != nil {
+					break
+				}
+				backups[rec.Backup] = lines
+			}
+			if rec.BackupLine < 1 || rec.BackupLine > len(lines) {
+				err = fmt.Errorf("backup %s has no line %d", rec.Backup, rec.BackupLine)
+				break
+			}
+			if rec.Hash != "" && lineHash(lines[rec.BackupLine-1]) != rec.Hash {
+				err = fmt.Errorf("line %d of backup %s does not match the record purged from line %d", rec.BackupLine, rec.Backup, rec.Line)
+				break
+			}
+			inserts = append(inserts, insertedLine{line: rec.Line, text: lines[rec.BackupLine-1], eol: rec.EOL})
+		}
+		if err == nil {
+			slices.SortStableFunc(inserts, func(a, b insertedLine) int { return cmp.Compare(a.line, b.line) })
+			err = rewrite(ctx, gcs, file, func(r io.Reader, w io.Writer) error {
+				return insertLines(r, w, inserts)
+			})
+		}
+		if err != nil {
+			slog.Error("Restore: could not restore file", "path", file, "error", err)
+			result.FilesFailed++
+			err = nil
+			continue
+		}
+		for _, i := range indexes {
+			m.Records[i].Restored = true
+			restored = append(restored, m.Records[i])
+		}
+		result.FilesRestored++
+		result.RecordsRestored += len(indexes)
+	}
+	if result.RecordsRestored > 0 {
+		if err := m.save(path); err != nil {
+			return result, fmt.Errorf("records were restored but the manifest could not be updated: %w", err)
+		}
+	}
+	if len(files) > 0 {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		if err := audit(auditLogPath, AuditRestore, "", path, restored, result.FilesFailed); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// insertedLine is a backed-up record, the line it is restored to and the
+// line ending it had.
+type insertedLine struct {
+	line int
+	text string
+	eol  string
+}
+
+// insertLines copies the lines of r to w, writing each of inserts, which are
+// in line order, so that it becomes the given line of the output. Inserted
+// lines get the ending they had, or that of the first line of r if it is not
+// known, and the output ends with a newline only if r does.
+func insertLines(r io.Reader, w io.Writer, inserts []insertedLine) error {
+	lr := newLineReader(r)
+	lw := &lineWriter{w: w}
+	written, next := 0, 0
+	writeInserts := func(all bool) error {
+		for ; next < len(inserts) && (all || inserts[next].line <= written+1); next++ {
+			written++
+			eol := inserts[next].eol
+			if eol == "" {
+				eol = lw.eol
+			}
+			if err := lw.write([]byte(inserts[next].text + eol)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for lr.next() {
+		if lw.eol == "" {
+			lw.eol = lr.eol()
+		}
+		if err := writeInserts(false); err != nil {
+			return err
+		}
+		written++
+		if err := lw.write(lr.raw()); err != nil {
+			return err
+		}
+	}
+	if lr.err != nil {
+		return fmt.Errorf("could not scan file: %w", lr.err)
+	}
+	if err := writeInserts(true); err != nil {
+		return err
+	}
+	return lw.close(lr.finalNewline)
+}
+
+// readLines reads every line of a local file or GCS object.
+func readLines(ctx context.Context, gcs *gcsClient, p string) ([]string, error) {
+	r, err := openFile(ctx, gcs, p)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read backup %s: %w", p, err)
+	}
+	return lines, nil
+}
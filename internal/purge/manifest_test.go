@@ -0,0 +1,171 @@
+// internal/purge/manifest_test.go
+package purge
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// purgeFile purges the numbered lines of the file at p under opts and
+// returns the result.
+func purgeFile(t *testing.T, p string, lines []int, opts Options) Result {
+	t.Helper()
+	plan := Plan{p: make(map[int]bool)}
+	for _, line := range lines {
+		plan[p][line] = true
+	}
+	result, err := Apply(context.Background(), plan, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.FilesFailed != 0 || result.Manifest == "" {
+		t.Fatalf("purge failed %d files, manifest %q", result.FilesFailed, result.Manifest)
+	}
+	return result
+}
+
+func readFile(t *testing.T, p string) string {
+	t.Helper()
+	data, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRestoreRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		purge    []int
+	}{
+		{"lf", "{\"id\":1}\n{\"id\":1}\n{\"id\":2}\n{\"id\":1}\n", []int{2, 4}},
+		{"crlf", "{\"id\":1}\r\n{\"id\":1}\r\n{\"id\":2}\r\n", []int{1, 2}},
+		{"mixed", "{\"id\":1}\r\n{\"id\":1}\n{\"id\":2}\r\n", []int{2}},
+		{"no final newline", "{\"id\":1}\n{\"id\":2}\n{\"id\":1}", []int{3}},
+		{"first line", "{\"id\":1}\n{\"id\":2}\n", []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			p := filepath.Join(dir, "data.json")
+			if err := os.WriteFile(p, []byte(tt.original), 0644); err != nil {
+				t.Fatal(err)
+			}
+			result := purgeFile(t, p, tt.purge, Options{BackupDir: filepath.Join(dir, "backups"), Key: "id"})
+			if got := readFile(t, p); got == tt.original {
+				t.Fatal("purge left the file unchanged")
+			}
+
+			restored, err := Restore(context.Background(), result.Manifest, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if restored.FilesFailed != 0 || restored.RecordsRestored != len(tt.purge) {
+				t.Fatalf("restored %d records with %d files failed, want %d and 0", restored.RecordsRestored, restored.FilesFailed, len(tt.purge))
+			}
+			if got := readFile(t, p); got != tt.original {
+				t.Errorf("restored file is %q, want %q", got, tt.original)
+			}
+
+			// Running Restore again finds nothing left to put back.
+			again, err := Restore(context.Background(), result.Manifest, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again.RecordsRestored != 0 {
+				t.Errorf("second restore put back %d records", again.RecordsRestored)
+			}
+		})
+	}
+}
+
+func TestRestoreFromQuarantine(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "data.json")
+	original := "{\"id\":1}\n{\"id\":1}\n{\"id\":2}\n{\"id\":2}\n"
+	if err := os.WriteFile(p, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The quarantine file already holds records from an earlier purge, so the
+	// records of this one start at line 3 of it.
+	quarantineDir := filepath.Join(dir, "quarantine")
+	if err := os.MkdirAll(quarantineDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	quarantine := filepath.Join(quarantineDir, "data.json")
+	if err := os.WriteFile(quarantine, []byte("{\"id\":8}\n{\"id\":9}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result := purgeFile(t, p, []int{2, 4}, Options{
+		BackupDir:     filepath.Join(dir, "backups"),
+		Key:           "id",
+		Quarantine:    map[string]string{p: quarantine},
+		QuarantineDir: quarantineDir,
+	})
+	m, err := LoadManifest(result.Manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, rec := range m.Records {
+		if rec.Backup != quarantine || rec.BackupLine != i+3 {
+			t.Errorf("record %d backed up to %s:%d, want %s:%d", i, rec.Backup, rec.BackupLine, quarantine, i+3)
+		}
+	}
+	if got, want := readFile(t, quarantine), "{\"id\":8}\n{\"id\":9}\n{\"id\":1}\n{\"id\":2}\n"; got != want {
+		t.Errorf("quarantine file is %q, want %q", got, want)
+	}
+
+	restored, err := Restore(context.Background(), result.Manifest, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.RecordsRestored != 2 || restored.FilesFailed != 0 {
+		t.Fatalf("restored %d records with %d files failed, want 2 and 0", restored.RecordsRestored, restored.FilesFailed)
+	}
+	if got := readFile(t, p); got != original {
+		t.Errorf("restored file is %q, want %q", got, original)
+	}
+}
+
+func TestRestoreRefusesChangedBackup(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "data.json")
+	if err := os.WriteFile(p, []byte("{\"id\":1}\n{\"id\":1}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result := purgeFile(t, p, []int{2}, Options{BackupDir: filepath.Join(dir, "backups"), Key: "id"})
+	purged := readFile(t, p)
+
+	m, err := LoadManifest(result.Manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(m.Records[0].Backup, []byte("{\"id\":\"tampered\"}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := Restore(context.Background(), result.Manifest, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.FilesFailed != 1 || restored.RecordsRestored != 0 {
+		t.Fatalf("restored %d records with %d files failed, want 0 and 1", restored.RecordsRestored, restored.FilesFailed)
+	}
+	if got := readFile(t, p); got != purged {
+		t.Errorf("refused restore changed the file to %q", got)
+	}
+	if m, err = LoadManifest(result.Manifest); err != nil {
+		t.Fatal(err)
+	}
+	if m.Records[0].Restored {
+		t.Error("refused record is marked restored")
+	}
+	if !strings.HasPrefix(m.Records[0].Hash, "sha256:") {
+		t.Errorf("record hash %q is not a sha256", m.Records[0].Hash)
+	}
+}
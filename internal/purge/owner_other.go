@@ -0,0 +1,10 @@
+//go:build !unix
+
+// internal/purge/owner_other.go
+package purge
+
+import "os"
+
+func keepOwner(*os.File, os.FileInfo) error {
+	return nil
+}
@@ -0,0 +1,27 @@
+//go:build unix
+
+// internal/purge/owner_unix.go
+package purge
+
+import (
+	"os"
+	"syscall"
+)
+
+// keepOwner gives f the owner and group of the file described by info. It
+// only calls chown when they differ, so rewriting one's own files never
+// needs privileges.
+func keepOwner(f *os.File, info os.FileInfo) error {
+	want, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	current, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if have, ok := current.Sys().(*syscall.Stat_t); ok && have.Uid == want.Uid && have.Gid == want.Gid {
+		return nil
+	}
+	return f.Chown(int(want.Uid), int(want.Gid))
+}
@@ -0,0 +1,139 @@
+// internal/purge/plan.go
+package purge
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+)
+
+// PlanVersion is the layout version of saved purge plans.
+const PlanVersion = 1
+
+// SavedPlan is a purge plan written for review before it is applied. Files
+// is what Apply acts on; Sets explains which record of each set is kept.
+type SavedPlan struct {
+	Version       int           `json:"version"`
+	CreatedAt     time.Time     `json:"createdAt"`
+	Strategy      string        `json:"strategy"`
+	QuarantineDir string        `json:"quarantineDir,omitempty"`
+	Records       int           `json:"records"`
+	Files         []PlannedFile `json:"files"`
+	Sets          []Decision    `json:"sets"`
+}
+
+// PlannedFile lists the lines to delete from one file, with the size and
+// modification time the file had when the plan was made. Generation is the
+// object generation of GCS objects, and Quarantine the file the lines are
+// moved to when the purge quarantines them.
+type PlannedFile struct {
+	Path        string    `json:"path"`
+	SizeBytes   int64     `json:"sizeBytes"`
+	ModTime     time.Time `json:"modTime"`
+	Generation  int64     `json:"generation,omitempty"`
+	Quarantine  string    `json:"quarantine,omitempty"`
+	DeleteLines []int     `json:"deleteLines"`
+}
+
+// NewSavedPlan records decisions made under strategy, noting the current
+// state of every file they change and the quarantine of opts.
+func NewSavedPlan(ctx context.Context, strategy string, decisions []Decision, opts Options) (*SavedPlan, error) {
+	plan := NewPlan(decisions)
+	saved := &SavedPlan{
+		Version:       PlanVersion,
+		CreatedAt:     time.Now(),
+		Strategy:      strategy,
+		QuarantineDir: opts.QuarantineDir,
+		Records:       plan.Records(),
+		Files:         make([]PlannedFile, 0, len(plan)),
+		Sets:          decisions,
+	}
+	gcs := &gcsClient{}
+	defer gcs.Close()
+	for path, lines := range plan {
+		info, err := statFile(ctx, gcs, path)
+		if err != nil {
+			return nil, err
+		}
+		f := PlannedFile{Path: path, SizeBytes: info.Size, ModTime: info.ModTime, Generation: info.Generation, Quarantine: opts.Quarantine[path]}
+		for line := range lines {
+			f.DeleteLines = append(f.DeleteLines, line)
+		}
+		slices.Sort(f.DeleteLines)
+		saved.Files = append(saved.Files, f)
+	}
+	slices.SortFunc(saved.Files, func(a, b PlannedFile) int { return cmp.Compare(a.Path, b.Path) })
+	return saved, nil
+}
+
+// Save writes the plan to path as indented JSON.
+func (p *SavedPlan) Save(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal purge plan: %w", err)
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// LoadPlan reads a plan written by Save.
+func LoadPlan(path string) (*SavedPlan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &SavedPlan{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("could not read purge plan %s: %w", path, err)
+	}
+	if p.Version != PlanVersion {
+		return nil, fmt.Errorf("purge plan %s has version %d; this build applies version %d", path, p.Version, PlanVersion)
+	}
+	return p, nil
+}
+
+// Check returns an error if any file in the plan has changed since it was
+// made, as its line numbers may then point at different records.
+func (p *SavedPlan) Check(ctx context.Context) error {
+	gcs := &gcsClient{}
+	defer gcs.Close()
+	for _, f := range p.Files {
+		info, err := statFile(ctx, gcs, f.Path)
+		if err != nil {
+			return err
+		}
+		if info.Size != f.SizeBytes || !info.ModTime.Equal(f.ModTime) || info.Generation != f.Generation {
+			return fmt.Errorf("%s has changed since the plan was made; analyse it again and make a new plan", f.Path)
+		}
+	}
+	return nil
+}
+
+// Quarantine returns the quarantine folder of the plan and the quarantine
+// file of each of its files, for Options.
+func (p *SavedPlan) Quarantine() (string, map[string]string) {
+	if p.QuarantineDir == "" {
+		return "", nil
+	}
+	files := make(map[string]string, len(p.Files))
+	for _, f := range p.Files {
+		files[f.Path] = f.Quarantine
+	}
+	return p.QuarantineDir, files
+}
+
+// Plan returns the records the saved plan deletes.
+func (p *SavedPlan) Plan() Plan {
+	plan := make(Plan)
+	for _, f := range p.Files {
+		for _, line := range f.DeleteLines {
+			plan.Delete(report.LocationInfo{FilePath: f.Path, LineNumber: line})
+		}
+	}
+	return plan
+}
@@ -0,0 +1,449 @@
+// internal/purge/purge.go
+package purge
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+)
+
+// BackupDir is the default directory, relative to the working directory,
+// that every purged record is copied to before it is removed. Each purge
+// backs up to its own timestamped folder inside it.
+const BackupDir = "deleted_records"
+
+// maxLineSize is the longest line a purge can rewrite.
+const maxLineSize = 1 << 30
+
+// Plan holds the records to delete as line numbers per file.
+type Plan map[string]map[int]bool
+
+// Delete adds the record at loc to the plan.
+func (p Plan) Delete(loc report.LocationInfo) {
+	if _, ok := p[loc.FilePath]; !ok {
+		p[loc.FilePath] = make(map[int]bool)
+	}
+	p[loc.FilePath][loc.LineNumber] = true
+}
+
+// Keep adds every location of a duplicate set but locations[keep] to the plan.
+func (p Plan) Keep(locations []report.LocationInfo, keep int) {
+	for i, loc := range locations {
+		if i != keep {
+			p.Delete(loc)
+		}
+	}
+}
+
+// Records returns the number of records the plan deletes.
+func (p Plan) Records() int {
+	n := 0
+	for _, lines := range p {
+		n += len(lines)
+	}
+	return n
+}
+
+// Result counts what Apply changed.
+type Result struct {
+	FilesModified  int
+	RecordsDeleted int
+	// FilesFailed counts the files that could not be rewritten; the reasons
+	// are logged.
+	FilesFailed int
+	// Manifest is the path of the manifest to restore the records from, or
+	// empty if nothing was deleted.
+	Manifest string
+	// Backups lists the folders the records were backed up to.
+	Backups []string
+}
+
+// Options configures where Apply writes backups.
+type Options struct {
+	// BackupDir is the directory the timestamped backup folder of each purge
+	// is created in, BackupDir when empty. The manifest is always saved
+	// there.
+	BackupDir string
+	// GCSBackup, when set, is the gs://bucket/prefix/ folder that records
+	// removed from GCS objects are backed up to, again in a timestamped
+	// folder. Without it they are backed up locally like those of local
+	// files.
+	GCSBackup string
+	// Key is the unique key field, whose value is noted in the manifest for
+	// each record removed.
+	Key string
+	// Quarantine maps files to the quarantine file their removed records are
+	// appended to instead of being backed up, as made by MirrorPaths for
+	// QuarantineDir. The manifest still records them, so Restore can put
+	// them back.
+	Quarantine    map[string]string
+	QuarantineDir string
+	// AuditLogPath, when set, is the log path whose audit log records the
+	// purge, and Strategy is the keep strategy it notes.
+	AuditLogPath string
+	Strategy     string
+}
+
+// Apply removes the planned records from their files and GCS objects,
+// backing them up first and recording where each came from in a Manifest
+// saved to BackupDir, which Restore uses to put them back. A file that cannot
+// be read or rewritten is logged and skipped.
+func Apply(ctx context.Context, plan Plan, opts Options) (Result, error) {
+	if opts.BackupDir == "" {
+		opts.BackupDir = BackupDir
+	}
+	now := time.Now()
+	a := &applier{ctx: ctx, gcs: &gcsClient{}, opts: opts, stamp: now.Format("2006-01-02_15-04-05"), names: make(map[string]int)}
+	defer a.gcs.Close()
+	var result Result
+	manifest := &Manifest{Version: ManifestVersion, CreatedAt: now, KeyField: opts.Key}
+	for _, filePath := range slices.Sorted(maps.Keys(plan)) {
+		records, err := a.purge(filePath, plan[filePath])
+		if err != nil {
+			slog.Error("Purge: could not rewrite file", "path", filePath, "error", err)
+			result.FilesFailed++
+			continue
+		}
+		result.FilesModified++
+		result.RecordsDeleted += len(records)
+		manifest.Records = append(manifest.Records, records...)
+	}
+	var err error
+	if len(manifest.Records) > 0 {
+		result.Backups = a.folders()
+		result.Manifest, err = a.saveManifest(manifest)
+	}
+	if len(plan) > 0 {
+		action := AuditPurge
+		if opts.QuarantineDir != "" {
+			action = AuditQuarantine
+		}
+		if aerr := audit(opts.AuditLogPath, action, opts.Strategy, result.Manifest, manifest.Records, result.FilesFailed); err == nil {
+			err = aerr
+		}
+	}
+	return result, err
+}
+
+// saveManifest saves the manifest to the local backup folder and returns its
+// path.
+func (a *applier) saveManifest(manifest *Manifest) (string, error) {
+	runDir, err := a.localDir()
+	if err != nil {
+		return "", fmt.Errorf("records were purged but the restore manifest could not be written: %w", err)
+	}
+	manifestPath := filepath.Join(runDir, ManifestName)
+	if err := manifest.save(manifestPath); err != nil {
+		return "", fmt.Errorf("records were purged but the restore manifest could not be written: %w", err)
+	}
+	return manifestPath, nil
+}
+
+// applier holds the state of one Apply.
+type applier struct {
+	ctx  context.Context
+	gcs  *gcsClient
+	opts Options
+	// stamp names the backup folder of this purge.
+	stamp string
+	// runDir is the local backup folder once it has been created, and
+	// gcsUsed is set once a record has been backed up to Options.GCSBackup.
+	runDir  string
+	gcsUsed bool
+	// quarantined is set once a record has been moved to a quarantine file.
+	quarantined bool
+	// names counts the backups created under each name, so files with the
+	// same base name in different folders get their own backup.
+	names map[string]int
+}
+
+// purge rewrites one file or GCS object without the given lines, backing
+// them up first, and returns the manifest records of the removed lines.
+func (a *applier) purge(filePath string, lines map[int]bool) ([]ManifestRecord, error) {
+	var backup io.WriteCloser
+	var backupPath string
+	var backupOffset int
+	var removed []removedLine
+	filter := func(r io.Reader, w io.Writer) error {
+		var err error
+		removed, err = filterLines(r, w, lines, func() (io.Writer, error) {
+			var err error
+			backup, backupPath, backupOffset, err = a.openBackup(filePath)
+			return backup, err
+		})
+		if backup != nil {
+			if cerr := backup.Close(); err == nil && cerr != nil {
+				err = fmt.Errorf("could not write backup: %w", cerr)
+			}
+		}
+		return err
+	}
+	if err := rewrite(a.ctx, a.gcs, filePath, filter); err != nil {
+		return nil, err
+	}
+	records := make([]ManifestRecord, len(removed))
+	for i, rec := range removed {
+		records[i] = ManifestRecord{
+			File:       filePath,
+			Line:       rec.line,
+			Key:        keyValue(rec.text, a.opts.Key),
+			Hash:       lineHash(rec.text),
+			Backup:     backupPath,
+			BackupLine: backupOffset + i + 1,
+			EOL:        rec.eol,
+		}
+	}
+	return records, nil
+}
+
+// localDir returns the local backup folder of this purge, creating it on
+// first use. A folder left by a purge in the same second gets a suffix.
+func (a *applier) localDir() (string, error) {
+	if a.runDir != "" {
+		return a.runDir, nil
+	}
+	if err := os.MkdirAll(a.opts.BackupDir, 0755); err != nil {
+		return "", fmt.Errorf("could not create backup dir: %w", err)
+	}
+	base, err := filepath.Abs(filepath.Join(a.opts.BackupDir, a.stamp))
+	if err != nil {
+		return "", err
+	}
+	dir := base
+	for n := 2; ; n++ {
+		err := os.Mkdir(dir, 0755)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return "", fmt.Errorf("could not create backup dir: %w", err)
+		}
+		dir = fmt.Sprintf("%s_%d", base, n)
+	}
+	a.runDir = dir
+	return dir, nil
+}
+
+// gcsDir returns the GCS backup folder of this purge.
+func (a *applier) gcsDir() string {
+	return strings.TrimSuffix(a.opts.GCSBackup, "/") + "/" + a.stamp + "/"
+}
+
+// folders lists the backup folders this purge wrote to.
+func (a *applier) folders() []string {
+	var folders []string
+	if a.runDir != "" {
+		folders = append(folders, a.runDir)
+	}
+	if a.gcsUsed {
+		folders = append(folders, a.gcsDir())
+	}
+	if a.quarantined {
+		folders = append(folders, a.opts.QuarantineDir)
+	}
+	return folders
+}
+
+// openBackup creates the backup for the lines removed from filePath,
+// returning it with its path and the number of lines already in it. Records
+// are appended to the file's quarantine file when it has one. Otherwise
+// records of GCS objects are backed up under Options.GCSBackup when it is
+// set, and everything else goes to the local backup folder.
+func (a *applier) openBackup(filePath string) (io.WriteCloser, string, int, error) {
+	if dest, ok := a.opts.Quarantine[filePath]; ok {
+		a.quarantined = true
+		w, lines, err := openAppend(a.ctx, a.gcs, dest)
+		return w, dest, lines, err
+	}
+	name := path.Base(filepath.ToSlash(filePath))
+	a.names[name]++
+	if n := a.names[name]; n > 1 {
+		ext := path.Ext(name)
+		name = fmt.Sprintf("%s_%d%s", strings.TrimSuffix(name, ext), n, ext)
+	}
+	if _, _, ok := splitGCSPath(filePath); ok && a.opts.GCSBackup != "" {
+		a.gcsUsed = true
+		w, backupPath, err := createGCSBackup(a.ctx, a.gcs, a.gcsDir(), name)
+		return w, backupPath, 0, err
+	}
+	dir, err := a.localDir()
+	if err != nil {
+		return nil, "", 0, err
+	}
+	backupPath := filepath.Join(dir, name)
+	f, err := os.Create(backupPath)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	return syncedFile{f}, backupPath, 0, nil
+}
+
+// rewrite replaces the content of a local file or GCS object with what
+// transform writes when given its current content.
+func rewrite(ctx context.Context, gcs *gcsClient, p string, transform func(r io.Reader, w io.Writer) error) error {
+	if _, _, ok := splitGCSPath(p); ok {
+		return rewriteObject(ctx, gcs, p, transform)
+	}
+	return rewriteFile(p, transform)
+}
+
+// rewriteFile replaces the content of a local file atomically. The new
+// content is written to a temporary file in the same directory, synced to
+// disk, given the mode and owner of the original and renamed over it, so a
+// crash leaves either the old file or the new one. A symlink is followed and
+// its target rewritten.
+func rewriteFile(filePath string, transform func(r io.Reader, w io.Writer) error) error {
+	target, err := filepath.EvalSymlinks(filePath)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(target)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(target)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(target)+".purge-*.tmp")
+	if err != nil {
+		return fmt.Errorf("could not create temporary file: %w", err)
+	}
+	renamed := false
+	defer func() {
+		if !renamed {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	w := bufio.NewWriterSize(tmp, 1<<20)
+	if err := transform(file, w); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("could not write temporary file: %w", err)
+	}
+	if err := tmp.Chmod(info.Mode()); err != nil {
+		return fmt.Errorf("could not keep the file mode: %w", err)
+	}
+	if err := keepOwner(tmp, info); err != nil {
+		return fmt.Errorf("could not keep the file owner: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("could not sync temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write temporary file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return fmt.Errorf("could not replace original file: %w", err)
+	}
+	renamed = true
+	syncDir(dir)
+	return nil
+}
+
+// syncDir makes a rename in dir durable where the platform allows it.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
+
+// syncedFile is a backup file that is synced to disk as it is closed, so the
+// backup is durable before the original is replaced.
+type syncedFile struct {
+	*os.File
+}
+
+func (f syncedFile) Close() error {
+	if err := f.Sync(); err != nil {
+		f.File.Close()
+		return err
+	}
+	return f.File.Close()
+}
+
+// openFile opens a local file or GCS object for reading.
+func openFile(ctx context.Context, gcs *gcsClient, p string) (io.ReadCloser, error) {
+	bucket, object, ok := splitGCSPath(p)
+	if !ok {
+		return os.Open(p)
+	}
+	client, err := gcs.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.Bucket(bucket).Object(object).NewReader(ctx)
+}
+
+// removedLine is a line filterLines removed, with its ending.
+type removedLine struct {
+	line int
+	text string
+	eol  string
+}
+
+// filterLines copies the lines of r to kept, except the numbered lines,
+// which go to the writer returned by backup on first use. Kept lines keep
+// their line endings, and kept ends with a newline only if r does. Backed-up
+// lines end in "\n". It returns the lines removed, in order.
+func filterLines(r io.Reader, kept io.Writer, lines map[int]bool, backup func() (io.Writer, error)) ([]removedLine, error) {
+	var removed io.Writer
+	var deleted []removedLine
+	lr := newLineReader(r)
+	lw := &lineWriter{w: kept}
+	lineNumber := 0
+	for lr.next() {
+		lineNumber++
+		if !lines[lineNumber] {
+			if err := lw.write(lr.raw()); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if removed == nil {
+			var err error
+			if removed, err = backup(); err != nil {
+				return nil, fmt.Errorf("could not write backup: %w", err)
+			}
+		}
+		deleted = append(deleted, removedLine{line: lineNumber, text: lr.text(), eol: lr.eol()})
+		if _, err := io.WriteString(removed, lr.text()+"\n"); err != nil {
+			return nil, err
+		}
+	}
+	if lr.err != nil {
+		return nil, fmt.Errorf("could not scan file: %w", lr.err)
+	}
+	if err := lw.close(lr.finalNewline); err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// statLocal returns the state of a local file.
+func statLocal(p string) (fileState, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return fileState{}, err
+	}
+	return fileState{Size: info.Size(), ModTime: info.ModTime()}, nil
+}
@@ -0,0 +1,165 @@
+// internal/purge/purge_test.go
+package purge
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFilterLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		delete  []int
+		kept    string
+		backup  string
+		removed []removedLine
+	}{
+		{
+			name:    "lf",
+			input:   "a\nb\nc\n",
+			delete:  []int{2},
+			kept:    "a\nc\n",
+			backup:  "b\n",
+			removed: []removedLine{{line: 2, text: "b", eol: "\n"}},
+		},
+		{
+			name:    "crlf",
+			input:   "a\r\nb\r\nc\r\n",
+			delete:  []int{1},
+			kept:    "b\r\nc\r\n",
+			backup:  "a\n",
+			removed: []removedLine{{line: 1, text: "a", eol: "\r\n"}},
+		},
+		{
+			name:    "mixed endings",
+			input:   "a\r\nb\nc\r\nd\n",
+			delete:  []int{2, 3},
+			kept:    "a\r\nd\n",
+			backup:  "b\nc\n",
+			removed: []removedLine{{line: 2, text: "b", eol: "\n"}, {line: 3, text: "c", eol: "\r\n"}},
+		},
+		{
+			name:    "no final newline",
+			input:   "a\nb\nc",
+			delete:  []int{1},
+			kept:    "b\nc",
+			backup:  "a\n",
+			removed: []removedLine{{line: 1, text: "a", eol: "\n"}},
+		},
+		{
+			name:    "last line",
+			input:   "a\nb\nc\n",
+			delete:  []int{3},
+			kept:    "a\nb\n",
+			backup:  "c\n",
+			removed: []removedLine{{line: 3, text: "c", eol: "\n"}},
+		},
+		{
+			name:    "last line without final newline",
+			input:   "a\r\nb\r\nc",
+			delete:  []int{3},
+			kept:    "a\r\nb",
+			backup:  "c\n",
+			removed: []removedLine{{line: 3, text: "c", eol: ""}},
+		},
+		{
+			name:    "every line",
+			input:   "a\nb\n",
+			delete:  []int{1, 2},
+			kept:    "",
+			backup:  "a\nb\n",
+			removed: []removedLine{{line: 1, text: "a", eol: "\n"}, {line: 2, text: "b", eol: "\n"}},
+		},
+		{
+			name:   "nothing",
+			input:  "a\r\nb",
+			delete: nil,
+			kept:   "a\r\nb",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := make(map[int]bool)
+			for _, line := range tt.delete {
+				lines[line] = true
+			}
+			var kept, backup bytes.Buffer
+			removed, err := filterLines(strings.NewReader(tt.input), &kept, lines, func() (io.Writer, error) {
+				return &backup, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := kept.String(); got != tt.kept {
+				t.Errorf("kept %q, want %q", got, tt.kept)
+			}
+			if got := backup.String(); got != tt.backup {
+				t.Errorf("backed up %q, want %q", got, tt.backup)
+			}
+			if len(removed) != len(tt.removed) {
+				t.Fatalf("removed %v, want %v", removed, tt.removed)
+			}
+			for i := range removed {
+				if removed[i] != tt.removed[i] {
+					t.Errorf("removed line %d is %+v, want %+v", i, removed[i], tt.removed[i])
+				}
+			}
+		})
+	}
+}
+
+func TestApplyKeepsBytes(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		delete []int
+		want   string
+	}{
+		{"lf", "a\nb\nc\n", []int{2}, "a\nc\n"},
+		{"crlf", "a\r\nb\r\nc\r\n", []int{2}, "a\r\nc\r\n"},
+		{"mixed endings", "a\nb\r\nc\n", []int{1}, "b\r\nc\n"},
+		{"no final newline", "a\r\nb\r\nc", []int{2}, "a\r\nc"},
+		{"last line", "a\r\nb\r\nc\r\n", []int{3}, "a\r\nb\r\n"},
+		{"last line without final newline", "a\nb\nc", []int{3}, "a\nb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			p := filepath.Join(dir, "data.json")
+			if err := os.WriteFile(p, []byte(tt.input), 0640); err != nil {
+				t.Fatal(err)
+			}
+			plan := Plan{p: make(map[int]bool)}
+			for _, line := range tt.delete {
+				plan[p][line] = true
+			}
+			result, err := Apply(context.Background(), plan, Options{BackupDir: filepath.Join(dir, "backups")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.FilesModified != 1 || result.RecordsDeleted != len(tt.delete) {
+				t.Fatalf("modified %d files and deleted %d records, want 1 and %d", result.FilesModified, result.RecordsDeleted, len(tt.delete))
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, []byte(tt.want)) {
+				t.Errorf("purged file is %q, want %q", data, tt.want)
+			}
+			info, err := os.Stat(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0640 {
+				t.Errorf("purged file has mode %v, want 0640", info.Mode().Perm())
+			}
+		})
+	}
+}
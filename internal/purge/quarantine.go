@@ -0,0 +1,135 @@
+// internal/purge/quarantine.go
+package purge
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// openAppend opens a local file or GCS object for appending records,
+// creating it if needed, and returns the number of lines already in it. A
+// last line without a newline is ended first.
+func openAppend(ctx context.Context, gcs *gcsClient, p string) (io.WriteCloser, int, error) {
+	bucketName, objectName, ok := splitGCSPath(p)
+	if !ok {
+		return appendFile(p)
+	}
+	client, err := gcs.get(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	bucket := client.Bucket(bucketName)
+	dest := bucket.Object(objectName)
+	var generation int64
+	lines, complete := 0, true
+	attrs, err := dest.Attrs(ctx)
+	switch {
+	case errors.Is(err, storage.ErrObjectNotExist):
+	case err != nil:
+		return nil, 0, err
+	default:
+		generation = attrs.Generation
+		r, err := dest.Generation(generation).NewReader(ctx)
+		if err != nil {
+			return nil, 0, err
+		}
+		lines, complete, err = countLines(r)
+		r.Close()
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	tmp := bucket.Object(fmt.Sprintf("%s.append-%d.tmp", objectName, time.Now().UnixNano()))
+	w := tmp.NewWriter(ctx)
+	w.ContentType = "application/x-ndjson"
+	a := &objectAppender{ctx: ctx, Writer: w, dest: dest, tmp: tmp, generation: generation}
+	if !complete {
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return nil, 0, err
+		}
+		lines++
+	}
+	return a, lines, nil
+}
+
+// appendFile opens a local file for appending.
+func appendFile(p string) (io.WriteCloser, int, error) {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return nil, 0, err
+	}
+	f, err := os.OpenFile(p, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, 0, err
+	}
+	lines, complete, err := countLines(f)
+	if err == nil && !complete {
+		_, err = io.WriteString(f, "\n")
+		lines++
+	}
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return syncedFile{f}, lines, nil
+}
+
+// countLines counts the lines in r, and reports whether the last one ends in
+// a newline. An empty reader has no lines and is complete.
+func countLines(r io.Reader) (int, bool, error) {
+	buf := make([]byte, 64*1024)
+	lines, last := 0, byte('\n')
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			lines += bytes.Count(buf[:n], []byte{'\n'})
+			last = buf[n-1]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, false, err
+		}
+	}
+	if last != '\n' {
+		return lines + 1, false, nil
+	}
+	return lines, true, nil
+}
+
+// objectAppender writes the appended records to a temporary object, which
+// Close composes onto the end of the destination object, or copies to it if
+// there was none. The destination must not have changed in the meantime.
+type objectAppender struct {
+	ctx context.Context
+	*storage.Writer
+	dest, tmp  *storage.ObjectHandle
+	generation int64
+}
+
+func (a *objectAppender) Close() error {
+	if err := a.Writer.Close(); err != nil {
+		return err
+	}
+	defer a.tmp.Delete(a.ctx)
+	var err error
+	if a.generation == 0 {
+		_, err = a.dest.If(storage.Conditions{DoesNotExist: true}).CopierFrom(a.tmp).Run(a.ctx)
+	} else {
+		composer := a.dest.If(storage.Conditions{GenerationMatch: a.generation}).ComposerFrom(a.dest, a.tmp)
+		composer.ContentType = "application/x-ndjson"
+		_, err = composer.Run(a.ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("could not append to %s, it may have changed since it was read: %w", a.dest.ObjectName(), err)
+	}
+	return nil
+}
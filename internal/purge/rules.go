@@ -0,0 +1,150 @@
+// internal/purge/rules.go
+package purge
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Keep rules choose the record of each duplicate set to keep by its content.
+// KeepNewestPrefix and KeepOldestPrefix are followed by a top-level field,
+// as in keep-newest:updated_at, whose values are compared as timestamps,
+// numbers or strings. KeepFewestNulls keeps the record with the fewest
+// top-level fields set to null.
+const (
+	KeepNewestPrefix = "keep-newest:"
+	KeepOldestPrefix = "keep-oldest:"
+	KeepFewestNulls  = "keep-fewest-nulls"
+)
+
+// keepRule is a parsed keep rule.
+type keepRule struct {
+	field  string
+	newest bool
+}
+
+// parseRule returns the keep rule of strategy. ok is false for strategies
+// that are not rules.
+func parseRule(strategy string) (rule keepRule, ok bool) {
+	if strategy == KeepFewestNulls {
+		return keepRule{}, true
+	}
+	if field, found := strings.CutPrefix(strategy, KeepNewestPrefix); found && field != "" {
+		return keepRule{field: field, newest: true}, true
+	}
+	if field, found := strings.CutPrefix(strategy, KeepOldestPrefix); found && field != "" {
+		return keepRule{field: field}, true
+	}
+	return keepRule{}, false
+}
+
+// ruleScore is what a keep rule notes about one record. ok is false when the
+// record is not a JSON object or lacks the rule's field, so it never wins.
+type ruleScore struct {
+	ok    bool
+	value any
+	nulls int
+}
+
+// score reads the parts of a record that the rule compares.
+func (r keepRule) score(line string) ruleScore {
+	var data map[string]any
+	if json.Unmarshal([]byte(line), &data) != nil {
+		return ruleScore{}
+	}
+	if r.field == "" {
+		nulls := 0
+		for _, v := range data {
+			if v == nil {
+				nulls++
+			}
+		}
+		return ruleScore{ok: true, nulls: nulls}
+	}
+	v := data[r.field]
+	if v == nil {
+		return ruleScore{}
+	}
+	return ruleScore{ok: true, value: v}
+}
+
+// better reports whether a record scoring a should be kept over one scoring b.
+func (r keepRule) better(a, b ruleScore) bool {
+	if !a.ok || !b.ok {
+		return a.ok && !b.ok
+	}
+	if r.field == "" {
+		return a.nulls < b.nulls
+	}
+	c := compareValues(a.value, b.value)
+	if r.newest {
+		return c > 0
+	}
+	return c < 0
+}
+
+// timeLayouts are the timestamp formats keep rules recognise in strings.
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", time.DateTime, time.DateOnly}
+
+func parseTime(s string) (time.Time, bool) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// compareValues orders two field values: as timestamps when both are strings
+// in a recognised format, as numbers when both are numbers, and otherwise by
+// their text.
+func compareValues(a, b any) int {
+	if x, ok := a.(float64); ok {
+		if y, ok := b.(float64); ok {
+			return cmp.Compare(x, y)
+		}
+	}
+	if x, ok := a.(string); ok {
+		if y, ok := b.(string); ok {
+			if tx, ok := parseTime(x); ok {
+				if ty, ok := parseTime(y); ok {
+					return tx.Compare(ty)
+				}
+			}
+			return strings.Compare(x, y)
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// scoreRecords reads the given lines of each file once and scores them under
+// the rule.
+func (r keepRule) scoreRecords(ctx context.Context, gcs *gcsClient, lines map[string]map[int]bool) (map[string]map[int]ruleScore, error) {
+	scores := make(map[string]map[int]ruleScore, len(lines))
+	for file, wanted := range lines {
+		f, err := openFile(ctx, gcs, file)
+		if err != nil {
+			return nil, err
+		}
+		fileScores := make(map[int]ruleScore, len(wanted))
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+		for n := 1; scanner.Scan() && len(fileScores) < len(wanted); n++ {
+			if wanted[n] {
+				fileScores[n] = r.score(scanner.Text())
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %w", file, err)
+		}
+		scores[file] = fileScores
+	}
+	return scores, nil
+}
@@ -0,0 +1,185 @@
+// internal/purge/strategy.go
+package purge
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+)
+
+// Keep strategies choose the record of each duplicate set that survives an
+// automatic purge. Locations are in report order, by file path then line.
+// The keep rules of rules.go are strategies too.
+const (
+	KeepFirst       = "keep-first"
+	KeepLast        = "keep-last"
+	KeepLargestFile = "keep-largest-file"
+	KeepNewestFile  = "keep-newest-file"
+)
+
+// ValidStrategy reports whether s is a keep strategy.
+func ValidStrategy(s string) bool {
+	switch s {
+	case KeepFirst, KeepLast, KeepLargestFile, KeepNewestFile:
+		return true
+	}
+	_, ok := parseRule(s)
+	return ok
+}
+
+// chooser picks the location to keep under a strategy, reading the size and
+// modification time of each file or GCS object once. Under a keep rule it
+// uses the scores of the records instead.
+type chooser struct {
+	ctx      context.Context
+	gcs      *gcsClient
+	strategy string
+	files    map[string]fileState
+	rule     *keepRule
+	scores   map[string]map[int]ruleScore
+}
+
+// newChooser returns a chooser for the duplicate sets, scoring their records
+// up front when strategy is a keep rule.
+func newChooser(ctx context.Context, gcs *gcsClient, strategy string, sets [][]report.LocationInfo) (*chooser, error) {
+	if !ValidStrategy(strategy) {
+		return nil, fmt.Errorf("invalid keep strategy %q", strategy)
+	}
+	c := &chooser{ctx: ctx, gcs: gcs, strategy: strategy, files: make(map[string]fileState)}
+	rule, ok := parseRule(strategy)
+	if !ok {
+		return c, nil
+	}
+	lines := make(Plan)
+	for _, locations := range sets {
+		for _, loc := range locations {
+			lines.Delete(loc)
+		}
+	}
+	scores, err := rule.scoreRecords(ctx, gcs, lines)
+	if err != nil {
+		return nil, err
+	}
+	c.rule, c.scores = &rule, scores
+	return c, nil
+}
+
+func (c *chooser) stat(path string) (fileState, error) {
+	if info, ok := c.files[path]; ok {
+		return info, nil
+	}
+	info, err := statFile(c.ctx, c.gcs, path)
+	if err != nil {
+		return fileState{}, err
+	}
+	c.files[path] = info
+	return info, nil
+}
+
+// choose returns the index of the location to keep. Ties between records in
+// the same file, or files of equal size or age, go to the first location.
+func (c *chooser) choose(locations []report.LocationInfo) (int, error) {
+	switch c.strategy {
+	case KeepFirst:
+		return 0, nil
+	case KeepLast:
+		return len(locations) - 1, nil
+	}
+	keep := 0
+	if c.rule != nil {
+		for i, loc := range locations {
+			if c.rule.better(c.scores[loc.FilePath][loc.LineNumber], c.scores[locations[keep].FilePath][locations[keep].LineNumber]) {
+				keep = i
+			}
+		}
+		return keep, nil
+	}
+	var best fileState
+	for i, loc := range locations {
+		info, err := c.stat(loc.FilePath)
+		if err != nil {
+			return 0, err
+		}
+		if i == 0 ||
+			(c.strategy == KeepLargestFile && info.Size > best.Size) ||
+			(c.strategy == KeepNewestFile && info.ModTime.After(best.ModTime)) {
+			keep, best = i, info
+		}
+	}
+	return keep, nil
+}
+
+// Decision records the record kept from one duplicate set and the records
+// deleted. Type is "id" or "row" and Value is the duplicated key value or
+// row hash, as in the report.
+type Decision struct {
+	Type   string                `json:"type"`
+	Scope  string                `json:"scope,omitempty"`
+	Value  string                `json:"value"`
+	Keep   report.LocationInfo   `json:"keep"`
+	Delete []report.LocationInfo `json:"delete"`
+}
+
+// Decide resolves every duplicate set of rep under strategy, covering the
+// primary key's duplicate sets when ids is set and the duplicate row sets
+// when rows is set, including those of each scope. Only complete reports can
+// be purged.
+func Decide(ctx context.Context, rep *report.AnalysisReport, strategy string, ids, rows bool) ([]Decision, error) {
+	if !ValidStrategy(strategy) {
+		return nil, fmt.Errorf("invalid keep strategy %q", strategy)
+	}
+	if rep.Summary.IsPartialReport {
+		return nil, fmt.Errorf("the report is incomplete, so it may not list every duplicate")
+	}
+	var decisions []Decision
+	var sets [][]report.LocationInfo
+	rep.EachDuplicateSet(ids, rows, func(kind, key, scope, value string, locations []report.LocationInfo) {
+		if kind == "id" && key != rep.Summary.UniqueKey {
+			return
+		}
+		decisions = append(decisions, Decision{Type: kind, Scope: scope, Value: value})
+		sets = append(sets, locations)
+	})
+	keeps, err := Preselect(ctx, strategy, sets)
+	if err != nil {
+		return nil, err
+	}
+	for i, keep := range keeps {
+		locations := sets[i]
+		decisions[i].Keep = locations[keep]
+		decisions[i].Delete = append(slices.Clone(locations[:keep]), locations[keep+1:]...)
+	}
+	return decisions, nil
+}
+
+// Preselect returns the index of the location that strategy keeps in each of
+// the duplicate sets. The interactive purge uses it to pick the record each
+// set starts on.
+func Preselect(ctx context.Context, strategy string, sets [][]report.LocationInfo) ([]int, error) {
+	gcs := &gcsClient{}
+	defer gcs.Close()
+	c, err := newChooser(ctx, gcs, strategy, sets)
+	if err != nil {
+		return nil, err
+	}
+	keeps := make([]int, len(sets))
+	for i, locations := range sets {
+		if keeps[i], err = c.choose(locations); err != nil {
+			return nil, err
+		}
+	}
+	return keeps, nil
+}
+
+// NewPlan returns the plan that carries out decisions.
+func NewPlan(decisions []Decision) Plan {
+	plan := make(Plan)
+	for _, d := range decisions {
+		for _, loc := range d.Delete {
+			plan.Delete(loc)
+		}
+	}
+	return plan
+}
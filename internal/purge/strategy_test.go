@@ -0,0 +1,131 @@
+// internal/purge/strategy_test.go
+package purge
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+)
+
+// writeDatedFile writes content to name in dir with the given modification
+// time and returns its path.
+func writeDatedFile(t *testing.T, dir, name, content string, modTime time.Time) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(p, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestDecide(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().Truncate(time.Second)
+	// a.json is the largest and oldest, b.json the smallest and newest.
+	a := writeDatedFile(t, dir, "a.json", `{"id":1,"pad":"xxxxxxxxxxxxxxxx"}`+"\n"+`{"id":1,"pad":"xxxxxxxxxxxxxxxx"}`+"\n", now.Add(-2*time.Hour))
+	b := writeDatedFile(t, dir, "b.json", `{"id":1}`+"\n", now)
+	c := writeDatedFile(t, dir, "c.json", `{"id":1,"pad":"x"}`+"\n", now.Add(-time.Hour))
+	locations := []report.LocationInfo{
+		{FilePath: a, LineNumber: 1},
+		{FilePath: a, LineNumber: 2},
+		{FilePath: b, LineNumber: 1},
+		{FilePath: c, LineNumber: 1},
+	}
+	rep := &report.AnalysisReport{
+		Summary:      report.SummaryReport{UniqueKey: "id"},
+		DuplicateIDs: map[string][]report.LocationInfo{"1": locations},
+	}
+
+	tests := []struct {
+		strategy string
+		keep     report.LocationInfo
+	}{
+		{KeepFirst, locations[0]},
+		{KeepLast, locations[3]},
+		// Ties between records of the same file go to the first.
+		{KeepLargestFile, locations[0]},
+		{KeepNewestFile, locations[2]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			decisions, err := Decide(context.Background(), rep, tt.strategy, true, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(decisions) != 1 {
+				t.Fatalf("got %d decisions, want 1", len(decisions))
+			}
+			d := decisions[0]
+			if d.Type != "id" || d.Value != "1" {
+				t.Errorf("decision is for %s %q, want id \"1\"", d.Type, d.Value)
+			}
+			if d.Keep != tt.keep {
+				t.Errorf("kept %v, want %v", d.Keep, tt.keep)
+			}
+			if len(d.Delete) != len(locations)-1 {
+				t.Fatalf("deletes %d records, want %d", len(d.Delete), len(locations)-1)
+			}
+			for _, loc := range d.Delete {
+				if loc == tt.keep {
+					t.Errorf("deletes the kept record %v", loc)
+				}
+			}
+		})
+	}
+}
+
+func TestDecideRefusesPartialReport(t *testing.T) {
+	rep := &report.AnalysisReport{
+		Summary: report.SummaryReport{UniqueKey: "id", IsPartialReport: true},
+		DuplicateIDs: map[string][]report.LocationInfo{
+			"1": {{FilePath: "a.json", LineNumber: 1}, {FilePath: "a.json", LineNumber: 2}},
+		},
+	}
+	decisions, err := Decide(context.Background(), rep, KeepFirst, true, true)
+	if err == nil {
+		t.Fatalf("Decide on a partial report returned %d decisions and no error", len(decisions))
+	}
+	if !strings.Contains(err.Error(), "incomplete") {
+		t.Errorf("error %q does not say the report is incomplete", err)
+	}
+}
+
+func TestDecideRefusesInvalidStrategy(t *testing.T) {
+	rep := &report.AnalysisReport{Summary: report.SummaryReport{UniqueKey: "id"}}
+	if _, err := Decide(context.Background(), rep, "keep-middle", true, true); err == nil {
+		t.Fatal("Decide accepted an unknown keep strategy")
+	}
+}
+
+func TestSavedPlanCheckRefusesStaleFile(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	p := writeDatedFile(t, dir, "a.json", `{"id":1}`+"\n"+`{"id":1}`+"\n", modTime)
+	decisions := []Decision{{
+		Type:   "id",
+		Value:  "1",
+		Keep:   report.LocationInfo{FilePath: p, LineNumber: 1},
+		Delete: []report.LocationInfo{{FilePath: p, LineNumber: 2}},
+	}}
+	ctx := context.Background()
+	saved, err := NewSavedPlan(ctx, KeepFirst, decisions, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := saved.Check(ctx); err != nil {
+		t.Fatalf("Check on an unchanged file: %v", err)
+	}
+
+	writeDatedFile(t, dir, "a.json", `{"id":2}`+"\n"+`{"id":1}`+"\n"+`{"id":1}`+"\n", modTime.Add(time.Minute))
+	if err := saved.Check(ctx); err == nil {
+		t.Fatal("Check accepted a plan whose file has changed")
+	}
+}
@@ -0,0 +1,228 @@
+// internal/server/grpc.go
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCService is the full name of the gRPC service. Its methods take and
+// return the same JSON messages as the REST API, so clients call them with
+// the "json" content subtype, grpc.CallContentSubtype(GRPCCodec) in Go:
+//
+//	Submit(JobRequest) returns (JobStatus)
+//	Progress(JobRef) returns (stream JobStatus)
+//	GetReport(ReportRequest) returns (Report)
+//	Cancel(JobRef) returns (JobStatus)
+const GRPCService = "dupeanalyser.Analyser"
+
+// GRPCCodec is the name of the codec the gRPC API is served with.
+const GRPCCodec = "json"
+
+// progressStreamInterval is how often Progress sends the status of a job
+// while it is queued or running.
+const progressStreamInterval = time.Second
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// jsonCodec encodes gRPC messages as JSON, as the messages are plain Go
+// structs rather than generated protobuf types.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                       { return GRPCCodec }
+
+// JobRef names a job in the Progress and Cancel RPCs.
+type JobRef struct {
+	ID string `json:"id"`
+}
+
+// ReportRequest asks GetReport for the report of a finished job in one of
+// the report formats, json by default.
+type ReportRequest struct {
+	ID     string `json:"id"`
+	Format string `json:"format"`
+}
+
+// Report is a job's report rendered by GetReport. Large reports need a
+// larger receive limit than gRPC's default of 4 MB, set in Go with
+// grpc.MaxCallRecvMsgSize.
+type Report struct {
+	ID          string `json:"id"`
+	Format      string `json:"format"`
+	ContentType string `json:"contentType"`
+	Data        []byte `json:"data"`
+}
+
+var grpcServiceDesc = grpc.ServiceDesc{
+	ServiceName: GRPCService,
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{
+		unaryMethod("Submit", (*Server).grpcSubmit),
+		unaryMethod("GetReport", (*Server).grpcGetReport),
+		unaryMethod("Cancel", (*Server).grpcCancel),
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "Progress", Handler: progressHandler, ServerStreams: true},
+	},
+}
+
+// unaryMethod describes a unary RPC handled by call.
+func unaryMethod[Req, Resp any](name string, call func(*Server, context.Context, *Req) (*Resp, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			req := new(Req)
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, req any) (any, error) {
+				return call(srv.(*Server), ctx, req.(*Req))
+			}
+			if interceptor == nil {
+				return handler(ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + GRPCService + "/" + name}
+			return interceptor(ctx, req, info, handler)
+		},
+	}
+}
+
+func progressHandler(srv any, stream grpc.ServerStream) error {
+	ref := new(JobRef)
+	if err := stream.RecvMsg(ref); err != nil {
+		return err
+	}
+	return srv.(*Server).grpcProgress(ref, stream)
+}
+
+// grpcServer returns a gRPC server for the API, checking the bearer token
+// when one is set.
+func (s *Server) grpcServer() *grpc.Server {
+	var opts []grpc.ServerOption
+	if s.opts.Token != "" {
+		opts = append(opts,
+			grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				if err := s.grpcAuthorised(ctx); err != nil {
+					return nil, err
+				}
+				return handler(ctx, req)
+			}),
+			grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				if err := s.grpcAuthorised(ss.Context()); err != nil {
+					return err
+				}
+				return handler(srv, ss)
+			}),
+		)
+	}
+	server := grpc.NewServer(opts...)
+	server.RegisterService(&grpcServiceDesc, s)
+	return server
+}
+
+// grpcAuthorised checks the bearer token in the authorization metadata.
+func (s *Server) grpcAuthorised(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) != 1 || subtle.ConstantTimeCompare([]byte(values[0]), []byte("Bearer "+s.opts.Token)) != 1 {
+		return status.Error(codes.Unauthenticated, "missing or invalid bearer token")
+	}
+	return nil
+}
+
+func (s *Server) grpcSubmit(_ context.Context, req *JobRequest) (*JobStatus, error) {
+	st, err := s.submitRequest(*req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &st, nil
+}
+
+func (s *Server) grpcGetReport(_ context.Context, req *ReportRequest) (*Report, error) {
+	j, err := s.job(req.ID)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	format := req.Format
+	if format == "" {
+		format = "json"
+	}
+	if !report.ValidFormat(format) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid format %q; must be one of %s", format, strings.Join(report.Formats, ", "))
+	}
+	rep, err := s.finishedReport(j)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	var buf bytes.Buffer
+	checkKey, checkRow := rep.ChecksRun()
+	if err := rep.Render(&buf, format, nil, checkKey, checkRow, s.base.ShowFolderBreakdown); err != nil {
+		return nil, status.Errorf(codes.Internal, "could not render report: %v", err)
+	}
+	return &Report{ID: j.id, Format: format, ContentType: report.ContentType(format), Data: buf.Bytes()}, nil
+}
+
+func (s *Server) grpcCancel(_ context.Context, ref *JobRef) (*JobStatus, error) {
+	j, err := s.job(ref.ID)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	if err := s.cancelJob(j); err != nil {
+		return nil, grpcError(err)
+	}
+	st := s.status(j)
+	return &st, nil
+}
+
+// grpcProgress sends the status of a job when the stream opens, then every
+// progressStreamInterval until the job finishes, ending with its final
+// status.
+func (s *Server) grpcProgress(ref *JobRef, stream grpc.ServerStream) error {
+	j, err := s.job(ref.ID)
+	if err != nil {
+		return grpcError(err)
+	}
+	ticker := time.NewTicker(progressStreamInterval)
+	defer ticker.Stop()
+	for {
+		st := s.status(j)
+		if err := stream.SendMsg(&st); err != nil {
+			return err
+		}
+		if st.Finished != "" {
+			return nil
+		}
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// grpcError returns the gRPC status for an error of a job operation.
+func grpcError(err error) error {
+	switch {
+	case errors.Is(err, errNoJob), errors.Is(err, errNoReport):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, errRunning), errors.Is(err, errFinished):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
@@ -0,0 +1,690 @@
+// internal/server/server.go
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benjaminwestern/dupe-analyser/internal/config"
+	"github.com/benjaminwestern/dupe-analyser/internal/headless"
+	"github.com/benjaminwestern/dupe-analyser/pkg/analyser"
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+	"google.golang.org/grpc"
+)
+
+// TokenEnv names the environment variable holding the bearer token that
+// every request but /healthz must carry. The API is open when it is unset.
+const TokenEnv = "DUPE_ANALYSER_API_TOKEN"
+
+// jobHistory is how many finished jobs are kept for their status and report.
+// The oldest are forgotten first; their saved report files are left on disk.
+const jobHistory = 50
+
+// maxRequestSize bounds the body of a job submission.
+const maxRequestSize = 1 << 20
+
+// Job states.
+const (
+	StateQueued    = "queued"
+	StateRunning   = "running"
+	StateDone      = "done"
+	StateFailed    = "failed"
+	StateCancelled = "cancelled"
+)
+
+// Options configures a Server.
+type Options struct {
+	// MaxJobs is how many jobs run at once. Later jobs wait in a queue.
+	MaxJobs int
+	// Allow, when set, lists the local directories and gs:// prefixes that
+	// jobs may read. Jobs may read any path when it is empty.
+	Allow []string
+	// Token, when set, must be sent as "Authorization: Bearer <token>", in
+	// the request headers or the gRPC metadata.
+	Token string
+}
+
+// Server runs analysis jobs submitted over its REST or gRPC API. Each job
+// runs the base settings with the fields of its request laid over them, and
+// saves its report files under <log path>/jobs/<id>.
+type Server struct {
+	base  headless.Config
+	opts  Options
+	slots chan struct{}
+	ctx   context.Context
+	wg    sync.WaitGroup
+
+	mu   sync.Mutex
+	jobs map[string]*job
+	// order holds the job IDs, oldest first.
+	order []string
+}
+
+// New returns a server running jobs with the settings in base.
+func New(base headless.Config, opts Options) (*Server, error) {
+	if opts.MaxJobs < 1 {
+		return nil, errors.New("-max-jobs must be at least 1")
+	}
+	for i, a := range opts.Allow {
+		if strings.HasPrefix(a, "gs://") {
+			// The trailing slash keeps gs://bucket from allowing
+			// gs://bucket-other.
+			opts.Allow[i] = strings.TrimSuffix(a, "/") + "/"
+			continue
+		}
+		abs, err := resolve(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -allow path %s: %w", a, err)
+		}
+		opts.Allow[i] = abs
+	}
+	// Jobs only print to the server's output when something went wrong.
+	base.Quiet = true
+	base.OutputFormat = ""
+	return &Server{
+		base:  base,
+		opts:  opts,
+		slots: make(chan struct{}, opts.MaxJobs),
+		jobs:  make(map[string]*job),
+	}, nil
+}
+
+// JobRequest is the body of POST /jobs. Fields left out keep the settings
+// the server was started with. Relative paths are taken from the server's
+// working directory.
+type JobRequest struct {
+	Paths          []string                  `json:"paths"`
+	Key            string                    `json:"key"`
+	AdditionalKeys []string                  `json:"additionalKeys"`
+	CheckKey       *bool                     `json:"checkKey"`
+	CheckRow       *bool                     `json:"checkRow"`
+	ValidateOnly   bool                      `json:"validateOnly"`
+	Scope          string                    `json:"scope"`
+	Workers        int                       `json:"workers"`
+	FuzzyField     string                    `json:"fuzzyField"`
+	FuzzyThreshold float64                   `json:"fuzzyThreshold"`
+	Constraints    []config.ConstraintConfig `json:"constraints"`
+}
+
+// JobStatus is the state of a job as the API returns it. Progress is set
+// once the files are discovered; ExitCode, Result and Summary once the job
+// has finished, Result naming it ok, duplicates, over-budget or error.
+type JobStatus struct {
+	ID         string                  `json:"id"`
+	State      string                  `json:"state"`
+	Paths      string                  `json:"paths"`
+	Created    string                  `json:"created"`
+	Started    string                  `json:"started,omitempty"`
+	Finished   string                  `json:"finished,omitempty"`
+	Progress   *headless.ProgressEvent `json:"progress,omitempty"`
+	ExitCode   *int                    `json:"exitCode,omitempty"`
+	Result     string                  `json:"result,omitempty"`
+	ReportBase string                  `json:"reportBase,omitempty"`
+	Summary    *report.SummaryReport   `json:"summary,omitempty"`
+}
+
+// job is a submitted analysis. Its fields are guarded by the server's mutex.
+type job struct {
+	id       string
+	paths    string
+	state    string
+	created  time.Time
+	started  time.Time
+	finished time.Time
+	cancel   context.CancelFunc
+	// progress reads the engine while the job runs. It is replaced by its
+	// last reading when the job finishes, so the engine can be freed.
+	progress func() headless.ProgressEvent
+	last     *headless.ProgressEvent
+	result   headless.Result
+}
+
+// Serve serves the REST API on addr and the gRPC API on grpcAddr, either of
+// which may be empty, until ctx is cancelled. It then cancels the jobs still
+// queued or running and waits for them to stop.
+func (s *Server) Serve(ctx context.Context, addr, grpcAddr string) error {
+	if addr == "" && grpcAddr == "" {
+		return errors.New("no address to serve the API on")
+	}
+	if s.opts.Token == "" && len(s.opts.Allow) == 0 {
+		for _, a := range []string{addr, grpcAddr} {
+			if a != "" && !loopback(a) {
+				return fmt.Errorf("refusing to serve on %s, which other hosts can reach, without a token in %s or -serve.allow", a, TokenEnv)
+			}
+		}
+	}
+	var listener, grpcListener net.Listener
+	var err error
+	if addr != "" {
+		if listener, err = net.Listen("tcp", addr); err != nil {
+			return fmt.Errorf("could not listen on %s: %w", addr, err)
+		}
+	}
+	if grpcAddr != "" {
+		if grpcListener, err = net.Listen("tcp", grpcAddr); err != nil {
+			if listener != nil {
+				listener.Close()
+			}
+			return fmt.Errorf("could not listen on %s: %w", grpcAddr, err)
+		}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		s.wg.Wait()
+	}()
+	s.ctx = ctx
+
+	errc := make(chan error, 2)
+	var httpServer *http.Server
+	if listener != nil {
+		httpServer = &http.Server{Handler: s.handler(), ReadHeaderTimeout: 10 * time.Second}
+		fmt.Fprintf(os.Stderr, "Serving the API on http://%s/\n", listener.Addr())
+		slog.Info("Serving the REST API", "addr", listener.Addr().String(), "max_jobs", s.opts.MaxJobs, "allow", s.opts.Allow)
+		go func() { errc <- fmt.Errorf("API server stopped: %w", httpServer.Serve(listener)) }()
+	}
+	var grpcServer *grpc.Server
+	if grpcListener != nil {
+		grpcServer = s.grpcServer()
+		fmt.Fprintf(os.Stderr, "Serving the gRPC API on %s\n", grpcListener.Addr())
+		slog.Info("Serving the gRPC API", "addr", grpcListener.Addr().String(), "max_jobs", s.opts.MaxJobs, "allow", s.opts.Allow)
+		go func() { errc <- fmt.Errorf("gRPC server stopped: %w", grpcServer.Serve(grpcListener)) }()
+	}
+	select {
+	case err := <-errc:
+		if httpServer != nil {
+			httpServer.Close()
+		}
+		if grpcServer != nil {
+			grpcServer.Stop()
+		}
+		return err
+	case <-ctx.Done():
+	}
+	// Cancelling the jobs first ends the progress streams following them.
+	cancel()
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
+	}
+	if httpServer != nil {
+		shutdownCtx, stop := context.WithTimeout(context.Background(), 5*time.Second)
+		defer stop()
+		_ = httpServer.Shutdown(shutdownCtx)
+	}
+	return nil
+}
+
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	mux.Handle("POST /jobs", s.authorised(s.handleSubmit))
+	mux.Handle("GET /jobs", s.authorised(s.handleList))
+	mux.Handle("GET /jobs/{id}", s.authorised(s.handleStatus))
+	mux.Handle("GET /jobs/{id}/report", s.authorised(s.handleReport))
+	mux.Handle("POST /jobs/{id}/cancel", s.authorised(s.handleCancel))
+	return mux
+}
+
+// authorised wraps next so that it is only called for requests carrying the
+// bearer token, when one is set.
+func (s *Server) authorised(next http.HandlerFunc) http.Handler {
+	if s.opts.Token == "" {
+		return next
+	}
+	want := []byte("Bearer " + s.opts.Token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		next(w, r)
+	})
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	counts := map[string]int{StateQueued: 0, StateRunning: 0}
+	for _, j := range s.jobs {
+		counts[j.state]++
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "queued": counts[StateQueued], "running": counts[StateRunning]})
+}
+
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var req JobRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid job request: "+err.Error())
+		return
+	}
+	st, err := s.submitRequest(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.Header().Set("Location", "/jobs/"+st.ID)
+	writeJSON(w, http.StatusAccepted, st)
+}
+
+func (s *Server) handleList(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	statuses := make([]JobStatus, 0, len(s.order))
+	for i := len(s.order) - 1; i >= 0; i-- {
+		statuses = append(statuses, s.statusLocked(s.jobs[s.order[i]]))
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	j := s.lookup(w, r)
+	if j == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.status(j))
+}
+
+// handleReport renders the report of a finished job in the format given by
+// the format query parameter, json by default.
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	j := s.lookup(w, r)
+	if j == nil {
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if !report.ValidFormat(format) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid format %q; must be one of %s", format, strings.Join(report.Formats, ", ")))
+		return
+	}
+	rep, err := s.finishedReport(j)
+	if err != nil {
+		writeError(w, httpStatus(err), err.Error())
+		return
+	}
+	checkKey, checkRow := rep.ChecksRun()
+	w.Header().Set("Content-Type", report.ContentType(format))
+	if err := rep.Render(w, format, nil, checkKey, checkRow, s.base.ShowFolderBreakdown); err != nil {
+		slog.Error("Could not write job report", "job", j.id, "format", format, "error", err)
+	}
+}
+
+// handleCancel cancels a queued or running job.
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	j := s.lookup(w, r)
+	if j == nil {
+		return
+	}
+	if err := s.cancelJob(j); err != nil {
+		writeError(w, httpStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, s.status(j))
+}
+
+// lookup returns the job named in the request path, or writes a 404 and
+// returns nil.
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) *job {
+	j, err := s.job(r.PathValue("id"))
+	if err != nil {
+		writeError(w, httpStatus(err), err.Error())
+	}
+	return j
+}
+
+// Errors of the job operations shared by the REST and gRPC APIs, which
+// each API maps to its own status codes.
+var (
+	errNoJob    = errors.New("no such job")
+	errRunning  = errors.New("job has not finished")
+	errFinished = errors.New("job has already finished")
+	errNoReport = errors.New("job did not produce a report")
+)
+
+// httpStatus returns the HTTP status code for an error of a job operation.
+func httpStatus(err error) int {
+	switch {
+	case errors.Is(err, errNoJob), errors.Is(err, errNoReport):
+		return http.StatusNotFound
+	case errors.Is(err, errRunning), errors.Is(err, errFinished):
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
+// job returns the job with id.
+func (s *Server) job(id string) (*job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j := s.jobs[id]
+	if j == nil {
+		return nil, fmt.Errorf("%w %q", errNoJob, id)
+	}
+	return j, nil
+}
+
+// submitRequest queues the job described by req and returns its status.
+func (s *Server) submitRequest(req JobRequest) (JobStatus, error) {
+	cfg, err := s.jobConfig(req)
+	if err != nil {
+		return JobStatus{}, err
+	}
+	return s.status(s.submit(cfg)), nil
+}
+
+// finishedReport returns the report of a finished job.
+func (s *Server) finishedReport(j *job) (*report.AnalysisReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case j.finished.IsZero():
+		return nil, fmt.Errorf("%w: %s is still %s", errRunning, j.id, j.state)
+	case j.result.Report == nil:
+		return nil, fmt.Errorf("%w: %s", errNoReport, j.id)
+	}
+	return j.result.Report, nil
+}
+
+// cancelJob cancels a queued or running job. A running job stops at the next
+// record and keeps the partial report it had built.
+func (s *Server) cancelJob(j *job) error {
+	s.mu.Lock()
+	switch j.state {
+	case StateQueued:
+		s.finishLocked(j, StateCancelled, headless.Result{ExitCode: headless.ExitError, Status: "error"})
+	case StateRunning:
+	default:
+		s.mu.Unlock()
+		return fmt.Errorf("%w: %s", errFinished, j.id)
+	}
+	s.mu.Unlock()
+	j.cancel()
+	slog.Info("Job cancelled", "job", j.id)
+	return nil
+}
+
+// jobConfig lays req over the base settings and checks the result.
+func (s *Server) jobConfig(req JobRequest) (*headless.Config, error) {
+	cfg := s.base
+	if len(req.Paths) > 0 {
+		cfg.Paths = strings.Join(req.Paths, ",")
+	}
+	if strings.TrimSpace(cfg.Paths) == "" {
+		return nil, errors.New("paths is required")
+	}
+	for _, p := range strings.Split(cfg.Paths, ",") {
+		if p = strings.TrimSpace(p); !s.allowed(p) {
+			return nil, fmt.Errorf("path %s is not under an allowed path", p)
+		}
+	}
+	// Discovery opens symlinked files, so each one found is checked too.
+	cfg.Allowed = s.allowed
+	if req.Key != "" {
+		cfg.Key = req.Key
+	}
+	if req.AdditionalKeys != nil {
+		cfg.AdditionalKeys = req.AdditionalKeys
+	}
+	if req.CheckKey != nil {
+		cfg.CheckKey = *req.CheckKey
+	}
+	if req.CheckRow != nil {
+		cfg.CheckRow = *req.CheckRow
+	}
+	cfg.ValidateOnly = req.ValidateOnly
+	if cfg.Key == "" && (cfg.ValidateOnly || cfg.CheckKey) {
+		return nil, errors.New("key is required")
+	}
+	if !cfg.ValidateOnly && !cfg.CheckKey && !cfg.CheckRow {
+		return nil, errors.New("at least one of checkKey and checkRow must be true for a full analysis")
+	}
+	if req.Scope != "" {
+		if !analyser.ValidScope(req.Scope) {
+			return nil, fmt.Errorf("invalid scope %q; must be global, file or folder", req.Scope)
+		}
+		cfg.Scope = req.Scope
+	}
+	if req.Workers < 0 {
+		return nil, errors.New("workers must not be negative")
+	}
+	if req.Workers > 0 {
+		cfg.Workers = req.Workers
+	}
+	if req.FuzzyField != "" {
+		cfg.FuzzyField = req.FuzzyField
+	}
+	if req.FuzzyThreshold != 0 {
+		if req.FuzzyThreshold < 0 || req.FuzzyThreshold > 1 {
+			return nil, errors.New("fuzzyThreshold must be greater than 0 and at most 1")
+		}
+		cfg.FuzzyThreshold = req.FuzzyThreshold
+	}
+	if req.Constraints != nil {
+		if err := config.CheckConstraints(req.Constraints, "the request"); err != nil {
+			return nil, err
+		}
+		cfg.Constraints = req.Constraints
+	}
+	return &cfg, nil
+}
+
+// allowed reports whether a job may read p.
+func (s *Server) allowed(p string) bool {
+	if len(s.opts.Allow) == 0 {
+		return true
+	}
+	if strings.HasPrefix(p, "gs://") {
+		for _, a := range s.opts.Allow {
+			if strings.HasPrefix(a, "gs://") && (p == strings.TrimSuffix(a, "/") || strings.HasPrefix(p, a)) {
+				return true
+			}
+		}
+		return false
+	}
+	abs, err := resolve(p)
+	if err != nil {
+		return false
+	}
+	for _, a := range s.opts.Allow {
+		if abs == a || strings.HasPrefix(abs, strings.TrimSuffix(a, string(filepath.Separator))+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the absolute path of p with its symlinks resolved, so a
+// link inside an allowed directory cannot lead out of it.
+func resolve(p string) (string, error) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// loopback reports whether addr, as host:port, only listens on the loopback
+// interface. An empty host listens on every interface.
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// submit queues a job running cfg.
+func (s *Server) submit(cfg *headless.Config) *job {
+	ctx, cancel := context.WithCancel(s.ctx)
+	j := &job{id: newID(), paths: cfg.Paths, state: StateQueued, created: time.Now(), cancel: cancel}
+	cfg.LogPath = filepath.Join(s.base.LogPath, "jobs", j.id)
+	cfg.Started = func(progress func() headless.ProgressEvent) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		j.progress = progress
+	}
+
+	s.mu.Lock()
+	s.jobs[j.id] = j
+	s.order = append(s.order, j.id)
+	s.forgetOldJobs()
+	s.mu.Unlock()
+	slog.Info("Job queued", "job", j.id, "paths", cfg.Paths)
+
+	s.wg.Add(1)
+	go s.run(ctx, j, cfg)
+	return j
+}
+
+// run waits for a free slot, then runs the job.
+func (s *Server) run(ctx context.Context, j *job, cfg *headless.Config) {
+	defer s.wg.Done()
+	defer j.cancel()
+	select {
+	case s.slots <- struct{}{}:
+	case <-ctx.Done():
+		s.finish(j, StateCancelled, headless.Result{ExitCode: headless.ExitError, Status: "error"})
+		return
+	}
+	defer func() { <-s.slots }()
+
+	s.mu.Lock()
+	if j.state != StateQueued || ctx.Err() != nil {
+		s.finishLocked(j, StateCancelled, headless.Result{ExitCode: headless.ExitError, Status: "error"})
+		s.mu.Unlock()
+		return
+	}
+	j.state = StateRunning
+	j.started = time.Now()
+	s.mu.Unlock()
+
+	if err := os.MkdirAll(cfg.LogPath, 0755); err != nil {
+		slog.Error("Could not create the job's report directory", "job", j.id, "error", err)
+		s.finish(j, StateFailed, headless.Result{ExitCode: headless.ExitError, Status: "error"})
+		return
+	}
+	slog.Info("Job started", "job", j.id)
+	res := headless.RunResult(ctx, cfg)
+	state := StateDone
+	switch {
+	case ctx.Err() != nil:
+		state = StateCancelled
+	case res.ExitCode == headless.ExitError:
+		state = StateFailed
+	}
+	s.finish(j, state, res)
+	slog.Info("Job finished", "job", j.id, "state", state, "result", res.Status, "exit_code", res.ExitCode, "report", res.ReportBase)
+}
+
+func (s *Server) finish(j *job, state string, res headless.Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finishLocked(j, state, res)
+}
+
+// finishLocked records the end of a job unless it has already ended.
+func (s *Server) finishLocked(j *job, state string, res headless.Result) {
+	if !j.finished.IsZero() {
+		return
+	}
+	j.state = state
+	j.finished = time.Now()
+	j.result = res
+	if j.progress != nil {
+		last := j.progress()
+		j.last = &last
+		j.progress = nil
+	}
+	s.forgetOldJobs()
+}
+
+// forgetOldJobs drops the oldest finished jobs beyond jobHistory.
+func (s *Server) forgetOldJobs() {
+	finished := 0
+	for _, id := range s.order {
+		if !s.jobs[id].finished.IsZero() {
+			finished++
+		}
+	}
+	kept := s.order[:0]
+	for _, id := range s.order {
+		if finished > jobHistory && !s.jobs[id].finished.IsZero() {
+			delete(s.jobs, id)
+			finished--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	s.order = kept
+}
+
+func (s *Server) status(j *job) JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.statusLocked(j)
+}
+
+func (s *Server) statusLocked(j *job) JobStatus {
+	st := JobStatus{ID: j.id, State: j.state, Paths: j.paths, Created: j.created.Format(time.RFC3339)}
+	if !j.started.IsZero() {
+		st.Started = j.started.Format(time.RFC3339)
+	}
+	if j.progress != nil {
+		progress := j.progress()
+		st.Progress = &progress
+	} else {
+		st.Progress = j.last
+	}
+	if j.finished.IsZero() {
+		return st
+	}
+	st.Finished = j.finished.Format(time.RFC3339)
+	code := j.result.ExitCode
+	st.ExitCode = &code
+	st.Result = j.result.Status
+	st.ReportBase = j.result.ReportBase
+	if j.result.Report != nil {
+		summary := j.result.Report.Summary
+		st.Summary = &summary
+	}
+	return st
+}
+
+// newID returns a random job ID.
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
@@ -0,0 +1,235 @@
+// internal/sink/bigquery.go
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+	"google.golang.org/api/bigquery/v2"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	// bigQueryBatchRows is the number of rows sent in each streaming insert,
+	// the size BigQuery recommends.
+	bigQueryBatchRows = 500
+	// bigQueryNewTableWait bounds how long inserts are retried while a newly
+	// created table becomes visible to the streaming API.
+	bigQueryNewTableWait = 2 * time.Minute
+)
+
+// BigQueryTable identifies a table as project.dataset.table.
+type BigQueryTable struct {
+	Project string
+	Dataset string
+	Table   string
+}
+
+// ParseBigQueryTable parses a project.dataset.table reference.
+func ParseBigQueryTable(ref string) (BigQueryTable, error) {
+	parts := strings.Split(ref, ".")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return BigQueryTable{}, fmt.Errorf("invalid BigQuery table %q, expected project.dataset.table", ref)
+	}
+	return BigQueryTable{Project: parts[0], Dataset: parts[1], Table: parts[2]}, nil
+}
+
+func (t BigQueryTable) String() string {
+	return t.Project + "." + t.Dataset + "." + t.Table
+}
+
+// summaryTable is the table that holds one summary row per run.
+func (t BigQueryTable) summaryTable() BigQueryTable {
+	t.Table += "_summary"
+	return t
+}
+
+var bigQueryFindingsSchema = &bigquery.TableSchema{Fields: []*bigquery.TableFieldSchema{
+	{Name: "run_id", Type: "STRING", Mode: "REQUIRED"},
+	{Name: "run_time", Type: "TIMESTAMP", Mode: "REQUIRED"},
+	{Name: "paths", Type: "STRING"},
+	{Name: "type", Type: "STRING", Mode: "REQUIRED"},
+	{Name: "key", Type: "STRING"},
+	{Name: "value", Type: "STRING"},
+	{Name: "scope", Type: "STRING"},
+	{Name: "file", Type: "STRING"},
+	{Name: "line", Type: "INTEGER"},
+	{Name: "count", Type: "INTEGER"},
+}}
+
+var bigQuerySummarySchema = &bigquery.TableSchema{Fields: []*bigquery.TableFieldSchema{
+	{Name: "run_id", Type: "STRING", Mode: "REQUIRED"},
+	{Name: "run_time", Type: "TIMESTAMP", Mode: "REQUIRED"},
+	{Name: "paths", Type: "STRING"},
+	{Name: "unique_key", Type: "STRING"},
+	{Name: "scope", Type: "STRING"},
+	{Name: "is_partial", Type: "BOOLEAN"},
+	{Name: "files_processed", Type: "INTEGER"},
+	{Name: "total_files", Type: "INTEGER"},
+	{Name: "processed_bytes", Type: "INTEGER"},
+	{Name: "total_bytes", Type: "INTEGER"},
+	{Name: "rows_processed", Type: "INTEGER"},
+	{Name: "elapsed", Type: "STRING"},
+	{Name: "key_occurrences", Type: "INTEGER"},
+	{Name: "unique_keys_duplicated", Type: "INTEGER"},
+	{Name: "duplicate_row_instances", Type: "INTEGER"},
+	{Name: "folders", Type: "RECORD", Mode: "REPEATED", Fields: []*bigquery.TableFieldSchema{
+		{Name: "path", Type: "STRING"},
+		{Name: "processed_bytes", Type: "INTEGER"},
+		{Name: "files_processed", Type: "INTEGER"},
+		{Name: "rows_processed", Type: "INTEGER"},
+		{Name: "keys_found", Type: "INTEGER"},
+		{Name: "duplicate_ids", Type: "INTEGER"},
+		{Name: "duplicate_rows", Type: "INTEGER"},
+	}},
+}}
+
+// WriteBigQuery streams the summary of rep into the table's "_summary"
+// companion and every duplicate location into the table itself. Both tables
+// are created when missing. Every row carries the same run ID and time, and
+// the analysed paths, so that results can be trended per dataset.
+func WriteBigQuery(ctx context.Context, table BigQueryTable, rep *report.AnalysisReport, paths string, checkKey, checkRow bool) error {
+	svc, err := bigquery.NewService(ctx)
+	if err != nil {
+		return fmt.Errorf("could not create BigQuery client: %w", err)
+	}
+	runTime := time.Now().UTC()
+	runID := runTime.Format("20060102T150405.000000000Z")
+	ts := runTime.Format(time.RFC3339Nano)
+
+	summary := table.summaryTable()
+	if err := ensureBigQueryTable(ctx, svc, summary, bigQuerySummarySchema); err != nil {
+		return err
+	}
+	if err := ensureBigQueryTable(ctx, svc, table, bigQueryFindingsSchema); err != nil {
+		return err
+	}
+
+	s := rep.Summary
+	var folders []bigquery.JsonValue
+	for path, d := range s.FolderDetails {
+		folders = append(folders, map[string]bigquery.JsonValue{
+			"path":            path,
+			"processed_bytes": d.ProcessedSizeBytes,
+			"files_processed": d.FilesProcessed,
+			"rows_processed":  d.RowsProcessed,
+			"keys_found":      d.KeysFound,
+			"duplicate_ids":   s.DuplicateIDsPerFolder[path],
+			"duplicate_rows":  s.DuplicateRowsPerFolder[path],
+		})
+	}
+	summaryRow := &bigquery.TableDataInsertAllRequestRows{InsertId: runID, Json: map[string]bigquery.JsonValue{
+		"run_id":                  runID,
+		"run_time":                ts,
+		"paths":                   paths,
+		"unique_key":              s.UniqueKey,
+		"scope":                   s.Scope,
+		"is_partial":              s.IsPartialReport,
+		"files_processed":         s.FilesProcessed,
+		"total_files":             s.TotalFiles,
+		"processed_bytes":         s.ProcessedDataSizeBytes,
+		"total_bytes":             s.TotalDataSizeOverallBytes,
+		"rows_processed":          s.TotalRowsProcessed,
+		"elapsed":                 s.TotalElapsedTime,
+		"key_occurrences":         s.TotalKeyOccurrences,
+		"unique_keys_duplicated":  s.UniqueKeysDuplicated,
+		"duplicate_row_instances": s.DuplicateRowInstances,
+		"folders":                 folders,
+	}}
+	if err := insertBigQueryRows(ctx, svc, summary, []*bigquery.TableDataInsertAllRequestRows{summaryRow}); err != nil {
+		return err
+	}
+
+	batch := make([]*bigquery.TableDataInsertAllRequestRows, 0, bigQueryBatchRows)
+	n := 0
+	err = rep.EachDuplicateRecord(checkKey, checkRow, func(rec report.DuplicateRecord) error {
+		n++
+		batch = append(batch, &bigquery.TableDataInsertAllRequestRows{
+			InsertId: fmt.Sprintf("%s-%d", runID, n),
+			Json: map[string]bigquery.JsonValue{
+				"run_id":   runID,
+				"run_time": ts,
+				"paths":    paths,
+				"type":     rec.Type,
+				"key":      rec.Key,
+				"value":    rec.Value,
+				"scope":    rec.Scope,
+				"file":     rec.File,
+				"line":     rec.Line,
+				"count":    rec.Count,
+			},
+		})
+		if len(batch) < bigQueryBatchRows {
+			return nil
+		}
+		err := insertBigQueryRows(ctx, svc, table, batch)
+		batch = batch[:0]
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		return insertBigQueryRows(ctx, svc, table, batch)
+	}
+	return nil
+}
+
+// ensureBigQueryTable creates table with schema unless it already exists.
+func ensureBigQueryTable(ctx context.Context, svc *bigquery.Service, table BigQueryTable, schema *bigquery.TableSchema) error {
+	_, err := svc.Tables.Get(table.Project, table.Dataset, table.Table).Context(ctx).Do()
+	if err == nil {
+		return nil
+	}
+	if !isNotFound(err) {
+		return fmt.Errorf("could not look up BigQuery table %s: %w", table, err)
+	}
+	_, err = svc.Tables.Insert(table.Project, table.Dataset, &bigquery.Table{
+		TableReference: &bigquery.TableReference{ProjectId: table.Project, DatasetId: table.Dataset, TableId: table.Table},
+		Schema:         schema,
+	}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("could not create BigQuery table %s: %w", table, err)
+	}
+	return nil
+}
+
+// insertBigQueryRows streams rows into table. A table that was only just
+// created can report not found for a short while, so those errors are retried.
+func insertBigQueryRows(ctx context.Context, svc *bigquery.Service, table BigQueryTable, rows []*bigquery.TableDataInsertAllRequestRows) error {
+	deadline := time.Now().Add(bigQueryNewTableWait)
+	for delay := time.Second; ; delay = min(2*delay, 15*time.Second) {
+		resp, err := svc.Tabledata.InsertAll(table.Project, table.Dataset, table.Table,
+			&bigquery.TableDataInsertAllRequest{Rows: rows}).Context(ctx).Do()
+		if err != nil && isNotFound(err) && time.Now().Before(deadline) {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("could not insert into BigQuery table %s: %w", table, err)
+		}
+		if len(resp.InsertErrors) > 0 {
+			first := resp.InsertErrors[0]
+			msg := "unknown error"
+			if len(first.Errors) > 0 {
+				msg = first.Errors[0].Message
+			}
+			return fmt.Errorf("BigQuery rejected %d of %d rows in %s (row %d: %s)", len(resp.InsertErrors), len(rows), table, first.Index, msg)
+		}
+		return nil
+	}
+}
+
+func isNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
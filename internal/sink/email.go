@@ -0,0 +1,189 @@
+// internal/sink/email.go
+package sink
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxAttachmentBytes is the largest report attached to an email. Mail
+// servers commonly reject messages over 25 MB, and base64 adds a third.
+const maxAttachmentBytes = 15 << 20
+
+// SMTPConfig holds the mail server used for email notifications.
+type SMTPConfig struct {
+	// Addr is the server as host:port. Port 465 uses implicit TLS; other
+	// ports upgrade with STARTTLS when the server offers it.
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+// ParseEmailRecipients splits a comma-separated list of addresses.
+func ParseEmailRecipients(list string) ([]string, error) {
+	var to []string
+	for _, addr := range strings.Split(list, ",") {
+		if addr = strings.TrimSpace(addr); addr == "" {
+			continue
+		}
+		if !strings.Contains(addr, "@") {
+			return nil, fmt.Errorf("invalid email address %q", addr)
+		}
+		to = append(to, addr)
+	}
+	if len(to) == 0 {
+		return nil, fmt.Errorf("no email addresses in %q", list)
+	}
+	return to, nil
+}
+
+// SendEmail mails the notification to the recipients. When a report was
+// saved, its text summary and JSON files are attached; larger files are
+// mentioned in the message instead.
+func SendEmail(cfg SMTPConfig, to []string, n Notification) error {
+	if cfg.Addr == "" || cfg.From == "" {
+		return fmt.Errorf("email notifications need an SMTP server and from address")
+	}
+	msg, err := buildEmail(cfg.From, to, n)
+	if err != nil {
+		return err
+	}
+	host, port, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP server %q, expected host:port", cfg.Addr)
+	}
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, host)
+	}
+	if port != "465" {
+		if err := smtp.SendMail(cfg.Addr, auth, cfg.From, to, msg); err != nil {
+			return fmt.Errorf("could not send email: %w", err)
+		}
+		return nil
+	}
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: webhookTimeout}, "tcp", cfg.Addr, &tls.Config{ServerName: host})
+	if err != nil {
+		return fmt.Errorf("could not connect to SMTP server: %w", err)
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("could not connect to SMTP server: %w", err)
+	}
+	defer client.Close()
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+	if err := client.Mail(cfg.From); err != nil {
+		return fmt.Errorf("could not send email: %w", err)
+	}
+	for _, addr := range to {
+		if err := client.Rcpt(addr); err != nil {
+			return fmt.Errorf("could not send email to %s: %w", addr, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("could not send email: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("could not send email: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("could not send email: %w", err)
+	}
+	return client.Quit()
+}
+
+// buildEmail renders a multipart MIME message with the summary as its body
+// and the report files as attachments.
+func buildEmail(from string, to []string, n Notification) ([]byte, error) {
+	var attachments, skipped []string
+	if n.Report != "" {
+		for _, path := range []string{n.Report + "_summary.txt", n.Report + ".json", n.Report + ".json.gz"} {
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			if info.Size() > maxAttachmentBytes {
+				skipped = append(skipped, filepath.Base(path))
+				continue
+			}
+			attachments = append(attachments, path)
+		}
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", fmt.Sprintf("dupe-analyser: %s for %s", n.Status, n.Paths)))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary())
+
+	body := n.text(false)
+	if len(skipped) > 0 {
+		body += fmt.Sprintf("\n\nNot attached, as larger than %d MB: %s", maxAttachmentBytes>>20, strings.Join(skipped, ", "))
+	}
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	writeBase64(part, []byte(strings.ReplaceAll(body, "\n", "\r\n")))
+
+	for _, path := range attachments {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not attach %s: %w", path, err)
+		}
+		contentType := mime.TypeByExtension(filepath.Ext(path))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {contentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": filepath.Base(path)})},
+		})
+		if err != nil {
+			return nil, err
+		}
+		writeBase64(part, data)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeBase64 writes data base64-encoded in 76 character lines, as MIME
+// requires.
+func writeBase64(w io.Writer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		_, _ = w.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	_, _ = w.Write([]byte(encoded + "\r\n"))
+}
@@ -0,0 +1,153 @@
+// internal/sink/notify.go
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+)
+
+// Webhook payload formats.
+const (
+	// WebhookAuto posts Slack messages to hooks.slack.com and JSON elsewhere.
+	WebhookAuto = "auto"
+	// WebhookJSON posts the Notification itself.
+	WebhookJSON = "json"
+	// WebhookSlack posts a Slack incoming-webhook message, which Mattermost,
+	// Rocket.Chat and others also accept.
+	WebhookSlack = "slack"
+)
+
+// webhookTimeout bounds a webhook request, so an unreachable endpoint cannot
+// hold up the end of a run.
+const webhookTimeout = 30 * time.Second
+
+// ValidWebhookFormat reports whether s is a recognised webhook format.
+func ValidWebhookFormat(s string) bool {
+	return s == WebhookAuto || s == WebhookJSON || s == WebhookSlack
+}
+
+// Notification is the compact summary posted when a headless run finishes or
+// fails. The counts are zero when the run failed before producing a report.
+type Notification struct {
+	Status         string `json:"status"`
+	ExitCode       int    `json:"exitCode"`
+	Host           string `json:"host,omitempty"`
+	Paths          string `json:"paths"`
+	Key            string `json:"key,omitempty"`
+	Partial        bool   `json:"partial,omitempty"`
+	FilesProcessed int    `json:"filesProcessed"`
+	TotalFiles     int    `json:"totalFiles"`
+	Rows           int64  `json:"rows"`
+	DuplicateKeys  int    `json:"duplicateKeys"`
+	DuplicateRows  int    `json:"duplicateRows"`
+	Elapsed        string `json:"elapsed,omitempty"`
+	Report         string `json:"report,omitempty"`
+}
+
+// NewNotification summarises a run. rep may be nil for runs that failed
+// before analysing anything, and reportBase is empty when no report files
+// were saved.
+func NewNotification(status string, exitCode int, paths string, rep *report.AnalysisReport, reportBase string) Notification {
+	n := Notification{Status: status, ExitCode: exitCode, Paths: paths, Report: reportBase}
+	n.Host, _ = os.Hostname()
+	if rep != nil {
+		s := rep.Summary
+		n.Key = s.UniqueKey
+		n.Partial = s.IsPartialReport
+		n.FilesProcessed = int(s.FilesProcessed)
+		n.TotalFiles = s.TotalFiles
+		n.Rows = s.TotalRowsProcessed
+		n.DuplicateKeys = s.UniqueKeysDuplicated
+		n.DuplicateRows = s.DuplicateRowInstances
+		n.Elapsed = s.TotalElapsedTime
+	}
+	return n
+}
+
+// text renders the notification as a short message: Slack mrkdwn when
+// markdown is set, plain text otherwise.
+func (n Notification) text(markdown bool) string {
+	code, bold, icon := func(s string) string { return s }, func(s string) string { return s }, ""
+	if markdown {
+		code = func(s string) string { return "`" + s + "`" }
+		bold = func(s string) string { return "*" + s + "*" }
+		switch n.Status {
+		case "error":
+			icon = ":x: "
+		case "duplicates", "over-budget":
+			icon = ":warning: "
+		default:
+			icon = ":white_check_mark: "
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s%s run on %s finished with status %s (exit code %d)", icon, bold("dupe-analyser"), code(n.Paths), bold(n.Status), n.ExitCode)
+	if n.Host != "" {
+		fmt.Fprintf(&b, " on %s", n.Host)
+	}
+	if n.TotalFiles > 0 {
+		fmt.Fprintf(&b, "\n• Files: %d of %d", n.FilesProcessed, n.TotalFiles)
+		if n.Partial {
+			b.WriteString(" (partial report)")
+		}
+		fmt.Fprintf(&b, "\n• Rows: %d in %s", n.Rows, n.Elapsed)
+		if n.Key != "" {
+			fmt.Fprintf(&b, "\n• Duplicate %s values: %d", code(n.Key), n.DuplicateKeys)
+		}
+		fmt.Fprintf(&b, "\n• Duplicate rows: %d", n.DuplicateRows)
+	}
+	if n.Report != "" {
+		fmt.Fprintf(&b, "\n• Report: %s", code(n.Report))
+	}
+	return b.String()
+}
+
+// PostWebhook posts n to rawURL in the given format.
+func PostWebhook(ctx context.Context, rawURL, format string, n Notification) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook URL %q", rawURL)
+	}
+	if format == WebhookAuto {
+		format = WebhookJSON
+		if u.Host == "hooks.slack.com" {
+			format = WebhookSlack
+		}
+	}
+	var payload any = n
+	if format == WebhookSlack {
+		payload = map[string]string{"text": n.text(true)}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not post to webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// internal/sink/upload.go
+package sink
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cloud.google.com/go/storage"
+)
+
+// UploadDestination is a gs://bucket/prefix/ location that report files are
+// copied to.
+type UploadDestination struct {
+	Bucket string
+	Prefix string
+}
+
+// ParseUploadDestination parses a gs:// URI. The path is treated as a folder,
+// so gs://bucket/reports and gs://bucket/reports/ are the same destination.
+func ParseUploadDestination(uri string) (UploadDestination, error) {
+	rest, ok := strings.CutPrefix(uri, "gs://")
+	if !ok {
+		return UploadDestination{}, fmt.Errorf("invalid upload destination %q, only gs:// URIs are supported", uri)
+	}
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return UploadDestination{}, fmt.Errorf("invalid upload destination %q: bucket name cannot be empty", uri)
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return UploadDestination{Bucket: bucket, Prefix: prefix}, nil
+}
+
+func (d UploadDestination) String() string {
+	return "gs://" + d.Bucket + "/" + d.Prefix
+}
+
+// UploadReports copies every file whose path starts with base, the name
+// returned by report.SaveAndLog, to d and returns the URIs of the uploaded
+// objects.
+func UploadReports(ctx context.Context, d UploadDestination, base string) ([]string, error) {
+	files, err := filepath.Glob(base + "*")
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %w. Ensure you are authenticated", err)
+	}
+	defer client.Close()
+
+	bucket := client.Bucket(d.Bucket)
+	var uploaded []string
+	for _, file := range files {
+		name := d.Prefix + filepath.Base(file)
+		if err := uploadFile(ctx, bucket.Object(name), file); err != nil {
+			return uploaded, fmt.Errorf("failed to upload %s to gs://%s/%s: %w", file, d.Bucket, name, err)
+		}
+		uploaded = append(uploaded, "gs://"+d.Bucket+"/"+name)
+	}
+	return uploaded, nil
+}
+
+func uploadFile(ctx context.Context, obj *storage.ObjectHandle, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := obj.NewWriter(ctx)
+	w.ContentType = mime.TypeByExtension(filepath.Ext(file))
+	if _, err := io.Copy(w, f); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
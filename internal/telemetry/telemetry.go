@@ -0,0 +1,47 @@
+// internal/telemetry/telemetry.go
+package telemetry
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// serviceName identifies the analyser in the tracing backend unless
+// OTEL_SERVICE_NAME says otherwise.
+const serviceName = "dupe-analyser"
+
+// StartTracing installs a global tracer provider that exports spans over
+// OTLP/HTTP. The endpoint, headers and sampling follow the standard
+// OTEL_EXPORTER_OTLP_* and OTEL_TRACES_SAMPLER variables, and default to a
+// collector on localhost:4318. Until it is called, every span in the program
+// is a no-op. The returned function flushes buffered spans and must be called
+// before exiting.
+func StartTracing(ctx context.Context) (func(context.Context) error, error) {
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not create OTLP trace exporter: %w", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("could not describe trace resource: %w", err)
+	}
+	// Environment attributes are merged last so OTEL_SERVICE_NAME and
+	// OTEL_RESOURCE_ATTRIBUTES take precedence.
+	if env, err := resource.New(ctx, resource.WithFromEnv()); err == nil {
+		if merged, err := resource.Merge(res, env); err == nil {
+			res = merged
+		}
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
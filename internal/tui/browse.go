@@ -0,0 +1,260 @@
+// internal/tui/browse.go
+package tui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/filepicker"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+	"github.com/benjaminwestern/dupe-analyser/pkg/source"
+)
+
+// newPathPicker returns the browser of local files and folders offered as
+// an alternative to typing paths. Space picks the highlighted entry, so that
+// enter is left to open folders.
+func newPathPicker() filepicker.Model {
+	picker := filepicker.New()
+	picker.DirAllowed = true
+	picker.FileAllowed = true
+	picker.ShowPermissions = false
+	picker.AutoHeight = false
+	picker.KeyMap.Back = key.NewBinding(key.WithKeys("h", "backspace", "left"))
+	picker.KeyMap.Open = key.NewBinding(key.WithKeys("l", "right", "enter", " "))
+	picker.KeyMap.Select = key.NewBinding(key.WithKeys(" "))
+	return picker
+}
+
+// startBrowsing opens the browser in the last local folder typed, or the
+// working directory.
+func (m *model) startBrowsing() tea.Cmd {
+	dir, _ := filepath.Abs(".")
+	if last := m.lastEditorPath(); last != "" && !strings.HasPrefix(last, "gs://") {
+		if info, err := os.Stat(last); err == nil && info.IsDir() {
+			dir, _ = filepath.Abs(last)
+		}
+	}
+	m.pathPicker = newPathPicker()
+	m.pathPicker.CurrentDirectory = dir
+	m.pathPicker.SetHeight(max(m.height-12, 5))
+	m.pickedPaths = nil
+	m.browsing = true
+	m.pathInput.Blur()
+	return m.pathPicker.Init()
+}
+
+// stopBrowsing closes the browser, adding the paths picked to the editor.
+func (m *model) stopBrowsing() tea.Cmd {
+	m.browsing = false
+	return tea.Batch(m.addPaths(m.pickedPaths...), m.pathInput.Focus())
+}
+
+// updateBrowsing passes keys and directory listings to the browser. Picking
+// a path that is already picked drops it again.
+func updateBrowsing(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
+	if m.gcsBrowsing {
+		return updateGCSBrowsing(m, msg)
+	}
+	if msg, ok := msg.(tea.KeyMsg); ok && key.Matches(msg, m.keys.Browse) {
+		return m, m.stopBrowsing()
+	}
+	picker, cmd := m.pathPicker.Update(msg)
+	if picked, path := picker.DidSelectFile(msg); picked {
+		if i := slices.Index(m.pickedPaths, path); i >= 0 {
+			m.pickedPaths = slices.Delete(m.pickedPaths, i, i+1)
+		} else {
+			m.pickedPaths = append(m.pickedPaths, path)
+		}
+		// Picking a folder also opens it; go back up so that several
+		// folders side by side can be picked.
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			picker, cmd = picker.Update(tea.KeyMsg{Type: tea.KeyLeft})
+		}
+	}
+	m.pathPicker = picker
+	return m, cmd
+}
+
+func renderBrowsing(m *model) string {
+	if m.gcsBrowsing {
+		return renderGCSBrowsing(m)
+	}
+	var b strings.Builder
+	b.WriteString(headerStyle.Render("Browse for paths to analyse") + "\n")
+	b.WriteString(m.pathPicker.CurrentDirectory + "\n\n")
+	b.WriteString(m.pathPicker.View())
+	if len(m.pickedPaths) == 0 {
+		b.WriteString("\nNothing picked yet.")
+	} else {
+		b.WriteString("\nPicked:")
+		for _, p := range m.pickedPaths {
+			b.WriteString("\n  " + selectionStyle.Render(p))
+		}
+	}
+	b.WriteString(helpStyle.Render("\nUse up/down to move, enter or right to open a folder, left to go up, space to pick a file or folder. Press " + m.keys.Browse.Help().Key + " to add the picked paths and return to typing, " + m.keys.Back.Help().Key + " to cancel."))
+	return b.String()
+}
+
+// gcsListMsg carries a listing of GCS buckets, or of the prefixes and
+// objects under path.
+type gcsListMsg struct {
+	path      string
+	entries   []source.GCSEntry
+	truncated bool
+	err       error
+}
+
+// listGCSCmd lists the buckets when path is empty, or what is under path.
+func listGCSCmd(ctx context.Context, path string) tea.Cmd {
+	return func() tea.Msg {
+		if path == "" {
+			entries, err := source.ListGCSBuckets(ctx)
+			return gcsListMsg{entries: entries, err: err}
+		}
+		entries, truncated, err := source.ListGCSPrefix(ctx, path)
+		return gcsListMsg{path: path, entries: entries, truncated: truncated, err: err}
+	}
+}
+
+// startGCSBrowsing opens the GCS browser at the last gs:// path typed, or at
+// the list of buckets.
+func (m *model) startGCSBrowsing() tea.Cmd {
+	path := ""
+	if last := m.lastEditorPath(); strings.HasPrefix(last, "gs://") && len(last) > len("gs://") {
+		path = last
+		if !strings.HasSuffix(path, "/") {
+			path += "/"
+		}
+	}
+	m.pickedPaths = nil
+	m.browsing = true
+	m.gcsBrowsing = true
+	m.pathInput.Blur()
+	return m.openGCS(path)
+}
+
+// openGCS starts listing path.
+func (m *model) openGCS(path string) tea.Cmd {
+	m.gcsPath = path
+	m.gcsLoading = true
+	m.gcsErr = nil
+	return listGCSCmd(m.ctx, path)
+}
+
+// gcsParent returns the path one level up from a gs:// bucket or prefix
+// path, or "" for the list of buckets.
+func gcsParent(path string) string {
+	trimmed := strings.TrimSuffix(strings.TrimPrefix(path, "gs://"), "/")
+	i := strings.LastIndex(trimmed, "/")
+	if i < 0 {
+		return ""
+	}
+	return "gs://" + trimmed[:i+1]
+}
+
+func updateGCSBrowsing(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case gcsListMsg:
+		if msg.path != m.gcsPath {
+			return m, nil
+		}
+		m.gcsLoading = false
+		m.gcsErr = msg.err
+		m.gcsEntries, m.gcsTruncated = msg.entries, msg.truncated
+		m.gcsCursor = 0
+	case tea.KeyMsg:
+		if key.Matches(msg, m.keys.Browse, m.keys.BrowseGCS) {
+			m.gcsBrowsing = false
+			return m, m.stopBrowsing()
+		}
+		last := len(m.gcsEntries) - 1
+		if m.gcsLoading {
+			last = -1
+		}
+		switch msg.String() {
+		case "up", "k":
+			m.gcsCursor = max(m.gcsCursor-1, 0)
+		case "down", "j":
+			m.gcsCursor = max(min(m.gcsCursor+1, last), 0)
+		case "left", "h", "backspace":
+			if m.gcsPath != "" {
+				return m, m.openGCS(gcsParent(m.gcsPath))
+			}
+		case "enter", "right", "l":
+			if m.gcsCursor <= last && m.gcsEntries[m.gcsCursor].IsPrefix {
+				return m, m.openGCS(m.gcsEntries[m.gcsCursor].Path)
+			}
+		case " ":
+			if m.gcsCursor <= last {
+				path := m.gcsEntries[m.gcsCursor].Path
+				if i := slices.Index(m.pickedPaths, path); i >= 0 {
+					m.pickedPaths = slices.Delete(m.pickedPaths, i, i+1)
+				} else {
+					m.pickedPaths = append(m.pickedPaths, path)
+				}
+			}
+		}
+	}
+	return m, nil
+}
+
+func renderGCSBrowsing(m *model) string {
+	var b strings.Builder
+	b.WriteString(headerStyle.Render("Browse GCS for paths to analyse") + "\n")
+	location := m.gcsPath
+	if location == "" {
+		location = "Buckets of project " + os.Getenv(source.ProjectEnv)
+	}
+	b.WriteString(location + "\n\n")
+	switch {
+	case m.gcsLoading:
+		b.WriteString("Listing...\n")
+	case m.gcsErr != nil:
+		b.WriteString(errorStyle.Render(m.gcsErr.Error()) + "\n")
+	case len(m.gcsEntries) == 0:
+		b.WriteString("Nothing here.\n")
+	default:
+		page := max(m.height-12, 5)
+		start := m.gcsCursor / page * page
+		for i := start; i < min(start+page, len(m.gcsEntries)); i++ {
+			e := m.gcsEntries[i]
+			cursor := "  "
+			if i == m.gcsCursor {
+				cursor = selectionStyle.Render("> ")
+			}
+			detail := report.HumanSize(e.Size)
+			if e.IsPrefix {
+				detail = ""
+				if m.gcsPath != "" {
+					detail = fmt.Sprintf("%d object(s)", e.Objects)
+					if m.gcsTruncated {
+						detail = fmt.Sprintf("%d+ object(s)", e.Objects)
+					}
+				}
+			}
+			picked := "    "
+			if slices.Contains(m.pickedPaths, e.Path) {
+				picked = selectionStyle.Render("[x] ")
+			}
+			b.WriteString(fmt.Sprintf("%s%s%-48s %s\n", cursor, picked, e.Name, timingStyle.Render(detail)))
+		}
+		if m.gcsTruncated {
+			b.WriteString(timingStyle.Render(fmt.Sprintf("Counts stop after the first %d objects.", source.MaxCountedObjects)) + "\n")
+		}
+	}
+	if len(m.pickedPaths) > 0 {
+		b.WriteString("\nPicked:")
+		for _, p := range m.pickedPaths {
+			b.WriteString("\n  " + selectionStyle.Render(p))
+		}
+	}
+	b.WriteString(helpStyle.Render("\nUse up/down to move, enter or right to open a bucket or prefix, left to go up, space to pick one. Press " + m.keys.Browse.Help().Key + " to add the picked paths and return to typing, " + m.keys.Back.Help().Key + " to cancel."))
+	return b.String()
+}
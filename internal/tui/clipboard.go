@@ -0,0 +1,58 @@
+// internal/tui/clipboard.go
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/muesli/termenv"
+)
+
+// clipboardMsg reports what was copied to the clipboard.
+type clipboardMsg struct {
+	what string
+	// viaTerminal is set when no clipboard tool was found and the text was
+	// handed to the terminal instead, which may ignore it.
+	viaTerminal bool
+}
+
+// copyCmd copies text to the system clipboard. Without a clipboard tool, as
+// over SSH, it falls back to the OSC 52 escape sequence, which most modern
+// terminals turn into a copy on the local machine.
+func copyCmd(what, text string) tea.Cmd {
+	return func() tea.Msg {
+		if err := clipboard.WriteAll(text); err != nil {
+			termenv.NewOutput(os.Stdout).Copy(text)
+			return clipboardMsg{what: what, viaTerminal: true}
+		}
+		return clipboardMsg{what: what}
+	}
+}
+
+// String describes the copy for the status line.
+func (c clipboardMsg) String() string {
+	if c.viaTerminal {
+		return fmt.Sprintf("Sent %s to the terminal's clipboard.", c.what)
+	}
+	return fmt.Sprintf("Copied %s to the clipboard.", c.what)
+}
+
+// detailSetText renders a duplicate set and every one of its locations for
+// pasting into a ticket.
+func detailSetText(set detailSet) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Duplicate %s: %d locations\n", set.label, len(set.locations)))
+	for _, loc := range set.locations {
+		b.WriteString(fmt.Sprintf("  %s:%d\n", loc.FilePath, loc.LineNumber))
+	}
+	return b.String()
+}
+
+// summaryText renders the report as shown on the report screen, without the
+// purge results.
+func (m *model) summaryText() string {
+	return strings.TrimSpace(m.finalReport.String(false, m.checkKey, m.checkRow, m.showFolderBreakdown)) + "\n"
+}
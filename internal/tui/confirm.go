@@ -0,0 +1,146 @@
+// internal/tui/confirm.go
+package tui
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/benjaminwestern/dupe-analyser/internal/purge"
+)
+
+// purgeConfirmWord must be typed to start a purge.
+const purgeConfirmWord = "yes"
+
+// purgePlan turns the choices made for every set into the records to
+// delete.
+func (m *model) purgePlan() purge.Plan {
+	plan := make(purge.Plan)
+	for i, keep := range m.purgeChoices {
+		if keep == nil {
+			continue
+		}
+		for j, loc := range m.purgeLocations(i) {
+			if !keep[j] {
+				plan.Delete(loc)
+			}
+		}
+	}
+	return plan
+}
+
+// noteSkippedSets logs the sets skipped for manual follow-up and lists them
+// under the report.
+func (m *model) noteSkippedSets() {
+	for i, keep := range m.purgeChoices {
+		if keep == nil {
+			label := m.purgeSetLabel(i)
+			slog.Info("Purge: duplicate set skipped for manual follow-up", "set", label, "locations", len(m.purgeLocations(i)))
+			m.purgeSkipped = append(m.purgeSkipped, label)
+		}
+	}
+}
+
+// confirmPurge shows what the choices made will delete and waits for the
+// purge to be confirmed.
+func (m *model) confirmPurge() tea.Cmd {
+	m.recordsToDelete = m.purgePlan()
+	input := textinput.New()
+	input.Placeholder = purgeConfirmWord
+	input.CharLimit = 16
+	m.purgeConfirmInput = input
+	m.purgeConfirmErr = ""
+	m.viewState = viewPurgeConfirm
+	return m.purgeConfirmInput.Focus()
+}
+
+// startPurge deletes the records confirmed, noting the sets skipped for
+// manual follow-up.
+func (m *model) startPurge() tea.Cmd {
+	m.noteSkippedSets()
+	m.purgeConfirmInput.Blur()
+	m.viewState = viewPurging
+	m.status = "Purging records..."
+	return tea.Batch(performPurgeCmd(m.recordsToDelete, m.key, m.logPath), m.spinner.Tick)
+}
+
+func updatePurgeConfirm(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok && msg.Type == tea.KeyEnter {
+		if strings.EqualFold(strings.TrimSpace(m.purgeConfirmInput.Value()), purgeConfirmWord) {
+			return m, m.startPurge()
+		}
+		m.purgeConfirmErr = fmt.Sprintf("Type %q and press Enter to purge, or press esc to go back.", purgeConfirmWord)
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.purgeConfirmInput, cmd = m.purgeConfirmInput.Update(msg)
+	return m, cmd
+}
+
+// purgeFolder returns the folder of a local path or GCS object.
+func purgeFolder(path string) string {
+	if strings.HasPrefix(path, "gs://") {
+		if i := strings.LastIndex(path, "/"); i > len("gs://") {
+			return path[:i]
+		}
+		return path
+	}
+	return filepath.Dir(path)
+}
+
+// renderPurgeConfirm renders the files to modify and the records to delete
+// in each folder, and the confirmation input.
+func renderPurgeConfirm(m *model) string {
+	type folderCount struct{ files, records int }
+	counts := make(map[string]*folderCount)
+	for path, lines := range m.recordsToDelete {
+		folder := purgeFolder(path)
+		if counts[folder] == nil {
+			counts[folder] = &folderCount{}
+		}
+		counts[folder].files++
+		counts[folder].records += len(lines)
+	}
+	folders := slices.Sorted(maps.Keys(counts))
+
+	var b strings.Builder
+	b.WriteString(headerStyle.Render("Confirm Purge") + "\n")
+	b.WriteString(fmt.Sprintf("%d record(s) will be deleted from %d file(s). Each file is backed up first.\n\n", m.recordsToDelete.Records(), len(m.recordsToDelete)))
+	b.WriteString(timingStyle.Render(fmt.Sprintf("  %-*s  %8s  %10s", folderProgressWidth, "Folder", "Files", "Records")) + "\n")
+	limit := len(folders)
+	if m.height > 0 {
+		limit = max(m.height-14, 3)
+	}
+	for i, f := range folders {
+		if i == limit {
+			b.WriteString(timingStyle.Render(fmt.Sprintf("  ...and %d more folder(s)", len(folders)-i)) + "\n")
+			break
+		}
+		name := []rune(f)
+		if len(name) > folderProgressWidth {
+			name = append([]rune("…"), name[len(name)-folderProgressWidth+1:]...)
+		}
+		b.WriteString(fmt.Sprintf("  %-*s  %8d  %10d\n", folderProgressWidth, string(name), counts[f].files, counts[f].records))
+	}
+	skipped := 0
+	for _, keep := range m.purgeChoices {
+		if keep == nil {
+			skipped++
+		}
+	}
+	if skipped > 0 {
+		b.WriteString(fmt.Sprintf("\n%d set(s) skipped will be left untouched.\n", skipped))
+	}
+	b.WriteString(fmt.Sprintf("\nType %q to purge: %s", purgeConfirmWord, m.purgeConfirmInput.View()))
+	if m.purgeConfirmErr != "" {
+		b.WriteString("\n" + errorStyle.Render(m.purgeConfirmErr))
+	}
+	b.WriteString(helpStyle.Render("\nPress Enter to confirm, esc to go back to the last set."))
+	return b.String()
+}
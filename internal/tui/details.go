@@ -0,0 +1,143 @@
+// internal/tui/details.go
+package tui
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+)
+
+// maxDetailLocations limits the locations listed under an expanded set.
+const maxDetailLocations = 50
+
+// detailSet is one duplicate set listed in the details view.
+type detailSet struct {
+	label     string
+	locations []report.LocationInfo
+}
+
+// openDetails lists the duplicate sets of the report that match the current
+// search, most repeated first.
+func (m *model) openDetails() {
+	m.detailSets = nil
+	if m.checkKey {
+		for _, k := range m.matchingKeys(m.finalReport.DuplicateIDs) {
+			m.detailSets = append(m.detailSets, detailSet{label: fmt.Sprintf("ID '%s'", k), locations: m.search.locations(m.finalReport.DuplicateIDs[k])})
+		}
+	}
+	if m.checkRow {
+		for _, h := range m.matchingKeys(m.finalReport.DuplicateRows) {
+			m.detailSets = append(m.detailSets, detailSet{label: fmt.Sprintf("Row %s", h), locations: m.search.locations(m.finalReport.DuplicateRows[h])})
+		}
+	}
+	slices.SortStableFunc(m.detailSets, func(a, b detailSet) int { return cmp.Compare(len(b.locations), len(a.locations)) })
+	m.detailCursor = 0
+	m.detailExpanded = make(map[int]bool)
+	m.viewState = viewDetails
+}
+
+// detailPageSize returns the number of sets on a page of the details view.
+func (m *model) detailPageSize() int {
+	if m.height == 0 {
+		return 20
+	}
+	return max(m.height-8, 5)
+}
+
+func updateDetails(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok || len(m.detailSets) == 0 {
+		return m, nil
+	}
+	if key.Matches(keyMsg, m.keys.Copy) {
+		set := m.detailSets[m.detailCursor]
+		return m, copyCmd(set.label, detailSetText(set))
+	}
+	if key.Matches(keyMsg, m.keys.View) {
+		set := m.detailSets[m.detailCursor]
+		locations := set.locations[:min(len(set.locations), maxDetailLocations)]
+		return m, m.openPreview("Records of "+set.label, locations)
+	}
+	page := m.detailPageSize()
+	last := len(m.detailSets) - 1
+	switch keyMsg.String() {
+	case "up", "k":
+		m.detailCursor = max(m.detailCursor-1, 0)
+	case "down", "j":
+		m.detailCursor = min(m.detailCursor+1, last)
+	case "pgup", "left":
+		m.detailCursor = max(m.detailCursor-page, 0)
+	case "pgdown", "right":
+		m.detailCursor = min(m.detailCursor+page, last)
+	case "home":
+		m.detailCursor = 0
+	case "end":
+		m.detailCursor = last
+	case "enter", " ":
+		m.detailExpanded[m.detailCursor] = !m.detailExpanded[m.detailCursor]
+	}
+	return m, nil
+}
+
+// renderDetails renders the page of duplicate sets holding the cursor, with
+// the locations of each expanded set.
+func renderDetails(m *model) string {
+	s, _ := detailsLayout(m)
+	return s
+}
+
+// detailsLayout renders the details view, returning with it the set shown
+// on each line that names one, by line number.
+func detailsLayout(m *model) (string, map[int]int) {
+	var b strings.Builder
+	rows := make(map[int]int)
+	title := fmt.Sprintf("Duplicate Sets (%d)", len(m.detailSets))
+	if m.search.active() {
+		title = fmt.Sprintf("Duplicate Sets matching %q (%d)", m.search.query, len(m.detailSets))
+	}
+	b.WriteString(headerStyle.Render(title) + "\n")
+	if len(m.detailSets) == 0 {
+		b.WriteString("No duplicate sets to show.\n")
+		b.WriteString(helpStyle.Render("Press esc to go back to the report."))
+		return b.String(), rows
+	}
+	page := m.detailPageSize()
+	start := m.detailCursor / page * page
+	end := min(start+page, len(m.detailSets))
+	pages := (len(m.detailSets) + page - 1) / page
+	b.WriteString(timingStyle.Render(fmt.Sprintf("Page %d of %d", start/page+1, pages)) + "\n\n")
+	for i := start; i < end; i++ {
+		set := m.detailSets[i]
+		cursor := "  "
+		if i == m.detailCursor {
+			cursor = selectionStyle.Render("> ")
+		}
+		marker := "[+]"
+		if m.detailExpanded[i] {
+			marker = "[-]"
+		}
+		rows[strings.Count(b.String(), "\n")] = i
+		b.WriteString(fmt.Sprintf("%s%s %s: %d locations\n", cursor, marker, set.label, len(set.locations)))
+		if !m.detailExpanded[i] {
+			continue
+		}
+		for j, loc := range set.locations {
+			if j == maxDetailLocations {
+				b.WriteString(fmt.Sprintf("      ...and %d more\n", len(set.locations)-j))
+				break
+			}
+			b.WriteString(fmt.Sprintf("      %s:%d\n", loc.FilePath, loc.LineNumber))
+		}
+	}
+	if m.notice != "" {
+		b.WriteString(timingStyle.Render(m.notice) + "\n")
+	}
+	b.WriteString(helpStyle.Render("Use up/down to move, enter or space to expand a set, PgUp/PgDn or left/right to change page, Home/End to jump, " + m.keys.View.Help().Key + " to view the records, " + m.keys.Copy.Help().Key + " to copy a set. Press esc to go back."))
+	return b.String(), rows
+}
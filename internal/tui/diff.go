@@ -0,0 +1,137 @@
+// internal/tui/diff.go
+package tui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxDiffRecords is the number of records compared side by side, and
+// maxDiffSameFields the number of identical fields listed before they are
+// only counted.
+const (
+	maxDiffRecords    = 4
+	maxDiffSameFields = 10
+)
+
+// loadPurgeDiff reads the records of the current set for the field diff when
+// it is shown and not already loaded.
+func (m *model) loadPurgeDiff() tea.Cmd {
+	if !m.purgeDiff || m.viewState != viewPurgeSelection || m.purgeDiffSet == m.purgeCursor && m.purgeDiffRecords != nil {
+		return nil
+	}
+	m.purgeDiffSet = m.purgeCursor
+	m.purgeDiffRecords = nil
+	m.previewID++
+	return loadPreviewCmd(m.ctx, m.previewID, m.originalSources, m.finalReport, m.maxLineSize(), m.purgeLocations(m.purgeCursor))
+}
+
+// diffWindow returns the range of records compared: up to maxDiffRecords,
+// taking in the one under the cursor.
+func diffWindow(cursor, n int) (int, int) {
+	start := max(0, min(cursor-maxDiffRecords+1, n-maxDiffRecords))
+	return start, min(start+maxDiffRecords, n)
+}
+
+// renderPurgeDiff renders the top-level fields of the records of the current
+// set side by side, marking the fields whose values differ.
+func renderPurgeDiff(m *model) string {
+	if !m.purgeDiff {
+		return ""
+	}
+	if m.purgeDiffRecords == nil || m.purgeDiffSet != m.purgeCursor {
+		return "\nReading records to compare...\n"
+	}
+	start, end := diffWindow(m.purgeSelectionCursor, len(m.purgeDiffRecords))
+	records := m.purgeDiffRecords[start:end]
+	fields := make([]map[string]json.RawMessage, len(records))
+	var names []string
+	for i, rec := range records {
+		if rec.err != nil || json.Unmarshal([]byte(rec.raw), &fields[i]) != nil {
+			continue
+		}
+		for name := range fields[i] {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	slices.Sort(names)
+
+	width := 80
+	if m.width > 0 {
+		width = m.width
+	}
+	nameWidth := 16
+	colWidth := max((width-nameWidth-4)/len(records)-2, 8)
+	cell := func(s string, w int) string {
+		r := []rune(s)
+		if len(r) > w {
+			return string(r[:w-1]) + "…"
+		}
+		return s + strings.Repeat(" ", w-len(r))
+	}
+
+	var b strings.Builder
+	b.WriteString("\nField differences")
+	if len(m.purgeDiffRecords) > len(records) {
+		b.WriteString(fmt.Sprintf(" (records %d-%d of %d)", start+1, end, len(m.purgeDiffRecords)))
+	}
+	b.WriteString(":\n  " + cell("", nameWidth))
+	for i := range records {
+		b.WriteString("  " + cell(fmt.Sprintf("#%d", start+i+1), colWidth))
+	}
+	b.WriteString("\n")
+	same := 0
+	for _, name := range names {
+		values := make([]string, len(records))
+		differ := false
+		for i := range records {
+			switch {
+			case records[i].err != nil:
+				values[i] = "(unreadable)"
+			case fields[i] == nil:
+				values[i] = "(not JSON)"
+			case fields[i][name] == nil:
+				values[i] = "—"
+			default:
+				var compact bytes.Buffer
+				if json.Compact(&compact, fields[i][name]) == nil {
+					values[i] = compact.String()
+				} else {
+					values[i] = string(fields[i][name])
+				}
+			}
+			differ = differ || values[i] != values[0]
+		}
+		if !differ {
+			same++
+			if len(names) > maxDiffSameFields {
+				continue
+			}
+		}
+		marker := "  "
+		if differ {
+			marker = selectionStyle.Render("≠ ")
+		}
+		line := cell(name, nameWidth)
+		for _, v := range values {
+			line += "  " + cell(v, colWidth)
+		}
+		if !differ {
+			line = timingStyle.Render(line)
+		}
+		b.WriteString(marker + line + "\n")
+	}
+	if same == len(names) {
+		b.WriteString(timingStyle.Render("  The records have the same fields and values.") + "\n")
+	} else if len(names) > maxDiffSameFields && same > 0 {
+		b.WriteString(timingStyle.Render(fmt.Sprintf("  %d identical field(s) not shown.", same)) + "\n")
+	}
+	return b.String()
+}
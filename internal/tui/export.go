@@ -0,0 +1,62 @@
+// internal/tui/export.go
+package tui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+)
+
+// exportMsg reports the files an export wrote.
+type exportMsg struct {
+	files     []string
+	ids, rows int
+	err       error
+}
+
+// filteredReport returns the report cut down to the duplicate sets, and the
+// locations of them, that match the current search.
+func (m *model) filteredReport() *report.AnalysisReport {
+	r := &report.AnalysisReport{
+		SchemaVersion: m.finalReport.SchemaVersion,
+		Summary:       m.finalReport.Summary,
+		DuplicateIDs:  make(map[string][]report.LocationInfo),
+		DuplicateRows: make(map[string][]report.LocationInfo),
+	}
+	if m.checkKey {
+		for _, k := range m.matchingKeys(m.finalReport.DuplicateIDs) {
+			r.DuplicateIDs[k] = m.search.locations(m.finalReport.DuplicateIDs[k])
+		}
+	}
+	if m.checkRow {
+		for _, h := range m.matchingKeys(m.finalReport.DuplicateRows) {
+			r.DuplicateRows[h] = m.search.locations(m.finalReport.DuplicateRows[h])
+		}
+	}
+	return r
+}
+
+// exportCmd writes the sets matching the current search to a timestamped
+// CSV and JSON file in the log path, next to the saved reports.
+func (m *model) exportCmd() tea.Cmd {
+	r := m.filteredReport()
+	base := filepath.Join(m.logPath, "export-"+time.Now().Format("2006-01-02_15-04-05"))
+	checkKey, checkRow := m.checkKey, m.checkRow
+	return func() tea.Msg {
+		files, err := r.Export(base, checkKey, checkRow)
+		return exportMsg{files: files, ids: len(r.DuplicateIDs), rows: len(r.DuplicateRows), err: err}
+	}
+}
+
+// String describes the export for the status line.
+func (e exportMsg) String() string {
+	if e.err != nil {
+		return "Export failed: " + e.err.Error()
+	}
+	return fmt.Sprintf("Exported %d duplicate ID set(s) and %d duplicate row set(s) to %s.", e.ids, e.rows, strings.Join(e.files, " and "))
+}
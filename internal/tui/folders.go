@@ -0,0 +1,67 @@
+// internal/tui/folders.go
+package tui
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/analyser"
+)
+
+// folderProgressWidth is the width of the folder column of the progress
+// panel; longer folders keep their last characters.
+const folderProgressWidth = 48
+
+// folderRank orders the progress panel: folders being read, then those not
+// yet started, then those complete.
+func folderRank(f analyser.FolderProgress) int {
+	switch {
+	case f.Complete():
+		return 2
+	case f.Started():
+		return 0
+	}
+	return 1
+}
+
+// renderFolderProgress renders a table of the files done and rows read in
+// each folder, as many as fit in the terminal, most active first.
+func renderFolderProgress(m *model) string {
+	if len(m.folderProgress) == 0 {
+		return ""
+	}
+	folders := slices.Clone(m.folderProgress)
+	slices.SortStableFunc(folders, func(a, b analyser.FolderProgress) int { return cmp.Compare(folderRank(a), folderRank(b)) })
+	complete := 0
+	for _, f := range folders {
+		if f.Complete() {
+			complete++
+		}
+	}
+	limit := 10
+	if m.height > 0 {
+		limit = max(m.height-12-logPaneHeight(m), 3)
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("\n\nFolders (%d of %d complete):\n", complete, len(folders)))
+	b.WriteString(timingStyle.Render(fmt.Sprintf("  %-*s  %15s  %12s", folderProgressWidth, "Folder", "Files", "Rows")) + "\n")
+	for i, f := range folders {
+		if i == limit {
+			b.WriteString(timingStyle.Render(fmt.Sprintf("  ...and %d more folder(s)", len(folders)-i)) + "\n")
+			break
+		}
+		name := []rune(f.Folder)
+		if len(name) > folderProgressWidth {
+			name = append([]rune("…"), name[len(name)-folderProgressWidth+1:]...)
+		}
+		row := fmt.Sprintf("  %-*s  %15s  %12d", folderProgressWidth, string(name), fmt.Sprintf("%d / %d", f.FilesDone, f.TotalFiles), f.Rows)
+		if !f.Started() || f.Complete() {
+			row = timingStyle.Render(row)
+		}
+		b.WriteString(row + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,235 @@
+// internal/tui/history.go
+package tui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+)
+
+// The actions of the Previous Reports view, run once the reports they need
+// have been read.
+const (
+	historyOpen = iota
+	historyCompare
+	historyRelaunch
+)
+
+// historyLoadedMsg carries the saved reports read for a history action.
+type historyLoadedMsg struct {
+	action  int
+	runs    []report.SavedReport
+	reports []*report.AnalysisReport
+}
+
+// openHistory lists the reports saved as JSON in the log path.
+func (m *model) openHistory() {
+	m.historyRuns, m.historyErr = report.ListSaved(m.logPath)
+	m.historyCursor = 0
+	m.historyMark = -1
+	m.viewState = viewHistory
+}
+
+// loadHistoryCmd reads the JSON reports of runs.
+func loadHistoryCmd(action int, runs ...report.SavedReport) tea.Cmd {
+	return func() tea.Msg {
+		msg := historyLoadedMsg{action: action, runs: runs}
+		for _, run := range runs {
+			rep, err := report.LoadJSON(run.JSONPath)
+			if err != nil {
+				return errMsg{err}
+			}
+			msg.reports = append(msg.reports, rep)
+		}
+		return msg
+	}
+}
+
+func updateHistory(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case historyLoadedMsg:
+		return m.applyHistory(msg)
+	case tea.KeyMsg:
+		if len(m.historyRuns) == 0 {
+			return m, nil
+		}
+		m.historyErr = nil
+		page := m.detailPageSize()
+		last := len(m.historyRuns) - 1
+		switch msg.String() {
+		case "up", "k":
+			m.historyCursor = max(m.historyCursor-1, 0)
+		case "down", "j":
+			m.historyCursor = min(m.historyCursor+1, last)
+		case "pgup", "left":
+			m.historyCursor = max(m.historyCursor-page, 0)
+		case "pgdown", "right":
+			m.historyCursor = min(m.historyCursor+page, last)
+		case "home":
+			m.historyCursor = 0
+		case "end":
+			m.historyCursor = last
+		case "enter":
+			return m, loadHistoryCmd(historyOpen, m.historyRuns[m.historyCursor])
+		case "r":
+			return m, loadHistoryCmd(historyRelaunch, m.historyRuns[m.historyCursor])
+		case "c":
+			switch m.historyMark {
+			case -1:
+				m.historyMark = m.historyCursor
+			case m.historyCursor:
+				m.historyMark = -1
+			default:
+				// Runs are listed newest first, so the later one in the list
+				// is the earlier run.
+				before, after := m.historyRuns[max(m.historyMark, m.historyCursor)], m.historyRuns[min(m.historyMark, m.historyCursor)]
+				m.historyMark = -1
+				return m, loadHistoryCmd(historyCompare, before, after)
+			}
+		}
+	}
+	return m, nil
+}
+
+// applyHistory opens, compares or relaunches the reports read for a history
+// action.
+func (m model) applyHistory(msg historyLoadedMsg) (tea.Model, tea.Cmd) {
+	rep := msg.reports[0]
+	switch msg.action {
+	case historyCompare:
+		m.historyDiff = report.Diff(msg.runs[0].Name, rep, msg.runs[1].Name, msg.reports[1]).String()
+		m.historyView.YOffset = 0
+		m.viewState = viewHistoryDiff
+		return m, nil
+	case historyRelaunch:
+		if len(rep.Summary.Inputs) == 0 {
+			m.historyErr = fmt.Errorf("%s does not record the paths it was run on, so it cannot be relaunched", msg.runs[0].Name)
+			return m, nil
+		}
+	}
+
+	// Start from the report's settings, as if the run had just finished.
+	s := rep.Summary
+	if len(s.Inputs) > 0 {
+		m.path = strings.Join(s.Inputs, ",")
+	}
+	if s.UniqueKey != "" {
+		m.key = s.UniqueKey
+		m.keyInput.SetValue(m.key)
+	}
+	if s.KeyChecked || s.RowChecked {
+		m.checkKey, m.checkRow = s.KeyChecked, s.RowChecked
+	}
+	m.isValidationRun = s.IsValidationReport
+	m.analyser = nil
+	m.originalSources = nil
+	m.purgeStats = purgeResultMsg{}
+	m.search = searchFilter{}
+	m.wasCancelled = false
+
+	if msg.action == historyRelaunch {
+		m.finalReport = nil
+		m.totalElapsedTime = 0
+		m.viewState = viewProcessing
+		return m, discoverAllSourcesCmd(m.ctx, s.Inputs)
+	}
+	m.finalReport = rep
+	m.savedFilename = strings.TrimSuffix(strings.TrimSuffix(msg.runs[0].JSONPath, ".gz"), ".json")
+	m.reportView.YOffset = 0
+	m.viewState = viewReport
+	return m, nil
+}
+
+func renderHistory(m *model) string {
+	s, _ := historyLayout(m)
+	return s
+}
+
+// historyLayout renders the list of saved reports, returning with it the
+// run shown on each line that names one, by line number.
+func historyLayout(m *model) (string, map[int]int) {
+	var b strings.Builder
+	rows := make(map[int]int)
+	b.WriteString(headerStyle.Render(fmt.Sprintf("Previous Reports in %s (%d)", m.logPath, len(m.historyRuns))) + "\n")
+	if m.historyErr != nil {
+		b.WriteString(errorStyle.Render(m.historyErr.Error()) + "\n\n")
+	}
+	if len(m.historyRuns) == 0 {
+		b.WriteString("No JSON reports found. Enable the JSON report in Options to keep a history of runs.\n")
+		b.WriteString(helpStyle.Render("Press esc to go back to the menu."))
+		return b.String(), rows
+	}
+	page := m.detailPageSize()
+	start := m.historyCursor / page * page
+	end := min(start+page, len(m.historyRuns))
+	b.WriteString(timingStyle.Render(fmt.Sprintf("Page %d of %d", start/page+1, (len(m.historyRuns)+page-1)/page)) + "\n\n")
+	for i := start; i < end; i++ {
+		run := m.historyRuns[i]
+		cursor := "  "
+		if i == m.historyCursor {
+			cursor = selectionStyle.Render("> ")
+		}
+		mark := "    "
+		if i == m.historyMark {
+			mark = selectionStyle.Render("[c] ")
+		}
+		rows[strings.Count(b.String(), "\n")] = i
+		b.WriteString(fmt.Sprintf("%s%s%s  %s\n", cursor, mark, run.Time.Format("2006-01-02 15:04:05"), filepath.Base(run.JSONPath)))
+	}
+	help := "Use up/down to move, enter to open a report, r to run its job again, c to pick a report and c on another to compare them. Press esc to go back."
+	if m.historyMark >= 0 {
+		help = "Move to another report and press c to compare it with the one picked, or c again to cancel."
+	}
+	b.WriteString(helpStyle.Render(help))
+	return b.String(), rows
+}
+
+var historyDiffHelp = "\n" + helpStyle.Render("Use up/down or PgUp/PgDn to scroll. Press esc to go back to the reports.")
+
+// historyViewport returns the comparison viewport sized to the terminal,
+// less the lines the help takes.
+func (m *model) historyViewport() viewport.Model {
+	vp := m.historyView
+	vp.Width = m.width
+	vp.Height = max(m.height-lipgloss.Height(historyDiffHelp), 1)
+	vp.SetContent(m.historyDiff)
+	return vp
+}
+
+func updateHistoryDiff(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	vp := m.historyViewport()
+	switch key.String() {
+	case "pgup", "left":
+		vp.PageUp()
+	case "pgdown", "right", " ":
+		vp.PageDown()
+	case "home":
+		vp.GotoTop()
+	case "end":
+		vp.GotoBottom()
+	case "up", "k":
+		vp.ScrollUp(1)
+	case "down", "j":
+		vp.ScrollDown(1)
+	}
+	m.historyView.YOffset = vp.YOffset
+	return m, nil
+}
+
+func renderHistoryDiff(m *model) string {
+	if m.height == 0 {
+		return m.historyDiff + historyDiffHelp
+	}
+	vp := m.historyViewport()
+	return vp.View() + historyDiffHelp
+}
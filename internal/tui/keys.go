@@ -0,0 +1,158 @@
+// internal/tui/keys.go
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// keyMap holds the keys of the TUI actions that can be rebound in
+// config.KeysPath. Keys that move through a list, and those of the Previous
+// Reports list, are fixed.
+type keyMap struct {
+	Quit      key.Binding
+	Back      key.Binding
+	Help      key.Binding
+	Browse    key.Binding
+	BrowseGCS key.Binding
+	Restart   key.Binding
+	NewJob    key.Binding
+	Analyse   key.Binding
+	Continue  key.Binding
+	Search    key.Binding
+	Details   key.Binding
+	Purge     key.Binding
+	Copy      key.Binding
+	Export    key.Binding
+	ChangeKey key.Binding
+	Logs      key.Binding
+	View      key.Binding
+	Diff      key.Binding
+	Skip      key.Binding
+	PrevSet   key.Binding
+	KeepAll   key.Binding
+	FirstAll  key.Binding
+}
+
+// keyAction names a binding as it appears in the keys file.
+type keyAction struct {
+	name    string
+	binding *key.Binding
+}
+
+// actions lists the bindings in the order of the cheatsheet.
+func (k *keyMap) actions() []keyAction {
+	return []keyAction{
+		{"quit", &k.Quit},
+		{"back", &k.Back},
+		{"help", &k.Help},
+		{"browse", &k.Browse},
+		{"browseGcs", &k.BrowseGCS},
+		{"restart", &k.Restart},
+		{"newJob", &k.NewJob},
+		{"analyse", &k.Analyse},
+		{"continue", &k.Continue},
+		{"search", &k.Search},
+		{"details", &k.Details},
+		{"purge", &k.Purge},
+		{"copy", &k.Copy},
+		{"export", &k.Export},
+		{"changeKey", &k.ChangeKey},
+		{"logs", &k.Logs},
+		{"view", &k.View},
+		{"diff", &k.Diff},
+		{"skip", &k.Skip},
+		{"previousSet", &k.PrevSet},
+		{"keepAll", &k.KeepAll},
+		{"keepFirstAll", &k.FirstAll},
+	}
+}
+
+func binding(help string, keys ...string) key.Binding {
+	return key.NewBinding(key.WithKeys(keys...), key.WithHelp(strings.Join(keys, " / "), help))
+}
+
+func defaultKeyMap() keyMap {
+	return keyMap{
+		Quit:      binding("Quit the application, or cancel a running job", "q", "ctrl+c"),
+		Back:      binding("Go back to the previous menu", "esc"),
+		Help:      binding("Show this help screen (from main menu)", "?"),
+		Browse:    binding("Browse for local paths, space to pick them (path input)", "tab"),
+		BrowseGCS: binding("Browse GCS buckets and prefixes (path input)", "ctrl+g"),
+		Restart:   binding("Restart the last job (from report screen)", "r"),
+		NewJob:    binding("Start a new job (from report screen)", "n"),
+		Analyse:   binding("Run full analysis (after a validation report)", "a"),
+		Continue:  binding("Continue a cancelled job (from report screen)", "c"),
+		Search:    binding("Search duplicate IDs and row hashes (report screen, or while purging)", "/"),
+		Details:   binding("Browse the duplicate sets and their locations (from report screen)", "d"),
+		Purge:     binding("Proceed to purge duplicates (from report screen)", "p"),
+		Copy:      binding("Copy the summary (report screen) or the selected set (details) to the clipboard", "y"),
+		Export:    binding("Export the sets matching the search to CSV and JSON (report screen)", "x"),
+		ChangeKey: binding("Change the key and rerun on the files already found (report screen)", "e"),
+		Logs:      binding("Show or hide the warnings and errors logged (while processing)", "l"),
+		View:      binding("View the records of a set (details) or the selected record (while purging)", "v"),
+		Diff:      binding("Show which fields differ between the records of a set (while purging)", "f"),
+		Skip:      binding("Skip a set, leaving it untouched (while purging)", "s"),
+		PrevSet:   binding("Go back to revise the previous set (while purging)", "b", "left", "backspace"),
+		KeepAll:   binding("Confirm and keep the pre-selected record of every remaining set (while purging)", "A"),
+		FirstAll:  binding("Keep the first record of this and every remaining set (while purging)", "F"),
+	}
+}
+
+// newKeyMap returns the default keys with those of the actions in keys
+// replaced. An action given no keys is switched off; ctrl+c still quits.
+func newKeyMap(keys map[string][]string) (keyMap, error) {
+	k := defaultKeyMap()
+	actions := k.actions()
+	for name, bound := range keys {
+		i := slices.IndexFunc(actions, func(a keyAction) bool { return a.name == name })
+		if i < 0 {
+			names := make([]string, len(actions))
+			for j, a := range actions {
+				names[j] = a.name
+			}
+			return k, fmt.Errorf("unknown action %q in keys file; expected one of %s", name, strings.Join(names, ", "))
+		}
+		b := actions[i].binding
+		*b = binding(b.Help().Desc, bound...)
+	}
+	return k, nil
+}
+
+// keyNames lists keys for a help line, such as "'q' or 'ctrl+c'".
+func keyNames(keys []string) string {
+	quoted := make([]string, len(keys))
+	for i, k := range keys {
+		quoted[i] = "'" + k + "'"
+	}
+	return strings.Join(quoted, " or ")
+}
+
+// keyHint renders a binding for the report screen's help, as "(r)estart"
+// when the label starts with its first key, or "(x) restart" otherwise.
+func keyHint(b key.Binding, label string) string {
+	keys := b.Keys()
+	if len(keys) == 0 {
+		return ""
+	}
+	if rest, ok := strings.CutPrefix(label, keys[0]); ok {
+		return "(" + keys[0] + ")" + rest
+	}
+	return "(" + keys[0] + ") " + label
+}
+
+// cheatsheet lists every rebindable action with its keys, one per line.
+func (k *keyMap) cheatsheet() string {
+	var b strings.Builder
+	for _, a := range k.actions() {
+		help := a.binding.Help()
+		if help.Key == "" {
+			help.Key = "(none)"
+		}
+		b.WriteString(fmt.Sprintf("  - %-16s%s\n", help.Key+":", help.Desc))
+	}
+	return b.String()
+}
@@ -0,0 +1,51 @@
+// internal/tui/logpane.go
+package tui
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// logPaneEntries is the number of warnings and errors shown while
+// processing.
+const logPaneEntries = 5
+
+// logPaneHeight returns the lines the warnings pane takes under the
+// progress, so the folder table can leave room for it.
+func logPaneHeight(m *model) int {
+	switch {
+	case m.logCount == 0:
+		return 0
+	case m.hideLogs:
+		return 2
+	}
+	return 2 + len(m.logEntries)
+}
+
+// renderLogPane renders the last warnings and errors logged during the run,
+// such as lines that could not be decoded or files that could not be read,
+// or a one-line count of them when the pane is collapsed.
+func renderLogPane(m *model) string {
+	if m.logCount == 0 {
+		return ""
+	}
+	if m.hideLogs {
+		return "\n\n" + timingStyle.Render(fmt.Sprintf("Warnings and errors: %d (%s to show).", m.logCount, m.keys.Logs.Help().Key))
+	}
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("\n\nRecent warnings and errors (%d in all):\n", m.logCount))
+	for _, e := range m.logEntries {
+		line := e.String()
+		if m.width > 4 && len([]rune(line)) > m.width-2 {
+			line = string([]rune(line)[:m.width-3]) + "…"
+		}
+		if e.Level >= slog.LevelError {
+			line = errorStyle.Render(line)
+		} else {
+			line = selectionStyle.Render(line)
+		}
+		b.WriteString("  " + line + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
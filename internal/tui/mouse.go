@@ -0,0 +1,70 @@
+// internal/tui/mouse.go
+package tui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// updateMouse handles mouse events. The wheel scrolls as the up and down
+// keys do; a left click picks the menu item, option, duplicate set or
+// record under the pointer.
+func updateMouse(m model, msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	if m.err != nil || m.searching || m.viewState == viewHelp {
+		return m, nil
+	}
+	switch msg.Button {
+	case tea.MouseButtonWheelUp:
+		return m.Update(tea.KeyMsg{Type: tea.KeyUp})
+	case tea.MouseButtonWheelDown:
+		return m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	case tea.MouseButtonLeft:
+		if msg.Action != tea.MouseActionPress {
+			return m, nil
+		}
+	default:
+		return m, nil
+	}
+
+	// A view taller than the terminal loses its first lines, so the line
+	// clicked is counted from the top of the view rather than the screen.
+	line := msg.Y
+	if m.height > 0 {
+		line += max(lipgloss.Height(m.View())-m.height, 0)
+	}
+	enter := tea.KeyMsg{Type: tea.KeyEnter}
+	switch m.viewState {
+	case viewMenu:
+		if item := line - 2; item >= 0 && item <= 5 {
+			m.menuCursor = item
+			return m.Update(enter)
+		}
+	case viewOptions:
+		if item := line - 2; item >= 0 && item <= 9 {
+			m.optionsCursor = item
+			return m.Update(enter)
+		}
+	case viewDetails:
+		_, rows := detailsLayout(&m)
+		if i, ok := rows[line]; ok {
+			if i == m.detailCursor {
+				return m.Update(enter)
+			}
+			m.detailCursor = i
+		}
+	case viewHistory:
+		_, rows := historyLayout(&m)
+		if i, ok := rows[line]; ok {
+			if i == m.historyCursor {
+				return m.Update(enter)
+			}
+			m.historyCursor = i
+		}
+	case viewPurgeSelection:
+		_, rows := purgeSelectionLayout(&m)
+		if i, ok := rows[line]; ok {
+			m.purgeSelectionCursor = i
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,274 @@
+// internal/tui/paths.go
+package tui
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/source"
+)
+
+var pathValidStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+
+// The states of a path in the path editor.
+const (
+	pathChecking = iota
+	pathValid
+	pathInvalid
+)
+
+// pathEntry is one path listed in the path editor, with the result of
+// checking that it can be discovered.
+type pathEntry struct {
+	path   string
+	status int
+	err    error
+}
+
+// icon renders the status of the path.
+func (e pathEntry) icon() string {
+	switch e.status {
+	case pathValid:
+		return pathValidStyle.Render("✓")
+	case pathInvalid:
+		return errorStyle.Render("✗")
+	}
+	return timingStyle.Render("…")
+}
+
+// pathCheckedMsg carries the result of checking a path.
+type pathCheckedMsg struct {
+	path string
+	err  error
+}
+
+func checkPathCmd(ctx context.Context, path string) tea.Cmd {
+	return func() tea.Msg {
+		return pathCheckedMsg{path: path, err: source.Check(ctx, path)}
+	}
+}
+
+// openPathEditor lists the paths of the last job in the path editor, with
+// the input to add another focused, and checks them again.
+func (m *model) openPathEditor() tea.Cmd {
+	m.pathList = nil
+	m.pathErr = ""
+	m.pathInput.Reset()
+	var paths []string
+	if m.path != "" {
+		paths = m.inputPaths()
+	}
+	cmd := m.addPaths(paths...)
+	m.viewState = viewInputPath
+	return tea.Batch(cmd, m.pathInput.Focus())
+}
+
+// addPaths adds the paths not already listed to the end of the editor and
+// starts checking them, leaving the cursor on the input.
+func (m *model) addPaths(paths ...string) tea.Cmd {
+	var cmds []tea.Cmd
+	for _, p := range paths {
+		p = strings.TrimSpace(p)
+		if p == "" || slices.ContainsFunc(m.pathList, func(e pathEntry) bool { return e.path == p }) {
+			continue
+		}
+		entry := pathEntry{path: p}
+		if strings.HasPrefix(p, "gs://") && !m.gcsAvailable {
+			entry.status, entry.err = pathInvalid, fmt.Errorf("cannot process GCS path: GCS credentials not available")
+		} else {
+			cmds = append(cmds, checkPathCmd(m.ctx, p))
+		}
+		m.pathList = append(m.pathList, entry)
+	}
+	m.pathCursor = len(m.pathList)
+	return tea.Batch(cmds...)
+}
+
+// editorPaths returns the paths listed in the editor, in order.
+func (m *model) editorPaths() []string {
+	paths := make([]string, len(m.pathList))
+	for i, e := range m.pathList {
+		paths[i] = e.path
+	}
+	return paths
+}
+
+// lastEditorPath returns the path being typed, or the last one listed, for
+// the browsers to start from.
+func (m *model) lastEditorPath() string {
+	if p := strings.TrimSpace(m.pathInput.Value()); p != "" {
+		return p
+	}
+	if len(m.pathList) > 0 {
+		return m.pathList[len(m.pathList)-1].path
+	}
+	return ""
+}
+
+// submitPaths starts the job on the paths listed once every one of them has
+// passed its check, or is still being checked.
+func (m *model) submitPaths() tea.Cmd {
+	if len(m.pathList) == 0 {
+		m.err = fmt.Errorf("path cannot be empty")
+		return nil
+	}
+	if slices.ContainsFunc(m.pathList, func(e pathEntry) bool { return e.status == pathInvalid }) {
+		m.pathErr = "Fix or remove the paths marked ✗ before continuing."
+		return nil
+	}
+	paths := m.editorPaths()
+	m.path = strings.Join(paths, ",")
+	m.pathInput.Blur()
+	if m.isValidationRun || m.checkKey {
+		m.viewState = viewInputKey
+		m.keyInput.Focus()
+		return textinput.Blink
+	}
+	m.viewState = viewProcessing
+	return discoverAllSourcesCmd(m.ctx, paths)
+}
+
+func updateInputPath(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(pathCheckedMsg); ok {
+		for i, e := range m.pathList {
+			if e.path == msg.path && e.status == pathChecking {
+				m.pathList[i].status, m.pathList[i].err = pathValid, msg.err
+				if msg.err != nil {
+					m.pathList[i].status = pathInvalid
+				}
+			}
+		}
+		return m, nil
+	}
+	if m.browsing {
+		return updateBrowsing(m, msg)
+	}
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		var cmd tea.Cmd
+		m.pathInput, cmd = m.pathInput.Update(msg)
+		return m, cmd
+	}
+	m.pathErr = ""
+	switch {
+	case key.Matches(keyMsg, m.keys.Browse):
+		return m, m.startBrowsing()
+	case key.Matches(keyMsg, m.keys.BrowseGCS) && m.gcsAvailable:
+		return m, m.startGCSBrowsing()
+	}
+	if m.pathCursor < len(m.pathList) {
+		return updatePathList(m, keyMsg)
+	}
+
+	switch keyMsg.Type {
+	case tea.KeyEnter:
+		if value := m.pathInput.Value(); strings.TrimSpace(value) != "" {
+			m.pathInput.Reset()
+			return m, m.addPaths(strings.Split(value, ",")...)
+		}
+		return m, m.submitPaths()
+	case tea.KeyUp:
+		if len(m.pathList) > 0 {
+			m.pathCursor = len(m.pathList) - 1
+			m.pathInput.Blur()
+		}
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.pathInput, cmd = m.pathInput.Update(keyMsg)
+	return m, cmd
+}
+
+// updatePathList handles keys while the cursor is on a listed path rather
+// than the input.
+func updatePathList(m model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	i := m.pathCursor
+	switch msg.String() {
+	case "up", "k":
+		m.pathCursor = max(i-1, 0)
+	case "down", "j":
+		m.pathCursor++
+		if m.pathCursor == len(m.pathList) {
+			return m, m.pathInput.Focus()
+		}
+	case "shift+up", "K":
+		if i > 0 {
+			m.pathList[i-1], m.pathList[i] = m.pathList[i], m.pathList[i-1]
+			m.pathCursor--
+		}
+	case "shift+down", "J":
+		if i < len(m.pathList)-1 {
+			m.pathList[i+1], m.pathList[i] = m.pathList[i], m.pathList[i+1]
+			m.pathCursor++
+		}
+	case "d", "delete", "backspace":
+		m.pathList = slices.Delete(m.pathList, i, i+1)
+		if m.pathCursor == len(m.pathList) {
+			return m, m.pathInput.Focus()
+		}
+	case "enter", "e":
+		// Editing takes the path out of the list and back into the input;
+		// pressing Enter adds it again, checked afresh, at the end.
+		m.pathInput.SetValue(m.pathList[i].path)
+		m.pathInput.CursorEnd()
+		m.pathList = slices.Delete(m.pathList, i, i+1)
+		m.pathCursor = len(m.pathList)
+		return m, m.pathInput.Focus()
+	case "r":
+		m.pathList[i].status, m.pathList[i].err = pathChecking, nil
+		return m, checkPathCmd(m.ctx, m.pathList[i].path)
+	}
+	return m, nil
+}
+
+func renderInputPath(m *model) string {
+	if m.browsing {
+		return renderBrowsing(m)
+	}
+	pad := strings.Repeat(" ", 2)
+	var b strings.Builder
+	if m.gcsAvailable {
+		b.WriteString("\n" + pad + "Paths to analyse, local folders or gs://bucket/prefix:\n\n")
+	} else {
+		b.WriteString("\n" + pad + "Local folders to analyse:\n\n")
+	}
+	if len(m.pathList) == 0 {
+		b.WriteString(pad + timingStyle.Render("No paths yet.") + "\n")
+	}
+	for i, e := range m.pathList {
+		cursor := "  "
+		if i == m.pathCursor {
+			cursor = selectionStyle.Render("> ")
+		}
+		line := fmt.Sprintf("%s%s %s", cursor, e.icon(), e.path)
+		switch {
+		case e.err != nil:
+			line += "  " + errorStyle.Render(e.err.Error())
+		case e.status == pathChecking:
+			line += "  " + timingStyle.Render("checking...")
+		}
+		b.WriteString(line + "\n")
+	}
+	b.WriteString("\n" + pad + "Add: " + m.pathInput.View())
+	if m.pathErr != "" {
+		b.WriteString("\n" + pad + errorStyle.Render(m.pathErr))
+	}
+
+	browse := m.keys.Browse.Help().Key + " to browse for local paths"
+	if m.gcsAvailable {
+		browse += ", " + m.keys.BrowseGCS.Help().Key + " to browse GCS"
+	}
+	help := "Press Enter to add a path (or several, comma-separated), Enter on an empty line to continue, up to edit the list, " + browse + ", " + m.inputHelp()
+	if m.pathCursor < len(m.pathList) {
+		help = "Use up/down to move, shift+up/down or K/J to reorder, enter to edit, d to remove, r to check again, down past the last path to add another. " + m.inputHelp()
+	}
+	b.WriteString(helpStyle.Render("\n" + help))
+	return b.String()
+}
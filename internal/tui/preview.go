@@ -0,0 +1,175 @@
+// internal/tui/preview.go
+package tui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+	"github.com/benjaminwestern/dupe-analyser/pkg/source"
+)
+
+// previewRecord is one record read for the preview, as read and
+// pretty-printed, or why it could not be read.
+type previewRecord struct {
+	loc  report.LocationInfo
+	raw  string
+	text string
+	err  error
+}
+
+// previewMsg carries the records read for preview id, so records that
+// arrive after their preview was closed are dropped.
+type previewMsg struct {
+	id      int
+	records []previewRecord
+}
+
+// openPreview shows the records at locations, returning to the current view
+// when the preview is closed.
+func (m *model) openPreview(title string, locations []report.LocationInfo) tea.Cmd {
+	m.previewReturn = m.viewState
+	m.previewTitle = title
+	m.previewRecords = nil
+	m.previewView.YOffset = 0
+	m.previewID++
+	m.viewState = viewPreview
+	return loadPreviewCmd(m.ctx, m.previewID, m.originalSources, m.finalReport, m.maxLineSize(), locations)
+}
+
+func (m *model) maxLineSize() int {
+	if m.analyser != nil {
+		return m.analyser.MaxLineSize
+	}
+	return source.DefaultMaxLineSize
+}
+
+// loadPreviewCmd reads the records at locations, reading each file or GCS
+// object once. Records already sampled into the report are not read again.
+func loadPreviewCmd(ctx context.Context, id int, sources []source.InputSource, rep *report.AnalysisReport, maxLineSize int, locations []report.LocationInfo) tea.Cmd {
+	return func() tea.Msg {
+		records := make([]previewRecord, len(locations))
+		wanted := make(map[string]map[int]bool)
+		for i, loc := range locations {
+			records[i].loc = loc
+			if sample, ok := rep.RecordSamples[loc.Key()]; ok {
+				records[i].raw, records[i].text = string(sample), prettyRecord(sample)
+				continue
+			}
+			if wanted[loc.FilePath] == nil {
+				wanted[loc.FilePath] = make(map[int]bool)
+			}
+			wanted[loc.FilePath][loc.LineNumber] = true
+		}
+		read := make(map[string][]byte)
+		errs := make(map[string]error)
+		for _, src := range sources {
+			lines, ok := wanted[src.Path()]
+			if !ok {
+				continue
+			}
+			err := source.ReadLines(ctx, src, lines, maxLineSize, func(lineNumber int, data []byte) {
+				read[report.LocationInfo{FilePath: src.Path(), LineNumber: lineNumber}.Key()] = bytes.Clone(data)
+			})
+			if err != nil {
+				errs[src.Path()] = err
+			}
+		}
+		for i, rec := range records {
+			if rec.text != "" {
+				continue
+			}
+			if data, ok := read[rec.loc.Key()]; ok {
+				records[i].raw, records[i].text = string(data), prettyRecord(data)
+			} else if err, ok := errs[rec.loc.FilePath]; ok {
+				records[i].err = err
+			} else {
+				records[i].err = fmt.Errorf("the line could not be read; the file may have changed since it was analysed")
+			}
+		}
+		return previewMsg{id: id, records: records}
+	}
+}
+
+// prettyRecord indents a JSON record, keeping its field order. Lines that
+// are not valid JSON are shown as they are.
+func prettyRecord(data []byte) string {
+	var b bytes.Buffer
+	if err := json.Indent(&b, data, "", "  "); err != nil {
+		return string(data)
+	}
+	return b.String()
+}
+
+func updatePreview(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case previewMsg:
+		if msg.id == m.previewID {
+			m.previewRecords = msg.records
+		}
+	case tea.KeyMsg:
+		vp := m.previewViewport()
+		switch msg.String() {
+		case "pgup", "left":
+			vp.PageUp()
+		case "pgdown", "right", " ":
+			vp.PageDown()
+		case "home":
+			vp.GotoTop()
+		case "end":
+			vp.GotoBottom()
+		case "up", "k":
+			vp.ScrollUp(1)
+		case "down", "j":
+			vp.ScrollDown(1)
+		}
+		m.previewView.YOffset = vp.YOffset
+	}
+	return m, nil
+}
+
+// previewBody renders the records being previewed.
+func previewBody(m *model) string {
+	var b strings.Builder
+	b.WriteString(headerStyle.Render(m.previewTitle) + "\n")
+	if m.previewRecords == nil {
+		b.WriteString("Reading records...\n")
+		return b.String()
+	}
+	for _, rec := range m.previewRecords {
+		b.WriteString(selectionStyle.Render(fmt.Sprintf("File: %s  Line: %d", rec.loc.FilePath, rec.loc.LineNumber)) + "\n")
+		if rec.err != nil {
+			b.WriteString(errorStyle.Render(rec.err.Error()) + "\n\n")
+			continue
+		}
+		b.WriteString(rec.text + "\n\n")
+	}
+	return b.String()
+}
+
+var previewHelp = "\n" + helpStyle.Render("Use up/down or PgUp/PgDn to scroll. Press esc to go back.")
+
+// previewViewport returns the preview viewport sized to the terminal, less
+// the lines the help takes.
+func (m *model) previewViewport() viewport.Model {
+	vp := m.previewView
+	vp.Width = m.width
+	vp.Height = max(m.height-lipgloss.Height(previewHelp), 1)
+	vp.SetContent(previewBody(m))
+	return vp
+}
+
+func renderPreview(m *model) string {
+	if m.height == 0 {
+		return previewBody(m) + previewHelp
+	}
+	vp := m.previewViewport()
+	return vp.View() + previewHelp
+}
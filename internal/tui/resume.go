@@ -0,0 +1,172 @@
+// internal/tui/resume.go
+package tui
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/benjaminwestern/dupe-analyser/internal/config"
+	"github.com/benjaminwestern/dupe-analyser/pkg/analyser"
+)
+
+// checkpointSettings are the settings of a headless run, saved in its
+// checkpoint, that the TUI needs to continue it. The field names match the
+// headless configuration the checkpoint was written with.
+type checkpointSettings struct {
+	Paths               string
+	Key                 string
+	AdditionalKeys      []string
+	Constraints         []config.ConstraintConfig
+	ValidateOnly        bool
+	CheckKey            bool
+	CheckRow            bool
+	ShowFolderBreakdown bool
+	ShowFileBreakdown   bool
+	IndexMode           string
+	IndexDir            string
+	RawRowHash          bool
+	MaxMemory           int64
+	MaxLineSize         int
+	ChunkSize           int64
+	MemoryMap           bool
+	Scope               string
+	CheckpointInterval  time.Duration
+}
+
+// savedCheckpoint is a checkpoint listed under Resume Previous Run, with the
+// settings read from it or the reason they could not be.
+type savedCheckpoint struct {
+	checkpoint *analyser.Checkpoint
+	settings   checkpointSettings
+	err        error
+}
+
+// openCheckpoints lists the checkpoints left in the log path by headless runs
+// that did not finish.
+func (m *model) openCheckpoints() {
+	m.checkpoints = nil
+	checkpoints, err := analyser.ListCheckpoints(m.logPath)
+	m.checkpointErr = err
+	for _, cp := range checkpoints {
+		saved := savedCheckpoint{checkpoint: cp}
+		if err := json.Unmarshal(cp.Meta, &saved.settings); err != nil {
+			saved.err = fmt.Errorf("could not read its settings: %w", err)
+		} else if strings.TrimSpace(saved.settings.Paths) == "" {
+			saved.err = fmt.Errorf("it does not record the paths it was run on")
+		}
+		m.checkpoints = append(m.checkpoints, saved)
+	}
+	m.checkpointCursor = 0
+	m.viewState = viewCheckpoints
+}
+
+// resumeCheckpoint takes the paths, key and checks of a checkpoint and finds
+// the files again. The checkpoint is restored once they are found.
+func (m *model) resumeCheckpoint(saved savedCheckpoint) tea.Cmd {
+	s := saved.settings
+	m.path = s.Paths
+	m.key = s.Key
+	m.keyInput.SetValue(m.key)
+	m.checkKey, m.checkRow = s.CheckKey, s.CheckRow
+	m.isValidationRun = s.ValidateOnly
+	m.showFolderBreakdown = s.ShowFolderBreakdown
+	m.resume = &saved
+	m.analyser = nil
+	m.finalReport = nil
+	m.originalSources = nil
+	m.purgeStats = purgeResultMsg{}
+	m.search = searchFilter{}
+	m.wasCancelled = false
+	m.totalElapsedTime = 0
+	m.viewState = viewProcessing
+	return discoverAllSourcesCmd(m.ctx, m.inputPaths())
+}
+
+// apply configures the analyser as the headless run was, restores the
+// completed files and keeps writing checkpoints to the same file, so a run
+// cancelled again can be resumed again.
+func (r *savedCheckpoint) apply(a *analyser.Analyser) error {
+	s := r.settings
+	a.SetAdditionalKeys(s.AdditionalKeys)
+	for _, c := range s.Constraints {
+		a.AddConstraint(c.Name, c.Fields)
+	}
+	a.FileBreakdown = s.ShowFileBreakdown
+	if s.IndexMode != "" {
+		a.IndexMode = s.IndexMode
+		a.IndexDir = s.IndexDir
+	}
+	a.RawRowHash = s.RawRowHash
+	a.MaxMemory = s.MaxMemory
+	if s.MaxLineSize > 0 {
+		a.MaxLineSize = s.MaxLineSize
+	}
+	a.ChunkSize = s.ChunkSize
+	a.MemoryMap = s.MemoryMap
+	if s.Scope != "" {
+		a.Scope = s.Scope
+	}
+	a.CheckpointPath = r.checkpoint.Path
+	a.CheckpointMeta = r.checkpoint.Meta
+	a.CheckpointInterval = s.CheckpointInterval
+	return a.Restore(r.checkpoint)
+}
+
+func updateCheckpoints(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok || len(m.checkpoints) == 0 {
+		return m, nil
+	}
+	m.checkpointErr = nil
+	switch keyMsg.String() {
+	case "up", "k":
+		m.checkpointCursor = max(m.checkpointCursor-1, 0)
+	case "down", "j":
+		m.checkpointCursor = min(m.checkpointCursor+1, len(m.checkpoints)-1)
+	case "enter":
+		saved := m.checkpoints[m.checkpointCursor]
+		if saved.err != nil {
+			m.checkpointErr = fmt.Errorf("%s cannot be resumed: %w", filepath.Base(saved.checkpoint.Path), saved.err)
+			return m, nil
+		}
+		return m, m.resumeCheckpoint(saved)
+	}
+	return m, nil
+}
+
+func renderCheckpoints(m *model) string {
+	var b strings.Builder
+	b.WriteString(headerStyle.Render(fmt.Sprintf("Checkpoints in %s (%d)", m.logPath, len(m.checkpoints))) + "\n")
+	if m.checkpointErr != nil {
+		b.WriteString(errorStyle.Render(m.checkpointErr.Error()) + "\n\n")
+	}
+	if len(m.checkpoints) == 0 {
+		b.WriteString("No checkpoints found. Headless runs started with -checkpoint leave one behind when they do not finish.\n")
+		b.WriteString(helpStyle.Render("Press esc to go back to the menu."))
+		return b.String()
+	}
+	for i, saved := range m.checkpoints {
+		cursor := "  "
+		if i == m.checkpointCursor {
+			cursor = selectionStyle.Render("> ")
+		}
+		cp := saved.checkpoint
+		b.WriteString(fmt.Sprintf("%s%s  %s  %d file(s) complete\n", cursor, cp.CreatedAt.Format("2006-01-02 15:04:05"), filepath.Base(cp.Path), cp.FilesCompleted))
+		if saved.err != nil {
+			b.WriteString("    " + errorStyle.Render(saved.err.Error()) + "\n")
+			continue
+		}
+		mode := "analysis"
+		if saved.settings.ValidateOnly {
+			mode = "validation"
+		}
+		b.WriteString(timingStyle.Render(fmt.Sprintf("    %s of %s, key '%s'", mode, saved.settings.Paths, saved.settings.Key)) + "\n")
+	}
+	b.WriteString(helpStyle.Render("Use up/down to move, enter to continue the run with its original settings. Press esc to go back."))
+	return b.String()
+}
@@ -0,0 +1,232 @@
+// internal/tui/search.go
+package tui
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+)
+
+// maxSearchSets and maxSearchLocations limit the search results shown under
+// the report.
+const (
+	maxSearchSets      = 20
+	maxSearchLocations = 5
+)
+
+// searchFilter is a parsed "/" search. A folder:<text> word keeps only the
+// locations whose folder contains text; the rest of the query matches the
+// duplicated ID or row hash as a substring, or as a regular expression when
+// it starts with "re:".
+type searchFilter struct {
+	query  string
+	text   string
+	re     *regexp.Regexp
+	folder string
+}
+
+func parseSearch(query string) (searchFilter, error) {
+	f := searchFilter{query: strings.TrimSpace(query)}
+	var words []string
+	for _, word := range strings.Fields(f.query) {
+		if folder, ok := strings.CutPrefix(word, "folder:"); ok {
+			f.folder = folder
+			continue
+		}
+		words = append(words, word)
+	}
+	f.text = strings.Join(words, " ")
+	if pattern, ok := strings.CutPrefix(f.text, "re:"); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return searchFilter{}, fmt.Errorf("invalid regular expression: %w", err)
+		}
+		f.re, f.text = re, ""
+	}
+	return f, nil
+}
+
+func (f searchFilter) active() bool { return f.query != "" }
+
+// locations returns the locations of a set that are in the folder searched
+// for, or all of them if no folder was given.
+func (f searchFilter) locations(locations []report.LocationInfo) []report.LocationInfo {
+	if f.folder == "" {
+		return locations
+	}
+	var matched []report.LocationInfo
+	for _, loc := range locations {
+		if strings.Contains(filepath.Dir(loc.FilePath), f.folder) {
+			matched = append(matched, loc)
+		}
+	}
+	return matched
+}
+
+// match reports whether the duplicate set with the given ID or row hash
+// matches the search.
+func (f searchFilter) match(value string, locations []report.LocationInfo) bool {
+	switch {
+	case f.re != nil && !f.re.MatchString(value):
+		return false
+	case f.re == nil && !strings.Contains(value, f.text):
+		return false
+	}
+	return len(f.locations(locations)) > 0
+}
+
+// startSearch opens the search input, starting from the current query.
+func (m *model) startSearch() tea.Cmd {
+	m.searching = true
+	m.searchErr = nil
+	m.searchInput.SetValue(m.search.query)
+	m.searchInput.CursorEnd()
+	return m.searchInput.Focus()
+}
+
+// updateSearch handles keys while the search input is open. Enter applies
+// the search, to the report or to the sets left to purge, and esc closes it
+// unchanged.
+func updateSearch(m model, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.searching = false
+		m.searchInput.Blur()
+		return m, nil
+	case tea.KeyEnter:
+		f, err := parseSearch(m.searchInput.Value())
+		if err != nil {
+			m.searchErr = err
+			return m, nil
+		}
+		if m.viewState == viewPurgeSelection && !m.filterPurgeSets(f) {
+			m.searchErr = fmt.Errorf("no remaining duplicate set matches %q", f.query)
+			return m, nil
+		}
+		m.search = f
+		m.searching = false
+		m.searchInput.Blur()
+		if m.viewState == viewPurgeSelection {
+			m.purgeDiffRecords = nil
+			return m, m.loadPurgeDiff()
+		}
+		if m.viewState == viewReport {
+			// The results are at the end of the report; the viewport stops
+			// at its last page.
+			m.reportView.YOffset = math.MaxInt
+		}
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.searchInput, cmd = m.searchInput.Update(msg)
+	return m, cmd
+}
+
+// filterPurgeSets drops the sets from the current one on that do not match
+// f, leaving them untouched by the purge. It changes nothing and reports
+// false if none of them match.
+func (m *model) filterPurgeSets(f searchFilter) bool {
+	var ids, rows []string
+	var defaults []int
+	for i := range len(m.purgeIDKeys) + len(m.purgeRowHashes) {
+		if i >= m.purgeCursor && !f.match(m.purgeSetValue(i), m.purgeLocations(i)) {
+			continue
+		}
+		if i < len(m.purgeIDKeys) {
+			ids = append(ids, m.purgeIDKeys[i])
+		} else {
+			rows = append(rows, m.purgeRowHashes[i-len(m.purgeIDKeys)])
+		}
+		if i < len(m.purgeDefaults) {
+			defaults = append(defaults, m.purgeDefaults[i])
+		}
+	}
+	if len(ids)+len(rows) == m.purgeCursor {
+		return false
+	}
+	m.purgeIDKeys, m.purgeRowHashes, m.purgeDefaults = ids, rows, defaults
+	m.purgeKeepMarks = nil
+	m.purgeSelectionCursor = m.purgeDefault(m.purgeCursor)
+	return true
+}
+
+// matchingKeys returns the sorted keys of sets that match the search.
+func (m *model) matchingKeys(sets map[string][]report.LocationInfo) []string {
+	var keys []string
+	for k, locations := range sets {
+		if !m.search.active() || m.search.match(k, locations) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// renderSearch renders the search input while it is open, or the duplicate
+// sets matching the current search under the report.
+func renderSearch(m *model) string {
+	var b strings.Builder
+	if m.searching {
+		b.WriteString("\n\nSearch: " + m.searchInput.View())
+		b.WriteString(helpStyle.Render("\nText matches IDs and row hashes, re:<pattern> a regular expression, folder:<text> the folder. Enter to apply, esc to cancel."))
+		if m.searchErr != nil {
+			b.WriteString("\n" + errorStyle.Render(m.searchErr.Error()))
+		}
+		return b.String()
+	}
+	if !m.search.active() || m.viewState != viewReport || m.finalReport == nil {
+		return ""
+	}
+	var ids, rows []string
+	if m.checkKey {
+		ids = m.matchingKeys(m.finalReport.DuplicateIDs)
+	}
+	if m.checkRow {
+		rows = m.matchingKeys(m.finalReport.DuplicateRows)
+	}
+	b.WriteString("\n\n" + headerStyle.Render(fmt.Sprintf("Search %q: %d duplicate ID set(s), %d duplicate row set(s)", m.search.query, len(ids), len(rows))))
+	shown := 0
+	writeSet := func(label string, locations []report.LocationInfo) {
+		locations = m.search.locations(locations)
+		b.WriteString(fmt.Sprintf("\n%s (%d location(s))", label, len(locations)))
+		for i, loc := range locations {
+			if i == maxSearchLocations {
+				b.WriteString(fmt.Sprintf("\n    ...and %d more", len(locations)-i))
+				break
+			}
+			b.WriteString(fmt.Sprintf("\n    %s:%d", loc.FilePath, loc.LineNumber))
+		}
+		shown++
+	}
+	for _, k := range ids {
+		if shown == maxSearchSets {
+			break
+		}
+		writeSet(fmt.Sprintf("ID '%s'", k), m.finalReport.DuplicateIDs[k])
+	}
+	for _, h := range rows {
+		if shown == maxSearchSets {
+			break
+		}
+		writeSet(fmt.Sprintf("Row %s", h), m.finalReport.DuplicateRows[h])
+	}
+	if more := len(ids) + len(rows) - shown; more > 0 {
+		b.WriteString(fmt.Sprintf("\n...and %d more set(s); narrow the search to see them.", more))
+	}
+	return b.String()
+}
+
+// newSearchInput returns the input of the "/" search.
+func newSearchInput() textinput.Model {
+	input := textinput.New()
+	input.Placeholder = "ID, re:pattern or folder:name"
+	return input
+}
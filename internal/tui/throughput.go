@@ -0,0 +1,48 @@
+// internal/tui/throughput.go
+package tui
+
+import (
+	"fmt"
+	"time"
+)
+
+// throughputWindow is how long rows and bytes are counted before the rates
+// shown are updated.
+const throughputWindow = 2 * time.Second
+
+// throughput measures the rows and bytes read per second over the last
+// window, so a stalled run shows as a drop to zero rather than a slowly
+// falling average.
+type throughput struct {
+	at          time.Time
+	rows, bytes int64
+	rowsPerSec  float64
+	bytesPerSec float64
+	measured    bool
+}
+
+// sample records the counters at now, updating the rates once a window has
+// passed since the last update.
+func (t *throughput) sample(now time.Time, rows, bytes int64) {
+	if t.at.IsZero() {
+		t.at, t.rows, t.bytes = now, rows, bytes
+		return
+	}
+	elapsed := now.Sub(t.at)
+	if elapsed < throughputWindow {
+		return
+	}
+	// The counters start again from zero after a bloom training pass.
+	t.rowsPerSec = float64(max(rows-t.rows, 0)) / elapsed.Seconds()
+	t.bytesPerSec = float64(max(bytes-t.bytes, 0)) / elapsed.Seconds()
+	t.measured = true
+	t.at, t.rows, t.bytes = now, rows, bytes
+}
+
+// String renders the rates, or nothing until the first window has passed.
+func (t throughput) String() string {
+	if !t.measured {
+		return ""
+	}
+	return fmt.Sprintf("%.0f rows/s, %.1f MB/s", t.rowsPerSec, t.bytesPerSec/(1024*1024))
+}
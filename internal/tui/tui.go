@@ -2,27 +2,30 @@
 package tui
 
 import (
-	"bufio"
 	"context"
 	"fmt"
-	"log"
-	"os"
-	"path/filepath"
-	"sort"
+	"log/slog"
+	"maps"
+	"slices"
 	"strings"
 	"time"
 
 	"cloud.google.com/go/storage"
+	"github.com/charmbracelet/bubbles/filepicker"
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 
-	"github.com/benjaminwestern/dupe-analyser/internal/analyser"
 	"github.com/benjaminwestern/dupe-analyser/internal/config"
-	"github.com/benjaminwestern/dupe-analyser/internal/report"
-	"github.com/benjaminwestern/dupe-analyser/internal/source"
+	"github.com/benjaminwestern/dupe-analyser/internal/logging"
+	"github.com/benjaminwestern/dupe-analyser/internal/purge"
+	"github.com/benjaminwestern/dupe-analyser/pkg/analyser"
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+	"github.com/benjaminwestern/dupe-analyser/pkg/source"
 )
 
 const (
@@ -37,6 +40,12 @@ const (
 	viewReport
 	viewPurgeSelection
 	viewPurging
+	viewPurgeConfirm
+	viewDetails
+	viewPreview
+	viewHistory
+	viewHistoryDiff
+	viewCheckpoints
 )
 
 var (
@@ -52,11 +61,12 @@ var (
 )
 
 type sourcesFoundMsg struct{ sources []source.InputSource }
-type progressUpdateMsg struct{}
+type progressUpdateMsg struct{ progress analyser.Progress }
 type allWorkCompleteMsg struct{ report *report.AnalysisReport; savedFilenameBase string }
 type purgeResultMsg struct {
 	filesModified  int
 	recordsDeleted int
+	manifest       string
 	err            error
 }
 type errMsg struct{ err error }
@@ -70,6 +80,7 @@ type model struct {
 	processing      bool
 	analyser        *analyser.Analyser
 	originalSources []source.InputSource
+	progressCh      chan analyser.Progress
 	isValidationRun bool
 	
 	viewState       int
@@ -79,10 +90,20 @@ type model struct {
 	wantsToRestart  bool
 	wantsToStartNew bool
 	gcsAvailable    bool
+	keys            keyMap
+	changingKey     bool
+	logMark         uint64
+	logEntries      []logging.Entry
+	logCount        int
+	hideLogs        bool
+	notice          string
 	width           int
 	height          int
 
 	pathInput    textinput.Model
+	pathList     []pathEntry
+	pathCursor   int
+	pathErr      string
 	keyInput     textinput.Model
 	logPathInput textinput.Model
 	spinner      spinner.Model
@@ -91,6 +112,8 @@ type model struct {
 	startTime        time.Time
 	totalElapsedTime time.Duration
 	eta              time.Duration
+	folderProgress   []analyser.FolderProgress
+	throughput       throughput
 	finalReport      *report.AnalysisReport
 	savedFilename    string
 	
@@ -113,8 +136,69 @@ type model struct {
 	purgeRowHashes       []string
 	purgeCursor          int
 	purgeSelectionCursor int
-	recordsToDelete      map[string]map[int]bool
+	recordsToDelete      purge.Plan
 	purgeStats           purgeResultMsg
+	keepStrategy         string
+	purgeDefaults        []int
+	purgeKeepMarks       map[int]bool
+	purgeSkipped         []string
+	purgeChoices         []map[int]bool
+	purgeConfirmInput    textinput.Model
+	purgeConfirmErr      string
+
+	reportView viewport.Model
+
+	detailSets     []detailSet
+	detailCursor   int
+	detailExpanded map[int]bool
+
+	previewView    viewport.Model
+	previewReturn  int
+	previewTitle   string
+	previewRecords []previewRecord
+	previewID      int
+
+	purgeDiff        bool
+	purgeDiffSet     int
+	purgeDiffRecords []previewRecord
+
+	searchInput textinput.Model
+	searching   bool
+	search      searchFilter
+	searchErr   error
+
+	historyRuns   []report.SavedReport
+	historyCursor int
+	historyMark   int
+	historyErr    error
+	historyDiff   string
+	historyView   viewport.Model
+
+	checkpoints      []savedCheckpoint
+	checkpointCursor int
+	checkpointErr    error
+	resume           *savedCheckpoint
+
+	pathPicker  filepicker.Model
+	browsing    bool
+	pickedPaths []string
+
+	gcsBrowsing  bool
+	gcsPath      string
+	gcsEntries   []source.GCSEntry
+	gcsCursor    int
+	gcsLoading   bool
+	gcsErr       error
+	gcsTruncated bool
+}
+
+// Options holds the settings of the TUI that are not saved with the config.
+// KeepStrategy is the purge keep strategy whose choice is pre-selected in
+// each duplicate set; it defaults to keep-first.
+type Options struct {
+	KeepStrategy string
+	// Keys rebinds TUI actions, as read by config.LoadKeys.
+	Keys map[string][]string
 }
 
 func testGCSClient() bool {
@@ -123,22 +207,26 @@ func testGCSClient() bool {
 
 	client, err := storage.NewClient(ctx)
 	if err != nil {
-		log.Printf("GCS client pre-flight check failed: %v. GCS functionality will be disabled.", err)
+		slog.Warn("GCS client pre-flight check failed, GCS functionality will be disabled", "error", err)
 		return false
 	}
 	client.Close()
 	return true
 }
 
-func Run(cfg *config.Config) (*config.Config, bool, bool, error) {
+func Run(cfg *config.Config, opts Options) (*config.Config, bool, bool, error) {
 	cfg.GCSAvailable = testGCSClient()
 	ctx := context.Background()
 	m, err := initModel(ctx, cfg)
+	m.keepStrategy = opts.KeepStrategy
 	if err != nil {
 		return nil, false, false, fmt.Errorf("failed to initialise TUI model: %w", err)
 	}
+	if m.keys, err = newKeyMap(opts.Keys); err != nil {
+		return nil, false, false, err
+	}
 
-	p := tea.NewProgram(m, tea.WithAltScreen())
+	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion())
 	finalModel, err := p.Run()
 	if err != nil {
 		return nil, false, false, fmt.Errorf("error running TUI: %w", err)
@@ -155,12 +243,11 @@ func Run(cfg *config.Config) (*config.Config, bool, bool, error) {
 func initModel(ctx context.Context, cfg *config.Config) (model, error) {
 	pathInput := textinput.New()
 	if cfg.GCSAvailable {
-		pathInput.Placeholder = "/path/a,/path/b,gs://bucket/c"
+		pathInput.Placeholder = "/path/a or gs://bucket/prefix"
 	} else {
-		pathInput.Placeholder = "/path/a,/path/b (GCS unavailable)"
+		pathInput.Placeholder = "/path/a (GCS unavailable)"
 	}
 	pathInput.Focus()
-	pathInput.SetValue(cfg.Path)
 
 	keyInput := textinput.New()
 	keyInput.Placeholder = "id"
@@ -181,9 +268,11 @@ func initModel(ctx context.Context, cfg *config.Config) (model, error) {
 		logPathInput:    logPathInput,
 		spinner:         s,
 		progress:        p,
-		recordsToDelete: make(map[string]map[int]bool),
+		searchInput:     newSearchInput(),
+		recordsToDelete: make(purge.Plan),
 		viewState:       viewMenu,
 		gcsAvailable:    cfg.GCSAvailable,
+		keys:            defaultKeyMap(),
 
 		path:                cfg.Path,
 		key:                 cfg.Key,
@@ -239,7 +328,7 @@ func (m *model) buildConfig() *config.Config {
 func saveConfigCmd(cfg *config.Config) tea.Cmd {
 	return func() tea.Msg {
 		if err := cfg.Save(); err != nil {
-			log.Printf("Failed to save config: %v", err)
+			slog.Error("Failed to save config", "error", err)
 		}
 		return nil
 	}
@@ -252,14 +341,31 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, tea.Quit
 	}
 
+	if msg, ok := msg.(tea.MouseMsg); ok {
+		return updateMouse(m, msg)
+	}
+
 	switch msg := msg.(type) {
+	case clipboardMsg:
+		m.notice = msg.String()
+		return m, nil
+	case exportMsg:
+		m.notice = msg.String()
+		return m, nil
 	case tea.KeyMsg:
+		m.notice = ""
 		if m.err != nil {
 			m.err = nil
 			m.viewState = viewMenu
 			return m, nil
 		}
-		if msg.String() == "ctrl+c" || msg.String() == "q" {
+		if m.viewState == viewReport && !m.searching && m.scrollReport(msg) {
+			return m, nil
+		}
+		if m.searching && msg.String() != "ctrl+c" {
+			return updateSearch(m, msg)
+		}
+		if msg.String() == "ctrl+c" || key.Matches(msg, m.keys.Quit) {
 			if m.viewState == viewProcessing {
 				m.status = "Cancelling... generating partial report."
 				m.viewState = viewCancelling
@@ -282,12 +388,49 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			return m, tea.Quit
 		}
-		if msg.Type == tea.KeyEsc {
+		if m.viewState == viewProcessing && key.Matches(msg, m.keys.Logs) {
+			m.hideLogs = !m.hideLogs
+			return m, nil
+		}
+		if key.Matches(msg, m.keys.Back) {
 			switch m.viewState {
-			case viewHelp, viewOptions, viewInputPath, viewReport:
+			case viewReport:
+				if m.search.active() {
+					m.search = searchFilter{}
+					return m, nil
+				}
+				m.viewState = viewMenu
+				return m, nil
+			case viewInputPath:
+				if m.browsing {
+					m.browsing, m.gcsBrowsing = false, false
+					m.pathCursor = len(m.pathList)
+					return m, m.pathInput.Focus()
+				}
+				m.viewState = viewMenu
+				return m, nil
+			case viewHelp, viewOptions:
 				m.viewState = viewMenu
 				return m, nil
+			case viewDetails:
+				m.viewState = viewReport
+				return m, nil
+			case viewPreview:
+				m.viewState = m.previewReturn
+				return m, m.loadPurgeDiff()
+			case viewHistory, viewCheckpoints:
+				m.viewState = viewMenu
+				return m, nil
+			case viewHistoryDiff:
+				m.viewState = viewHistory
+				return m, nil
 			case viewInputKey:
+				if m.changingKey {
+					m.changingKey = false
+					m.keyInput.Blur()
+					m.viewState = viewReport
+					return m, nil
+				}
 				m.viewState = viewInputPath
 				m.keyInput.Blur()
 				m.pathInput.Focus()
@@ -296,18 +439,30 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.viewState = viewOptions
 				m.logPathInput.Blur()
 				return m, nil
+			case viewPurgeConfirm:
+				m.purgeConfirmInput.Blur()
+				m.viewState = viewPurgeSelection
+				m.previousPurgeSet()
+				return m, m.loadPurgeDiff()
 			case viewPurgeSelection:
 				m.viewState = viewReport
-				m.purgeCursor = 0
-				m.purgeSelectionCursor = 0
-				m.recordsToDelete = make(map[string]map[int]bool)
-				m.purgeIDKeys = nil
-				m.purgeRowHashes = nil
+				m.resetPurge()
+				m.purgeSkipped = nil
 				return m, nil
 			}
 		}
 	}
 
+	if msg, ok := msg.(tea.WindowSizeMsg); ok {
+		m.width = msg.Width
+		m.height = msg.Height
+		m.progress.Width = msg.Width - 10
+		if m.progress.Width > 120 {
+			m.progress.Width = 120
+		}
+		return m, nil
+	}
+
 	switch m.viewState {
 	case viewMenu:
 		return updateMenu(m, msg)
@@ -327,39 +482,63 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case viewReport:
 		return updateReport(m, msg)
 	case viewPurgeSelection:
-		return updatePurgeSelection(m, msg)
+		next, cmd := updatePurgeSelection(m, msg)
+		pm := next.(model)
+		load := pm.loadPurgeDiff()
+		return pm, tea.Batch(cmd, load)
+	case viewPurgeConfirm:
+		return updatePurgeConfirm(m, msg)
+	case viewDetails:
+		return updateDetails(m, msg)
+	case viewPreview:
+		return updatePreview(m, msg)
+	case viewHistory:
+		return updateHistory(m, msg)
+	case viewHistoryDiff:
+		return updateHistoryDiff(m, msg)
+	case viewCheckpoints:
+		return updateCheckpoints(m, msg)
 	}
 
 	switch msg := msg.(type) {
-	case tea.WindowSizeMsg:
-		m.width = msg.Width
-		m.height = msg.Height
-		m.progress.Width = msg.Width - 10
-		if m.progress.Width > 120 {
-			m.progress.Width = 120
-		}
-		return m, nil
 	case sourcesFoundMsg:
 		m.originalSources = msg.sources
 		m.processing = true
+		m.folderProgress = nil
+		m.throughput = throughput{}
+		m.logMark = logging.LastSeq()
+		m.logEntries, m.logCount = nil, 0
 		m.totalElapsedTime = 0
 		m.startTime = time.Now()
 		m.analyser = analyser.New(m.key, m.workers, m.checkKey, m.checkRow, m.isValidationRun)
+		resume := m.resume
+		m.resume = nil
+		if resume != nil {
+			if err := resume.apply(m.analyser); err != nil {
+				m.err = err
+				m.processing = false
+				m.viewState = viewMenu
+				return m, nil
+			}
+		}
 		m.jobCtx, m.jobCancel = context.WithCancel(m.ctx)
 
-		if m.isValidationRun {
+		if resume != nil {
+			m.status = fmt.Sprintf("Found %d files. Resuming a run with %d files already complete...", len(m.originalSources), resume.checkpoint.FilesCompleted)
+		} else if m.isValidationRun {
 			m.status = fmt.Sprintf("Found %d files. Validating key '%s'...", len(m.originalSources), m.key)
 		} else {
 			m.status = fmt.Sprintf("Found %d files. Analysing...", len(m.originalSources))
 		}
 
+		waitForProgress := m.followProgress()
 		return m, tea.Batch(
-			startAnalysisCmd(m.analyser, m.jobCtx, m.originalSources, m.logPath, m.outputTxt, m.outputJson, m.checkKey, m.checkRow, m.showFolderBreakdown),
+			startAnalysisCmd(m.analyser, m.jobCtx, m.originalSources, m.inputPaths(), m.logPath, m.outputTxt, m.outputJson, m.checkKey, m.checkRow, m.showFolderBreakdown),
 			m.spinner.Tick,
-			pollProgressCmd(&m),
+			waitForProgress,
 		)
 	case progressUpdateMsg:
-		return updateProgress(m)
+		return updateProgress(m, msg.progress)
 	case progress.FrameMsg:
 		progressModel, cmd := m.progress.Update(msg)
 		if newModel, ok := progressModel.(progress.Model); ok {
@@ -382,6 +561,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.finalReport = msg.report
 		m.savedFilename = msg.savedFilenameBase
 		m.viewState = viewReport
+		m.reportView.YOffset = 0
 		return m, nil
 	case purgeResultMsg:
 		m.purgeStats = msg
@@ -442,8 +622,20 @@ func (m model) View() string {
 		return renderReport(&m)
 	case viewPurgeSelection:
 		return renderPurgeSelection(&m)
+	case viewDetails:
+		return renderDetails(&m)
+	case viewPreview:
+		return renderPreview(&m)
+	case viewHistory:
+		return renderHistory(&m)
+	case viewHistoryDiff:
+		return renderHistoryDiff(&m)
+	case viewCheckpoints:
+		return renderCheckpoints(&m)
 	case viewPurging:
 		return fmt.Sprintf("\n%s %s\n", m.spinner.View(), m.status)
+	case viewPurgeConfirm:
+		return renderPurgeConfirm(&m)
 	}
 	return ""
 }
@@ -461,7 +653,7 @@ func discoverAllSourcesCmd(ctx context.Context, paths []string) tea.Cmd {
 	}
 }
 
-func startAnalysisCmd(a *analyser.Analyser, ctx context.Context, sources []source.InputSource, logPath string, outputTxt, outputJson, checkKey, checkRow, showFolderBreakdown bool) tea.Cmd {
+func startAnalysisCmd(a *analyser.Analyser, ctx context.Context, sources []source.InputSource, inputs []string, logPath string, outputTxt, outputJson, checkKey, checkRow, showFolderBreakdown bool) tea.Cmd {
 	return func() tea.Msg {
 		finalReport := a.Run(ctx, sources)
 		if ctx.Err() == context.Canceled {
@@ -469,96 +661,79 @@ func startAnalysisCmd(a *analyser.Analyser, ctx context.Context, sources []sourc
 				return nil
 			}
 		}
-		filenameBase := report.SaveAndLog(finalReport, logPath, outputTxt, outputJson, checkKey, checkRow, showFolderBreakdown)
+		finalReport.Summary.Inputs = inputs
+		filenameBase := report.SaveAndLog(finalReport, logPath, report.SaveOptions{
+			Txt:                 outputTxt,
+			JSON:                outputJson,
+			CheckKey:            checkKey,
+			CheckRow:            checkRow,
+			ShowFolderBreakdown: showFolderBreakdown,
+		})
 		return allWorkCompleteMsg{report: finalReport, savedFilenameBase: filenameBase}
 	}
 }
 
-func pollProgressCmd(m *model) tea.Cmd {
-	return tea.Tick(time.Millisecond*100, func(t time.Time) tea.Msg {
-		if m.analyser == nil {
-			return progressUpdateMsg{}
+// followProgress has the analyser send its progress to a new channel and
+// returns the command that waits for the first update. Each run gets its own
+// channel, so a continued run never picks up the last update of the one
+// before.
+func (m *model) followProgress() tea.Cmd {
+	ch := make(chan analyser.Progress, 1)
+	m.progressCh = ch
+	m.analyser.OnProgress = func(p analyser.Progress) {
+		// Only the latest progress is worth drawing, so an update the view
+		// has not picked up yet is replaced.
+		select {
+		case <-ch:
+		default:
 		}
-		if m.viewState != viewProcessing {
-			return nil
-		}
-		return progressUpdateMsg{}
-	})
+		ch <- p
+	}
+	return waitForProgressCmd(ch)
 }
 
-func performPurgeCmd(recordsToDelete map[string]map[int]bool) tea.Cmd {
+func waitForProgressCmd(ch <-chan analyser.Progress) tea.Cmd {
 	return func() tea.Msg {
-		backupDir := "deleted_records"
-		if err := os.MkdirAll(backupDir, 0755); err != nil {
-			return purgeResultMsg{err: fmt.Errorf("could not create backup dir: %w", err)}
-		}
-		result := purgeResultMsg{}
-		for filePath, lineNumbersToDelete := range recordsToDelete {
-			file, err := os.Open(filePath)
-			if err != nil {
-				log.Printf("Purge: Could not open %s: %v", filePath, err)
-				continue
-			}
-			var newContent, backupContent strings.Builder
-			scanner := bufio.NewScanner(file)
-			lineNumber := 0
-			for scanner.Scan() {
-				lineNumber++
-				if lineNumbersToDelete[lineNumber] {
-					backupContent.WriteString(scanner.Text() + "\n")
-					result.recordsDeleted++
-				} else {
-					newContent.WriteString(scanner.Text() + "\n")
-				}
-			}
-			file.Close()
-			if err := scanner.Err(); err != nil {
-				log.Printf("Purge: Error scanning %s: %v", filePath, err)
-				continue
-			}
-			if backupContent.Len() > 0 {
-				backupFileName := fmt.Sprintf("deleted_records_%s", filepath.Base(filePath))
-				backupPath := filepath.Join(backupDir, backupFileName)
-				if err := os.WriteFile(backupPath, []byte(backupContent.String()), 0644); err != nil {
-					log.Printf("Purge: Could not write backup for %s: %v", filePath, err)
-					continue
-				}
-			}
-			if err := os.WriteFile(filePath, []byte(newContent.String()), 0644); err != nil {
-				log.Printf("Purge: Could not overwrite original file %s: %v", filePath, err)
-				continue
-			}
-			result.filesModified++
-		}
-		return result
+		return progressUpdateMsg{progress: <-ch}
 	}
 }
 
-func updateProgress(m model) (tea.Model, tea.Cmd) {
-	if m.analyser == nil {
-		return m, pollProgressCmd(&m)
-	}
-	processed := m.analyser.ProcessedFiles.Load()
-	total := len(m.originalSources)
-	percent := 0.0
-	if total > 0 {
-		percent = float64(processed) / float64(total)
-		elapsed := m.totalElapsedTime + time.Since(m.startTime)
-		if processed > 10 && percent < 1.0 {
-			timePerFile := elapsed / time.Duration(processed)
-			remainingFiles := total - int(processed)
-			m.eta = time.Duration(remainingFiles) * timePerFile
-		}
+func performPurgeCmd(recordsToDelete purge.Plan, key, logPath string) tea.Cmd {
+	return func() tea.Msg {
+		opts := purge.Options{Key: key, AuditLogPath: logPath, Strategy: "interactive"}
+		result, err := purge.Apply(context.Background(), recordsToDelete, opts)
+		return purgeResultMsg{filesModified: result.FilesModified, recordsDeleted: result.RecordsDeleted, manifest: result.Manifest, err: err}
+	}
+}
+
+func updateProgress(m model, p analyser.Progress) (tea.Model, tea.Cmd) {
+	elapsed := m.totalElapsedTime + time.Since(m.startTime)
+	percent, eta := analyser.EstimateProgress(p.BytesDone, p.BytesTotal, elapsed)
+	if eta > 0 {
+		m.eta = eta
+	}
+	// Bytes are counted as they are read, so hold the bar short of complete
+	// until every file has actually finished processing.
+	if p.FilesDone < p.FilesTotal {
+		percent = min(percent, 0.99)
+	} else if p.FilesTotal > 0 {
+		percent = 1.0
 	}
 	folderStr := "Discovering..."
-	if f, ok := m.analyser.CurrentFolder.Load().(string); ok && f != "" {
-		folderStr = f
+	if p.CurrentFolder != "" {
+		folderStr = p.CurrentFolder
+	}
+	m.status = fmt.Sprintf("Folder: %s | File %d of %d | %s of %s", folderStr, p.FilesDone, p.FilesTotal, report.HumanSize(p.BytesDone), report.HumanSize(p.BytesTotal))
+	if p.Errors > 0 {
+		m.status += fmt.Sprintf(" | %d errors", p.Errors)
 	}
-	m.status = fmt.Sprintf("Folder: %s | File %d of %d", folderStr, processed, total)
+	m.folderProgress = m.analyser.FolderProgress(m.originalSources)
+	m.throughput.sample(time.Now(), p.Rows, p.BytesDone)
+	m.logEntries, m.logCount = logging.Recent(m.logMark, logPaneEntries)
 	var cmds []tea.Cmd
 	cmds = append(cmds, m.progress.SetPercent(percent))
-	if percent < 1.0 && m.viewState == viewProcessing {
-		cmds = append(cmds, pollProgressCmd(&m))
+	if !p.Done {
+		cmds = append(cmds, waitForProgressCmd(m.progressCh))
 	}
 	return m, tea.Batch(cmds...)
 }
@@ -566,17 +741,19 @@ func updateProgress(m model) (tea.Model, tea.Cmd) {
 func updateMenu(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if key.Matches(msg, m.keys.Help) {
+			m.viewState = viewHelp
+			return m, nil
+		}
 		switch msg.String() {
 		case "up", "k":
 			if m.menuCursor > 0 {
 				m.menuCursor--
 			}
 		case "down", "j":
-			if m.menuCursor < 3 {
+			if m.menuCursor < 5 {
 				m.menuCursor++
 			}
-		case "?":
-			m.viewState = viewHelp
 		case "enter":
 			m.analyser = nil
 			m.finalReport = nil
@@ -586,17 +763,17 @@ func updateMenu(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
 			switch m.menuCursor {
 			case 0: // Start Validator
 				m.isValidationRun = true
-				m.viewState = viewInputPath
-				m.pathInput.Focus()
-				return m, textinput.Blink
+				return m, tea.Batch(m.openPathEditor(), textinput.Blink)
 			case 1: // Start Full Analysis
 				m.isValidationRun = false
-				m.viewState = viewInputPath
-				m.pathInput.Focus()
-				return m, textinput.Blink
-			case 2: // Options
+				return m, tea.Batch(m.openPathEditor(), textinput.Blink)
+			case 2: // Previous Reports
+				m.openHistory()
+			case 3: // Resume Previous Run
+				m.openCheckpoints()
+			case 4: // Options
 				m.viewState = viewOptions
-			case 3: // Quit
+			case 5: // Quit
 				m.quitting = true
 				return m, tea.Quit
 			}
@@ -617,7 +794,7 @@ func updateOptions(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.optionsCursor++
 			}
 		case "left":
-			if m.optionsCursor == 0 && m.workers > 1 {
+			if m.optionsCursor == 0 && m.workers > analyser.AutoWorkers {
 				m.workers--
 			}
 		case "right":
@@ -653,38 +830,13 @@ func updateOptions(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-func updateInputPath(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
-	var cmd tea.Cmd
-	switch msg := msg.(type) {
-	case tea.KeyMsg:
-		if msg.Type == tea.KeyEnter {
-			m.path = m.pathInput.Value()
-			if m.path == "" {
-				m.err = fmt.Errorf("path cannot be empty")
-				return m, nil
-			}
-
-			paths := strings.Split(m.path, ",")
-			for i, p := range paths {
-				paths[i] = strings.TrimSpace(p)
-				if strings.HasPrefix(paths[i], "gs://") && !m.gcsAvailable {
-					m.err = fmt.Errorf("cannot process GCS path: GCS credentials not available")
-					return m, nil
-				}
-			}
-
-			if m.isValidationRun || m.checkKey {
-				m.viewState = viewInputKey
-				m.pathInput.Blur()
-				m.keyInput.Focus()
-				return m, textinput.Blink
-			}
-			m.viewState = viewProcessing
-			return m, discoverAllSourcesCmd(m.ctx, paths)
-		}
+// inputPaths returns the comma-separated paths of the job.
+func (m *model) inputPaths() []string {
+	paths := strings.Split(m.path, ",")
+	for i, p := range paths {
+		paths[i] = strings.TrimSpace(p)
 	}
-	m.pathInput, cmd = m.pathInput.Update(msg)
-	return m, cmd
+	return paths
 }
 
 func updateInputKey(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -699,17 +851,43 @@ func updateInputKey(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			m.keyInput.Blur()
 			m.viewState = viewProcessing
-			paths := strings.Split(m.path, ",")
-			for i, p := range paths {
-				paths[i] = strings.TrimSpace(p)
+			if m.changingKey {
+				return m, m.rerunWithKey()
 			}
-			return m, discoverAllSourcesCmd(m.ctx, paths)
+			return m, discoverAllSourcesCmd(m.ctx, m.inputPaths())
 		}
 	}
 	m.keyInput, cmd = m.keyInput.Update(msg)
 	return m, cmd
 }
 
+// canChangeKey reports whether the report screen can rerun the job with a
+// different key: the report must have checked one.
+func (m *model) canChangeKey() bool {
+	return m.finalReport != nil && (m.finalReport.Summary.IsValidationReport || m.checkKey)
+}
+
+// rerunWithKey starts the job again once a new key is entered from the
+// report screen. The files found for the report are reused, unless a purge
+// has since rewritten some of them or the report was opened from the saved
+// reports, in which case they are found again.
+func (m *model) rerunWithKey() tea.Cmd {
+	sources := m.originalSources
+	if m.purgeStats.filesModified > 0 {
+		sources = nil
+	}
+	m.changingKey = false
+	m.finalReport = nil
+	m.purgeStats = purgeResultMsg{}
+	m.purgeSkipped = nil
+	m.search = searchFilter{}
+	m.wasCancelled = false
+	if len(sources) == 0 {
+		return discoverAllSourcesCmd(m.ctx, m.inputPaths())
+	}
+	return func() tea.Msg { return sourcesFoundMsg{sources: sources} }
+}
+
 func updateInputLogPath(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 	switch msg := msg.(type) {
@@ -732,15 +910,15 @@ func updateInputLogPath(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
 func updateReport(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "r":
+		switch {
+		case key.Matches(msg, m.keys.Restart):
 			m.wantsToRestart = true
 			return m, tea.Quit
-		case "n":
+		case key.Matches(msg, m.keys.NewJob):
 			m.wantsToRestart = true
 			m.wantsToStartNew = true
 			return m, tea.Quit
-		case "a":
+		case key.Matches(msg, m.keys.Analyse):
 			if m.finalReport != nil && m.finalReport.Summary.IsValidationReport {
 				m.isValidationRun = false
 				m.viewState = viewProcessing
@@ -748,7 +926,7 @@ func updateReport(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.wasCancelled = false
 				return m, discoverAllSourcesCmd(m.ctx, strings.Split(m.path, ","))
 			}
-		case "c":
+		case key.Matches(msg, m.keys.Continue):
 			if m.wasCancelled && m.analyser != nil {
 				unprocessedSources := m.analyser.GetUnprocessedSources(m.originalSources)
 				if len(unprocessedSources) > 0 {
@@ -756,51 +934,219 @@ func updateReport(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.viewState = viewProcessing
 					m.wasCancelled = false
 					m.startTime = time.Now()
+					m.throughput = throughput{}
 					m.jobCtx, m.jobCancel = context.WithCancel(m.ctx)
+					waitForProgress := m.followProgress()
 					return m, tea.Batch(
-						startAnalysisCmd(m.analyser, m.jobCtx, unprocessedSources, m.logPath, m.outputTxt, m.outputJson, m.checkKey, m.checkRow, m.showFolderBreakdown),
+						startAnalysisCmd(m.analyser, m.jobCtx, unprocessedSources, m.inputPaths(), m.logPath, m.outputTxt, m.outputJson, m.checkKey, m.checkRow, m.showFolderBreakdown),
 						m.spinner.Tick,
-						pollProgressCmd(&m),
+						waitForProgress,
 					)
 				}
 			}
-		case "p":
+		case key.Matches(msg, m.keys.Search):
+			if m.finalReport != nil && !m.finalReport.Summary.IsValidationReport {
+				return m, m.startSearch()
+			}
+		case key.Matches(msg, m.keys.Copy):
+			if m.finalReport != nil {
+				return m, copyCmd("the summary", m.summaryText())
+			}
+		case key.Matches(msg, m.keys.ChangeKey):
+			if m.canChangeKey() {
+				m.changingKey = true
+				m.keyInput.SetValue(m.key)
+				m.keyInput.CursorEnd()
+				m.viewState = viewInputKey
+				return m, m.keyInput.Focus()
+			}
+		case key.Matches(msg, m.keys.Export):
+			if m.finalReport != nil && !m.finalReport.Summary.IsValidationReport {
+				m.notice = "Exporting..."
+				return m, m.exportCmd()
+			}
+		case key.Matches(msg, m.keys.Details):
+			if m.finalReport != nil && !m.finalReport.Summary.IsValidationReport {
+				m.openDetails()
+			}
+		case key.Matches(msg, m.keys.Purge):
 			hasIdDupes := m.finalReport != nil && len(m.finalReport.DuplicateIDs) > 0
 			hasRowDupes := m.finalReport != nil && len(m.finalReport.DuplicateRows) > 0
 			canStartPurge := m.finalReport != nil && !m.finalReport.Summary.IsValidationReport &&
 				((m.purgeIds && hasIdDupes) || (m.purgeRows && hasRowDupes))
 
-			isGCS := strings.Contains(m.path, "gs://")
-			if !isGCS && canStartPurge && m.purgeStats.filesModified == 0 {
+			if canStartPurge && m.purgeStats.filesModified == 0 {
+				m.purgeSkipped = nil
 				if m.purgeIds && hasIdDupes {
-					for k := range m.finalReport.DuplicateIDs {
-						m.purgeIDKeys = append(m.purgeIDKeys, k)
-					}
-					sort.Strings(m.purgeIDKeys)
+					m.purgeIDKeys = m.matchingKeys(m.finalReport.DuplicateIDs)
 				}
 				if m.purgeRows && hasRowDupes {
-					for k := range m.finalReport.DuplicateRows {
-						m.purgeRowHashes = append(m.purgeRowHashes, k)
-					}
-					sort.Strings(m.purgeRowHashes)
+					m.purgeRowHashes = m.matchingKeys(m.finalReport.DuplicateRows)
+				}
+				if len(m.purgeIDKeys)+len(m.purgeRowHashes) == 0 {
+					return m, nil
 				}
+				m.preselectPurge()
 				m.viewState = viewPurgeSelection
 			}
 		}
 	}
 	return m, nil
 }
-func updatePurgeSelection(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
-	var locations []report.LocationInfo
-	if m.purgeCursor < len(m.purgeIDKeys) {
-		key := m.purgeIDKeys[m.purgeCursor]
-		locations = m.finalReport.DuplicateIDs[key]
-	} else {
-		hash := m.purgeRowHashes[m.purgeCursor-len(m.purgeIDKeys)]
-		locations = m.finalReport.DuplicateRows[hash]
+// preselectPurge works out the record the keep strategy would keep in each
+// set to purge, so each set starts with it selected. If the records cannot
+// be read, each set starts on its first record.
+func (m *model) preselectPurge() {
+	sets := make([][]report.LocationInfo, len(m.purgeIDKeys)+len(m.purgeRowHashes))
+	for i := range sets {
+		sets[i] = m.purgeLocations(i)
+	}
+	strategy := m.keepStrategy
+	if strategy == "" {
+		strategy = purge.KeepFirst
+	}
+	defaults, err := purge.Preselect(m.ctx, strategy, sets)
+	if err != nil {
+		slog.Warn("Could not pre-select the records to keep", "strategy", strategy, "error", err)
+	}
+	m.purgeDefaults = defaults
+	m.purgeSelectionCursor = m.purgeDefault(m.purgeCursor)
+}
+
+// purgeDefault returns the pre-selected record of the i-th set to purge.
+func (m *model) purgeDefault(i int) int {
+	if i < len(m.purgeDefaults) {
+		return m.purgeDefaults[i]
+	}
+	return 0
+}
+
+// purgeLocations returns the locations of the i-th set to purge. Duplicate
+// ID sets come before duplicate row sets.
+func (m *model) purgeLocations(i int) []report.LocationInfo {
+	if i < len(m.purgeIDKeys) {
+		return m.finalReport.DuplicateIDs[m.purgeIDKeys[i]]
+	}
+	return m.finalReport.DuplicateRows[m.purgeRowHashes[i-len(m.purgeIDKeys)]]
+}
+
+// selectedKeeps returns the records of the current set to keep: those marked
+// with space, or the record under the cursor if none are.
+func (m *model) selectedKeeps() map[int]bool {
+	if len(m.purgeKeepMarks) == 0 {
+		return map[int]bool{m.purgeSelectionCursor: true}
+	}
+	return maps.Clone(m.purgeKeepMarks)
+}
+
+// purgeSetValue returns the duplicated ID or row hash of the i-th set to
+// purge.
+func (m *model) purgeSetValue(i int) string {
+	if i < len(m.purgeIDKeys) {
+		return m.purgeIDKeys[i]
+	}
+	return m.purgeRowHashes[i-len(m.purgeIDKeys)]
+}
+
+// purgeSetLabel names the i-th set to purge for the list of skipped sets.
+func (m *model) purgeSetLabel(i int) string {
+	if i < len(m.purgeIDKeys) {
+		return fmt.Sprintf("ID '%s'", m.purgeIDKeys[i])
+	}
+	return fmt.Sprintf("row %s...", m.purgeRowHashes[i-len(m.purgeIDKeys)][:8])
+}
+
+// resetPurge clears the sets and choices of the interactive purge.
+func (m *model) resetPurge() {
+	m.purgeCursor = 0
+	m.purgeSelectionCursor = 0
+	m.recordsToDelete = make(purge.Plan)
+	m.purgeIDKeys = nil
+	m.purgeRowHashes = nil
+	m.purgeDefaults = nil
+	m.purgeKeepMarks = nil
+	m.purgeChoices = nil
+	m.purgeDiffRecords = nil
+}
+
+// nextPurgeSet records the records kept from the current set, or nil to skip
+// it, and moves on to the next set. Once every set has been resolved the
+// choices become the records to delete, which are shown for confirmation
+// before the purge starts. If every set was skipped, nothing is purged.
+func (m *model) nextPurgeSet(keep map[int]bool) tea.Cmd {
+	m.purgeChoices = append(m.purgeChoices, keep)
+	m.purgeCursor++
+	m.purgeKeepMarks = nil
+	if m.purgeCursor < len(m.purgeIDKeys)+len(m.purgeRowHashes) {
+		m.purgeSelectionCursor = m.purgeDefault(m.purgeCursor)
+		return nil
 	}
+	if m.purgePlan().Records() == 0 {
+		m.noteSkippedSets()
+		m.viewState = viewReport
+		m.resetPurge()
+		return nil
+	}
+	return m.confirmPurge()
+}
+
+// previousPurgeSet goes back to the previous set, bringing back the choice
+// made for it so that it can be revised.
+func (m *model) previousPurgeSet() {
+	if m.purgeCursor == 0 {
+		return
+	}
+	m.purgeCursor--
+	keep := m.purgeChoices[m.purgeCursor]
+	m.purgeChoices = m.purgeChoices[:m.purgeCursor]
+	m.purgeKeepMarks = nil
+	m.purgeSelectionCursor = m.purgeDefault(m.purgeCursor)
+	if len(keep) > 0 {
+		m.purgeSelectionCursor = slices.Min(slices.Collect(maps.Keys(keep)))
+	}
+	if len(keep) > 1 {
+		m.purgeKeepMarks = keep
+	}
+}
+
+func updatePurgeSelection(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
+	locations := m.purgeLocations(m.purgeCursor)
+	totalToPurge := len(m.purgeIDKeys) + len(m.purgeRowHashes)
 	switch msg := msg.(type) {
+	case previewMsg:
+		if msg.id == m.previewID {
+			m.purgeDiffRecords = msg.records
+		}
 	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.keys.Diff):
+			m.purgeDiff = !m.purgeDiff
+			return m, nil
+		case key.Matches(msg, m.keys.Skip):
+			return m, m.nextPurgeSet(nil)
+		case key.Matches(msg, m.keys.Search):
+			return m, m.startSearch()
+		case key.Matches(msg, m.keys.View):
+			loc := locations[m.purgeSelectionCursor]
+			return m, m.openPreview(fmt.Sprintf("Record %d of %s", m.purgeSelectionCursor+1, m.purgeSetLabel(m.purgeCursor)), []report.LocationInfo{loc})
+		case key.Matches(msg, m.keys.PrevSet):
+			m.previousPurgeSet()
+			return m, nil
+		case key.Matches(msg, m.keys.KeepAll):
+			keep := m.selectedKeeps()
+			for m.purgeCursor < totalToPurge-1 {
+				m.purgeChoices = append(m.purgeChoices, keep)
+				m.purgeCursor++
+				keep = map[int]bool{m.purgeDefault(m.purgeCursor): true}
+			}
+			return m, m.nextPurgeSet(keep)
+		case key.Matches(msg, m.keys.FirstAll):
+			for m.purgeCursor < totalToPurge-1 {
+				m.purgeChoices = append(m.purgeChoices, map[int]bool{0: true})
+				m.purgeCursor++
+			}
+			return m, m.nextPurgeSet(map[int]bool{0: true})
+		}
 		switch msg.String() {
 		case "up", "k":
 			if m.purgeSelectionCursor > 0 {
@@ -810,30 +1156,24 @@ func updatePurgeSelection(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
 			if m.purgeSelectionCursor < len(locations)-1 {
 				m.purgeSelectionCursor++
 			}
-		case "enter":
-			for i, loc := range locations {
-				if i != m.purgeSelectionCursor {
-					if _, ok := m.recordsToDelete[loc.FilePath]; !ok {
-						m.recordsToDelete[loc.FilePath] = make(map[int]bool)
-					}
-					m.recordsToDelete[loc.FilePath][loc.LineNumber] = true
+		case " ":
+			if m.purgeKeepMarks[m.purgeSelectionCursor] {
+				delete(m.purgeKeepMarks, m.purgeSelectionCursor)
+			} else {
+				if m.purgeKeepMarks == nil {
+					m.purgeKeepMarks = make(map[int]bool)
 				}
+				m.purgeKeepMarks[m.purgeSelectionCursor] = true
 			}
-			m.purgeCursor++
-			m.purgeSelectionCursor = 0
-			totalToPurge := len(m.purgeIDKeys) + len(m.purgeRowHashes)
-			if m.purgeCursor >= totalToPurge {
-				m.viewState = viewPurging
-				m.status = "Purging records..."
-				return m, tea.Batch(performPurgeCmd(m.recordsToDelete), m.spinner.Tick)
-			}
+		case "enter":
+			return m, m.nextPurgeSet(m.selectedKeeps())
 		}
 	}
 	return m, nil
 }
 
 func renderMenu(m *model) string {
-	choices := []string{"Start Validator", "Start Full Analysis", "Options", "Quit"}
+	choices := []string{"Start Validator", "Start Full Analysis", "Previous Reports", "Resume Previous Run", "Options", "Quit"}
 	s := "What would you like to do?\n\n"
 	for i, choice := range choices {
 		cursor := " "
@@ -842,11 +1182,20 @@ func renderMenu(m *model) string {
 		}
 		s += fmt.Sprintf("%s %s\n", menuCursorStyle.Render(cursor), choice)
 	}
-	return s + helpStyle.Render("\nUse up/down arrows, Enter to select, ? for help, q to quit.")
+	return s + helpStyle.Render(fmt.Sprintf("\nUse up/down arrows, Enter to select, %s for help, %s to quit.", m.keys.Help.Help().Key, m.keys.Quit.Help().Key))
+}
+
+// workersLabel shows the configured worker count, where zero means automatic.
+func workersLabel(workers int) string {
+	if workers <= analyser.AutoWorkers {
+		return "auto"
+	}
+	return fmt.Sprintf("%d", workers)
 }
+
 func renderOptions(m *model) string {
 	opts := []string{
-		fmt.Sprintf("Number of Workers: %d", m.workers),
+		fmt.Sprintf("Number of Workers: %s", workersLabel(m.workers)),
 		fmt.Sprintf("Duplicate Key Check: %t", m.checkKey),
 		fmt.Sprintf("Duplicate Row Check: %t", m.checkRow),
 		fmt.Sprintf("Show Folder Breakdown: %t", m.showFolderBreakdown),
@@ -885,54 +1234,125 @@ func renderHelp(m *model) string {
   --- Interactive Controls ---
   - Arrows:         Navigate menus
   - Enter:          Select menu item or submit input
-  - q / ctrl+c:     Quit the application
-  - esc:            Go back to the previous menu.
-  - ?:              Show this help screen (from main menu)
-  - r:              Restart the last job (from report screen)
-  - c:              Continue a cancelled job (from report screen)
-  - n:              Start a new job (from report screen)
-  - a:              Run full analysis (after a validation report)
-  - p:              Proceed to purge duplicates (from report screen, local files only)
+  - space:          Mark several records of a set to keep (while purging)
+  - PgUp/PgDn, Home/End: Scroll a long report (report screen)
+  - enter / r / c:  Open, rerun, or pick two to compare (Previous Reports)
+  - Mouse:          Click a menu item, option, set or record; the wheel scrolls. Hold Shift to select text.
 
+  --- Keys (rebind them in %s; ctrl+c always quits) ---
+%s
   --- Headless Mode Flags ---
   %s
-  -key <name>         Key for uniqueness check (default "id").
-  -workers <int>      Number of concurrent workers (default 8).
+  -key <name>         Key for uniqueness check (default "id"). Repeatable in headless mode.
+  -workers <int|auto> Number of concurrent workers (default 8), or auto.
   -log-path <path>    Directory to save logs and reports (default "logs").
+  -retention.keep, -retention.max-age, -retention.max-size
+                      Remove old reports by count, age or total MB.
+  -log.level          Minimum log level: debug, info, warn or error.
+  -log.format         Log format: text, or json for Cloud Logging.
+  -log.max-size       Size in MB at which analyser.log is rotated.
+  -log.max-files      Number of rotated log files to keep.
   -validate           Run a key validation test and exit (headless only).
   -check.key <bool>   Enable duplicate key check (default true).
   -check.row <bool>   Enable duplicate row check (default true).
   -output.txt <bool>  Enable .txt report output (default false).
   -output.json <bool> Enable .json report output (default false).
+  -output.html        Enable a self-contained, filterable .html report.
+  -output.md          Enable .md report output with Markdown summary tables.
+  -output.sarif       Enable .sarif output for code scanning annotations.
+  -output.junit       Enable .junit.xml output, one test case per check.
+  -output.ndjson      Export duplicate locations as NDJSON records.
+  -output.sqlite      Write an indexed SQLite database of the findings.
+  -report.bq          Stream findings into BigQuery (project.dataset.table).
+  -report.upload      Copy saved report files to a gs:// folder.
+  -report.template    Render text reports with a Go text/template file.
+  -report.max-sets    Limit duplicate sets listed per report section.
+  -report.max-locations-per-set
+                      Limit locations listed per duplicate set.
+  -report.mask-keys   Mask key values in reports: hash or partial.
+  -report.compress    Gzip the details, JSON, CSV and NDJSON report files.
+  -fail-on-duplicates Exit with code 3 if any duplicates are found.
+  -fail-threshold     Exit with code 3 above a count or percentage of repeats.
+  -quiet              Print only a one-line summary when something is found.
+  -progress           Progress format on stderr: text or json events.
+  -trace              Export OpenTelemetry spans over OTLP/HTTP.
+  -notify.webhook     Post a run summary to a webhook URL.
+  -notify.format      Webhook payload: auto, json or slack.
+  -notify.email       Email the summary and reports to these addresses.
+  -smtp.addr, -smtp.from, -smtp.user
+                      SMTP server, sender and username for -notify.email.
+  -output.csv         Enable .csv report output, one row per duplicate location.
   -show.folders <bool> Show per-folder breakdown table in summary (default true).
-  -purge-ids <bool>   Enable interactive purging (default false, interactive & local only).
-  -purge-rows <bool>  Enable interactive purging (default false, interactive & local only).
+  -show.files         Add a per-file breakdown table to the report.
+  -purge-ids <bool>   Enable interactive purging (default false).
+  -purge-rows <bool>  Enable interactive purging (default false).
+  -purge.auto         Purge without prompting: keep-first, keep-last,
+                      keep-largest-file, keep-newest-file, keep-newest:<field>,
+                      keep-oldest:<field> or keep-fewest-nulls (headless only).
+  -purge.keep         Keep strategy pre-selected in each set (default keep-first).
+  -purge.plan         Write the -purge.auto plan to a file instead of purging.
+  -purge.apply        Apply a reviewed purge plan file, then exit.
+  -dedup.out <dir>    Write deduplicated copies of every file to a mirror folder (headless only).
+  -dedup.merge-out    Write every unique record to one NDJSON file (headless only).
+  -dedup.keep         Keep strategy for -dedup.out and -dedup.merge-out (default keep-first).
+  -purge.quarantine   Move purged records to quarantine files under a folder (headless only).
+  -purge.backup-dir   Directory for the timestamped purge backups (default "deleted_records").
+  -purge.gcs-backup   gs://bucket/prefix/ for records purged from GCS (headless only).
+  -purge.restore      Put back the records of a purge from its manifest, then exit.
   -headless           Run without TUI and print report to stdout.
   -output <txt|json>  Output format for headless mode (default "txt").
-  `, pathHelp)
+  -fuzzy.field <name> Cluster similar values of a field as probable duplicates (headless only).
+  -fuzzy.threshold <f> Similarity threshold for -fuzzy.field (default 0.9).
+  -constraints <file> JSON file of extra/composite uniqueness constraints (headless only).
+  -discover-keys      Rank top-level fields as candidate keys (headless only).
+  -discover.sample <n> Rows to sample for -discover-keys (default 100000).
+  -profile            Add per-field cardinality and value profile (headless only).
+  -profile.top <n>    Top values reported per field (default 5).
+  -report.samples <n> Embed the first N records of each duplicate set in JSON output (headless only).
+  -report.sample-bytes <n> Size cap per embedded record (default 4096).
+  -index <mode>       Duplicate index: memory, disk or sort (headless only).
+  -index.dir <path>   Directory for disk/sort index temporary files.
+  -row.raw            Hash raw lines for row-only checks, skipping JSON decoding.
+  -max-memory <size>  Memory budget (e.g. 4GiB); spill to disk, then stop, as it is approached.
+  -max-line-size <size> Longest line to analyse (default 4MiB); longer lines are skipped and reported.
+  -chunk-size <size>  Scan local files over twice this size in parallel chunks (default 256MiB).
+  -checkpoint <dur>   Save a resumable checkpoint at this interval (headless only).
+  -resume <file>      Resume a headless run from a checkpoint.
+  -cache <file>       Reuse results for unchanged files from a fingerprint cache.
+  -shard              List single values too, so reports can be merged.
+  -mmap               Memory-map local files instead of buffered reads.
+  -debug.addr <addr>  Serve pprof and runtime metrics, e.g. :6060.
+  -bloom              Two-pass bloom pre-filter to reduce index memory (headless only).
+  -bloom.items <n>    Expected distinct values per index (default 10000000).
+  -left <p> -right <p> Compare two path sets by key (headless only).
+  -scope <global|file|folder> Compare records globally or within each file/folder (headless only).
+  `, config.KeysPath, m.keys.cheatsheet(), pathHelp)
 }
 
-func renderInputPath(m *model) string {
-	pad := strings.Repeat(" ", 2)
-	var prompt string
-	if m.gcsAvailable {
-		prompt = "Please enter one or more comma-separated paths to analyse:"
-	} else {
-		prompt = "Please enter one or more comma-separated local paths to analyse:"
+// inputHelp ends the help of the text inputs with the keys to quit and go
+// back.
+func (m *model) inputHelp() string {
+	return fmt.Sprintf("%s to quit, %s to go back.", m.quitKeys(), keyNames(m.keys.Back.Keys()))
+}
+
+// quitKeys names the keys that quit, ctrl+c among them whatever the keymap.
+func (m *model) quitKeys() string {
+	keys := m.keys.Quit.Keys()
+	if !slices.Contains(keys, "ctrl+c") {
+		keys = append(slices.Clone(keys), "ctrl+c")
 	}
-	help := helpStyle.Render("Press Enter to submit, 'q' or 'ctrl+c' to quit, 'esc' to go back.")
-	return fmt.Sprintf("\n%s%s\n\n%s%s\n\n%s", pad, prompt, pad, m.pathInput.View(), help)
+	return keyNames(keys)
 }
 
 func renderInputLogPath(m *model) string {
 	pad := strings.Repeat(" ", 2)
-	help := helpStyle.Render("Press Enter to submit, 'q' or 'ctrl+c' to quit, 'esc' to go back.")
+	help := helpStyle.Render("Press Enter to submit, " + m.inputHelp())
 	return fmt.Sprintf("\n%sPlease enter the path for logs and reports:\n\n%s%s\n\n%s", pad, pad, m.logPathInput.View(), help)
 }
 
 func renderInputKey(m *model) string {
 	pad := strings.Repeat(" ", 2)
-	help := helpStyle.Render("Press Enter to submit, 'q' or 'ctrl+c' to quit, 'esc' to go back.")
+	help := helpStyle.Render("Press Enter to submit, " + m.inputHelp())
 	return fmt.Sprintf("\n%sPaths: %s\n\n%sPlease enter the JSON key to check for uniqueness (e.g., id, product_sku):\n\n%s%s\n\n%s", pad, m.path, pad, pad, m.keyInput.View(), help)
 }
 
@@ -943,27 +1363,99 @@ func renderProcessing(m *model) string {
 		progressView = "\n" + m.progress.View()
 		elapsedStr := (m.totalElapsedTime + time.Since(m.startTime)).Round(time.Second).String()
 		etaStr := m.eta.Round(time.Second).String()
-		timingView = timingStyle.Render(fmt.Sprintf(" (Elapsed: %s, ETA: %s)", elapsedStr, etaStr))
+		rates := ""
+		if s := m.throughput.String(); s != "" {
+			rates = ", " + s
+		}
+		timingView = timingStyle.Render(fmt.Sprintf(" (Elapsed: %s, ETA: %s%s)", elapsedStr, etaStr, rates))
 	}
 	status := statusStyle.Render(m.status)
 	if m.viewState == viewCancelling {
 		return fmt.Sprintf("\n%s%s %s\n", pad, m.spinner.View(), m.status)
 	}
-	return fmt.Sprintf("\n%s%s%s%s\n%s", pad, m.spinner.View(), status, timingView, progressView) + helpStyle.Render("\nPress 'q' or 'ctrl+c' to cancel.")
+	help := "Press " + m.quitKeys() + " to cancel."
+	if m.logCount > 0 {
+		help = fmt.Sprintf("Press %s to cancel, %s to show or hide the warnings.", m.quitKeys(), m.keys.Logs.Help().Key)
+	}
+	return fmt.Sprintf("\n%s%s%s%s\n%s", pad, m.spinner.View(), status, timingView, progressView) + renderFolderProgress(m) + renderLogPane(m) + helpStyle.Render("\n"+help)
 }
 
+// renderReport renders the report screen. Once the terminal size is known,
+// the report is shown in a viewport that fits above the help line, so long
+// reports can be scrolled.
 func renderReport(m *model) string {
 	if m.finalReport == nil {
 		return "Generating report..."
 	}
+	body, help := reportBody(m), reportHelp(m, false)
+	if m.height == 0 {
+		return body + help
+	}
+	vp := m.reportViewport(body, help)
+	if vp.TotalLineCount() > vp.Height {
+		help = reportHelp(m, true)
+	}
+	return vp.View() + help
+}
+
+// reportViewport returns the report viewport sized to the terminal, less
+// the lines the help takes, and holding body.
+func (m *model) reportViewport(body, help string) viewport.Model {
+	vp := m.reportView
+	vp.Width = m.width
+	vp.Height = max(m.height-lipgloss.Height(help), 1)
+	vp.SetContent(body)
+	return vp
+}
+
+// scrollReport handles the keys that scroll the report, reporting whether
+// msg was one of them.
+func (m *model) scrollReport(msg tea.KeyMsg) bool {
+	if m.height == 0 || m.finalReport == nil {
+		return false
+	}
+	vp := m.reportViewport(reportBody(m), reportHelp(m, false))
+	switch msg.String() {
+	case "pgup":
+		vp.PageUp()
+	case "pgdown":
+		vp.PageDown()
+	case "home":
+		vp.GotoTop()
+	case "end":
+		vp.GotoBottom()
+	case "up", "k":
+		vp.ScrollUp(1)
+	case "down", "j":
+		vp.ScrollDown(1)
+	default:
+		return false
+	}
+	m.reportView.YOffset = vp.YOffset
+	return true
+}
+
+// reportBody renders the report and what has been done with it since.
+func reportBody(m *model) string {
 	var b strings.Builder
 	b.WriteString("\n" + m.finalReport.String(false, m.checkKey, m.checkRow, m.showFolderBreakdown))
 	if m.purgeStats.filesModified > 0 || m.purgeStats.recordsDeleted > 0 {
 		purgeSummary := fmt.Sprintf("Files Modified: %d\nRecords Deleted: %d (and backed up)", m.purgeStats.filesModified, m.purgeStats.recordsDeleted)
+		if m.purgeStats.manifest != "" {
+			purgeSummary += "\nUndo with: dupe-analyser -purge.restore " + m.purgeStats.manifest
+		}
 		b.WriteString("\n\n" + reportStyle.Render(purgeSummary))
 	} else if m.purgeStats.err != nil {
 		b.WriteString("\n\n" + errorStyle.Render("Purge failed: "+m.purgeStats.err.Error()))
 	}
+	if len(m.purgeSkipped) > 0 {
+		skipped := m.purgeSkipped
+		more := ""
+		if len(skipped) > 10 {
+			skipped, more = skipped[:10], fmt.Sprintf(", and %d more", len(skipped)-10)
+		}
+		b.WriteString("\n\n" + fmt.Sprintf("Skipped %d duplicate set(s) for manual follow-up: %s%s.", len(m.purgeSkipped), strings.Join(skipped, ", "), more))
+	}
 	if !m.finalReport.Summary.IsValidationReport && (m.outputTxt || m.outputJson) {
 		var parts []string
 		if m.outputTxt {
@@ -975,30 +1467,56 @@ func renderReport(m *model) string {
 		b.WriteString("\n\n" + fmt.Sprintf("Reports saved to files with extension(s): %s", m.savedFilename))
 	}
 
+	b.WriteString(renderSearch(m))
+	return b.String()
+}
+
+// reportHelp renders the keys of the report screen, with the scrolling keys
+// when the report does not fit.
+func reportHelp(m *model, scrollable bool) string {
 	helpParts := []string{}
 	if m.finalReport != nil && m.finalReport.Summary.IsValidationReport {
-		helpParts = append(helpParts, "(a)nalyse now")
+		helpParts = append(helpParts, keyHint(m.keys.Analyse, "analyse now"))
+	} else {
+		helpParts = append(helpParts, keyHint(m.keys.Search, "search"), keyHint(m.keys.Details, "details"), keyHint(m.keys.Export, "export"))
+	}
+	if m.canChangeKey() {
+		helpParts = append(helpParts, keyHint(m.keys.ChangeKey, "change key"))
 	}
 	if m.wasCancelled {
-		helpParts = append(helpParts, "(c)ontinue")
+		helpParts = append(helpParts, keyHint(m.keys.Continue, "continue"))
 	}
-	helpParts = append(helpParts, "(r)estart", "(n)ew job")
+	helpParts = append(helpParts, keyHint(m.keys.Restart, "restart"), keyHint(m.keys.NewJob, "new job"))
 
 	hasIdDupesToPurge := m.purgeIds && m.finalReport != nil && len(m.finalReport.DuplicateIDs) > 0
 	hasRowDupesToPurge := m.purgeRows && m.finalReport != nil && len(m.finalReport.DuplicateRows) > 0
 	canDisplayPurge := m.finalReport != nil && !m.finalReport.Summary.IsValidationReport && (hasIdDupesToPurge || hasRowDupesToPurge)
 
-	isGCS := strings.Contains(m.path, "gs://")
-	if !isGCS && canDisplayPurge && m.purgeStats.filesModified == 0 {
-		helpParts = append(helpParts, "(p)urge")
+	if canDisplayPurge && m.purgeStats.filesModified == 0 {
+		helpParts = append(helpParts, keyHint(m.keys.Purge, "purge"))
+	}
+	helpParts = append(helpParts, keyHint(m.keys.Copy, "copy"), keyHint(m.keys.Quit, "quit"))
+	helpParts = slices.DeleteFunc(helpParts, func(s string) bool { return s == "" })
+	if scrollable {
+		helpParts = append(helpParts, "PgUp/PgDn/Home/End to scroll")
 	}
-	helpParts = append(helpParts, "(q)uit")
 
-	b.WriteString("\n" + helpStyle.Render("Press "+strings.Join(helpParts, ", ")+"."))
-	return b.String()
+	help := "\n" + helpStyle.Render("Press "+strings.Join(helpParts, ", ")+".")
+	if m.notice != "" {
+		help = "\n" + timingStyle.Render(m.notice) + help
+	}
+	return help
 }
 func renderPurgeSelection(m *model) string {
+	s, _ := purgeSelectionLayout(m)
+	return s
+}
+
+// purgeSelectionLayout renders the purge selection view, returning with it
+// the record shown on each line that shows one, by line number.
+func purgeSelectionLayout(m *model) (string, map[int]int) {
 	var b strings.Builder
+	rows := make(map[int]int)
 	var locations []report.LocationInfo
 	var title string
 	totalToPurge := len(m.purgeIDKeys) + len(m.purgeRowHashes)
@@ -1014,14 +1532,31 @@ func renderPurgeSelection(m *model) string {
 	}
 	b.WriteString(fmt.Sprintf("Resolving %d of %d duplicate sets...\n", m.purgeCursor+1, totalToPurge))
 	b.WriteString(headerStyle.Render(title) + "\n\n")
-	b.WriteString("Select the one record to KEEP:\n")
+	b.WriteString("Select the record to KEEP, or mark several with space:\n")
 	for i, loc := range locations {
 		cursor := "  "
 		if i == m.purgeSelectionCursor {
 			cursor = selectionStyle.Render("> ")
 		}
-		b.WriteString(fmt.Sprintf("%sFile: %s\n  Line: %d\n", cursor, loc.FilePath, loc.LineNumber))
+		mark := ""
+		if m.purgeKeepMarks[i] {
+			mark = selectionStyle.Render("[keep] ")
+		}
+		line := strings.Count(b.String(), "\n")
+		rows[line], rows[line+1] = i, i
+		b.WriteString(fmt.Sprintf("%s%sFile: %s\n  Line: %d", cursor, mark, loc.FilePath, loc.LineNumber))
+		if i == m.purgeDefault(m.purgeCursor) && m.keepStrategy != "" && m.keepStrategy != purge.KeepFirst {
+			b.WriteString(timingStyle.Render(" (" + m.keepStrategy + ")"))
+		}
+		b.WriteString("\n")
 	}
-	b.WriteString(helpStyle.Render("\nUse up/down arrows to select. Enter to confirm and move to next set."))
-	return b.String()
+	b.WriteString(renderPurgeDiff(m))
+	if m.search.active() {
+		b.WriteString(timingStyle.Render(fmt.Sprintf("\nOnly sets matching %q are left to resolve.", m.search.query)))
+	}
+	b.WriteString(renderSearch(m))
+	k := m.keys
+	b.WriteString(helpStyle.Render(fmt.Sprintf("\nUse up/down arrows to select, space to mark several records to keep. Enter to confirm and move to next set, %s to skip it, %s to go back, %s to search, %s to view the record, %s to compare fields.\n", k.Skip.Help().Key, k.PrevSet.Help().Key, k.Search.Help().Key, k.View.Help().Key, k.Diff.Help().Key) +
+		fmt.Sprintf("%s: confirm and keep the pre-selected record of every remaining set. %s: keep the first record of this and every remaining set.", k.KeepAll.Help().Key, k.FirstAll.Help().Key)))
+	return b.String(), rows
 }
@@ -0,0 +1,846 @@
+// pkg/analyser/analyser.go
+
+// Package analyser reads NDJSON sources and finds the records that repeat a
+// key, a uniqueness constraint or a whole row. Most callers should use the
+// simpler dupeanalyse package.
+package analyser
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+	"github.com/benjaminwestern/dupe-analyser/pkg/source"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/benjaminwestern/dupe-analyser/pkg/analyser")
+
+// Analyser holds the state and configuration for an analysis run.
+type Analyser struct {
+	uniqueKey              string
+	numWorkers             int
+	autoWorkers            bool
+	workersNote            string
+	checkKey               bool
+	checkRow               bool
+	ValidateOnly           bool
+	FuzzyField             string
+	FuzzyThreshold         float64
+	Scope                  string
+	DiscoverKeys           bool
+	SampleRows             int64
+	Profile                bool
+	ProfileTopN            int
+	SampleRecords          int
+	MaxSampleBytes         int
+	IndexMode              string
+	IndexDir               string
+	BloomPrePass           bool
+	BloomExpectedItems     uint64
+	RawRowHash             bool
+	MaxMemory              int64
+	MaxLineSize            int
+	ChunkSize              int64
+	MemoryMap              bool
+	CheckpointPath         string
+	CheckpointInterval     time.Duration
+	CheckpointMeta         json.RawMessage
+	CachePath              string
+	ShardOutput            bool
+	FileBreakdown          bool
+	OnProgress             func(Progress)
+	ProgressInterval       time.Duration
+	cachedFiles            int
+	oversizedLines         map[string]int64
+	oversizedMutex         sync.Mutex
+	parseErrors            map[string]int64
+	parseErrorsMutex       sync.Mutex
+	memoryExhausted        atomic.Bool
+	errorCount             atomic.Int64
+	chunkSlots             chan struct{}
+	budgetNotes            []string
+	budgetMutex            sync.Mutex
+	training               bool
+	ids                    locationIndex
+	rows                   locationIndex
+	fuzzy                  locationIndex
+	extraKeys              []*keyIndex
+	primaryConstraints     []string
+	fieldStats             map[string]*fieldStats
+	fieldMutex             sync.Mutex
+	keysFoundPerFolder     map[string]int64
+	keysFoundMutex         sync.Mutex
+	rowsProcessedPerFolder map[string]int64
+	rowsProcessedMutex     sync.Mutex
+	ProcessedFiles         *atomic.Int32
+	TotalRows              *atomic.Int64
+	ProcessedBytes         *atomic.Int64
+	RepeatedRecords        *atomic.Int64
+	CurrentFolder          *atomic.Value
+	processedPaths         map[string]bool
+	fileTallies            map[string]fileTally
+	processedPathsMutex    sync.Mutex
+	sourcesByPath          map[string]source.InputSource
+}
+
+// New creates a new, configured Analyser instance. A worker count of
+// AutoWorkers, or below, sizes the pool automatically when Run starts.
+func New(uniqueKey string, numWorkers int, checkKey, checkRow, validateOnly bool) *Analyser {
+	return &Analyser{
+		uniqueKey:              uniqueKey,
+		numWorkers:             numWorkers,
+		autoWorkers:            numWorkers <= AutoWorkers,
+		checkKey:               checkKey,
+		checkRow:               checkRow,
+		ValidateOnly:           validateOnly,
+		Scope:                  ScopeGlobal,
+		IndexMode:              IndexMemory,
+		MaxLineSize:            source.DefaultMaxLineSize,
+		ChunkSize:              DefaultChunkSize,
+		oversizedLines:         make(map[string]int64),
+		parseErrors:            make(map[string]int64),
+		fieldStats:             make(map[string]*fieldStats),
+		keysFoundPerFolder:     make(map[string]int64),
+		rowsProcessedPerFolder: make(map[string]int64),
+		ProcessedFiles:         new(atomic.Int32),
+		TotalRows:              new(atomic.Int64),
+		ProcessedBytes:         new(atomic.Int64),
+		RepeatedRecords:        new(atomic.Int64),
+		CurrentFolder:          new(atomic.Value),
+		processedPaths:         make(map[string]bool),
+		fileTallies:            make(map[string]fileTally),
+		sourcesByPath:          make(map[string]source.InputSource),
+	}
+}
+
+// isProcessed reports whether a source has already been fully processed, for
+// example by a restored checkpoint.
+func (a *Analyser) isProcessed(path string) bool {
+	a.processedPathsMutex.Lock()
+	defer a.processedPathsMutex.Unlock()
+	return a.processedPaths[path]
+}
+
+// GetUnprocessedSources filters a list of all sources against the ones that have
+// already been successfully processed by this analyser instance.
+func (a *Analyser) GetUnprocessedSources(allSources []source.InputSource) []source.InputSource {
+	a.processedPathsMutex.Lock()
+	defer a.processedPathsMutex.Unlock()
+
+	var unprocessed []source.InputSource
+	for _, s := range allSources {
+		if !a.processedPaths[s.Path()] {
+			unprocessed = append(unprocessed, s)
+		}
+	}
+	return unprocessed
+}
+
+// Run executes the analysis process on a given set of sources and returns a full report.
+func (a *Analyser) Run(ctx context.Context, sources []source.InputSource) *report.AnalysisReport {
+	ctx, span := tracer.Start(ctx, "analyse", trace.WithAttributes(
+		attribute.Int("files", len(sources)),
+		attribute.Int64("bytes", source.TotalSize(sources)),
+		attribute.String("index", a.IndexMode),
+	))
+	defer span.End()
+	a.initIndexes()
+	for _, s := range sources {
+		a.sourcesByPath[s.Path()] = s
+	}
+	if a.autoWorkers {
+		a.numWorkers, a.workersNote = tuneWorkers(ctx, sources)
+	}
+	span.SetAttributes(attribute.Int("workers", a.numWorkers))
+	// Chunks of every large file share one pool, so files split at once
+	// cannot run more than numWorkers scans between them.
+	a.chunkSlots = make(chan struct{}, max(1, a.numWorkers))
+
+	if a.CachePath != "" {
+		a.applyCache(sources)
+	}
+
+	stopWatching := func() {}
+	if a.MaxMemory > 0 {
+		stopWatching = goUntilStopped(ctx, a.watchMemory)
+	}
+	stopProgress := func() {}
+	if a.OnProgress != nil {
+		filesTotal, bytesTotal := a.progressTotals()
+		start := time.Now()
+		stopSending := goUntilStopped(ctx, func(ctx context.Context) {
+			a.sendProgress(ctx, filesTotal, bytesTotal, start)
+		})
+		stopProgress = func() {
+			stopSending()
+			p := a.progress(filesTotal, bytesTotal, start)
+			p.Done = true
+			a.OnProgress(p)
+		}
+	}
+	stopCheckpoints := func() {}
+	if a.CheckpointPath != "" && a.CheckpointInterval > 0 {
+		stopCheckpoints = goUntilStopped(ctx, a.runCheckpoints)
+	}
+
+	if a.BloomPrePass && !a.ValidateOnly && !a.DiscoverKeys {
+		a.training = true
+		a.runPipeline(ctx, sources)
+		a.training = false
+		a.resetRunState()
+		a.finishTraining()
+	}
+	a.runPipeline(ctx, sources)
+	stopWatching()
+	stopProgress()
+	stopCheckpoints()
+	if a.CheckpointPath != "" {
+		if ctx.Err() != nil || a.memoryExhausted.Load() {
+			a.saveCheckpoint()
+		} else {
+			a.removeCheckpoint()
+		}
+	}
+	if a.CachePath != "" && ctx.Err() == nil && !a.memoryExhausted.Load() {
+		a.saveCache()
+	}
+
+	_, reportSpan := tracer.Start(ctx, "generate report")
+	rep := a.generateReport(sources, ctx.Err() != nil, a.ValidateOnly)
+	reportSpan.End()
+	if a.SampleRecords > 0 && !a.ValidateOnly && ctx.Err() == nil {
+		sampleCtx, sampleSpan := tracer.Start(ctx, "attach samples")
+		a.attachSamples(sampleCtx, rep)
+		sampleSpan.End()
+	}
+	span.SetAttributes(attribute.Int64("rows", rep.Summary.TotalRowsProcessed), attribute.Bool("partial", rep.Summary.IsPartialReport))
+	return rep
+}
+
+// goUntilStopped runs fn in a goroutine with a context derived from ctx. The
+// returned function cancels that context and waits for fn to return.
+func goUntilStopped(ctx context.Context, fn func(context.Context)) func() {
+	fnCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		fn(fnCtx)
+		close(done)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// runPipeline feeds every source through the worker pool and waits for the
+// workers to finish.
+func (a *Analyser) runPipeline(ctx context.Context, sources []source.InputSource) {
+	var workerWg sync.WaitGroup
+	sourceChan := make(chan source.InputSource, a.numWorkers)
+
+	for i := 0; i < a.numWorkers; i++ {
+		workerWg.Add(1)
+		go a.worker(ctx, sourceChan, &workerWg)
+	}
+
+	go func() {
+		defer close(sourceChan)
+	feedLoop:
+		for _, s := range sources {
+			if a.isProcessed(s.Path()) {
+				continue
+			}
+			select {
+			case sourceChan <- s:
+			case <-ctx.Done():
+				break feedLoop
+			}
+		}
+	}()
+
+	workerWg.Wait()
+}
+
+// resetRunState clears the counters gathered during a bloom training pass so
+// that the recording pass does not count every row twice.
+func (a *Analyser) resetRunState() {
+	a.TotalRows.Store(0)
+	a.ProcessedFiles.Store(0)
+	a.ProcessedBytes.Store(0)
+	a.rowsProcessedMutex.Lock()
+	a.rowsProcessedPerFolder = make(map[string]int64)
+	a.rowsProcessedMutex.Unlock()
+	a.keysFoundMutex.Lock()
+	a.keysFoundPerFolder = make(map[string]int64)
+	a.keysFoundMutex.Unlock()
+	a.processedPathsMutex.Lock()
+	a.processedPaths = make(map[string]bool)
+	a.fileTallies = make(map[string]fileTally)
+	a.processedPathsMutex.Unlock()
+	for _, extra := range a.extraKeys {
+		extra.occurrences.Store(0)
+	}
+	a.oversizedMutex.Lock()
+	a.oversizedLines = make(map[string]int64)
+	a.oversizedMutex.Unlock()
+	a.parseErrorsMutex.Lock()
+	a.parseErrors = make(map[string]int64)
+	a.parseErrorsMutex.Unlock()
+	a.errorCount.Store(0)
+}
+
+func (a *Analyser) worker(ctx context.Context, sourceChan <-chan source.InputSource, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for src := range sourceChan {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+			a.processSource(ctx, src)
+		}
+	}
+}
+
+// processSource reads a single source line by line and records its keys and
+// row hashes. Large local files are split into chunks that are scanned in
+// parallel.
+func (a *Analyser) processSource(ctx context.Context, src source.InputSource) {
+	if a.memoryExhausted.Load() || a.sampleDone() {
+		return
+	}
+	a.CurrentFolder.Store(src.Dir())
+	ctx, span := tracer.Start(ctx, "process file", trace.WithAttributes(
+		attribute.String("file.path", src.Path()),
+		attribute.Int64("file.size", src.Size()),
+	))
+	defer span.End()
+
+	var local *fileIndex
+	if a.Scope == ScopeFile {
+		local = newFileIndex()
+	}
+
+	var tally fileTally
+	var completed bool
+	start := time.Now()
+	if chunked, ok := src.(source.ChunkedSource); ok && a.ChunkSize > 0 && src.Size() > 2*a.ChunkSize {
+		completed = a.processChunks(ctx, chunked, local, &tally)
+	} else {
+		lines, closeSource, err := a.openLines(ctx, src)
+		if err != nil {
+			slog.Error("Could not open source", "path", src.Path(), "error", err)
+			a.errorCount.Add(1)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "could not open source")
+			return
+		}
+		st := a.newScanState(local)
+		completed = a.scanLines(ctx, src, lines, st)
+		closeSource()
+		tally.add(st.tally)
+	}
+	span.SetAttributes(attribute.Int64("rows", tally.Rows), attribute.Bool("completed", completed))
+	if !completed {
+		return
+	}
+	if local != nil {
+		a.flushFileIndex(src.Path(), local)
+	}
+
+	tally.Bytes = src.Size()
+	tally.Duration = time.Since(start)
+	tally.Fingerprint = src.Fingerprint()
+	a.processedPathsMutex.Lock()
+	a.processedPaths[src.Path()] = true
+	a.fileTallies[src.Path()] = tally
+	a.processedPathsMutex.Unlock()
+	a.ProcessedFiles.Add(1)
+	slog.Debug("Processed source", "path", src.Path(), "bytes", tally.Bytes, "rows", tally.Rows, "keys", tally.Keys)
+}
+
+// scanState is the per-goroutine state used while scanning one file or chunk.
+type scanState struct {
+	local     *fileIndex
+	rowHasher hash.Hash64
+	tally     fileTally
+	// lines counts the lines read, blank and skipped ones included.
+	lines int
+	// chunk is the chunk being scanned, or nil for a whole file. Line numbers
+	// are relative to the start of a chunk until the file is finished.
+	chunk *source.Chunk
+}
+
+// lineAttrs returns the log attributes that locate line n: its line number,
+// or within a chunk the chunk's offset and the line's number in the chunk.
+func (st *scanState) lineAttrs(n int) []any {
+	if st.chunk != nil {
+		return []any{"chunk_offset", st.chunk.Offset, "chunk_line", n}
+	}
+	return []any{"line", n}
+}
+
+func (a *Analyser) newScanState(local *fileIndex) *scanState {
+	return &scanState{
+		local:     local,
+		rowHasher: fnv.New64a(),
+		tally:     fileTally{Extra: make([]int64, len(a.extraKeys))},
+	}
+}
+
+// fileTally holds the counts contributed by a single completed file. They are
+// kept per file so a checkpoint can restore exactly the files that finished.
+type fileTally struct {
+	Rows      int64
+	Keys      int64
+	Oversized int64
+	// ParseErrors counts the lines that were not valid JSON.
+	ParseErrors int64
+	Bytes       int64
+	// Duration is the time spent reading the file.
+	Duration time.Duration
+	// Fingerprint identifies the file's content, so a cached tally is only
+	// reused while the file is unchanged.
+	Fingerprint string
+	// Extra counts the occurrences of each additional key, in the order of
+	// the analyser's additional keys.
+	Extra []int64
+}
+
+func (t *fileTally) add(o fileTally) {
+	t.Rows += o.Rows
+	t.Keys += o.Keys
+	t.Oversized += o.Oversized
+	t.ParseErrors += o.ParseErrors
+	t.Bytes += o.Bytes
+	if len(t.Extra) < len(o.Extra) {
+		t.Extra = append(t.Extra, make([]int64, len(o.Extra)-len(t.Extra))...)
+	}
+	for i, n := range o.Extra {
+		t.Extra[i] += n
+	}
+}
+
+// scanLines processes every line from lines, numbering lines from 1. It
+// reports whether the reader was consumed, or stopped early for a sample
+// limit, rather than interrupted by cancellation or a read error.
+func (a *Analyser) scanLines(ctx context.Context, src source.InputSource, lines *source.LineReader, st *scanState) bool {
+	rawRows := a.rawRowFastPath()
+	var normalised []byte
+
+	// Folder counters are tallied per file and published in batches so that
+	// workers do not contend on the shared maps for every row.
+	lineNumber := 0
+	dir := src.Dir()
+	var rowsTallied, keysTallied int64
+	var bytesReported int64
+	reportBytes := func() {
+		offset := lines.Offset()
+		a.ProcessedBytes.Add(offset - bytesReported)
+		bytesReported = offset
+	}
+	defer func() {
+		st.lines = lineNumber
+		reportBytes()
+		a.addFolderCounts(dir, rowsTallied, keysTallied)
+		a.errorCount.Add(st.tally.Oversized + st.tally.ParseErrors)
+		if st.tally.Oversized > 0 {
+			a.oversizedMutex.Lock()
+			a.oversizedLines[src.Path()] += st.tally.Oversized
+			a.oversizedMutex.Unlock()
+		}
+		if st.tally.ParseErrors > 0 {
+			a.parseErrorsMutex.Lock()
+			a.parseErrors[src.Path()] += st.tally.ParseErrors
+			a.parseErrorsMutex.Unlock()
+		}
+	}()
+	for {
+		if lineNumber%1000 == 0 {
+			reportBytes()
+			a.addFolderCounts(dir, rowsTallied, keysTallied)
+			rowsTallied, keysTallied = 0, 0
+			select {
+			case <-ctx.Done():
+				return false
+			default:
+			}
+		}
+		// A file cut short by the memory budget is incomplete, so a resumed
+		// run reads it again.
+		if a.memoryExhausted.Load() {
+			return false
+		}
+		if a.sampleDone() {
+			return true
+		}
+		line, tooLong, err := lines.Next()
+		if errors.Is(err, io.EOF) {
+			return true
+		}
+		if err != nil {
+			slog.Error("Read error in source", "path", src.Path(), "error", err)
+			a.errorCount.Add(1)
+			return false
+		}
+		lineNumber++
+		if tooLong {
+			st.tally.Oversized++
+			attrs := append([]any{"path", src.Path()}, st.lineAttrs(lineNumber)...)
+			slog.Warn("Skipping line longer than the limit", append(attrs, "limit", a.MaxLineSize)...)
+			continue
+		}
+		if len(line) == 0 {
+			continue
+		}
+		a.TotalRows.Add(1)
+		rowsTallied++
+		st.tally.Rows++
+
+		if rawRows {
+			normalised = normaliseRawRow(normalised[:0], line)
+			a.recordRowHash(normalised, src.Path(), lineNumber, st)
+			continue
+		}
+
+		var data report.JSONData
+		if err := json.Unmarshal(line, &data); err != nil {
+			attrs := append([]any{"path", src.Path()}, st.lineAttrs(lineNumber)...)
+			slog.Warn("Could not decode JSON", append(attrs, "error", err)...)
+			st.tally.ParseErrors++
+			continue
+		}
+		if a.DiscoverKeys {
+			a.recordFields(data)
+			continue
+		}
+		if a.Profile && !a.training {
+			a.recordFields(data)
+		}
+		if a.processRow(data, src.Path(), lineNumber, st) {
+			keysTallied++
+			st.tally.Keys++
+		}
+	}
+}
+
+// processRow records the key and row hash of a single decoded record. When local
+// is non-nil the locations are written to that per-file index instead of the
+// shared maps.
+func (a *Analyser) processRow(data report.JSONData, filePath string, lineNumber int, st *scanState) bool {
+	if a.FuzzyField != "" && !a.ValidateOnly && !a.training {
+		if value, ok := data[a.FuzzyField]; ok && value != nil {
+			if st.local != nil {
+				st.local.addFuzzy(fmt.Sprintf("%v", value), report.LocationInfo{FilePath: filePath, LineNumber: lineNumber})
+			} else {
+				a.recordFuzzyValue(fmt.Sprintf("%v", value), filePath, lineNumber)
+			}
+		}
+	}
+
+	_, keyFound := data[a.uniqueKey]
+	keyFound = keyFound && a.checkKey
+	if a.ValidateOnly {
+		return keyFound
+	}
+
+	if keyFound {
+		idStr := fmt.Sprintf("%v", data[a.uniqueKey])
+		loc := report.LocationInfo{FilePath: filePath, LineNumber: lineNumber}
+		if st.local != nil {
+			st.local.ids[idStr] = append(st.local.ids[idStr], loc)
+		} else {
+			a.ids.Add(a.sharedKey(filePath, idStr), loc)
+		}
+	}
+
+	// Additional keys are only reported alongside the key check, so they are
+	// not indexed without it.
+	if a.checkKey && len(a.extraKeys) > 0 {
+		a.processExtraKeys(data, filePath, lineNumber, st)
+	}
+
+	if a.checkRow {
+		compactRow, _ := json.Marshal(data)
+		a.recordRowHash(compactRow, filePath, lineNumber, st)
+	}
+	return keyFound
+}
+
+// recordRowHash hashes the canonical bytes of a row and records its location.
+func (a *Analyser) recordRowHash(row []byte, filePath string, lineNumber int, st *scanState) {
+	st.rowHasher.Reset()
+	_, _ = st.rowHasher.Write(row)
+	hashString := strconv.FormatUint(st.rowHasher.Sum64(), 10)
+	loc := report.LocationInfo{FilePath: filePath, LineNumber: lineNumber}
+	if st.local != nil {
+		st.local.rows[hashString] = append(st.local.rows[hashString], loc)
+	} else {
+		a.rows.Add(a.sharedKey(filePath, hashString), loc)
+	}
+}
+
+// addFolderCounts publishes a batch of per-file row and key tallies to the
+// per-folder counters.
+func (a *Analyser) addFolderCounts(dir string, rows, keys int64) {
+	if rows > 0 {
+		a.rowsProcessedMutex.Lock()
+		a.rowsProcessedPerFolder[dir] += rows
+		a.rowsProcessedMutex.Unlock()
+	}
+	if keys > 0 {
+		a.keysFoundMutex.Lock()
+		a.keysFoundPerFolder[dir] += keys
+		a.keysFoundMutex.Unlock()
+	}
+}
+
+func (a *Analyser) generateReport(sources []source.InputSource, wasCancelled, isValidation bool) *report.AnalysisReport {
+	rep := &report.AnalysisReport{
+		DuplicateIDs:  make(map[string][]report.LocationInfo),
+		DuplicateRows: make(map[string][]report.LocationInfo),
+	}
+	isScoped := a.Scope != ScopeGlobal
+	if isScoped && !isValidation {
+		rep.Scopes = make(map[string]*report.ScopeReport)
+	}
+	scopeFor := func(k string) (*report.ScopeReport, string) {
+		scope, key := splitScopedKey(k)
+		sr, ok := rep.Scopes[scope]
+		if !ok {
+			sr = &report.ScopeReport{
+				DuplicateIDs:  make(map[string][]report.LocationInfo),
+				DuplicateRows: make(map[string][]report.LocationInfo),
+			}
+			rep.Scopes[scope] = sr
+		}
+		return sr, key
+	}
+
+	// Shard runs also list the values seen only once, so that merging shard
+	// reports can find duplicates split across them.
+	shardOutput := a.ShardOutput && !isScoped && !isValidation
+	if shardOutput {
+		rep.SingletonIDs = make(map[string]report.LocationInfo)
+		rep.SingletonRows = make(map[string]report.LocationInfo)
+	}
+
+	totalIDs, uniqueDuplicateIDsCount := 0, 0
+	dupeIDsPerFolder := make(map[string]int)
+	var dupeIDsPerFile, dupeRowsPerFile map[string]int
+	if a.FileBreakdown && !isValidation {
+		dupeIDsPerFile = make(map[string]int)
+		dupeRowsPerFile = make(map[string]int)
+	}
+
+	if a.checkKey && !isValidation {
+		err := a.ids.ForEach(func(id string, locations []report.LocationInfo) {
+			totalIDs += len(locations)
+			if len(locations) > 1 {
+				uniqueDuplicateIDsCount++
+				if isScoped {
+					sr, key := scopeFor(id)
+					sr.DuplicateIDs[key] = locations
+				} else {
+					rep.DuplicateIDs[id] = locations
+				}
+				for _, loc := range locations {
+					dupeIDsPerFolder[filepath.Dir(loc.FilePath)]++
+					if dupeIDsPerFile != nil {
+						dupeIDsPerFile[loc.FilePath]++
+					}
+				}
+			} else if shardOutput {
+				rep.SingletonIDs[id] = locations[0]
+			}
+		})
+		if err != nil {
+			slog.Error("Could not read key index", "error", err)
+		}
+	}
+	var keySummaries map[string]report.KeySummary
+	if a.checkKey && !isValidation && len(a.extraKeys) > 0 {
+		rep.DuplicateIDsByKey = make(map[string]map[string][]report.LocationInfo)
+		keySummaries = a.extraKeyReports(rep, scopeFor)
+	}
+
+	totalDuplicateRowsCount := 0
+	dupeRowsPerFolder := make(map[string]int)
+	if a.checkRow && !isValidation {
+		err := a.rows.ForEach(func(hash string, locations []report.LocationInfo) {
+			if len(locations) > 1 {
+				totalDuplicateRowsCount += len(locations)
+				if isScoped {
+					sr, key := scopeFor(hash)
+					sr.DuplicateRows[key] = locations
+				} else {
+					rep.DuplicateRows[hash] = locations
+				}
+				for _, loc := range locations {
+					dupeRowsPerFolder[filepath.Dir(loc.FilePath)]++
+					if dupeRowsPerFile != nil {
+						dupeRowsPerFile[loc.FilePath]++
+					}
+				}
+			} else if shardOutput {
+				rep.SingletonRows[hash] = locations[0]
+			}
+		})
+		if err != nil {
+			slog.Error("Could not read row index", "error", err)
+		}
+	}
+
+	var fuzzyClusters []report.FuzzyCluster
+	if a.FuzzyField != "" && !isValidation {
+		var err error
+		if fuzzyClusters, err = clusterFuzzyValues(a.fuzzy, isScoped, a.FuzzyThreshold); err != nil {
+			slog.Error("Could not read fuzzy index", "error", err)
+		}
+		rep.FuzzyClusters = fuzzyClusters
+	}
+
+	var parseErrorTotal int64
+	a.parseErrorsMutex.Lock()
+	if len(a.parseErrors) > 0 {
+		rep.ParseErrors = make(map[string]int64, len(a.parseErrors))
+		for path, n := range a.parseErrors {
+			rep.ParseErrors[path] = n
+			parseErrorTotal += n
+		}
+	}
+	a.parseErrorsMutex.Unlock()
+
+	folderDetails := make(map[string]report.FolderDetail)
+	totalOverallBytes := int64(0)
+	totalKeysFound := 0
+
+	a.processedPathsMutex.Lock()
+	defer a.processedPathsMutex.Unlock()
+
+	for _, s := range sources {
+		dir := s.Dir()
+		detail := folderDetails[dir]
+		size := s.Size()
+
+		detail.TotalFiles++
+		detail.TotalSizeBytes += size
+		detail.ParseErrors += rep.ParseErrors[s.Path()]
+		totalOverallBytes += size
+
+		if a.processedPaths[s.Path()] {
+			detail.FilesProcessed++
+			detail.ProcessedSizeBytes += size
+		}
+		detail.KeysFound = int(a.keysFoundPerFolder[dir])
+		detail.RowsProcessed = int(a.rowsProcessedPerFolder[dir])
+		folderDetails[dir] = detail
+	}
+	if a.FileBreakdown {
+		rep.FileDetails = a.fileDetails(sources, dupeIDsPerFile, dupeRowsPerFile)
+	}
+
+	processedCount := a.ProcessedFiles.Load()
+	processedBytes := int64(0)
+	for _, detail := range folderDetails {
+		processedBytes += detail.ProcessedSizeBytes
+		totalKeysFound += detail.KeysFound
+	}
+
+	if isValidation || a.Scope == ScopeFile || a.BloomPrePass {
+		// Unique keys never reach the shared index in file scope or behind a
+		// bloom pre-pass, so the per-folder key counts are the only complete
+		// tally of occurrences.
+		totalIDs = totalKeysFound
+	}
+	if a.checkKey && !isValidation && len(a.primaryConstraints) > 0 {
+		if keySummaries == nil {
+			keySummaries = make(map[string]report.KeySummary, len(a.primaryConstraints))
+		}
+		a.primaryKeySummaries(keySummaries, totalIDs, uniqueDuplicateIDsCount)
+	}
+
+	var oversizedTotal int64
+	a.oversizedMutex.Lock()
+	if len(a.oversizedLines) > 0 {
+		rep.OversizedLines = make(map[string]int64, len(a.oversizedLines))
+		for path, n := range a.oversizedLines {
+			rep.OversizedLines[path] = n
+			oversizedTotal += n
+		}
+	}
+	a.oversizedMutex.Unlock()
+
+	rowCount := a.TotalRows.Load()
+	avgRows := 0.0
+	if processedCount > 0 {
+		avgRows = float64(rowCount) / float64(processedCount)
+	}
+
+	uniqueFolders := make(map[string]bool)
+	for _, s := range sources {
+		uniqueFolders[s.Dir()] = true
+	}
+	avgFilesPerFolder := 0.0
+	if len(uniqueFolders) > 0 {
+		avgFilesPerFolder = float64(len(sources)) / float64(len(uniqueFolders))
+	}
+
+	rep.Summary = report.SummaryReport{
+		IsValidationReport:        isValidation,
+		IsPartialReport:           wasCancelled || a.memoryExhausted.Load(),
+		FilesProcessed:            processedCount,
+		TotalFiles:                len(sources),
+		ProcessedDataSizeBytes:    processedBytes,
+		TotalDataSizeOverallBytes: totalOverallBytes,
+		ProcessedDataSizeHuman:    report.HumanSize(processedBytes),
+		TotalDataSizeOverallHuman: report.HumanSize(totalOverallBytes),
+		TotalRowsProcessed:        rowCount,
+		UniqueKey:                 a.uniqueKey,
+		KeyChecked:                a.checkKey,
+		RowChecked:                a.checkRow,
+		Scope:                     a.Scope,
+		Workers:                   a.numWorkers,
+		WorkersNote:               a.workersNote,
+		MemoryBudgetNotes:         a.budgetNotes,
+		OversizedLinesSkipped:     oversizedTotal,
+		ParseErrors:               parseErrorTotal,
+		CachedFiles:               a.cachedFiles,
+		TotalKeyOccurrences:       totalIDs,
+		UniqueKeysDuplicated:      uniqueDuplicateIDsCount,
+		AdditionalKeys:            keySummaries,
+		DuplicateRowInstances:     totalDuplicateRowsCount,
+		FuzzyField:                a.FuzzyField,
+		FuzzyThreshold:            a.FuzzyThreshold,
+		FuzzyClusterCount:         len(fuzzyClusters),
+		IsKeyDiscoveryReport:      a.DiscoverKeys,
+		IsShardReport:             shardOutput,
+		AverageRowsPerFile:        avgRows,
+		AverageFilesPerFolder:     avgFilesPerFolder,
+		DuplicateIDsPerFolder:     dupeIDsPerFolder,
+		DuplicateRowsPerFolder:    dupeRowsPerFolder,
+		FolderDetails:             folderDetails,
+	}
+	if a.DiscoverKeys {
+		rep.KeyCandidates = a.rankKeyCandidates(rowCount)
+	} else if a.Profile {
+		rep.Profile = a.buildProfile(rowCount, a.ProfileTopN)
+	}
+	rep.BuildMultiplicity()
+	rep.Normalise()
+	return rep
+}
@@ -0,0 +1,125 @@
+// pkg/analyser/bloom.go
+package analyser
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+	"sync/atomic"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+)
+
+// bloomFalsePositiveRate is the target false-positive rate of the pre-pass
+// filters. False positives only cost memory; they never change the results.
+const bloomFalsePositiveRate = 0.01
+
+// bloomFilter is a fixed-size bloom filter safe for concurrent Add/Test.
+type bloomFilter struct {
+	bits []uint64
+	m    uint64
+	k    uint64
+}
+
+func newBloomFilter(expectedItems uint64, falsePositiveRate float64) *bloomFilter {
+	if expectedItems == 0 {
+		expectedItems = 1
+	}
+	m := uint64(math.Ceil(-float64(expectedItems) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Max(1, math.Round(float64(m)/float64(expectedItems)*math.Ln2)))
+	return &bloomFilter{bits: make([]uint64, (m+63)/64), m: m, k: k}
+}
+
+// bloomHashes returns the two base hashes used for double hashing.
+func bloomHashes(key string) (uint64, uint64) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	sum := h.Sum64()
+	return sum, sum>>33 | sum<<31 | 1
+}
+
+// add sets the bits for a key and reports whether they were all already set.
+func (b *bloomFilter) add(key string) bool {
+	h1, h2 := bloomHashes(key)
+	present := true
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % b.m
+		mask := uint64(1) << (bit % 64)
+		if atomic.OrUint64(&b.bits[bit/64], mask)&mask == 0 {
+			present = false
+		}
+	}
+	return present
+}
+
+// test reports whether a key may have been added.
+func (b *bloomFilter) test(key string) bool {
+	h1, h2 := bloomHashes(key)
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % b.m
+		if atomic.LoadUint64(&b.bits[bit/64])&(uint64(1)<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomStripes serialises concurrent adds of the same key so that two workers
+// seeing a value at the same moment cannot both conclude it is new.
+const bloomStripes = 256
+
+// bloomIndex gates a locationIndex behind a two-pass bloom filter. While
+// training, values are only fed through the filters; afterwards, only values
+// that were seen more than once during training reach the wrapped index.
+type bloomIndex struct {
+	inner    locationIndex
+	seen     *bloomFilter
+	repeated *bloomFilter
+	stripes  [bloomStripes]sync.Mutex
+	training atomic.Bool
+}
+
+func newBloomIndex(inner locationIndex, expectedItems uint64) *bloomIndex {
+	idx := &bloomIndex{
+		inner:    inner,
+		seen:     newBloomFilter(expectedItems, bloomFalsePositiveRate),
+		repeated: newBloomFilter(expectedItems, bloomFalsePositiveRate),
+	}
+	idx.training.Store(true)
+	return idx
+}
+
+func (b *bloomIndex) Add(key string, loc report.LocationInfo) {
+	if !b.training.Load() {
+		if b.repeated.test(key) {
+			b.inner.Add(key, loc)
+		}
+		return
+	}
+	h1, _ := bloomHashes(key)
+	stripe := &b.stripes[h1%bloomStripes]
+	stripe.Lock()
+	if b.seen.add(key) {
+		b.repeated.add(key)
+	}
+	stripe.Unlock()
+}
+
+func (b *bloomIndex) ForEach(fn func(key string, locs []report.LocationInfo)) error {
+	return b.inner.ForEach(fn)
+}
+
+func (b *bloomIndex) Close() error { return b.inner.Close() }
+
+// finishTraining switches every bloom-gated index from training to recording.
+func (a *Analyser) finishTraining() {
+	indexes := []locationIndex{a.ids, a.rows}
+	for _, extra := range a.extraKeys {
+		indexes = append(indexes, extra.index)
+	}
+	for _, idx := range indexes {
+		if b, ok := idx.(*bloomIndex); ok {
+			b.training.Store(false)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// pkg/analyser/budget.go
+package analyser
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+)
+
+const (
+	// budgetCheckInterval is how often heap usage is compared to the budget.
+	budgetCheckInterval = 500 * time.Millisecond
+	// budgetSpillRatio is the fraction of the budget at which in-memory
+	// indexes are moved to disk.
+	budgetSpillRatio = 0.8
+	// budgetStopRatio is the fraction of the budget at which, with nothing
+	// left to spill, the analyser stops reading and reports on the rows seen.
+	budgetStopRatio = 0.95
+)
+
+// adaptiveIndex starts as a memory index and can be spilled to a disk index
+// part-way through a run when the memory budget is approached.
+type adaptiveIndex struct {
+	mu      sync.RWMutex
+	current locationIndex
+	spilled bool
+	name    string
+	dir     string
+}
+
+func newAdaptiveIndex(dir, name string, repeats *atomic.Int64) *adaptiveIndex {
+	return &adaptiveIndex{current: newMemoryIndex(repeats), name: name, dir: dir}
+}
+
+func (a *adaptiveIndex) Add(key string, loc report.LocationInfo) {
+	a.mu.RLock()
+	a.current.Add(key, loc)
+	a.mu.RUnlock()
+}
+
+func (a *adaptiveIndex) ForEach(fn func(key string, locs []report.LocationInfo)) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.current.ForEach(fn)
+}
+
+func (a *adaptiveIndex) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.current.Close()
+}
+
+// spill moves every recorded location to a new disk index. Adds block while
+// the move is in progress.
+func (a *adaptiveIndex) spill() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.spilled {
+		return nil
+	}
+	disk, err := newDiskIndex(a.dir, a.name)
+	if err != nil {
+		return err
+	}
+	_ = a.current.ForEach(func(key string, locs []report.LocationInfo) {
+		for _, loc := range locs {
+			disk.Add(key, loc)
+		}
+	})
+	a.current = disk
+	a.spilled = true
+	return nil
+}
+
+// adaptiveIndexes returns the analyser's indexes that can still be spilled.
+func (a *Analyser) adaptiveIndexes() []*adaptiveIndex {
+	indexes := []locationIndex{a.ids, a.rows, a.fuzzy}
+	for _, extra := range a.extraKeys {
+		indexes = append(indexes, extra.index)
+	}
+	var adaptive []*adaptiveIndex
+	for _, idx := range indexes {
+		if b, ok := idx.(*bloomIndex); ok {
+			idx = b.inner
+		}
+		if ad, ok := idx.(*adaptiveIndex); ok {
+			adaptive = append(adaptive, ad)
+		}
+	}
+	return adaptive
+}
+
+// watchMemory enforces MaxMemory until ctx is done. When heap usage nears the
+// budget the in-memory indexes are spilled to disk; if usage still reaches the
+// budget afterwards, reading stops so the run finishes with a partial report
+// rather than being killed by the operating system.
+func (a *Analyser) watchMemory(ctx context.Context) {
+	ticker := time.NewTicker(budgetCheckInterval)
+	defer ticker.Stop()
+	spilled := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		used := int64(stats.HeapAlloc)
+
+		if !spilled && float64(used) >= budgetSpillRatio*float64(a.MaxMemory) {
+			spilled = true
+			spillFailed := false
+			for _, idx := range a.adaptiveIndexes() {
+				if err := idx.spill(); err != nil {
+					slog.Error("Could not spill index to disk", "index", idx.name, "error", err)
+					spillFailed = true
+				}
+			}
+			if !spillFailed && len(a.adaptiveIndexes()) > 0 {
+				a.noteBudget(fmt.Sprintf("indexes spilled to disk at %s heap", report.HumanSize(used)))
+				debug.FreeOSMemory()
+				continue
+			}
+		}
+
+		if float64(used) >= budgetStopRatio*float64(a.MaxMemory) {
+			a.memoryExhausted.Store(true)
+			a.noteBudget(fmt.Sprintf("reading stopped after %d rows at %s heap", a.TotalRows.Load(), report.HumanSize(used)))
+			return
+		}
+	}
+}
+
+func (a *Analyser) noteBudget(note string) {
+	slog.Warn("Memory budget reached", "budget", report.HumanSize(a.MaxMemory), "note", note)
+	a.budgetMutex.Lock()
+	a.budgetNotes = append(a.budgetNotes, note)
+	a.budgetMutex.Unlock()
+}
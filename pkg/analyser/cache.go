@@ -0,0 +1,52 @@
+// pkg/analyser/cache.go
+package analyser
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/source"
+)
+
+// applyCache restores every source whose fingerprint matches the one saved in
+// CachePath, so Run only reads new or modified files. A missing cache, or one
+// written with different analysis settings, is ignored and rebuilt.
+func (a *Analyser) applyCache(sources []source.InputSource) {
+	cache, err := LoadCheckpoint(a.CachePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		slog.Warn("Ignoring fingerprint cache", "error", err)
+		return
+	}
+	if !cache.header.Settings.matches(a.checkpointSettings()) {
+		slog.Warn("Ignoring fingerprint cache built with different analysis settings", "path", a.CachePath)
+		return
+	}
+
+	current := make(map[string]string, len(sources))
+	for _, s := range sources {
+		if !a.isProcessed(s.Path()) {
+			current[s.Path()] = s.Fingerprint()
+		}
+	}
+	n, err := a.restoreFiles(a.CachePath, func(path string, tally fileTally) bool {
+		fingerprint, ok := current[path]
+		return ok && tally.Fingerprint != "" && tally.Fingerprint == fingerprint
+	})
+	if err != nil {
+		slog.Warn("Ignoring fingerprint cache", "error", err)
+		return
+	}
+	a.cachedFiles = n
+}
+
+// saveCache writes the state of every processed file to CachePath for the
+// next run to reuse.
+func (a *Analyser) saveCache() {
+	if err := a.writeCheckpoint(a.CachePath); err != nil {
+		slog.Error("Could not write fingerprint cache", "path", a.CachePath, "error", err)
+	}
+}
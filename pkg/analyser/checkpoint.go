@@ -0,0 +1,356 @@
+// pkg/analyser/checkpoint.go
+package analyser
+
+import (
+	"bufio"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+)
+
+// checkpointVersion is bumped whenever the checkpoint layout changes.
+const checkpointVersion = 1
+
+// Checkpoint describes a saved analysis that can be resumed. Only files that
+// had finished when it was written are included, so a resumed run re-reads
+// every other file from the start.
+type Checkpoint struct {
+	Path      string
+	CreatedAt time.Time
+	// Meta holds caller-defined settings, such as the paths being analysed,
+	// that are needed to restart the run.
+	Meta           json.RawMessage
+	FilesCompleted int
+	header         checkpointHeader
+}
+
+type checkpointHeader struct {
+	Version   int
+	CreatedAt time.Time
+	Meta      []byte
+	Settings  checkpointSettings
+	Files     map[string]fileTally
+}
+
+// checkpointSettings are the analyser options that must match for the saved
+// indexes to be meaningful.
+type checkpointSettings struct {
+	UniqueKey    string
+	CheckKey     bool
+	CheckRow     bool
+	ValidateOnly bool
+	RawRowHash   bool
+	Scope        string
+	MaxLineSize  int
+	ExtraKeys    []string
+	ExtraFields  [][]string
+}
+
+// checkpointEntry is one key of one index. Index 0 holds IDs, 1 holds row
+// hashes and 2 onwards the additional keys in order.
+type checkpointEntry struct {
+	Index     int
+	Key       string
+	Locations []report.LocationInfo
+}
+
+func (a *Analyser) checkpointSettings() checkpointSettings {
+	s := checkpointSettings{
+		UniqueKey:    a.uniqueKey,
+		CheckKey:     a.checkKey,
+		CheckRow:     a.checkRow,
+		ValidateOnly: a.ValidateOnly,
+		RawRowHash:   a.rawRowFastPath(),
+		Scope:        a.Scope,
+		MaxLineSize:  a.MaxLineSize,
+	}
+	for _, extra := range a.extraKeys {
+		s.ExtraKeys = append(s.ExtraKeys, extra.key)
+		s.ExtraFields = append(s.ExtraFields, extra.fields)
+	}
+	return s
+}
+
+// CheckpointSupported reports why the analyser's configuration cannot be
+// checkpointed, or nil if it can. Fuzzy matching, profiling, key discovery
+// and the bloom pre-pass hold state that is not saved.
+func (a *Analyser) CheckpointSupported() error {
+	switch {
+	case a.DiscoverKeys:
+		return errors.New("key discovery cannot be checkpointed")
+	case a.Profile:
+		return errors.New("profiling cannot be checkpointed")
+	case a.FuzzyField != "":
+		return errors.New("fuzzy matching cannot be checkpointed")
+	case a.BloomPrePass:
+		return errors.New("the bloom pre-pass cannot be checkpointed")
+	}
+	return nil
+}
+
+// runCheckpoints writes a checkpoint every CheckpointInterval until ctx is
+// done. The interval is measured from the end of the previous write so that
+// slow writes on large indexes never run back to back.
+func (a *Analyser) runCheckpoints(ctx context.Context) {
+	timer := time.NewTimer(a.CheckpointInterval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			a.saveCheckpoint()
+			timer.Reset(a.CheckpointInterval)
+		}
+	}
+}
+
+// saveCheckpoint writes a checkpoint and logs, rather than returns, any error
+// so that a failed checkpoint never interrupts the analysis itself.
+func (a *Analyser) saveCheckpoint() {
+	if err := a.writeCheckpoint(a.CheckpointPath); err != nil {
+		slog.Error("Could not write checkpoint", "path", a.CheckpointPath, "error", err)
+	}
+}
+
+// writeCheckpoint saves the state of every completed file to path. The file is
+// written alongside and renamed into place so a crash mid-write never
+// corrupts the previous checkpoint.
+func (a *Analyser) writeCheckpoint(path string) error {
+	a.processedPathsMutex.Lock()
+	files := make(map[string]fileTally, len(a.fileTallies))
+	for p, t := range a.fileTallies {
+		files[p] = t
+	}
+	a.processedPathsMutex.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriterSize(tmp, 256*1024)
+	enc := gob.NewEncoder(w)
+	header := checkpointHeader{
+		Version:   checkpointVersion,
+		CreatedAt: time.Now(),
+		Meta:      a.CheckpointMeta,
+		Settings:  a.checkpointSettings(),
+		Files:     files,
+	}
+	if err := enc.Encode(header); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	// Locations from files that were still being read are left out; those
+	// files are read again in full on resume.
+	var encErr error
+	for i, idx := range a.checkpointIndexes() {
+		err := idx.ForEach(func(key string, locs []report.LocationInfo) {
+			if encErr != nil {
+				return
+			}
+			kept := make([]report.LocationInfo, 0, len(locs))
+			for _, loc := range locs {
+				if _, ok := files[loc.FilePath]; ok {
+					kept = append(kept, loc)
+				}
+			}
+			if len(kept) > 0 {
+				encErr = enc.Encode(checkpointEntry{Index: i, Key: key, Locations: kept})
+			}
+		})
+		if err == nil {
+			err = encErr
+		}
+		if err != nil {
+			_ = tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// removeCheckpoint deletes the checkpoint of a finished run, along with any
+// partial writes left behind by a process that was killed mid-checkpoint.
+func (a *Analyser) removeCheckpoint() {
+	stale, _ := filepath.Glob(a.CheckpointPath + ".tmp-*")
+	for _, path := range append(stale, a.CheckpointPath) {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			slog.Warn("Could not remove checkpoint", "path", path, "error", err)
+		}
+	}
+}
+
+func (a *Analyser) checkpointIndexes() []locationIndex {
+	indexes := []locationIndex{a.ids, a.rows}
+	for _, extra := range a.extraKeys {
+		indexes = append(indexes, extra.index)
+	}
+	return indexes
+}
+
+// LoadCheckpoint reads the header of a checkpoint written by a previous run.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open checkpoint: %w", err)
+	}
+	defer f.Close()
+
+	var header checkpointHeader
+	if err := gob.NewDecoder(bufio.NewReader(f)).Decode(&header); err != nil {
+		return nil, fmt.Errorf("could not read checkpoint %s: %w", path, err)
+	}
+	if header.Version != checkpointVersion {
+		return nil, fmt.Errorf("checkpoint %s has unsupported version %d", path, header.Version)
+	}
+	return &Checkpoint{
+		Path:           path,
+		CreatedAt:      header.CreatedAt,
+		Meta:           header.Meta,
+		FilesCompleted: len(header.Files),
+		header:         header,
+	}, nil
+}
+
+// ListCheckpoints returns the checkpoints left in dir by runs that did not
+// finish, newest first. Files that cannot be read as a checkpoint are
+// skipped.
+func ListCheckpoints(dir string) ([]*Checkpoint, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "checkpoint-*.gob"))
+	if err != nil {
+		return nil, err
+	}
+	var checkpoints []*Checkpoint
+	for _, path := range paths {
+		cp, err := LoadCheckpoint(path)
+		if err != nil {
+			slog.Warn("Skipping unreadable checkpoint", "path", path, "error", err)
+			continue
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+	slices.SortFunc(checkpoints, func(a, b *Checkpoint) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return checkpoints, nil
+}
+
+// Restore loads a checkpoint's completed files, counters and index entries
+// into the analyser so that Run only processes the remaining files. The
+// analyser must be configured with the same key, checks, scope and additional
+// keys as the run that wrote the checkpoint, and Restore must be called
+// before Run.
+func (a *Analyser) Restore(cp *Checkpoint) error {
+	if err := a.CheckpointSupported(); err != nil {
+		return err
+	}
+	if !cp.header.Settings.matches(a.checkpointSettings()) {
+		return fmt.Errorf("checkpoint %s was written with different analysis settings", cp.Path)
+	}
+	_, err := a.restoreFiles(cp.Path, func(string, fileTally) bool { return true })
+	return err
+}
+
+// matches reports whether two runs would record identical index entries.
+func (s checkpointSettings) matches(o checkpointSettings) bool {
+	return s.UniqueKey == o.UniqueKey && s.CheckKey == o.CheckKey && s.CheckRow == o.CheckRow &&
+		s.ValidateOnly == o.ValidateOnly && s.RawRowHash == o.RawRowHash && s.Scope == o.Scope &&
+		s.MaxLineSize == o.MaxLineSize && slices.Equal(s.ExtraKeys, o.ExtraKeys) &&
+		slices.EqualFunc(s.ExtraFields, o.ExtraFields, slices.Equal[[]string])
+}
+
+// restoreFiles loads the counters and index entries of every file in a saved
+// state for which keep returns true, marking those files as processed. It
+// returns the number of files restored.
+func (a *Analyser) restoreFiles(path string, keep func(path string, tally fileTally) bool) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("could not open checkpoint: %w", err)
+	}
+	defer f.Close()
+	dec := gob.NewDecoder(bufio.NewReaderSize(f, 256*1024))
+	var header checkpointHeader
+	if err := dec.Decode(&header); err != nil {
+		return 0, fmt.Errorf("could not read checkpoint %s: %w", path, err)
+	}
+	kept := make(map[string]fileTally)
+	for p, tally := range header.Files {
+		if keep(p, tally) {
+			kept[p] = tally
+		}
+	}
+	if len(kept) == 0 {
+		return 0, nil
+	}
+
+	a.initIndexes()
+	indexes := a.checkpointIndexes()
+	for {
+		var entry checkpointEntry
+		err := dec.Decode(&entry)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("could not read checkpoint %s: %w", path, err)
+		}
+		if entry.Index < 0 || entry.Index >= len(indexes) {
+			return 0, fmt.Errorf("checkpoint %s is corrupt: unknown index %d", path, entry.Index)
+		}
+		for _, loc := range entry.Locations {
+			if _, ok := kept[loc.FilePath]; ok {
+				indexes[entry.Index].Add(entry.Key, loc)
+			}
+		}
+	}
+
+	a.processedPathsMutex.Lock()
+	defer a.processedPathsMutex.Unlock()
+	for p, tally := range kept {
+		a.processedPaths[p] = true
+		a.fileTallies[p] = tally
+		a.ProcessedFiles.Add(1)
+		a.TotalRows.Add(tally.Rows)
+		a.ProcessedBytes.Add(tally.Bytes)
+		a.addFolderCounts(filepath.Dir(p), tally.Rows, tally.Keys)
+		a.errorCount.Add(tally.Oversized + tally.ParseErrors)
+		if tally.Oversized > 0 {
+			a.oversizedMutex.Lock()
+			a.oversizedLines[p] += tally.Oversized
+			a.oversizedMutex.Unlock()
+		}
+		if tally.ParseErrors > 0 {
+			a.parseErrorsMutex.Lock()
+			a.parseErrors[p] += tally.ParseErrors
+			a.parseErrorsMutex.Unlock()
+		}
+		for i, n := range tally.Extra {
+			if i < len(a.extraKeys) {
+				a.extraKeys[i].occurrences.Add(n)
+			}
+		}
+	}
+	return len(kept), nil
+}
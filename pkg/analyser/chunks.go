@@ -0,0 +1,87 @@
+// pkg/analyser/chunks.go
+package analyser
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/source"
+)
+
+// DefaultChunkSize is the size above which, at twice this value, a local file
+// is split into chunks that are scanned in parallel.
+const DefaultChunkSize = 256 * 1024 * 1024
+
+// processChunks scans a large file as newline-aligned chunks, each in a
+// goroutine holding a slot of chunkSlots, which every file shares. Each chunk
+// collects into its own index, numbering its lines from 1, as where it starts
+// is only known once the chunks before it have been read. When every chunk has completed, their line numbers are
+// shifted by the lines of the chunks before them and the indexes are merged
+// in file order, so locations stay sorted and a file that fails part way adds
+// nothing to the shared indexes.
+func (a *Analyser) processChunks(ctx context.Context, src source.ChunkedSource, local *fileIndex, tally *fileTally) bool {
+	chunks, err := src.Chunks(a.ChunkSize)
+	if err != nil {
+		slog.Error("Could not split source into chunks", "path", src.Path(), "error", err)
+		a.errorCount.Add(1)
+		return false
+	}
+
+	// With memory mapping, the file is mapped once and each chunk reads its
+	// own slice of the mapping.
+	var mapped []byte
+	if a.MemoryMap {
+		if m, err := a.openMapped(src); err == nil {
+			defer m.Close()
+			mapped = m.Bytes()
+		}
+	}
+
+	states := make([]*scanState, len(chunks))
+	completed := make([]bool, len(chunks))
+	var wg sync.WaitGroup
+	for i := range chunks {
+		wg.Add(1)
+		a.chunkSlots <- struct{}{}
+		go func(i int, chunk *source.Chunk) {
+			defer wg.Done()
+			defer func() { <-a.chunkSlots }()
+			var lines *source.LineReader
+			if mapped != nil {
+				lines = source.NewBytesLineReader(mapped[chunk.Offset:chunk.Offset+chunk.Length], a.MaxLineSize)
+			} else {
+				reader, err := src.OpenChunk(*chunk)
+				if err != nil {
+					slog.Error("Could not open chunk", "path", src.Path(), "offset", chunk.Offset, "error", err)
+					a.errorCount.Add(1)
+					return
+				}
+				defer reader.Close()
+				lines = source.NewLineReader(reader, a.MaxLineSize)
+			}
+			states[i] = a.newScanState(newFileIndex())
+			states[i].chunk = chunk
+			completed[i] = a.scanLines(ctx, src, lines, states[i])
+		}(i, &chunks[i])
+	}
+	wg.Wait()
+
+	for i := range chunks {
+		if !completed[i] {
+			return false
+		}
+	}
+	before := 0
+	for _, st := range states {
+		st.local.shift(before)
+		before += st.lines
+		if local != nil {
+			local.merge(st.local)
+		} else {
+			a.flushChunkIndex(src.Path(), st.local)
+		}
+		tally.add(st.tally)
+	}
+	return true
+}
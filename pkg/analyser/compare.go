@@ -0,0 +1,56 @@
+// pkg/analyser/compare.go
+package analyser
+
+import (
+	"context"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+	"github.com/benjaminwestern/dupe-analyser/pkg/source"
+)
+
+// Compare runs a key-only analysis over two independent sets of sources and
+// reconciles them, reporting which key values appear in both sets, only the
+// left set, or only the right set.
+func Compare(ctx context.Context, uniqueKey string, numWorkers int, left, right []source.InputSource) *report.ComparisonReport {
+	leftEng := New(uniqueKey, numWorkers, true, false, false)
+	leftReport := leftEng.Run(ctx, left)
+	rightEng := New(uniqueKey, numWorkers, true, false, false)
+	rightReport := rightEng.Run(ctx, right)
+
+	defer leftEng.Close()
+	defer rightEng.Close()
+
+	rep := &report.ComparisonReport{
+		InBoth:    make(map[string]report.ComparisonEntry),
+		OnlyLeft:  make(map[string][]report.LocationInfo),
+		OnlyRight: make(map[string][]report.LocationInfo),
+	}
+	_ = leftEng.ids.ForEach(func(key string, locs []report.LocationInfo) {
+		rep.OnlyLeft[key] = locs
+	})
+	rightDistinct := 0
+	_ = rightEng.ids.ForEach(func(key string, rightLocs []report.LocationInfo) {
+		rightDistinct++
+		if leftLocs, ok := rep.OnlyLeft[key]; ok {
+			rep.InBoth[key] = report.ComparisonEntry{Left: leftLocs, Right: rightLocs}
+			delete(rep.OnlyLeft, key)
+		} else {
+			rep.OnlyRight[key] = rightLocs
+		}
+	})
+
+	rep.Summary = report.ComparisonSummary{
+		IsPartialReport:   leftReport.Summary.IsPartialReport || rightReport.Summary.IsPartialReport,
+		UniqueKey:         uniqueKey,
+		LeftFiles:         leftReport.Summary.FilesProcessed,
+		RightFiles:        rightReport.Summary.FilesProcessed,
+		LeftRows:          leftReport.Summary.TotalRowsProcessed,
+		RightRows:         rightReport.Summary.TotalRowsProcessed,
+		LeftDistinctKeys:  len(rep.OnlyLeft) + len(rep.InBoth),
+		RightDistinctKeys: rightDistinct,
+		InBothCount:       len(rep.InBoth),
+		OnlyLeftCount:     len(rep.OnlyLeft),
+		OnlyRightCount:    len(rep.OnlyRight),
+	}
+	return rep
+}
@@ -0,0 +1,133 @@
+// pkg/analyser/discover.go
+package analyser
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+)
+
+// maxProfiledDistinctValues caps how many distinct values are tracked per
+// field so that high-cardinality fields cannot exhaust memory. Beyond the cap
+// distinct counts become lower bounds and top-N values are approximate.
+const maxProfiledDistinctValues = 100000
+
+// fieldStats accumulates the observed values of a single top-level field.
+type fieldStats struct {
+	present int64
+	nulls   int64
+	capped  bool
+	values  map[string]int64
+}
+
+// sampleDone reports whether key discovery has read its whole sample, so the
+// rest of every file can be skipped. A file cut short this way still counts
+// as complete, as the sample is all that was asked for.
+func (a *Analyser) sampleDone() bool {
+	return a.DiscoverKeys && a.SampleRows > 0 && a.TotalRows.Load() >= a.SampleRows
+}
+
+// recordFields tallies every top-level field of a record for key discovery
+// and profiling.
+func (a *Analyser) recordFields(data report.JSONData) {
+	a.fieldMutex.Lock()
+	defer a.fieldMutex.Unlock()
+	for field, value := range data {
+		stats, ok := a.fieldStats[field]
+		if !ok {
+			stats = &fieldStats{values: make(map[string]int64)}
+			a.fieldStats[field] = stats
+		}
+		stats.present++
+		if value == nil {
+			stats.nulls++
+			continue
+		}
+		valueStr := fmt.Sprintf("%v", value)
+		if _, seen := stats.values[valueStr]; seen || len(stats.values) < maxProfiledDistinctValues {
+			stats.values[valueStr]++
+		} else {
+			stats.capped = true
+		}
+	}
+}
+
+// rankKeyCandidates orders fields by how well they would serve as a primary
+// key: fully unique and fully populated fields first, then by uniqueness ratio
+// and coverage of the sampled rows.
+func (a *Analyser) rankKeyCandidates(sampledRows int64) []report.KeyCandidate {
+	a.fieldMutex.Lock()
+	defer a.fieldMutex.Unlock()
+
+	candidates := make([]report.KeyCandidate, 0, len(a.fieldStats))
+	for field, stats := range a.fieldStats {
+		c := report.KeyCandidate{
+			Field:         field,
+			RowsPresent:   stats.present,
+			DistinctCount: int64(len(stats.values)),
+		}
+		if stats.present > 0 {
+			c.UniquenessRatio = float64(c.DistinctCount) / float64(stats.present)
+		}
+		if sampledRows > 0 {
+			c.Coverage = float64(stats.present) / float64(sampledRows)
+		}
+		c.IsCandidate = c.UniquenessRatio == 1 && c.Coverage == 1
+		candidates = append(candidates, c)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		ci, cj := candidates[i], candidates[j]
+		if ci.IsCandidate != cj.IsCandidate {
+			return ci.IsCandidate
+		}
+		if ci.UniquenessRatio != cj.UniquenessRatio {
+			return ci.UniquenessRatio > cj.UniquenessRatio
+		}
+		if ci.Coverage != cj.Coverage {
+			return ci.Coverage > cj.Coverage
+		}
+		return ci.Field < cj.Field
+	})
+	return candidates
+}
+
+// buildProfile summarises the recorded field statistics into per-field
+// profiles, sorted by field name, keeping the topN most frequent values.
+func (a *Analyser) buildProfile(totalRows int64, topN int) []report.FieldProfile {
+	a.fieldMutex.Lock()
+	defer a.fieldMutex.Unlock()
+
+	profiles := make([]report.FieldProfile, 0, len(a.fieldStats))
+	for field, stats := range a.fieldStats {
+		p := report.FieldProfile{
+			Field:          field,
+			RowsPresent:    stats.present,
+			NullCount:      stats.nulls,
+			DistinctCount:  int64(len(stats.values)),
+			DistinctCapped: stats.capped,
+		}
+		if totalRows > 0 {
+			missing := totalRows - stats.present
+			p.NullRate = float64(stats.nulls+missing) / float64(totalRows)
+		}
+
+		values := make([]report.ValueCount, 0, len(stats.values))
+		for v, c := range stats.values {
+			values = append(values, report.ValueCount{Value: v, Count: c})
+		}
+		sort.Slice(values, func(i, j int) bool {
+			if values[i].Count != values[j].Count {
+				return values[i].Count > values[j].Count
+			}
+			return values[i].Value < values[j].Value
+		})
+		if len(values) > topN {
+			values = values[:topN]
+		}
+		p.TopValues = values
+		profiles = append(profiles, p)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Field < profiles[j].Field })
+	return profiles
+}
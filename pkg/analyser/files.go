@@ -0,0 +1,30 @@
+// pkg/analyser/files.go
+package analyser
+
+import (
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+	"github.com/benjaminwestern/dupe-analyser/pkg/source"
+)
+
+// fileDetails builds the per-file breakdown from the tallies of the files
+// read, listing unread files with only their size. The caller must hold
+// processedPathsMutex.
+func (a *Analyser) fileDetails(sources []source.InputSource, dupeIDs, dupeRows map[string]int) map[string]report.FileDetail {
+	details := make(map[string]report.FileDetail, len(sources))
+	for _, s := range sources {
+		path := s.Path()
+		detail := report.FileDetail{SizeBytes: s.Size()}
+		if tally, ok := a.fileTallies[path]; ok {
+			detail.Processed = true
+			detail.RowsProcessed = tally.Rows
+			detail.KeysFound = tally.Keys
+			detail.ParseErrors = tally.ParseErrors
+			detail.OversizedLines = tally.Oversized
+			detail.ProcessingMillis = tally.Duration.Milliseconds()
+			detail.DuplicateIDs = dupeIDs[path]
+			detail.DuplicateRows = dupeRows[path]
+		}
+		details[path] = detail
+	}
+	return details
+}
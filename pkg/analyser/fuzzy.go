@@ -0,0 +1,240 @@
+// pkg/analyser/fuzzy.go
+package analyser
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+)
+
+// normaliseFuzzyValue lowercases a value and strips punctuation and repeated
+// whitespace so that cosmetic differences do not count against similarity.
+func normaliseFuzzyValue(s string) string {
+	var b strings.Builder
+	lastWasSpace := true
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+			lastWasSpace = false
+		case unicode.IsSpace(r) && !lastWasSpace:
+			b.WriteRune(' ')
+			lastWasSpace = true
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// jaroWinkler returns the Jaro-Winkler similarity of two strings in the range [0, 1].
+func jaroWinkler(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+
+	matchDistance := max(len(ra), len(rb))/2 - 1
+	if matchDistance < 0 {
+		matchDistance = 0
+	}
+	aMatches := make([]bool, len(ra))
+	bMatches := make([]bool, len(rb))
+
+	matches := 0
+	for i := range ra {
+		start := max(0, i-matchDistance)
+		end := min(len(rb), i+matchDistance+1)
+		for j := start; j < end; j++ {
+			if bMatches[j] || ra[i] != rb[j] {
+				continue
+			}
+			aMatches[i], bMatches[j] = true, true
+			matches++
+			break
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions, k := 0, 0
+	for i := range ra {
+		if !aMatches[i] {
+			continue
+		}
+		for !bMatches[k] {
+			k++
+		}
+		if ra[i] != rb[k] {
+			transpositions++
+		}
+		k++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(ra)) + m/float64(len(rb)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for i := 0; i < min(4, len(ra), len(rb)) && ra[i] == rb[i]; i++ {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+// fuzzyWindow is the number of neighbours each normalised value is compared
+// with in each sorted order.
+const fuzzyWindow = 20
+
+// recordFuzzyValue records the location of a fuzzy field value in the fuzzy
+// index, under the record's file or folder in those scopes so that values
+// are only clustered with others in the same scope.
+func (a *Analyser) recordFuzzyValue(value, filePath string, lineNumber int) {
+	key := value
+	switch a.Scope {
+	case ScopeFile:
+		key = scopedKey(filePath, value)
+	case ScopeFolder:
+		key = scopedKey(filepath.Dir(filePath), value)
+	}
+	a.fuzzy.Add(key, report.LocationInfo{FilePath: filePath, LineNumber: lineNumber})
+}
+
+// clusterFuzzyValues groups the raw field values of the fuzzy index whose
+// normalised forms are at or above the similarity threshold, within each
+// scope. Only the distinct values are held in memory while clustering; the
+// locations stay in the index and are read back for the values that cluster.
+//
+// Rather than comparing every pair, values are blocked by sorted
+// neighbourhood: each value is compared with the fuzzyWindow values either
+// side of it in sorted order and again in the order of the reversed values,
+// so near matches differing at either end are still found in linear time. Two
+// values far apart in both orders are missed. Only clusters containing more
+// than one distinct raw value are returned, as exact repeats are already
+// covered by the key check.
+func clusterFuzzyValues(idx locationIndex, scoped bool, threshold float64) ([]report.FuzzyCluster, error) {
+	// byScope maps each scope to its normalised values and their raw forms.
+	byScope := make(map[string]map[string][]string)
+	err := idx.ForEach(func(key string, _ []report.LocationInfo) {
+		scope, raw := "", key
+		if scoped {
+			scope, raw = splitScopedKey(key)
+		}
+		norm := normaliseFuzzyValue(raw)
+		if norm == "" {
+			return
+		}
+		if byScope[scope] == nil {
+			byScope[scope] = make(map[string][]string)
+		}
+		byScope[scope][norm] = append(byScope[scope][norm], raw)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// clustered maps the index key of each value in a cluster to the cluster.
+	clustered := make(map[string]int)
+	var clusters []report.FuzzyCluster
+	for scope, byNormalised := range byScope {
+		for _, raws := range groupSimilar(byNormalised, threshold) {
+			if len(raws) < 2 {
+				continue
+			}
+			sort.Strings(raws)
+			for _, raw := range raws {
+				key := raw
+				if scoped {
+					key = scopedKey(scope, raw)
+				}
+				clustered[key] = len(clusters)
+			}
+			clusters = append(clusters, report.FuzzyCluster{Scope: scope, Values: raws})
+		}
+	}
+	if len(clusters) == 0 {
+		return nil, nil
+	}
+	err = idx.ForEach(func(key string, locs []report.LocationInfo) {
+		if i, ok := clustered[key]; ok {
+			clusters[i].Locations = append(clusters[i].Locations, locs...)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if clusters[i].Scope != clusters[j].Scope {
+			return clusters[i].Scope < clusters[j].Scope
+		}
+		return clusters[i].Values[0] < clusters[j].Values[0]
+	})
+	return clusters, nil
+}
+
+// groupSimilar unions the normalised values compared by the sorted
+// neighbourhood blocking and returns the raw values of each group.
+func groupSimilar(byNormalised map[string][]string, threshold float64) [][]string {
+	parent := make(map[string]string, len(byNormalised))
+	var find func(string) string
+	find = func(s string) string {
+		if p, ok := parent[s]; ok && p != s {
+			root := find(p)
+			parent[s] = root
+			return root
+		}
+		return s
+	}
+	union := func(a, b string) {
+		ra, rb := find(a), find(b)
+		if ra != rb {
+			parent[rb] = ra
+		}
+	}
+
+	values := make([]string, 0, len(byNormalised))
+	for norm := range byNormalised {
+		values = append(values, norm)
+	}
+	compareWindow := func(order []string) {
+		for i := range order {
+			for j := i + 1; j < len(order) && j <= i+fuzzyWindow; j++ {
+				if find(order[i]) != find(order[j]) && jaroWinkler(order[i], order[j]) >= threshold {
+					union(order[i], order[j])
+				}
+			}
+		}
+	}
+	sort.Strings(values)
+	compareWindow(values)
+	reversed := make(map[string]string, len(values))
+	for _, v := range values {
+		reversed[v] = reverseString(v)
+	}
+	sort.Slice(values, func(i, j int) bool { return reversed[values[i]] < reversed[values[j]] })
+	compareWindow(values)
+
+	grouped := make(map[string][]string)
+	for norm, raws := range byNormalised {
+		root := find(norm)
+		grouped[root] = append(grouped[root], raws...)
+	}
+	groups := make([][]string, 0, len(grouped))
+	for _, raws := range grouped {
+		groups = append(groups, raws)
+	}
+	return groups
+}
+
+// reverseString returns s with its runes in reverse order.
+func reverseString(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
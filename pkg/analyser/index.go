@@ -0,0 +1,190 @@
+// pkg/analyser/index.go
+package analyser
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+)
+
+// Index modes select where key and row locations are held during a run.
+const (
+	// IndexMemory keeps every location in an in-memory map. It is the fastest
+	// option but memory grows with the number of rows analysed.
+	IndexMemory = "memory"
+	// IndexDisk spills locations to partitioned temporary files and only loads
+	// one partition at a time when the report is generated.
+	IndexDisk = "disk"
+	// IndexSort writes locations to sorted spill runs and merge-sorts them at
+	// report time. Memory stays bounded regardless of dataset size, making it
+	// the choice for audit runs that must be exact.
+	IndexSort = "sort"
+)
+
+// ValidIndexMode reports whether s is a recognised index mode.
+func ValidIndexMode(s string) bool {
+	return s == IndexMemory || s == IndexDisk || s == IndexSort
+}
+
+// locationIndex records every location seen for a key (an ID, constraint value
+// or row hash) and later yields the full location list of each key.
+type locationIndex interface {
+	// Add records a location for key. It is safe for concurrent use.
+	Add(key string, loc report.LocationInfo)
+	// ForEach calls fn once per key with all of its locations. When called
+	// concurrently with Add, as checkpoints do, locations added during the
+	// call may or may not be seen. fn must not call Add.
+	ForEach(fn func(key string, locs []report.LocationInfo)) error
+	// Close releases any resources, such as temporary files, held by the index.
+	Close() error
+}
+
+// memoryShards is the number of independently locked maps in a memory index.
+// Workers adding different keys rarely contend on the same shard, so adds
+// scale with the worker count instead of serialising on one mutex.
+const memoryShards = 64
+
+// memoryIndex is a locationIndex backed by hash-sharded, mutex-guarded maps.
+// When repeats is set, it counts every location added to a key that already
+// had one.
+type memoryIndex struct {
+	shards  [memoryShards]memoryShard
+	repeats *atomic.Int64
+}
+
+type memoryShard struct {
+	mu        sync.Mutex
+	locations map[string][]report.LocationInfo
+}
+
+func newMemoryIndex(repeats *atomic.Int64) *memoryIndex {
+	m := &memoryIndex{repeats: repeats}
+	for i := range m.shards {
+		m.shards[i].locations = make(map[string][]report.LocationInfo)
+	}
+	return m
+}
+
+// indexShard maps a key to one of n shards or partitions.
+func indexShard(key string, n uint32) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum32() % n
+}
+
+func (m *memoryIndex) Add(key string, loc report.LocationInfo) {
+	shard := &m.shards[indexShard(key, memoryShards)]
+	shard.mu.Lock()
+	locs := shard.locations[key]
+	shard.locations[key] = append(locs, loc)
+	shard.mu.Unlock()
+	if len(locs) > 0 && m.repeats != nil {
+		m.repeats.Add(1)
+	}
+}
+
+// ForEach copies one shard at a time before calling fn, so that a slow fn,
+// such as a checkpoint writer, never holds up concurrent adds.
+func (m *memoryIndex) ForEach(fn func(key string, locs []report.LocationInfo)) error {
+	type entry struct {
+		key  string
+		locs []report.LocationInfo
+	}
+	for i := range m.shards {
+		shard := &m.shards[i]
+		shard.mu.Lock()
+		entries := make([]entry, 0, len(shard.locations))
+		for key, locs := range shard.locations {
+			entries = append(entries, entry{key, locs})
+		}
+		shard.mu.Unlock()
+		for _, e := range entries {
+			fn(e.key, e.locs)
+		}
+	}
+	return nil
+}
+
+func (m *memoryIndex) Close() error { return nil }
+
+// newIndex creates a locationIndex for the analyser's index mode, behind a
+// bloom filter when the pre-pass is enabled.
+func (a *Analyser) newIndex(name string) locationIndex {
+	idx := a.newUnfilteredIndex(name)
+	if a.BloomPrePass {
+		return newBloomIndex(idx, a.BloomExpectedItems)
+	}
+	return idx
+}
+
+// newUnfilteredIndex creates a locationIndex for the analyser's index mode.
+// If an on-disk index cannot be created the analyser falls back to memory so
+// the run can still complete.
+func (a *Analyser) newUnfilteredIndex(name string) locationIndex {
+	var idx locationIndex = newMemoryIndex(a.RepeatedRecords)
+	switch a.IndexMode {
+	case IndexMemory:
+		if a.MaxMemory > 0 {
+			idx = newAdaptiveIndex(a.IndexDir, name, a.RepeatedRecords)
+		}
+	case IndexDisk:
+		disk, err := newDiskIndex(a.IndexDir, name)
+		if err == nil {
+			idx = disk
+		} else {
+			slog.Warn("Could not create disk index, falling back to memory", "index", name, "error", err)
+		}
+	case IndexSort:
+		sorted, err := newSortIndex(a.IndexDir, name)
+		if err == nil {
+			idx = sorted
+		} else {
+			slog.Warn("Could not create sort index, falling back to memory", "index", name, "error", err)
+		}
+	}
+	return idx
+}
+
+// initIndexes lazily creates the indexes so that IndexMode can be configured
+// after New and before the first Run.
+func (a *Analyser) initIndexes() {
+	if a.ids == nil {
+		a.ids = a.newIndex("ids")
+	}
+	if a.rows == nil {
+		a.rows = a.newIndex("rows")
+	}
+	for _, extra := range a.extraKeys {
+		if extra.index == nil {
+			extra.index = a.newIndex("key-" + extra.key)
+		}
+	}
+	// Every fuzzy value is needed to find similar ones, so the fuzzy index is
+	// never behind a bloom filter, and values are not recorded while it trains.
+	if a.FuzzyField != "" && a.fuzzy == nil {
+		a.fuzzy = a.newUnfilteredIndex("fuzzy")
+	}
+}
+
+// Close releases the resources held by the analyser's indexes, such as the
+// temporary files of a disk index. The analyser must not be used afterwards.
+func (a *Analyser) Close() error {
+	var firstErr error
+	indexes := []locationIndex{a.ids, a.rows, a.fuzzy}
+	for _, extra := range a.extraKeys {
+		indexes = append(indexes, extra.index)
+	}
+	for _, idx := range indexes {
+		if idx == nil {
+			continue
+		}
+		if err := idx.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("could not close index: %w", err)
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,204 @@
+// pkg/analyser/index_disk.go
+package analyser
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+)
+
+// diskPartitions is the number of spill files per disk index. Only one
+// partition is loaded into memory at a time, so peak memory at report time is
+// roughly the index size divided by this number.
+const diskPartitions = 64
+
+// diskIndex is a locationIndex that appends (key, file, line) records to
+// hash-partitioned temporary files. File paths are interned so each record
+// only stores a small numeric file ID.
+type diskIndex struct {
+	dir        string
+	partitions [diskPartitions]diskPartition
+	paths      pathTable
+
+	errMu sync.Mutex
+	err   error
+}
+
+type diskPartition struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+}
+
+func newDiskIndex(baseDir, name string) (*diskIndex, error) {
+	dir, err := os.MkdirTemp(baseDir, "dupe-analyser-"+sanitiseIndexName(name)+"-")
+	if err != nil {
+		return nil, fmt.Errorf("could not create index directory: %w", err)
+	}
+	idx := &diskIndex{dir: dir}
+	for i := range idx.partitions {
+		f, err := os.OpenFile(filepath.Join(dir, fmt.Sprintf("part-%02d", i)), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+		if err != nil {
+			_ = idx.Close()
+			return nil, fmt.Errorf("could not create index partition: %w", err)
+		}
+		idx.partitions[i].file = f
+		idx.partitions[i].writer = bufio.NewWriterSize(f, 64*1024)
+	}
+	return idx, nil
+}
+
+// sanitiseIndexName keeps index names safe for use in a directory name.
+func sanitiseIndexName(name string) string {
+	out := []rune(name)
+	for i, r := range out {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			out[i] = '_'
+		}
+	}
+	return string(out)
+}
+
+// pathTable interns file paths so spilled records only carry a numeric ID.
+type pathTable struct {
+	mu    sync.Mutex
+	ids   map[string]uint64
+	paths []string
+}
+
+func (t *pathTable) id(path string) uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if id, ok := t.ids[path]; ok {
+		return id
+	}
+	if t.ids == nil {
+		t.ids = make(map[string]uint64)
+	}
+	id := uint64(len(t.paths))
+	t.ids[path] = id
+	t.paths = append(t.paths, path)
+	return id
+}
+
+func (t *pathTable) location(fileID, line uint64) report.LocationInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return report.LocationInfo{FilePath: t.paths[fileID], LineNumber: int(line)}
+}
+
+// appendIndexRecord encodes one (key, file, line) record for a spill file.
+func appendIndexRecord(buf []byte, key string, fileID, line uint64) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(key)))
+	buf = append(buf, key...)
+	buf = binary.AppendUvarint(buf, fileID)
+	return binary.AppendUvarint(buf, line)
+}
+
+// readIndexRecord decodes one record written by appendIndexRecord. It returns
+// io.EOF only when the reader is exhausted on a record boundary.
+func readIndexRecord(br *bufio.Reader) (string, uint64, uint64, error) {
+	keyLen, err := binary.ReadUvarint(br)
+	if errors.Is(err, io.EOF) {
+		return "", 0, 0, io.EOF
+	}
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("corrupt index record: %w", err)
+	}
+	keyBytes := make([]byte, keyLen)
+	if _, err := io.ReadFull(br, keyBytes); err != nil {
+		return "", 0, 0, fmt.Errorf("corrupt index record: %w", err)
+	}
+	fileID, err := binary.ReadUvarint(br)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("corrupt index record: %w", err)
+	}
+	line, err := binary.ReadUvarint(br)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("corrupt index record: %w", err)
+	}
+	return string(keyBytes), fileID, line, nil
+}
+
+func (d *diskIndex) setErr(err error) {
+	d.errMu.Lock()
+	if d.err == nil {
+		d.err = err
+	}
+	d.errMu.Unlock()
+}
+
+func (d *diskIndex) Add(key string, loc report.LocationInfo) {
+	p := &d.partitions[indexShard(key, diskPartitions)]
+
+	buf := make([]byte, 0, len(key)+3*binary.MaxVarintLen64)
+	buf = appendIndexRecord(buf, key, d.paths.id(loc.FilePath), uint64(loc.LineNumber))
+
+	p.mu.Lock()
+	_, err := p.writer.Write(buf)
+	p.mu.Unlock()
+	if err != nil {
+		d.setErr(fmt.Errorf("could not write to disk index: %w", err))
+	}
+}
+
+func (d *diskIndex) ForEach(fn func(key string, locs []report.LocationInfo)) error {
+	for i := range d.partitions {
+		p := &d.partitions[i]
+		p.mu.Lock()
+		err := p.writer.Flush()
+		var info os.FileInfo
+		if err == nil {
+			info, err = p.file.Stat()
+		}
+		p.mu.Unlock()
+		if err != nil {
+			return fmt.Errorf("could not flush disk index: %w", err)
+		}
+		locations, err := d.readPartition(io.NewSectionReader(p.file, 0, info.Size()))
+		if err != nil {
+			return err
+		}
+		for key, locs := range locations {
+			fn(key, locs)
+		}
+	}
+	d.errMu.Lock()
+	defer d.errMu.Unlock()
+	return d.err
+}
+
+// readPartition decodes every record of one partition into a map.
+func (d *diskIndex) readPartition(r io.Reader) (map[string][]report.LocationInfo, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	locations := make(map[string][]report.LocationInfo)
+	for {
+		key, fileID, line, err := readIndexRecord(br)
+		if errors.Is(err, io.EOF) {
+			return locations, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		locations[key] = append(locations[key], d.paths.location(fileID, line))
+	}
+}
+
+func (d *diskIndex) Close() error {
+	for i := range d.partitions {
+		if f := d.partitions[i].file; f != nil {
+			_ = f.Close()
+		}
+	}
+	if err := os.RemoveAll(d.dir); err != nil {
+		return fmt.Errorf("could not remove disk index %s: %w", d.dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+// pkg/analyser/index_sort.go
+package analyser
+
+import (
+	"bufio"
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+)
+
+// sortSpillBytes is the approximate amount of buffered records a sort index
+// holds before sorting them and spilling a run to disk. It bounds the memory
+// used while adding, independent of the dataset size.
+const sortSpillBytes = 32 * 1024 * 1024
+
+// sortIndex is a locationIndex that buffers records, spills them to disk as
+// sorted runs and merge-sorts the runs when the report is generated. Only the
+// head record of each run and the locations of the current key are held in
+// memory during the merge, so results are exact at any dataset size.
+type sortIndex struct {
+	dir   string
+	paths pathTable
+
+	mu       sync.Mutex
+	buffer   []sortRecord
+	bufBytes int
+	runs     []string
+
+	// Runs are sorted and written outside mu, so adds carry on into a fresh
+	// buffer while a full one is spilled.
+	spills sync.WaitGroup
+	errMu  sync.Mutex
+	err    error
+}
+
+type sortRecord struct {
+	key    string
+	fileID uint64
+	line   uint64
+}
+
+func (r sortRecord) less(o sortRecord) bool {
+	if r.key != o.key {
+		return r.key < o.key
+	}
+	if r.fileID != o.fileID {
+		return r.fileID < o.fileID
+	}
+	return r.line < o.line
+}
+
+func newSortIndex(baseDir, name string) (*sortIndex, error) {
+	dir, err := os.MkdirTemp(baseDir, "dupe-analyser-"+sanitiseIndexName(name)+"-")
+	if err != nil {
+		return nil, fmt.Errorf("could not create index directory: %w", err)
+	}
+	return &sortIndex{dir: dir}, nil
+}
+
+func (s *sortIndex) Add(key string, loc report.LocationInfo) {
+	rec := sortRecord{key: key, fileID: s.paths.id(loc.FilePath), line: uint64(loc.LineNumber)}
+	s.mu.Lock()
+	s.buffer = append(s.buffer, rec)
+	s.bufBytes += len(key) + 40
+	if s.bufBytes < sortSpillBytes {
+		s.mu.Unlock()
+		return
+	}
+	path, records := s.takeLocked()
+	s.mu.Unlock()
+	s.spill(path, records)
+}
+
+// takeLocked hands the buffered records to a new run, returning the run's
+// path and the records for spill to write. The caller must hold s.mu.
+func (s *sortIndex) takeLocked() (string, []sortRecord) {
+	path := filepath.Join(s.dir, fmt.Sprintf("run-%05d", len(s.runs)))
+	s.runs = append(s.runs, path)
+	records := s.buffer
+	s.buffer = make([]sortRecord, 0, cap(records))
+	s.bufBytes = 0
+	s.spills.Add(1)
+	return path, records
+}
+
+// spill sorts records and writes them as the run at path, keeping the first
+// error for ForEach to return.
+func (s *sortIndex) spill(path string, records []sortRecord) {
+	defer s.spills.Done()
+	sort.Slice(records, func(i, j int) bool { return records[i].less(records[j]) })
+	if err := writeSortRun(path, records); err != nil {
+		s.errMu.Lock()
+		if s.err == nil {
+			s.err = err
+		}
+		s.errMu.Unlock()
+	}
+}
+
+func writeSortRun(path string, records []sortRecord) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create sort run: %w", err)
+	}
+	w := bufio.NewWriterSize(f, 64*1024)
+	var buf []byte
+	for _, rec := range records {
+		buf = appendIndexRecord(buf[:0], rec.key, rec.fileID, rec.line)
+		if _, err := w.Write(buf); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("could not write sort run: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("could not write sort run: %w", err)
+	}
+	return f.Close()
+}
+
+// sortRunReader is one input of the k-way merge.
+type sortRunReader struct {
+	file   *os.File
+	reader *bufio.Reader
+	head   sortRecord
+}
+
+func (r *sortRunReader) next() (bool, error) {
+	key, fileID, line, err := readIndexRecord(r.reader)
+	if errors.Is(err, io.EOF) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	r.head = sortRecord{key: key, fileID: fileID, line: line}
+	return true, nil
+}
+
+type sortRunHeap []*sortRunReader
+
+func (h sortRunHeap) Len() int           { return len(h) }
+func (h sortRunHeap) Less(i, j int) bool { return h[i].head.less(h[j].head) }
+func (h sortRunHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *sortRunHeap) Push(x any)        { *h = append(*h, x.(*sortRunReader)) }
+func (h *sortRunHeap) Pop() any {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
+
+func (s *sortIndex) ForEach(fn func(key string, locs []report.LocationInfo)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.buffer) > 0 {
+		s.spill(s.takeLocked())
+	}
+	s.spills.Wait()
+	s.errMu.Lock()
+	err := s.err
+	s.errMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	var readers sortRunHeap
+	defer func() {
+		for _, r := range readers {
+			_ = r.file.Close()
+		}
+	}()
+	for _, path := range s.runs {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("could not open sort run: %w", err)
+		}
+		r := &sortRunReader{file: f, reader: bufio.NewReaderSize(f, 64*1024)}
+		ok, err := r.next()
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+		if !ok {
+			_ = f.Close()
+			continue
+		}
+		readers = append(readers, r)
+	}
+	heap.Init(&readers)
+
+	var currentKey string
+	var locs []report.LocationInfo
+	for readers.Len() > 0 {
+		r := readers[0]
+		if len(locs) > 0 && r.head.key != currentKey {
+			fn(currentKey, locs)
+			locs = nil
+		}
+		currentKey = r.head.key
+		locs = append(locs, s.paths.location(r.head.fileID, r.head.line))
+
+		ok, err := r.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(&readers, 0)
+		} else {
+			_ = r.file.Close()
+			heap.Pop(&readers)
+		}
+	}
+	if len(locs) > 0 {
+		fn(currentKey, locs)
+	}
+	return nil
+}
+
+func (s *sortIndex) Close() error {
+	if err := os.RemoveAll(s.dir); err != nil {
+		return fmt.Errorf("could not remove sort index %s: %w", s.dir, err)
+	}
+	return nil
+}
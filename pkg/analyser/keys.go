@@ -0,0 +1,154 @@
+// pkg/analyser/keys.go
+package analyser
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync/atomic"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+)
+
+// keyIndex tracks the locations of every value of one additional key or
+// composite constraint, so that several uniqueness rules can be checked in a
+// single pass over the data.
+type keyIndex struct {
+	key         string
+	fields      []string
+	index       locationIndex
+	occurrences atomic.Int64
+}
+
+// SetAdditionalKeys registers extra single-field keys to check for duplicates alongside the
+// primary unique key. It must be called before Run.
+func (a *Analyser) SetAdditionalKeys(keys []string) {
+	for _, k := range keys {
+		if k == "" || k == a.uniqueKey {
+			continue
+		}
+		a.AddConstraint(k, []string{k})
+	}
+}
+
+// AddConstraint registers a named uniqueness constraint over one or more
+// fields. A record only participates when every field is present, and its
+// value is the JSON array of the field values. A constraint on the primary
+// key alone is not indexed again; its summary repeats the primary key's
+// results under its name. It must be called before Run.
+func (a *Analyser) AddConstraint(name string, fields []string) {
+	if len(fields) == 1 && fields[0] == a.uniqueKey {
+		if !slices.Contains(a.primaryConstraints, name) {
+			a.primaryConstraints = append(a.primaryConstraints, name)
+		}
+		return
+	}
+	for _, idx := range a.extraKeys {
+		if idx.key == name {
+			return
+		}
+	}
+	a.extraKeys = append(a.extraKeys, &keyIndex{key: name, fields: fields})
+}
+
+// value returns the constraint value of a record and whether every field of
+// the constraint is present.
+func (idx *keyIndex) value(data report.JSONData) (string, bool) {
+	if len(idx.fields) == 1 {
+		v, ok := data[idx.fields[0]]
+		if !ok {
+			return "", false
+		}
+		return fmt.Sprintf("%v", v), true
+	}
+	values := make([]interface{}, len(idx.fields))
+	for i, f := range idx.fields {
+		v, ok := data[f]
+		if !ok {
+			return "", false
+		}
+		values[i] = v
+	}
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return "", false
+	}
+	return string(encoded), true
+}
+
+// processExtraKeys records the values of every additional key in a record.
+func (a *Analyser) processExtraKeys(data report.JSONData, filePath string, lineNumber int, st *scanState) {
+	for i, idx := range a.extraKeys {
+		valueStr, ok := idx.value(data)
+		if !ok {
+			continue
+		}
+		idx.occurrences.Add(1)
+		st.tally.Extra[i]++
+		loc := report.LocationInfo{FilePath: filePath, LineNumber: lineNumber}
+		if st.local != nil {
+			st.local.addExtra(idx.key, valueStr, loc)
+			continue
+		}
+		idx.index.Add(a.sharedKey(filePath, valueStr), loc)
+	}
+}
+
+// primaryKeySummaries adds the summary of every constraint on the primary key
+// alone to summaries, from the primary key's totals.
+func (a *Analyser) primaryKeySummaries(summaries map[string]report.KeySummary, occurrences, duplicated int) {
+	for _, name := range a.primaryConstraints {
+		summaries[name] = report.KeySummary{
+			Fields:               []string{a.uniqueKey},
+			TotalKeyOccurrences:  occurrences,
+			UniqueKeysDuplicated: duplicated,
+			PrimaryKey:           true,
+		}
+	}
+}
+
+// extraKeyReports builds the duplicate maps and summaries for additional keys,
+// distributing scoped sets into the report's scopes.
+func (a *Analyser) extraKeyReports(rep *report.AnalysisReport, scopeFor func(string) (*report.ScopeReport, string)) map[string]report.KeySummary {
+	summaries := make(map[string]report.KeySummary, len(a.extraKeys))
+	for _, idx := range a.extraKeys {
+		summary := report.KeySummary{Fields: idx.fields, TotalKeyOccurrences: int(idx.occurrences.Load())}
+		dupes := make(map[string][]report.LocationInfo)
+		err := idx.index.ForEach(func(value string, locations []report.LocationInfo) {
+			if len(locations) < 2 {
+				if rep.SingletonIDs != nil {
+					if rep.SingletonIDsByKey == nil {
+						rep.SingletonIDsByKey = make(map[string]map[string]report.LocationInfo)
+					}
+					if rep.SingletonIDsByKey[idx.key] == nil {
+						rep.SingletonIDsByKey[idx.key] = make(map[string]report.LocationInfo)
+					}
+					rep.SingletonIDsByKey[idx.key][value] = locations[0]
+				}
+				return
+			}
+			summary.UniqueKeysDuplicated++
+			if rep.Scopes == nil {
+				dupes[value] = locations
+				return
+			}
+			sr, key := scopeFor(value)
+			if sr.DuplicateIDsByKey == nil {
+				sr.DuplicateIDsByKey = make(map[string]map[string][]report.LocationInfo)
+			}
+			if sr.DuplicateIDsByKey[idx.key] == nil {
+				sr.DuplicateIDsByKey[idx.key] = make(map[string][]report.LocationInfo)
+			}
+			sr.DuplicateIDsByKey[idx.key][key] = locations
+		})
+		if err != nil {
+			slog.Error("Could not read key index", "key", idx.key, "error", err)
+		}
+		if rep.Scopes == nil {
+			rep.DuplicateIDsByKey[idx.key] = dupes
+		}
+		summaries[idx.key] = summary
+	}
+	return summaries
+}
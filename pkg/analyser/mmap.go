@@ -0,0 +1,40 @@
+// pkg/analyser/mmap.go
+package analyser
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/source"
+)
+
+// openLines opens a source for line-by-line reading. With MemoryMap set,
+// sources that support it are memory-mapped so lines are sliced from the page
+// cache without being copied; anything else is streamed. The returned function
+// closes the source.
+func (a *Analyser) openLines(ctx context.Context, src source.InputSource) (*source.LineReader, func() error, error) {
+	if a.MemoryMap {
+		if m, err := a.openMapped(src); err == nil {
+			return source.NewBytesLineReader(m.Bytes(), a.MaxLineSize), m.Close, nil
+		}
+	}
+	reader, err := src.Open(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return source.NewLineReader(reader, a.MaxLineSize), reader.Close, nil
+}
+
+// openMapped memory-maps a source, logging why when it falls back to
+// streaming.
+func (a *Analyser) openMapped(src source.InputSource) (*source.MappedFile, error) {
+	mappable, ok := src.(source.MappedSource)
+	if !ok {
+		return nil, source.ErrMmapUnsupported
+	}
+	m, err := mappable.OpenMapped()
+	if err != nil {
+		slog.Info("Reading without memory mapping", "path", src.Path(), "error", err)
+	}
+	return m, err
+}
@@ -0,0 +1,159 @@
+// pkg/analyser/progress.go
+package analyser
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/source"
+)
+
+// DefaultProgressInterval is how often Run calls OnProgress when
+// ProgressInterval is not set.
+const DefaultProgressInterval = 100 * time.Millisecond
+
+// Progress is how far a run has got, passed to an Analyser's OnProgress
+// while Run works, so callers can show progress without polling its
+// counters. Run calls OnProgress every ProgressInterval, or
+// DefaultProgressInterval when that is zero, from one goroutine at a time,
+// and once more with Done set when reading has finished.
+type Progress struct {
+	FilesDone  int
+	FilesTotal int
+	BytesDone  int64
+	BytesTotal int64
+	Rows       int64
+	// Duplicates is the repeated records found so far across every check,
+	// or -1 when the index cannot count them as it goes; see CountsRepeats.
+	Duplicates int64
+	// CurrentFolder is the folder of the file read most recently.
+	CurrentFolder string
+	// Errors counts the lines that could not be parsed or were too long in
+	// the files finished so far, and the files that could not be read.
+	Errors  int64
+	Elapsed time.Duration
+	Done    bool
+}
+
+// progressTotals returns the number and size of the files of every source
+// given to Run, including those of earlier runs that are already processed.
+func (a *Analyser) progressTotals() (int, int64) {
+	var total int64
+	for _, s := range a.sourcesByPath {
+		total += s.Size()
+	}
+	return len(a.sourcesByPath), total
+}
+
+// progress returns the progress of a run of filesTotal files holding
+// bytesTotal that started at start.
+func (a *Analyser) progress(filesTotal int, bytesTotal int64, start time.Time) Progress {
+	p := Progress{
+		FilesDone:  int(a.ProcessedFiles.Load()),
+		FilesTotal: filesTotal,
+		BytesDone:  a.ProcessedBytes.Load(),
+		BytesTotal: bytesTotal,
+		Rows:       a.TotalRows.Load(),
+		Duplicates: -1,
+		Errors:     a.errorCount.Load(),
+		Elapsed:    time.Since(start),
+	}
+	if a.CountsRepeats() {
+		p.Duplicates = a.RepeatedRecords.Load()
+	}
+	p.CurrentFolder, _ = a.CurrentFolder.Load().(string)
+	return p
+}
+
+// sendProgress calls OnProgress every ProgressInterval until ctx is done.
+func (a *Analyser) sendProgress(ctx context.Context, filesTotal int, bytesTotal int64, start time.Time) {
+	interval := a.ProgressInterval
+	if interval <= 0 {
+		interval = DefaultProgressInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.OnProgress(a.progress(filesTotal, bytesTotal, start))
+		}
+	}
+}
+
+// EstimateProgress returns the completed fraction and the estimated time
+// remaining from the bytes processed so far. File sizes vary by orders of
+// magnitude, so bytes give a far steadier estimate than file counts. The ETA
+// is zero until there is enough progress to extrapolate from.
+func EstimateProgress(processedBytes, totalBytes int64, elapsed time.Duration) (float64, time.Duration) {
+	if totalBytes <= 0 {
+		return 0, 0
+	}
+	percent := min(float64(processedBytes)/float64(totalBytes), 1)
+	if processedBytes <= 0 || elapsed < time.Second || percent >= 1 {
+		return percent, 0
+	}
+	remaining := float64(elapsed) * (1 - percent) / percent
+	return percent, time.Duration(remaining)
+}
+
+// CountsRepeats reports whether RepeatedRecords tracks the run: the duplicate
+// records found so far across every check. Only in-memory indexes can tell
+// that a value was seen before as it is added; with a memory budget the count
+// stops growing once the indexes spill to disk.
+func (a *Analyser) CountsRepeats() bool {
+	return a.IndexMode == IndexMemory
+}
+
+// FolderProgress is how far a run has got through the files of one folder.
+type FolderProgress struct {
+	Folder     string
+	FilesDone  int
+	TotalFiles int
+	Rows       int64
+}
+
+// Started reports whether any of the folder's files have been read.
+func (f FolderProgress) Started() bool { return f.FilesDone > 0 || f.Rows > 0 }
+
+// Complete reports whether every file in the folder has been processed.
+func (f FolderProgress) Complete() bool { return f.FilesDone == f.TotalFiles }
+
+// FolderProgress returns the files processed and rows read so far in each
+// folder of sources, sorted by folder.
+func (a *Analyser) FolderProgress(sources []source.InputSource) []FolderProgress {
+	byFolder := make(map[string]*FolderProgress)
+	var folders []*FolderProgress
+	a.processedPathsMutex.Lock()
+	for _, s := range sources {
+		dir := s.Dir()
+		f, ok := byFolder[dir]
+		if !ok {
+			f = &FolderProgress{Folder: dir}
+			byFolder[dir] = f
+			folders = append(folders, f)
+		}
+		f.TotalFiles++
+		if a.processedPaths[s.Path()] {
+			f.FilesDone++
+		}
+	}
+	a.processedPathsMutex.Unlock()
+
+	a.rowsProcessedMutex.Lock()
+	for _, f := range folders {
+		f.Rows = a.rowsProcessedPerFolder[f.Folder]
+	}
+	a.rowsProcessedMutex.Unlock()
+
+	progress := make([]FolderProgress, len(folders))
+	for i, f := range folders {
+		progress[i] = *f
+	}
+	slices.SortFunc(progress, func(x, y FolderProgress) int { return cmp.Compare(x.Folder, y.Folder) })
+	return progress
+}
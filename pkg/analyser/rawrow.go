@@ -0,0 +1,38 @@
+// pkg/analyser/rawrow.go
+package analyser
+
+// rawRowFastPath reports whether rows can be hashed from their raw bytes. This
+// is only possible when the row hash is the sole check, as every other feature
+// needs the decoded record. Additional keys are only checked with the key
+// check, so they do not rule it out.
+func (a *Analyser) rawRowFastPath() bool {
+	return a.RawRowHash && a.checkRow && !a.checkKey && !a.ValidateOnly && !a.DiscoverKeys &&
+		!a.Profile && a.FuzzyField == ""
+}
+
+// normaliseRawRow appends line to dst with all whitespace outside of JSON
+// strings removed, so that rows differing only in formatting hash the same.
+// Unlike the decoded path, field order is significant and lines that are not
+// valid JSON are hashed as-is rather than skipped.
+func normaliseRawRow(dst, line []byte) []byte {
+	inString, escaped := false, false
+	for _, c := range line {
+		switch {
+		case inString:
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			continue
+		}
+		dst = append(dst, c)
+	}
+	return dst
+}
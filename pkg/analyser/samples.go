@@ -0,0 +1,70 @@
+// pkg/analyser/samples.go
+package analyser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+	"github.com/benjaminwestern/dupe-analyser/pkg/source"
+)
+
+// attachSamples re-reads the first SampleRecords members of every duplicate
+// set and embeds their JSON in the report, keyed by location. Records larger
+// than MaxSampleBytes are replaced with a short placeholder string.
+func (a *Analyser) attachSamples(ctx context.Context, rep *report.AnalysisReport) {
+	wanted := make(map[string]map[int]bool)
+	collect := func(sets map[string][]report.LocationInfo) {
+		for _, locs := range sets {
+			for i, loc := range locs {
+				if i >= a.SampleRecords {
+					break
+				}
+				if wanted[loc.FilePath] == nil {
+					wanted[loc.FilePath] = make(map[int]bool)
+				}
+				wanted[loc.FilePath][loc.LineNumber] = true
+			}
+		}
+	}
+	collect(rep.DuplicateIDs)
+	collect(rep.DuplicateRows)
+	for _, sets := range rep.DuplicateIDsByKey {
+		collect(sets)
+	}
+	for _, sr := range rep.Scopes {
+		collect(sr.DuplicateIDs)
+		collect(sr.DuplicateRows)
+		for _, sets := range sr.DuplicateIDsByKey {
+			collect(sets)
+		}
+	}
+	if len(wanted) == 0 {
+		return
+	}
+
+	rep.RecordSamples = make(map[string]json.RawMessage)
+	for path, lines := range wanted {
+		src, ok := a.sourcesByPath[path]
+		if !ok {
+			continue
+		}
+		err := source.ReadLines(ctx, src, lines, a.MaxLineSize, func(lineNumber int, data []byte) {
+			key := report.LocationInfo{FilePath: path, LineNumber: lineNumber}.Key()
+			if a.MaxSampleBytes > 0 && len(data) > a.MaxSampleBytes {
+				placeholder, _ := json.Marshal(fmt.Sprintf("<record of %d bytes omitted>", len(data)))
+				rep.RecordSamples[key] = placeholder
+				return
+			}
+			if !json.Valid(data) {
+				return
+			}
+			rep.RecordSamples[key] = append(json.RawMessage(nil), data...)
+		})
+		if err != nil {
+			slog.Warn("Could not read sample records", "path", path, "error", err)
+		}
+	}
+}
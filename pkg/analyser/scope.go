@@ -0,0 +1,182 @@
+// pkg/analyser/scope.go
+package analyser
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+)
+
+// Duplicate scopes control which records are compared against each other.
+const (
+	// ScopeGlobal compares every record against every other record in the run.
+	ScopeGlobal = "global"
+	// ScopeFile only compares records that live in the same file.
+	ScopeFile = "file"
+	// ScopeFolder only compares records that live in the same directory or GCS prefix.
+	ScopeFolder = "folder"
+)
+
+// ValidScope reports whether s is a recognised duplicate scope.
+func ValidScope(s string) bool {
+	return s == ScopeGlobal || s == ScopeFile || s == ScopeFolder
+}
+
+const scopeSeparator = "\x00"
+
+// scopedKey prefixes a key or hash with the scope it belongs to so that
+// identical values in different scopes are tracked as separate sets.
+func scopedKey(scope, key string) string {
+	return scope + scopeSeparator + key
+}
+
+// sharedKey returns the key under which a value is stored in the shared maps
+// for the analyser's scope. File scope never writes directly to the shared
+// maps, so only folder scope needs qualifying here.
+func (a *Analyser) sharedKey(filePath, key string) string {
+	if a.Scope == ScopeFolder {
+		return scopedKey(filepath.Dir(filePath), key)
+	}
+	return key
+}
+
+// splitScopedKey reverses scopedKey.
+func splitScopedKey(k string) (scope, key string) {
+	scope, key, _ = strings.Cut(k, scopeSeparator)
+	return scope, key
+}
+
+// fileIndex holds the key and row locations for a single source while it is
+// being scanned. It is used in file scope, where nothing needs to be shared
+// across workers until the file is finished, and for the chunks of a chunked
+// file, whose line numbers are only known once the file is finished.
+type fileIndex struct {
+	ids   map[string][]report.LocationInfo
+	rows  map[string][]report.LocationInfo
+	extra map[string]map[string][]report.LocationInfo
+	fuzzy map[string][]report.LocationInfo
+}
+
+func newFileIndex() *fileIndex {
+	return &fileIndex{
+		ids:  make(map[string][]report.LocationInfo),
+		rows: make(map[string][]report.LocationInfo),
+	}
+}
+
+// addFuzzy records a location for a fuzzy field value.
+func (idx *fileIndex) addFuzzy(value string, loc report.LocationInfo) {
+	if idx.fuzzy == nil {
+		idx.fuzzy = make(map[string][]report.LocationInfo)
+	}
+	idx.fuzzy[value] = append(idx.fuzzy[value], loc)
+}
+
+// addExtra records a location for an additional key.
+func (idx *fileIndex) addExtra(key, value string, loc report.LocationInfo) {
+	if idx.extra == nil {
+		idx.extra = make(map[string]map[string][]report.LocationInfo)
+	}
+	if idx.extra[key] == nil {
+		idx.extra[key] = make(map[string][]report.LocationInfo)
+	}
+	idx.extra[key][value] = append(idx.extra[key][value], loc)
+}
+
+// merge appends every location of other to idx.
+func (idx *fileIndex) merge(other *fileIndex) {
+	for key, locs := range other.ids {
+		idx.ids[key] = append(idx.ids[key], locs...)
+	}
+	for key, locs := range other.rows {
+		idx.rows[key] = append(idx.rows[key], locs...)
+	}
+	for key, values := range other.extra {
+		for value, locs := range values {
+			for _, loc := range locs {
+				idx.addExtra(key, value, loc)
+			}
+		}
+	}
+	for value, locs := range other.fuzzy {
+		for _, loc := range locs {
+			idx.addFuzzy(value, loc)
+		}
+	}
+}
+
+// shift adds n to the line number of every location in idx.
+func (idx *fileIndex) shift(n int) {
+	if n == 0 {
+		return
+	}
+	shiftSets := func(sets map[string][]report.LocationInfo) {
+		for _, locs := range sets {
+			for i := range locs {
+				locs[i].LineNumber += n
+			}
+		}
+	}
+	shiftSets(idx.ids)
+	shiftSets(idx.rows)
+	for _, values := range idx.extra {
+		shiftSets(values)
+	}
+	shiftSets(idx.fuzzy)
+}
+
+// flushFileIndex moves only the duplicated sets of a finished file into the
+// shared maps, so unique keys never occupy memory beyond their own file.
+func (a *Analyser) flushFileIndex(path string, idx *fileIndex) {
+	flushDuplicates(a.ids, path, idx.ids)
+	flushDuplicates(a.rows, path, idx.rows)
+	for _, extra := range a.extraKeys {
+		flushDuplicates(extra.index, path, idx.extra[extra.key])
+	}
+	a.flushFuzzy(idx)
+}
+
+// flushChunkIndex adds every location of a finished chunked file to the
+// shared maps under the analyser's scope.
+func (a *Analyser) flushChunkIndex(path string, idx *fileIndex) {
+	flushAll := func(dst locationIndex, sets map[string][]report.LocationInfo) {
+		for key, locs := range sets {
+			k := a.sharedKey(path, key)
+			for _, loc := range locs {
+				dst.Add(k, loc)
+			}
+		}
+	}
+	flushAll(a.ids, idx.ids)
+	flushAll(a.rows, idx.rows)
+	for _, extra := range a.extraKeys {
+		flushAll(extra.index, idx.extra[extra.key])
+	}
+	a.flushFuzzy(idx)
+}
+
+// flushFuzzy adds every fuzzy field value of a finished file to the fuzzy
+// index. Unlike keys, values seen once are kept, as they may be similar to
+// values elsewhere.
+func (a *Analyser) flushFuzzy(idx *fileIndex) {
+	for value, locs := range idx.fuzzy {
+		for _, loc := range locs {
+			a.recordFuzzyValue(value, loc.FilePath, loc.LineNumber)
+		}
+	}
+}
+
+// flushDuplicates adds every set with more than one location to dst under the
+// given scope.
+func flushDuplicates(dst locationIndex, scope string, sets map[string][]report.LocationInfo) {
+	for key, locs := range sets {
+		if len(locs) < 2 {
+			continue
+		}
+		k := scopedKey(scope, key)
+		for _, loc := range locs {
+			dst.Add(k, loc)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// pkg/analyser/workers.go
+package analyser
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"slices"
+	"time"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/source"
+)
+
+// AutoWorkers, passed as the worker count to New, sizes the worker pool from
+// the CPU count and the read latency of the sources when Run starts.
+const AutoWorkers = 0
+
+const (
+	// workerProbes is the number of sources sampled to measure read latency.
+	workerProbes = 3
+	// workerProbeBytes is how much of each sampled source is read.
+	workerProbeBytes = 64 * 1024
+	// latencyPerExtraWorker is the read latency that earns each CPU one more
+	// worker, so that slow remote reads overlap with decoding elsewhere.
+	latencyPerExtraWorker = 10 * time.Millisecond
+	maxWorkersPerCPU      = 8
+	maxAutoWorkers        = 256
+)
+
+// tuneWorkers picks a worker count for sources. Local reads are CPU bound, so
+// one worker per CPU keeps every core busy; remote reads such as GCS spend
+// most of their time waiting, so more workers are added as the measured
+// latency of opening and reading the first bytes of a few sources grows. The
+// returned note explains the choice for the report.
+func tuneWorkers(ctx context.Context, sources []source.InputSource) (int, string) {
+	cpus := runtime.NumCPU()
+	latency, probed := probeReadLatency(ctx, sources)
+	if probed == 0 {
+		return cpus, fmt.Sprintf("auto: %d CPUs", cpus)
+	}
+	perCPU := min(1+int(latency/latencyPerExtraWorker), maxWorkersPerCPU)
+	workers := min(cpus*perCPU, maxAutoWorkers)
+	return workers, fmt.Sprintf("auto: %d CPUs, %s median read latency over %d files", cpus, latency.Round(time.Microsecond), probed)
+}
+
+// probeReadLatency returns the median time taken to open and read the start of
+// up to workerProbes sources spread across the list, and how many were read.
+func probeReadLatency(ctx context.Context, sources []source.InputSource) (time.Duration, int) {
+	var latencies []time.Duration
+	for i := 0; i < workerProbes && i < len(sources); i++ {
+		src := sources[i*len(sources)/min(workerProbes, len(sources))]
+		start := time.Now()
+		reader, err := src.Open(ctx)
+		if err != nil {
+			continue
+		}
+		_, err = io.CopyN(io.Discard, reader, workerProbeBytes)
+		reader.Close()
+		if err != nil && err != io.EOF {
+			continue
+		}
+		latencies = append(latencies, time.Since(start))
+	}
+	if len(latencies) == 0 {
+		return 0, 0
+	}
+	slices.Sort(latencies)
+	return latencies[len(latencies)/2], len(latencies)
+}
@@ -0,0 +1,194 @@
+// pkg/dupeanalyse/dupeanalyse.go
+
+// Package dupeanalyse runs duplicate checks over NDJSON files in-process, so
+// Go services can check their data without shelling out to the dupe-analyser
+// binary:
+//
+//	rep, err := dupeanalyse.Analyse(ctx, dupeanalyse.Options{
+//		Paths:    []string{"gs://my-bucket/orders/"},
+//		Key:      "order_id",
+//		CheckKey: true,
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	fmt.Println(rep.Summary.UniqueKeysDuplicated, "order ids are duplicated")
+//
+// The report is the same one the binary saves as JSON. It can be written in
+// any of the binary's report formats with its Render method.
+package dupeanalyse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/benjaminwestern/dupe-analyser/pkg/analyser"
+	"github.com/benjaminwestern/dupe-analyser/pkg/report"
+	"github.com/benjaminwestern/dupe-analyser/pkg/source"
+)
+
+// Report is the result of an analysis.
+type Report = report.AnalysisReport
+
+// Progress is how far an analysis has got, passed to Options.OnProgress.
+type Progress = analyser.Progress
+
+// Scopes that duplicates are compared within.
+const (
+	ScopeGlobal = analyser.ScopeGlobal
+	ScopeFile   = analyser.ScopeFile
+	ScopeFolder = analyser.ScopeFolder
+)
+
+// Index modes that choose where the duplicate indexes are held.
+const (
+	IndexMemory = analyser.IndexMemory
+	IndexDisk   = analyser.IndexDisk
+	IndexSort   = analyser.IndexSort
+)
+
+// Constraint is an additional uniqueness check over one or more fields,
+// reported under Name. A constraint of several fields is composite: a record
+// repeats it only when every field matches.
+type Constraint struct {
+	Name   string
+	Fields []string
+}
+
+// Options configures an analysis. Paths or Files, Key, and CheckKey or
+// CheckRow are required unless ValidateOnly is set; the zero value of every
+// other field is a sensible default.
+type Options struct {
+	// Paths are the local directories, local files and gs:// prefixes or
+	// objects whose NDJSON files are discovered and analysed.
+	Paths []string
+	// Files, in place of Paths, lists every local file or gs:// object to
+	// analyse, skipping discovery.
+	Files []string
+	// Key is the JSON field checked for uniqueness, and AdditionalKeys are
+	// further fields checked in the same pass.
+	Key            string
+	AdditionalKeys []string
+	Constraints    []Constraint
+	// CheckKey finds repeated values of the keys, and CheckRow finds
+	// repeated records.
+	CheckKey bool
+	CheckRow bool
+	// ValidateOnly only reports how many records have Key, without looking
+	// for duplicates.
+	ValidateOnly bool
+	// Scope is ScopeGlobal, ScopeFile or ScopeFolder, ScopeGlobal when empty.
+	Scope string
+	// Workers is the number of files read at once; zero sizes the pool from
+	// the CPU count and read latency.
+	Workers int
+	// FuzzyField, when set, clusters records whose values of this field are
+	// at least FuzzyThreshold alike by Jaro-Winkler similarity, 0.9 when
+	// zero.
+	FuzzyField     string
+	FuzzyThreshold float64
+	// IndexMode is IndexMemory, IndexDisk or IndexSort, IndexMemory when
+	// empty. The disk and sort indexes spill to IndexDir, the system
+	// temporary directory when empty.
+	IndexMode string
+	IndexDir  string
+	// MaxMemory, when positive, is the memory budget in bytes that the
+	// indexes spill to disk, and then reading stops, as it is approached.
+	MaxMemory int64
+	// MaxLineSize, when positive, is the longest line analysed; longer lines
+	// are skipped and counted in the report.
+	MaxLineSize int
+	// MaxDuration, when positive, stops the analysis once it has taken this
+	// long, returning a partial report.
+	MaxDuration time.Duration
+	// OnProgress, when set, is called with the progress of the analysis
+	// every ProgressInterval, 100ms when zero, and once more with Done set
+	// when reading has finished. It is called from one goroutine at a time
+	// and should return quickly.
+	OnProgress       func(Progress)
+	ProgressInterval time.Duration
+}
+
+// validate returns an error describing the first invalid option.
+func (o *Options) validate() error {
+	switch {
+	case len(o.Paths) == 0 && len(o.Files) == 0:
+		return errors.New("no paths or files to analyse")
+	case len(o.Paths) > 0 && len(o.Files) > 0:
+		return errors.New("paths and files cannot both be given")
+	case o.Key == "":
+		return errors.New("a key is required")
+	case !o.ValidateOnly && !o.CheckKey && !o.CheckRow:
+		return errors.New("at least one of the key and row checks must be enabled")
+	case o.Scope != "" && !analyser.ValidScope(o.Scope):
+		return fmt.Errorf("invalid scope %q", o.Scope)
+	case o.IndexMode != "" && !analyser.ValidIndexMode(o.IndexMode):
+		return fmt.Errorf("invalid index mode %q", o.IndexMode)
+	case o.FuzzyThreshold < 0 || o.FuzzyThreshold > 1:
+		return errors.New("the fuzzy threshold must be between 0 and 1")
+	}
+	return nil
+}
+
+// Analyse discovers the files of opts and analyses them for duplicates. When
+// ctx is cancelled, Analyse returns the partial report of what was analysed
+// along with the context's error. When MaxDuration passes or MaxMemory runs
+// out, it returns a partial report without an error; the report's
+// Summary.IsPartialReport tells them apart from complete runs.
+func Analyse(ctx context.Context, opts Options) (*Report, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	startTime := time.Now()
+	analysisCtx := ctx
+	if opts.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		analysisCtx, cancel = context.WithTimeout(ctx, opts.MaxDuration)
+		defer cancel()
+	}
+
+	var sources []source.InputSource
+	var err error
+	inputs := opts.Paths
+	if len(opts.Files) > 0 {
+		inputs = opts.Files
+		sources, err = source.FromList(analysisCtx, opts.Files)
+	} else {
+		sources, err = source.DiscoverAll(analysisCtx, opts.Paths)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	eng := analyser.New(opts.Key, opts.Workers, opts.CheckKey, opts.CheckRow, opts.ValidateOnly)
+	defer eng.Close()
+	eng.SetAdditionalKeys(opts.AdditionalKeys)
+	for _, c := range opts.Constraints {
+		eng.AddConstraint(c.Name, c.Fields)
+	}
+	if opts.Scope != "" {
+		eng.Scope = opts.Scope
+	}
+	if opts.IndexMode != "" {
+		eng.IndexMode = opts.IndexMode
+		eng.IndexDir = opts.IndexDir
+	}
+	eng.FuzzyField = opts.FuzzyField
+	eng.FuzzyThreshold = opts.FuzzyThreshold
+	if eng.FuzzyThreshold == 0 {
+		eng.FuzzyThreshold = 0.9
+	}
+	eng.MaxMemory = opts.MaxMemory
+	if opts.MaxLineSize > 0 {
+		eng.MaxLineSize = opts.MaxLineSize
+	}
+	eng.OnProgress = opts.OnProgress
+	eng.ProgressInterval = opts.ProgressInterval
+
+	rep := eng.Run(analysisCtx, sources)
+	rep.Summary.TotalElapsedTime = time.Since(startTime).Round(time.Second).String()
+	rep.Summary.Inputs = inputs
+	return rep, ctx.Err()
+}
@@ -0,0 +1,106 @@
+// pkg/dupeanalyse/dupeanalyse_test.go
+package dupeanalyse
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestAnalyse(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.json":     "{\"id\":1,\"v\":\"x\"}\n{\"id\":2,\"v\":\"y\"}\n",
+		"sub/b.json": "{\"id\":1,\"v\":\"x\"}\n{\"id\":3,\"v\":\"z\"}\n",
+	})
+	rep, err := Analyse(context.Background(), Options{Paths: []string{dir}, Key: "id", CheckKey: true, CheckRow: true, Workers: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := rep.Summary
+	if s.IsPartialReport || s.TotalFiles != 2 || s.TotalRowsProcessed != 4 {
+		t.Errorf("got %d files and %d rows, partial %v; want 2 complete files of 4 rows", s.TotalFiles, s.TotalRowsProcessed, s.IsPartialReport)
+	}
+	if s.UniqueKeysDuplicated != 1 || s.DuplicateRowInstances != 2 {
+		t.Errorf("got %d duplicated ids and %d duplicate row instances, want 1 and 2", s.UniqueKeysDuplicated, s.DuplicateRowInstances)
+	}
+
+	rep, err = Analyse(context.Background(), Options{Files: []string{filepath.Join(dir, "a.json")}, Key: "id", CheckKey: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Summary.TotalFiles != 1 || rep.Summary.UniqueKeysDuplicated != 0 {
+		t.Errorf("listed file: got %d files and %d duplicated ids, want 1 and 0", rep.Summary.TotalFiles, rep.Summary.UniqueKeysDuplicated)
+	}
+}
+
+func TestAnalyseProgress(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.json": "{\"id\":1}\n{\"id\":1}\nnot json\n",
+		"b.json": "{\"id\":2}\n",
+	})
+	var updates []Progress
+	_, err := Analyse(context.Background(), Options{
+		Paths:      []string{dir},
+		Key:        "id",
+		CheckKey:   true,
+		OnProgress: func(p Progress) { updates = append(updates, p) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updates) == 0 {
+		t.Fatal("OnProgress was never called")
+	}
+	last := updates[len(updates)-1]
+	want := Progress{FilesDone: 2, FilesTotal: 2, BytesDone: 36, BytesTotal: 36, Rows: 4, Duplicates: 1, Errors: 1, Done: true}
+	last.CurrentFolder, last.Elapsed = "", 0
+	if last != want {
+		t.Errorf("last progress = %+v, want %+v", last, want)
+	}
+	for _, p := range updates[:len(updates)-1] {
+		if p.Done {
+			t.Errorf("progress before the last has Done set: %+v", p)
+		}
+	}
+}
+
+func TestAnalyseInvalidOptions(t *testing.T) {
+	tests := map[string]Options{
+		"no paths":        {Key: "id", CheckKey: true},
+		"paths and files": {Paths: []string{"a"}, Files: []string{"b"}, Key: "id", CheckKey: true},
+		"no key":          {Paths: []string{"a"}, CheckKey: true},
+		"no checks":       {Paths: []string{"a"}, Key: "id"},
+		"bad scope":       {Paths: []string{"a"}, Key: "id", CheckKey: true, Scope: "bucket"},
+		"bad index":       {Paths: []string{"a"}, Key: "id", CheckKey: true, IndexMode: "cloud"},
+	}
+	for name, opts := range tests {
+		if _, err := Analyse(context.Background(), opts); err == nil {
+			t.Errorf("%s: Analyse succeeded, want an error", name)
+		}
+	}
+}
+
+func TestAnalyseCancelled(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"a.json": "{\"id\":1}\n"})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Analyse(ctx, Options{Paths: []string{dir}, Key: "id", CheckKey: true}); err == nil {
+		t.Error("Analyse of a cancelled context succeeded, want its error")
+	}
+}
@@ -0,0 +1,135 @@
+// pkg/report/compare.go
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ComparisonEntry holds the locations of a key value present in both datasets.
+type ComparisonEntry struct {
+	Left  []LocationInfo `json:"left"`
+	Right []LocationInfo `json:"right"`
+}
+
+// ComparisonSummary contains aggregated metrics from a left/right reconciliation.
+type ComparisonSummary struct {
+	IsPartialReport   bool   `json:"isPartialReport"`
+	TotalElapsedTime  string `json:"totalElapsedTime"`
+	UniqueKey         string `json:"uniqueKey"`
+	LeftFiles         int32  `json:"leftFiles"`
+	RightFiles        int32  `json:"rightFiles"`
+	LeftRows          int64  `json:"leftRows"`
+	RightRows         int64  `json:"rightRows"`
+	LeftDistinctKeys  int    `json:"leftDistinctKeys"`
+	RightDistinctKeys int    `json:"rightDistinctKeys"`
+	InBothCount       int    `json:"inBothCount"`
+	OnlyLeftCount     int    `json:"onlyLeftCount"`
+	OnlyRightCount    int    `json:"onlyRightCount"`
+	KeysMasked        string `json:"keysMasked,omitempty"`
+}
+
+// ComparisonReport is the result of reconciling the keys of two datasets.
+type ComparisonReport struct {
+	Summary   ComparisonSummary          `json:"summary"`
+	InBoth    map[string]ComparisonEntry `json:"inBoth"`
+	OnlyLeft  map[string][]LocationInfo  `json:"onlyLeft"`
+	OnlyRight map[string][]LocationInfo  `json:"onlyRight"`
+}
+
+// String formats the comparison report for display.
+func (r *ComparisonReport) String(isFullReport bool) string {
+	s := r.Summary
+	var b strings.Builder
+
+	b.WriteString(headerStyle.Render("--- Dataset Comparison Summary ---") + "\n")
+	summaryContent := fmt.Sprintf(
+		"Key Compared:                 '%s'\nTotal Elapsed Time:           %s\nLeft Files / Rows:            %d / %d\nRight Files / Rows:           %d / %d\nDistinct Keys (Left/Right):   %d / %d\nKeys In Both:                 %d\nKeys Only In Left:            %d\nKeys Only In Right:           %d",
+		s.UniqueKey, s.TotalElapsedTime, s.LeftFiles, s.LeftRows, s.RightFiles, s.RightRows,
+		s.LeftDistinctKeys, s.RightDistinctKeys, s.InBothCount, s.OnlyLeftCount, s.OnlyRightCount,
+	)
+	if s.KeysMasked != "" {
+		summaryContent += fmt.Sprintf("\nKey Values Masked:            %s", s.KeysMasked)
+	}
+	if s.IsPartialReport {
+		summaryContent += "\nNote: the comparison was cancelled and is incomplete."
+	}
+	b.WriteString(reportStyle.Render(summaryContent))
+
+	if isFullReport {
+		if len(r.OnlyLeft) > 0 {
+			b.WriteString("\n\n" + headerStyle.Render("--- Keys Only In Left ---"))
+			writeIDDetails(&b, s.UniqueKey, r.OnlyLeft, DetailLimits{})
+		}
+		if len(r.OnlyRight) > 0 {
+			b.WriteString("\n\n" + headerStyle.Render("--- Keys Only In Right ---"))
+			writeIDDetails(&b, s.UniqueKey, r.OnlyRight, DetailLimits{})
+		}
+		if len(r.InBoth) > 0 {
+			b.WriteString("\n\n" + headerStyle.Render("--- Keys In Both ---") + "\n")
+			keys := make([]string, 0, len(r.InBoth))
+			for key := range r.InBoth {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				entry := r.InBoth[key]
+				b.WriteString(fmt.Sprintf("ID '%s': %s (left %d, right %d)\n", s.UniqueKey, key, len(entry.Left), len(entry.Right)))
+			}
+		}
+	}
+	return b.String()
+}
+
+// ToJSON converts the comparison report to a JSON string.
+func (r *ComparisonReport) ToJSON() (string, error) {
+	bytes, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("could not marshal comparison report to json: %w", err)
+	}
+	return string(bytes), nil
+}
+
+// SaveAndLog writes the comparison report into logPath with a timestamped
+// base name, applies the retention policy and returns that base name. With
+// compress, the details text and JSON files are gzipped.
+func (r *ComparisonReport) SaveAndLog(logPath string, enableTxt, enableJson, compress bool, retention RetentionPolicy) string {
+	opts := SaveOptions{Compress: compress}
+	baseFilename := filepath.Join(logPath, "comparison-"+time.Now().Format("2006-01-02_15-04-05"))
+	defer retention.Apply(logPath, baseFilename)
+	if enableTxt {
+		summaryFilename := baseFilename + "_summary.txt"
+		detailsFilename := opts.gz(baseFilename + "_details.txt")
+		if err := os.WriteFile(summaryFilename, []byte(r.String(false)), 0644); err != nil {
+			slog.Error("Failed to save TXT comparison summary", "path", summaryFilename, "error", err)
+		}
+		if err := writeFile(detailsFilename, func(w io.Writer) error {
+			_, err := io.WriteString(w, r.String(true))
+			return err
+		}); err != nil {
+			slog.Error("Failed to save TXT comparison details", "path", detailsFilename, "error", err)
+		}
+	}
+	if enableJson {
+		filename := opts.gz(baseFilename + ".json")
+		jsonData, err := r.ToJSON()
+		if err != nil {
+			slog.Error("Failed to marshal JSON comparison report", "error", err)
+			return baseFilename
+		}
+		if err := writeFile(filename, func(w io.Writer) error {
+			_, err := io.WriteString(w, jsonData)
+			return err
+		}); err != nil {
+			slog.Error("Failed to save JSON comparison report", "path", filename, "error", err)
+		}
+	}
+	return baseFilename
+}
@@ -0,0 +1,30 @@
+// pkg/report/csv.go
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// csvHeader is the header row of the CSV report. Each following row is one
+// location of one duplicate set.
+var csvHeader = []string{"type", "key", "value", "scope", "file", "line", "count"}
+
+// WriteCSV writes every duplicate location as a flat row for spreadsheets and
+// BI tools. The type column is "id" or "row"; key names the key or constraint
+// for ids, and value holds the duplicated value or the row hash. Count is the
+// size of the duplicate set the row belongs to, and scope is set for per-file
+// or per-folder duplicates.
+func (r *AnalysisReport) WriteCSV(w io.Writer, checkKey, checkRow bool) error {
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+	r.EachDuplicateSet(checkKey, checkRow, func(kind, key, scope, value string, locs []LocationInfo) {
+		count := strconv.Itoa(len(locs))
+		for _, loc := range locs {
+			cw.Write([]string{kind, key, value, scope, loc.FilePath, strconv.Itoa(loc.LineNumber), count})
+		}
+	})
+	cw.Flush()
+	return cw.Error()
+}
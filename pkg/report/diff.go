@@ -0,0 +1,180 @@
+// pkg/report/diff.go
+package report
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// DiffSet is a duplicate set that differs between two reports. Before and
+// After are its occurrence counts in each, zero where it is absent.
+type DiffSet struct {
+	Type   string `json:"type"`
+	Key    string `json:"key,omitempty"`
+	Scope  string `json:"scope,omitempty"`
+	Value  string `json:"value"`
+	Before int    `json:"before"`
+	After  int    `json:"after"`
+}
+
+// DiffMetric is a summary figure from both reports.
+type DiffMetric struct {
+	Name   string `json:"name"`
+	Before int64  `json:"before"`
+	After  int64  `json:"after"`
+	Delta  int64  `json:"delta"`
+}
+
+// ReportDiff describes how duplication changed between two analysis reports.
+type ReportDiff struct {
+	Before     string       `json:"before"`
+	After      string       `json:"after"`
+	Warnings   []string     `json:"warnings,omitempty"`
+	Metrics    []DiffMetric `json:"metrics"`
+	Introduced []DiffSet    `json:"introduced"`
+	Resolved   []DiffSet    `json:"resolved"`
+	Changed    []DiffSet    `json:"changed"`
+}
+
+// LoadJSON reads an analysis report saved by -output.json, decompressing it
+// when path ends in .gz.
+func LoadJSON(path string) (*AnalysisReport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("could not read report %s: %w", path, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	rep := &AnalysisReport{}
+	if err := json.NewDecoder(r).Decode(rep); err != nil {
+		return nil, fmt.Errorf("could not read report %s: %w", path, err)
+	}
+	return rep, nil
+}
+
+// Diff compares two reports. Duplicate sets are matched on their type, key,
+// scope and value; sets only in after are introduced, sets only in before
+// are resolved, and sets in both whose occurrence count moved are changed.
+func Diff(beforeName string, before *AnalysisReport, afterName string, after *AnalysisReport) *ReportDiff {
+	d := &ReportDiff{Before: beforeName, After: afterName, Introduced: []DiffSet{}, Resolved: []DiffSet{}, Changed: []DiffSet{}}
+	b, a := before.Summary, after.Summary
+	if b.UniqueKey != a.UniqueKey {
+		d.Warnings = append(d.Warnings, fmt.Sprintf("the reports check different keys ('%s' and '%s'), so their key duplicates cannot be matched", b.UniqueKey, a.UniqueKey))
+	}
+	if b.IsPartialReport || a.IsPartialReport {
+		d.Warnings = append(d.Warnings, "at least one report is partial, so some differences may only reflect files that were not read")
+	}
+
+	metric := func(name string, before, after int64) {
+		d.Metrics = append(d.Metrics, DiffMetric{Name: name, Before: before, After: after, Delta: after - before})
+	}
+	metric("Files Analysed", int64(b.FilesProcessed), int64(a.FilesProcessed))
+	metric("Data Analysed (bytes)", b.ProcessedDataSizeBytes, a.ProcessedDataSizeBytes)
+	metric("Rows Processed", b.TotalRowsProcessed, a.TotalRowsProcessed)
+	metric(fmt.Sprintf("Occurrences of '%s'", a.UniqueKey), int64(b.TotalKeyOccurrences), int64(a.TotalKeyOccurrences))
+	metric(fmt.Sprintf("Duplicated '%s' Values", a.UniqueKey), int64(b.UniqueKeysDuplicated), int64(a.UniqueKeysDuplicated))
+	keys := make(map[string]bool)
+	for key := range b.AdditionalKeys {
+		keys[key] = true
+	}
+	for key := range a.AdditionalKeys {
+		keys[key] = true
+	}
+	for _, key := range sortedKeys(keys) {
+		metric(fmt.Sprintf("Duplicated '%s' Values", key), int64(b.AdditionalKeys[key].UniqueKeysDuplicated), int64(a.AdditionalKeys[key].UniqueKeysDuplicated))
+	}
+	metric("Duplicate Row Instances", int64(b.DuplicateRowInstances), int64(a.DuplicateRowInstances))
+
+	type setID struct{ kind, key, scope, value string }
+	beforeSets := make(map[setID]int)
+	before.EachDuplicateSet(true, true, func(kind, key, scope, value string, locs []LocationInfo) {
+		beforeSets[setID{kind, key, scope, value}] = len(locs)
+	})
+	after.EachDuplicateSet(true, true, func(kind, key, scope, value string, locs []LocationInfo) {
+		id := setID{kind, key, scope, value}
+		set := DiffSet{Type: kind, Key: key, Scope: scope, Value: value, After: len(locs)}
+		count, ok := beforeSets[id]
+		delete(beforeSets, id)
+		switch {
+		case !ok:
+			d.Introduced = append(d.Introduced, set)
+		case count != len(locs):
+			set.Before = count
+			d.Changed = append(d.Changed, set)
+		}
+	})
+	// What is left of beforeSets was resolved; walking before again keeps
+	// the output in its stable order.
+	before.EachDuplicateSet(true, true, func(kind, key, scope, value string, locs []LocationInfo) {
+		if _, ok := beforeSets[setID{kind, key, scope, value}]; ok {
+			d.Resolved = append(d.Resolved, DiffSet{Type: kind, Key: key, Scope: scope, Value: value, Before: len(locs)})
+		}
+	})
+	return d
+}
+
+// String formats the diff for display.
+func (d *ReportDiff) String() string {
+	var b strings.Builder
+	b.WriteString(headerStyle.Render("--- Report Diff ---") + "\n")
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "Before: %s\nAfter:  %s\n\n", d.Before, d.After)
+	fmt.Fprintf(&summary, "%-36s %12s %12s %12s", "Metric", "Before", "After", "Delta")
+	for _, m := range d.Metrics {
+		fmt.Fprintf(&summary, "\n%-36s %12d %12d %+12d", m.Name, m.Before, m.After, m.Delta)
+	}
+	fmt.Fprintf(&summary, "\n\nDuplicate Sets Introduced: %d\nDuplicate Sets Resolved:   %d\nDuplicate Sets Changed:    %d",
+		len(d.Introduced), len(d.Resolved), len(d.Changed))
+	for _, w := range d.Warnings {
+		summary.WriteString("\nWarning: " + w)
+	}
+	b.WriteString(reportStyle.Render(summary.String()))
+
+	writeSets := func(title string, sets []DiffSet, count func(DiffSet) string) {
+		if len(sets) == 0 {
+			return
+		}
+		b.WriteString("\n\n" + headerStyle.Render(title) + "\n")
+		for _, s := range sets {
+			label := fmt.Sprintf("ID '%s': %s", s.Key, s.Value)
+			if s.Type == "row" {
+				label = fmt.Sprintf("Row (Hash: %s)", s.Value)
+			}
+			if s.Scope != "" {
+				label += " in " + s.Scope
+			}
+			fmt.Fprintf(&b, "%s (%s)\n", label, count(s))
+		}
+	}
+	writeSets("--- Newly Introduced Duplicates ---", d.Introduced, func(s DiffSet) string {
+		return fmt.Sprintf("appears %d times", s.After)
+	})
+	writeSets("--- Resolved Duplicates ---", d.Resolved, func(s DiffSet) string {
+		return fmt.Sprintf("appeared %d times", s.Before)
+	})
+	writeSets("--- Changed Duplicates ---", d.Changed, func(s DiffSet) string {
+		return fmt.Sprintf("%d -> %d times", s.Before, s.After)
+	})
+	return b.String()
+}
+
+// ToJSON converts the diff to a JSON string.
+func (d *ReportDiff) ToJSON() (string, error) {
+	bytes, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("could not marshal report diff to json: %w", err)
+	}
+	return string(bytes), nil
+}
@@ -0,0 +1,30 @@
+// pkg/report/errors.go
+package report
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// parseErrorList renders the lines that were not valid JSON, grouped by
+// folder with each folder's files beneath it, both in path order.
+func (r *AnalysisReport) parseErrorList() string {
+	byFolder := make(map[string][]string)
+	for _, path := range sortedKeys(r.ParseErrors) {
+		dir := filepath.Dir(path)
+		byFolder[dir] = append(byFolder[dir], path)
+	}
+	var b strings.Builder
+	for _, dir := range sortedKeys(byFolder) {
+		var total int64
+		for _, path := range byFolder[dir] {
+			total += r.ParseErrors[path]
+		}
+		b.WriteString(fmt.Sprintf("Folder: %s, Lines: %d\n", dir, total))
+		for _, path := range byFolder[dir] {
+			b.WriteString(fmt.Sprintf("  - File: %s, Lines: %d\n", path, r.ParseErrors[path]))
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
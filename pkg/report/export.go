@@ -0,0 +1,26 @@
+// pkg/report/export.go
+package report
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Export writes the duplicate sets of the report to baseFilename.csv and
+// baseFilename.json, creating the folder if needed, and returns the files
+// written. Unlike Save it stops at the first error, for callers that report
+// it to the user rather than the log.
+func (r *AnalysisReport) Export(baseFilename string, checkKey, checkRow bool) ([]string, error) {
+	if err := os.MkdirAll(filepath.Dir(baseFilename), 0755); err != nil {
+		return nil, err
+	}
+	csvFile, jsonFile := baseFilename+".csv", baseFilename+".json"
+	if err := writeFile(csvFile, func(w io.Writer) error { return r.WriteCSV(w, checkKey, checkRow) }); err != nil {
+		return nil, err
+	}
+	if err := writeFile(jsonFile, r.WriteJSON); err != nil {
+		return []string{csvFile}, err
+	}
+	return []string{csvFile, jsonFile}, nil
+}
@@ -0,0 +1,68 @@
+// pkg/report/files.go
+package report
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// fileTable renders the per-file breakdown, one row per file in path order.
+// The duplicate columns are only shown for the checks that ran.
+func (r *AnalysisReport) fileTable(checkKey, checkRow bool) string {
+	headers := []string{"File", "Size", "Rows", "Keys Found"}
+	if checkKey {
+		headers = append(headers, "Duplicate IDs")
+	}
+	if checkRow {
+		headers = append(headers, "Duplicate Rows")
+	}
+	headers = append(headers, "Parse Errors", "Time")
+
+	rows := make([][]string, 0, len(r.FileDetails))
+	maxWidths := make([]int, len(headers))
+	for i, h := range headers {
+		maxWidths[i] = len(h)
+	}
+	for _, path := range sortedKeys(r.FileDetails) {
+		d := r.FileDetails[path]
+		row := []string{path, HumanSize(d.SizeBytes)}
+		if d.Processed {
+			row = append(row, fmt.Sprintf("%d", d.RowsProcessed), fmt.Sprintf("%d", d.KeysFound))
+			if checkKey {
+				row = append(row, fmt.Sprintf("%d", d.DuplicateIDs))
+			}
+			if checkRow {
+				row = append(row, fmt.Sprintf("%d", d.DuplicateRows))
+			}
+			row = append(row, fmt.Sprintf("%d", d.ParseErrors), (time.Duration(d.ProcessingMillis) * time.Millisecond).String())
+		} else {
+			for len(row) < len(headers) {
+				row = append(row, "-")
+			}
+		}
+		rows = append(rows, row)
+		for i, cell := range row {
+			maxWidths[i] = max(maxWidths[i], len(cell))
+		}
+	}
+
+	formats := make([]string, len(headers))
+	for i, width := range maxWidths {
+		formats[i] = fmt.Sprintf("%%-%ds", width)
+	}
+	formatRow := func(cells []string) string {
+		padded := make([]string, len(cells))
+		for i, cell := range cells {
+			padded[i] = fmt.Sprintf(formats[i], cell)
+		}
+		return strings.Join(padded, " | ")
+	}
+
+	var tableContent strings.Builder
+	tableContent.WriteString(tableHeaderStyle.Render(formatRow(headers)) + "\n")
+	for _, row := range rows {
+		tableContent.WriteString(formatRow(row) + "\n")
+	}
+	return strings.TrimRight(tableContent.String(), "\n")
+}
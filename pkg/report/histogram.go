@@ -0,0 +1,95 @@
+// pkg/report/histogram.go
+package report
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MultiplicityBucket counts the duplicate sets whose size falls within
+// [Min, Max]; Max is zero for the open-ended last bucket.
+type MultiplicityBucket struct {
+	Min         int `json:"min"`
+	Max         int `json:"max,omitempty"`
+	Sets        int `json:"sets"`
+	Occurrences int `json:"occurrences"`
+}
+
+// Label names the bucket as it appears in text reports, such as "3-5x".
+func (b MultiplicityBucket) Label() string {
+	switch {
+	case b.Max == 0:
+		return fmt.Sprintf("%d+x", b.Min)
+	case b.Min == b.Max:
+		return fmt.Sprintf("%dx", b.Min)
+	}
+	return fmt.Sprintf("%d-%dx", b.Min, b.Max)
+}
+
+// multiplicityBounds are the lower bounds of the histogram buckets.
+var multiplicityBounds = []int{2, 3, 6, 11}
+
+// multiplicityHistogram buckets the duplicate sets in each of the maps by
+// their number of locations. Every bucket is returned, empty or not, so
+// reports from different runs line up.
+func multiplicityHistogram(sets []map[string][]LocationInfo) []MultiplicityBucket {
+	buckets := make([]MultiplicityBucket, len(multiplicityBounds))
+	for i, lo := range multiplicityBounds {
+		buckets[i].Min = lo
+		if i+1 < len(multiplicityBounds) {
+			buckets[i].Max = multiplicityBounds[i+1] - 1
+		}
+	}
+	for _, dupes := range sets {
+		for _, locs := range dupes {
+			n := len(locs)
+			i := len(buckets) - 1
+			for i > 0 && n < buckets[i].Min {
+				i--
+			}
+			buckets[i].Sets++
+			buckets[i].Occurrences += n
+		}
+	}
+	return buckets
+}
+
+// BuildMultiplicity fills in the summary's histograms of how many times
+// duplicated keys and rows occur, across every scope.
+func (r *AnalysisReport) BuildMultiplicity() {
+	ids := []map[string][]LocationInfo{r.DuplicateIDs}
+	rows := []map[string][]LocationInfo{r.DuplicateRows}
+	for _, sr := range r.Scopes {
+		ids = append(ids, sr.DuplicateIDs)
+		rows = append(rows, sr.DuplicateRows)
+	}
+	s := &r.Summary
+	s.IDMultiplicity, s.RowMultiplicity = nil, nil
+	if s.UniqueKeysDuplicated > 0 {
+		s.IDMultiplicity = multiplicityHistogram(ids)
+	}
+	if s.DuplicateRowInstances > 0 {
+		s.RowMultiplicity = multiplicityHistogram(rows)
+	}
+}
+
+// multiplicityTable renders a histogram with a bar per bucket scaled to the
+// largest.
+func multiplicityTable(buckets []MultiplicityBucket) string {
+	const barWidth = 30
+	largest := 0
+	for _, b := range buckets {
+		largest = max(largest, b.Sets)
+	}
+	var tableContent strings.Builder
+	rowFormat := "%-11s | %-10s | %-11s | %s"
+	tableContent.WriteString(tableHeaderStyle.Render(fmt.Sprintf(rowFormat, "Occurrences", "Sets", "Records", "")) + "\n")
+	for _, b := range buckets {
+		bar := ""
+		if b.Sets > 0 {
+			bar = strings.Repeat("█", max(1, b.Sets*barWidth/largest))
+		}
+		tableContent.WriteString(fmt.Sprintf(rowFormat, b.Label(), fmt.Sprintf("%d", b.Sets), fmt.Sprintf("%d", b.Occurrences), bar) + "\n")
+	}
+	return strings.TrimRight(tableContent.String(), "\n")
+}
@@ -0,0 +1,213 @@
+// pkg/report/html.go
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"io"
+)
+
+// htmlCard is one headline figure in the summary section of the HTML report.
+type htmlCard struct {
+	Label string
+	Value string
+}
+
+// htmlFolder is one row of the per-folder table in the HTML report.
+type htmlFolder struct {
+	Path     string
+	Data     string
+	Bytes    int64
+	Files    int
+	Rows     int
+	Keys     int
+	DupeIDs  int
+	DupeRows int
+}
+
+// htmlSet is a single collapsible duplicate set.
+type htmlSet struct {
+	Label     string
+	Value     string
+	Count     int
+	Locations []LocationInfo
+	Omitted   int
+}
+
+var htmlHeadTemplate = template.Must(template.New("head").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2rem; color: #1f2328; }
+h1 { margin-bottom: .25rem; }
+.partial { color: #9a6700; font-weight: bold; }
+.cards { display: flex; flex-wrap: wrap; gap: .75rem; margin: 1rem 0 2rem; }
+.card { border: 1px solid #d0d7de; border-radius: 8px; padding: .75rem 1rem; min-width: 10rem; }
+.card .label { font-size: .8rem; color: #57606a; }
+.card .value { font-size: 1.4rem; font-weight: 600; }
+input[type=search] { width: 100%; max-width: 30rem; padding: .5rem; margin-bottom: 1rem; }
+table { border-collapse: collapse; margin-bottom: 2rem; }
+th, td { border: 1px solid #d0d7de; padding: .3rem .6rem; text-align: left; }
+th { background: #f6f8fa; cursor: pointer; user-select: none; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+details { border-bottom: 1px solid #eaeef2; padding: .3rem 0; }
+summary { cursor: pointer; }
+summary code { font-weight: 600; }
+.omitted { color: #57606a; font-style: italic; }
+details ul { margin: .3rem 0 .3rem 1.5rem; font-family: ui-monospace, monospace; font-size: .85rem; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{if .Partial}}<p class="partial">Partial report: the analysis was stopped before every file was read.</p>{{end}}
+<div class="cards">
+{{range .Cards}}<div class="card"><div class="label">{{.Label}}</div><div class="value">{{.Value}}</div></div>
+{{end}}</div>
+<input type="search" id="filter" placeholder="Filter folders and duplicate sets...">
+{{if .Folders}}<h2>Per-Folder Breakdown</h2>
+<table class="sortable">
+<thead><tr><th>Path</th><th>Data</th><th>Files</th><th>Rows</th><th>Keys Found</th><th>Duplicate IDs</th><th>Duplicate Rows</th></tr></thead>
+<tbody>
+{{range .Folders}}<tr class="filterable"><td>{{.Path}}</td><td class="num" data-sort="{{.Bytes}}">{{.Data}}</td><td class="num">{{.Files}}</td><td class="num">{{.Rows}}</td><td class="num">{{.Keys}}</td><td class="num">{{.DupeIDs}}</td><td class="num">{{.DupeRows}}</td></tr>
+{{end}}</tbody>
+</table>
+{{end}}`))
+
+var htmlSectionTemplate = template.Must(template.New("section").Parse(`<h2>{{.}}</h2>
+`))
+
+var htmlOmittedTemplate = template.Must(template.New("omitted").Parse(`<p class="omitted">... {{.}} more duplicate set(s) omitted</p>
+`))
+
+var htmlSetTemplate = template.Must(template.New("set").Parse(`<details class="filterable"><summary>{{.Label}} <code>{{.Value}}</code> ({{.Count}} occurrences)</summary><ul>
+{{range .Locations}}<li>{{.FilePath}}:{{.LineNumber}}</li>
+{{end}}{{if .Omitted}}<li class="omitted">... {{.Omitted}} more location(s) omitted</li>
+{{end}}</ul></details>
+`))
+
+const htmlFoot = `<script>
+document.getElementById('filter').addEventListener('input', function (e) {
+  var q = e.target.value.toLowerCase();
+  document.querySelectorAll('.filterable').forEach(function (el) {
+    el.style.display = el.textContent.toLowerCase().indexOf(q) === -1 ? 'none' : '';
+  });
+});
+document.querySelectorAll('table.sortable th').forEach(function (th, col) {
+  th.addEventListener('click', function () {
+    var tbody = th.closest('table').tBodies[0];
+    var asc = th.dataset.dir !== 'asc';
+    th.dataset.dir = asc ? 'asc' : 'desc';
+    var key = function (row) {
+      var cell = row.cells[col];
+      var v = cell.dataset.sort || cell.textContent;
+      return cell.classList.contains('num') ? parseFloat(v) : v.toLowerCase();
+    };
+    Array.from(tbody.rows).sort(function (a, b) {
+      var x = key(a), y = key(b);
+      return (x < y ? -1 : x > y ? 1 : 0) * (asc ? 1 : -1);
+    }).forEach(function (row) { tbody.appendChild(row); });
+  });
+});
+</script>
+</body>
+</html>
+`
+
+// WriteHTML writes the report as a single self-contained HTML page with
+// summary cards, a sortable folder table and collapsible duplicate sets, all
+// filterable from a search box. It needs no network access to view, so it can
+// be attached to tickets as is.
+func (r *AnalysisReport) WriteHTML(w io.Writer, checkKey, checkRow bool) error {
+	s := r.Summary
+	bw := bufio.NewWriterSize(w, 256*1024)
+
+	cards := []htmlCard{
+		{"Files Analysed", fmt.Sprintf("%d", s.FilesProcessed)},
+		{"Data Analysed", s.ProcessedDataSizeHuman},
+		{"Rows Processed", fmt.Sprintf("%d", s.TotalRowsProcessed)},
+		{"Elapsed Time", s.TotalElapsedTime},
+	}
+	if checkKey {
+		cards = append(cards, htmlCard{fmt.Sprintf("Duplicated '%s' Values", s.UniqueKey), fmt.Sprintf("%d", s.UniqueKeysDuplicated)})
+		for _, key := range sortedKeys(s.AdditionalKeys) {
+			cards = append(cards, htmlCard{fmt.Sprintf("Duplicated %s Values", s.AdditionalKeys[key].Label(key)), fmt.Sprintf("%d", s.AdditionalKeys[key].UniqueKeysDuplicated)})
+		}
+	}
+	if checkRow {
+		cards = append(cards, htmlCard{"Duplicate Row Instances", fmt.Sprintf("%d", s.DuplicateRowInstances)})
+	}
+
+	var folders []htmlFolder
+	for _, path := range sortedKeys(s.FolderDetails) {
+		detail := s.FolderDetails[path]
+		folders = append(folders, htmlFolder{
+			Path:     path,
+			Data:     HumanSize(detail.ProcessedSizeBytes),
+			Bytes:    detail.ProcessedSizeBytes,
+			Files:    detail.FilesProcessed,
+			Rows:     detail.RowsProcessed,
+			Keys:     detail.KeysFound,
+			DupeIDs:  s.DuplicateIDsPerFolder[path],
+			DupeRows: s.DuplicateRowsPerFolder[path],
+		})
+	}
+
+	if err := htmlHeadTemplate.Execute(bw, map[string]any{
+		"Title":   "Duplicate Analysis Report",
+		"Partial": s.IsPartialReport,
+		"Cards":   cards,
+		"Folders": folders,
+	}); err != nil {
+		return err
+	}
+
+	// Duplicate sets are rendered one at a time so that large reports are
+	// streamed to w rather than built in memory.
+	var err error
+	writeSets := func(title, label string, dupes map[string][]LocationInfo) {
+		if err != nil || len(dupes) == 0 {
+			return
+		}
+		if err = htmlSectionTemplate.Execute(bw, title); err != nil {
+			return
+		}
+		values, omittedSets := r.Limits.sets(dupes)
+		for _, value := range values {
+			locs, omitted := r.Limits.locations(dupes[value])
+			if err = htmlSetTemplate.Execute(bw, htmlSet{Label: label, Value: value, Count: len(dupes[value]), Locations: locs, Omitted: omitted}); err != nil {
+				return
+			}
+		}
+		if omittedSets > 0 {
+			err = htmlOmittedTemplate.Execute(bw, omittedSets)
+		}
+	}
+	writeScope := func(suffix string, ids, rows map[string][]LocationInfo, idsByKey map[string]map[string][]LocationInfo) {
+		if checkKey {
+			writeSets(fmt.Sprintf("Duplicate '%s' Values%s", s.UniqueKey, suffix), s.UniqueKey, ids)
+			for _, key := range sortedKeys(idsByKey) {
+				writeSets(fmt.Sprintf("Duplicate %s Values%s", s.AdditionalKeys[key].Label(key), suffix), key, idsByKey[key])
+			}
+		}
+		if checkRow {
+			writeSets("Duplicate Rows"+suffix, "Row hash", rows)
+		}
+	}
+	writeScope("", r.DuplicateIDs, r.DuplicateRows, r.DuplicateIDsByKey)
+	for _, scope := range sortedKeys(r.Scopes) {
+		sr := r.Scopes[scope]
+		writeScope(" in "+scope, sr.DuplicateIDs, sr.DuplicateRows, sr.DuplicateIDsByKey)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := bw.WriteString(htmlFoot); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
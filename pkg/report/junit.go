@@ -0,0 +1,126 @@
+// pkg/report/junit.go
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// junitExamples is the number of duplicate values listed in each failure.
+const junitExamples = 20
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as JUnit XML for CI test reporters. Every
+// uniqueness check, the primary key, each additional key or constraint and
+// the row check, is a test case that fails with its duplicate count and a
+// sample of the duplicated values.
+func (r *AnalysisReport) WriteJUnit(w io.Writer, checkKey, checkRow bool) error {
+	s := r.Summary
+	suite := junitTestSuite{Name: "uniqueness"}
+	if elapsed, err := time.ParseDuration(s.TotalElapsedTime); err == nil {
+		suite.Time = fmt.Sprintf("%.3f", elapsed.Seconds())
+	}
+
+	addCase := func(name, failureType string, count int, message string, examples []string) {
+		tc := junitTestCase{ClassName: "dupe-analyser.uniqueness", Name: name}
+		if count > 0 {
+			tc.Failure = &junitFailure{Message: message, Type: failureType, Body: strings.Join(examples, "\n")}
+			suite.Failures++
+		}
+		suite.Tests++
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	if checkKey {
+		addCase(s.UniqueKey, "DuplicateKey", s.UniqueKeysDuplicated,
+			fmt.Sprintf("%d values of '%s' are duplicated", s.UniqueKeysDuplicated, s.UniqueKey),
+			r.junitExamples(func(sr *ScopeReport) map[string][]LocationInfo { return sr.DuplicateIDs }))
+		for _, key := range sortedKeys(s.AdditionalKeys) {
+			ks := s.AdditionalKeys[key]
+			addCase(key, "DuplicateKey", ks.UniqueKeysDuplicated,
+				fmt.Sprintf("%d values of %s are duplicated", ks.UniqueKeysDuplicated, ks.Label(key)),
+				r.junitExamples(func(sr *ScopeReport) map[string][]LocationInfo { return sr.DuplicateIDsByKey[key] }))
+		}
+	}
+	if checkRow {
+		addCase("duplicate rows", "DuplicateRow", s.DuplicateRowInstances,
+			fmt.Sprintf("%d duplicate row instances found", s.DuplicateRowInstances),
+			r.junitExamples(func(sr *ScopeReport) map[string][]LocationInfo { return sr.DuplicateRows }))
+	}
+
+	suites := junitTestSuites{
+		Name:     "dupe-analyser",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// junitExamples lists up to junitExamples duplicate values, with their
+// locations, for one check. The report's global sets are used first, then
+// the sets of each scope.
+func (r *AnalysisReport) junitExamples(sets func(*ScopeReport) map[string][]LocationInfo) []string {
+	var examples []string
+	add := func(scope string, dupes map[string][]LocationInfo) {
+		for _, value := range sortedKeys(dupes) {
+			if len(examples) == junitExamples {
+				return
+			}
+			locs := make([]string, len(dupes[value]))
+			for i, loc := range dupes[value] {
+				locs[i] = loc.Key()
+			}
+			prefix := ""
+			if scope != "" {
+				prefix = "[" + scope + "] "
+			}
+			examples = append(examples, fmt.Sprintf("%s%s: %s", prefix, value, strings.Join(locs, ", ")))
+		}
+	}
+	add("", sets(&ScopeReport{DuplicateIDs: r.DuplicateIDs, DuplicateRows: r.DuplicateRows, DuplicateIDsByKey: r.DuplicateIDsByKey}))
+	for _, scope := range sortedKeys(r.Scopes) {
+		add(scope, sets(r.Scopes[scope]))
+	}
+	return examples
+}
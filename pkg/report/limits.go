@@ -0,0 +1,49 @@
+// pkg/report/limits.go
+package report
+
+import (
+	"fmt"
+	"io"
+)
+
+// DetailLimits caps how much of each duplicate detail section the text and
+// HTML reports list, as the full details of pathological datasets can run to
+// gigabytes. Zero means no limit. The JSON report and the exports are always
+// complete.
+type DetailLimits struct {
+	// MaxSets is the number of duplicate sets listed per section.
+	MaxSets int
+	// MaxLocationsPerSet is the number of locations listed per set.
+	MaxLocationsPerSet int
+}
+
+// sets returns the sorted values of dupes to list and how many were left out.
+func (l DetailLimits) sets(dupes map[string][]LocationInfo) ([]string, int) {
+	values := sortedKeys(dupes)
+	if l.MaxSets > 0 && len(values) > l.MaxSets {
+		return values[:l.MaxSets], len(values) - l.MaxSets
+	}
+	return values, 0
+}
+
+// locations returns the locations of a set to list and how many were left out.
+func (l DetailLimits) locations(locs []LocationInfo) ([]LocationInfo, int) {
+	if l.MaxLocationsPerSet > 0 && len(locs) > l.MaxLocationsPerSet {
+		return locs[:l.MaxLocationsPerSet], len(locs) - l.MaxLocationsPerSet
+	}
+	return locs, 0
+}
+
+// writeOmittedSets notes the sets of a section left out by the limits.
+func writeOmittedSets(w io.Writer, omitted int) {
+	if omitted > 0 {
+		fmt.Fprintf(w, "\n... %d more duplicate set(s) omitted\n", omitted)
+	}
+}
+
+// writeOmittedLocations notes the locations of a set left out by the limits.
+func writeOmittedLocations(w io.Writer, omitted int) {
+	if omitted > 0 {
+		fmt.Fprintf(w, "  ... %d more location(s) omitted\n", omitted)
+	}
+}
@@ -0,0 +1,95 @@
+// pkg/report/markdown.go
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteMarkdown writes the summary and, when enabled, the per-folder
+// breakdown as GitHub-flavoured Markdown tables that can be pasted into pull
+// request comments as is. Duplicate details are left to the other formats.
+func (r *AnalysisReport) WriteMarkdown(w io.Writer, checkKey, checkRow, showFolderBreakdown bool) error {
+	s := r.Summary
+	bw := bufio.NewWriter(w)
+
+	bw.WriteString("## Duplicate Analysis Report\n\n")
+	if s.IsPartialReport {
+		bw.WriteString("> **Partial report:** the analysis was stopped before every file was read.\n\n")
+	}
+
+	filesAnalysed := fmt.Sprintf("%d", s.FilesProcessed)
+	dataAnalysed := s.ProcessedDataSizeHuman
+	if s.IsPartialReport {
+		filesAnalysed = fmt.Sprintf("%d of %d", s.FilesProcessed, s.TotalFiles)
+		dataAnalysed = fmt.Sprintf("%s of %s", s.ProcessedDataSizeHuman, s.TotalDataSizeOverallHuman)
+	}
+	rows := [][]string{
+		{"Total Elapsed Time", s.TotalElapsedTime},
+		{"Total Files Analysed", filesAnalysed},
+		{"Total Data Analysed", dataAnalysed},
+		{"Total Rows Processed", fmt.Sprintf("%d", s.TotalRowsProcessed)},
+	}
+	if s.Scope != "" && s.Scope != "global" {
+		rows = append(rows, []string{"Duplicate Scope", "per " + s.Scope})
+	}
+	if checkKey {
+		rows = append(rows,
+			[]string{fmt.Sprintf("Total Occurrences of '%s'", s.UniqueKey), fmt.Sprintf("%d", s.TotalKeyOccurrences)},
+			[]string{fmt.Sprintf("Unique '%s' Values with Duplicates", s.UniqueKey), fmt.Sprintf("%d", s.UniqueKeysDuplicated)},
+		)
+		for _, key := range sortedKeys(s.AdditionalKeys) {
+			ks := s.AdditionalKeys[key]
+			rows = append(rows, []string{fmt.Sprintf("Unique %s Values with Duplicates", ks.Label(key)), fmt.Sprintf("%d", ks.UniqueKeysDuplicated)})
+		}
+	}
+	if checkRow {
+		rows = append(rows, []string{"Total Duplicate Row Instances", fmt.Sprintf("%d", s.DuplicateRowInstances)})
+	}
+	if s.OversizedLinesSkipped > 0 {
+		rows = append(rows, []string{"Oversized Lines Skipped", fmt.Sprintf("%d", s.OversizedLinesSkipped)})
+	}
+	if s.ParseErrors > 0 {
+		rows = append(rows, []string{"Lines Not Valid JSON", fmt.Sprintf("%d", s.ParseErrors)})
+	}
+	writeMarkdownTable(bw, []string{"Metric", "Value"}, rows)
+
+	if showFolderBreakdown && len(s.FolderDetails) > 0 {
+		rows = rows[:0]
+		for _, path := range sortedKeys(s.FolderDetails) {
+			detail := s.FolderDetails[path]
+			rows = append(rows, []string{
+				path,
+				HumanSize(detail.ProcessedSizeBytes),
+				fmt.Sprintf("%d", detail.FilesProcessed),
+				fmt.Sprintf("%d", detail.RowsProcessed),
+				fmt.Sprintf("%d", detail.KeysFound),
+				fmt.Sprintf("%d", s.DuplicateIDsPerFolder[path]),
+				fmt.Sprintf("%d", s.DuplicateRowsPerFolder[path]),
+			})
+		}
+		bw.WriteString("\n### Per-Folder Breakdown\n\n")
+		writeMarkdownTable(bw, []string{"Path", "Data", "Files", "Rows", "Keys Found", "Duplicate IDs", "Duplicate Rows"}, rows)
+	}
+	return bw.Flush()
+}
+
+// writeMarkdownTable writes a GitHub-flavoured Markdown table, escaping any
+// pipes in the cells.
+func writeMarkdownTable(w *bufio.Writer, headers []string, rows [][]string) {
+	escape := strings.NewReplacer("|", `\|`, "\n", " ")
+	writeRow := func(cells []string) {
+		w.WriteString("|")
+		for _, cell := range cells {
+			w.WriteString(" " + escape.Replace(cell) + " |")
+		}
+		w.WriteString("\n")
+	}
+	writeRow(headers)
+	w.WriteString("|" + strings.Repeat(" --- |", len(headers)) + "\n")
+	for _, row := range rows {
+		writeRow(row)
+	}
+}
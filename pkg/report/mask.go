@@ -0,0 +1,147 @@
+// pkg/report/mask.go
+package report
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Key masking modes. MaskHash replaces each key value with a salted hash, so
+// equal values stay recognisably equal; MaskPartial keeps only the first and
+// last characters of each value and the domain of an email address.
+const (
+	MaskHash    = "hash"
+	MaskPartial = "partial"
+)
+
+// MaskSaltEnv is the environment variable holding the salt for MaskHash.
+// Reports hashed with the same salt can be matched against each other.
+const MaskSaltEnv = "DUPE_ANALYSER_MASK_SALT"
+
+// ValidMaskMode reports whether mode is a key masking mode.
+func ValidMaskMode(mode string) bool {
+	return mode == MaskHash || mode == MaskPartial
+}
+
+// KeyMasker replaces key values in reports so they can be shared without
+// exposing the values themselves.
+type KeyMasker struct {
+	mode string
+	salt []byte
+}
+
+// NewKeyMasker returns a masker for mode. An empty salt is replaced with a
+// random one, so hashed values can then only be matched within one run.
+func NewKeyMasker(mode, salt string) (*KeyMasker, error) {
+	if !ValidMaskMode(mode) {
+		return nil, fmt.Errorf("invalid key mask mode %q: must be %q or %q", mode, MaskHash, MaskPartial)
+	}
+	m := &KeyMasker{mode: mode, salt: []byte(salt)}
+	if len(m.salt) == 0 {
+		m.salt = make([]byte, 32)
+		if _, err := rand.Read(m.salt); err != nil {
+			return nil, fmt.Errorf("could not generate a salt: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// Mask returns the masked form of a single key value.
+func (m *KeyMasker) Mask(value string) string {
+	if m.mode == MaskHash {
+		mac := hmac.New(sha256.New, m.salt)
+		mac.Write([]byte(value))
+		return "sha256:" + hex.EncodeToString(mac.Sum(nil))[:16]
+	}
+	if local, domain, ok := strings.Cut(value, "@"); ok && local != "" && domain != "" {
+		return maskPartial(local) + "@" + domain
+	}
+	return maskPartial(value)
+}
+
+// maskPartial keeps the first and last runes of s, replacing the rest with a
+// fixed run of asterisks so the length of the value is not revealed. Values
+// too short to keep anything are masked entirely.
+func maskPartial(s string) string {
+	if utf8.RuneCountInString(s) <= 4 {
+		return "****"
+	}
+	first, _ := utf8.DecodeRuneInString(s)
+	last, _ := utf8.DecodeLastRuneInString(s)
+	return string(first) + "***" + string(last)
+}
+
+// maskKeys returns m with every key masked. Masked values that collide, which
+// partial masks can, are numbered so no entry is lost.
+func maskKeys[V any](masker *KeyMasker, m map[string]V) map[string]V {
+	if m == nil {
+		return nil
+	}
+	masked := make(map[string]V, len(m))
+	for _, key := range sortedKeys(m) {
+		name := masker.Mask(key)
+		for i := 2; ; i++ {
+			if _, taken := masked[name]; !taken {
+				break
+			}
+			name = fmt.Sprintf("%s #%d", masker.Mask(key), i)
+		}
+		masked[name] = m[key]
+	}
+	return masked
+}
+
+// MaskKeys replaces the key values in the report, leaving every file and line
+// location intact. Record samples are dropped, as the records contain the
+// values. Row duplicates are keyed by hash already and are left alone.
+func (r *AnalysisReport) MaskKeys(masker *KeyMasker) {
+	r.DuplicateIDs = maskKeys(masker, r.DuplicateIDs)
+	r.SingletonIDs = maskKeys(masker, r.SingletonIDs)
+	for key, sets := range r.DuplicateIDsByKey {
+		r.DuplicateIDsByKey[key] = maskKeys(masker, sets)
+	}
+	for key, singles := range r.SingletonIDsByKey {
+		r.SingletonIDsByKey[key] = maskKeys(masker, singles)
+	}
+	for _, sr := range r.Scopes {
+		sr.DuplicateIDs = maskKeys(masker, sr.DuplicateIDs)
+		for key, sets := range sr.DuplicateIDsByKey {
+			sr.DuplicateIDsByKey[key] = maskKeys(masker, sets)
+		}
+	}
+	for i, cluster := range r.FuzzyClusters {
+		for j, value := range cluster.Values {
+			r.FuzzyClusters[i].Values[j] = masker.Mask(value)
+		}
+	}
+
+	keyFields := map[string]bool{r.Summary.UniqueKey: true}
+	for _, ks := range r.Summary.AdditionalKeys {
+		for _, field := range ks.Fields {
+			keyFields[field] = true
+		}
+	}
+	for _, fp := range r.Profile {
+		if keyFields[fp.Field] {
+			for i, vc := range fp.TopValues {
+				fp.TopValues[i].Value = masker.Mask(vc.Value)
+			}
+		}
+	}
+	r.RecordSamples = nil
+	r.Summary.KeysMasked = masker.mode
+}
+
+// MaskKeys replaces the key values in the comparison, leaving every file and
+// line location intact.
+func (r *ComparisonReport) MaskKeys(masker *KeyMasker) {
+	r.InBoth = maskKeys(masker, r.InBoth)
+	r.OnlyLeft = maskKeys(masker, r.OnlyLeft)
+	r.OnlyRight = maskKeys(masker, r.OnlyRight)
+	r.Summary.KeysMasked = masker.mode
+}
@@ -0,0 +1,297 @@
+// pkg/report/merge.go
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Merge combines the reports of several runs over disjoint parts of a
+// dataset, such as per-folder shards, into one report. Duplicate sets with
+// the same key and value are combined across shards and the duplicate counts
+// are recomputed from the combined sets; the other summary figures are
+// summed. Every report must check the same key in the same scope.
+//
+// Reports from shard runs also list the values they saw only once, so a value
+// seen once in each of two shards becomes a duplicate set. Without them such
+// duplicates cannot be found, and the returned warnings say so, along with
+// anything else that was dropped.
+func Merge(reports []*AnalysisReport) (*AnalysisReport, []string, error) {
+	if len(reports) == 0 {
+		return nil, nil, fmt.Errorf("no reports to merge")
+	}
+	first := reports[0].Summary
+	if first.IsKeyDiscoveryReport {
+		return nil, nil, fmt.Errorf("key discovery reports cannot be merged")
+	}
+	merged := &AnalysisReport{
+		DuplicateIDs:  make(map[string][]LocationInfo),
+		DuplicateRows: make(map[string][]LocationInfo),
+	}
+	s := &merged.Summary
+	s.IsValidationReport = first.IsValidationReport
+	s.UniqueKey = first.UniqueKey
+	s.KeyChecked, s.RowChecked = first.KeyChecked, first.RowChecked
+	s.Scope = first.Scope
+	s.FolderDetails = make(map[string]FolderDetail)
+	s.IsShardReport = true
+
+	var warnings []string
+	var elapsed time.Duration
+	droppedExtras := false
+	for i, r := range reports {
+		rs := r.Summary
+		if rs.UniqueKey != s.UniqueKey || rs.Scope != s.Scope || rs.IsValidationReport != s.IsValidationReport || rs.IsKeyDiscoveryReport {
+			return nil, nil, fmt.Errorf("report %d does not match the first report: every shard must be the same kind of run on key '%s' with scope '%s'", i+1, s.UniqueKey, s.Scope)
+		}
+		for _, input := range rs.Inputs {
+			if !slices.Contains(s.Inputs, input) {
+				s.Inputs = append(s.Inputs, input)
+			}
+		}
+		s.IsPartialReport = s.IsPartialReport || rs.IsPartialReport
+		s.IsShardReport = s.IsShardReport && rs.IsShardReport
+		s.FilesProcessed += rs.FilesProcessed
+		s.TotalFiles += rs.TotalFiles
+		s.ProcessedDataSizeBytes += rs.ProcessedDataSizeBytes
+		s.TotalDataSizeOverallBytes += rs.TotalDataSizeOverallBytes
+		s.TotalRowsProcessed += rs.TotalRowsProcessed
+		s.TotalKeyOccurrences += rs.TotalKeyOccurrences
+		s.OversizedLinesSkipped += rs.OversizedLinesSkipped
+		s.ParseErrors += rs.ParseErrors
+		s.CachedFiles += rs.CachedFiles
+		if d, err := time.ParseDuration(rs.TotalElapsedTime); err == nil && d > elapsed {
+			elapsed = d
+		}
+		for key, ks := range rs.AdditionalKeys {
+			if s.AdditionalKeys == nil {
+				s.AdditionalKeys = make(map[string]KeySummary)
+			}
+			sum := s.AdditionalKeys[key]
+			sum.Fields = ks.Fields
+			sum.PrimaryKey = ks.PrimaryKey
+			sum.TotalKeyOccurrences += ks.TotalKeyOccurrences
+			s.AdditionalKeys[key] = sum
+		}
+		for path, d := range rs.FolderDetails {
+			m := s.FolderDetails[path]
+			m.ProcessedSizeBytes += d.ProcessedSizeBytes
+			m.TotalSizeBytes += d.TotalSizeBytes
+			m.FilesProcessed += d.FilesProcessed
+			m.TotalFiles += d.TotalFiles
+			m.KeysFound += d.KeysFound
+			m.RowsProcessed += d.RowsProcessed
+			m.ParseErrors += d.ParseErrors
+			s.FolderDetails[path] = m
+		}
+
+		mergeSets(merged.DuplicateIDs, r.DuplicateIDs)
+		mergeSets(merged.DuplicateRows, r.DuplicateRows)
+		merged.DuplicateIDsByKey = mergeKeySets(merged.DuplicateIDsByKey, r.DuplicateIDsByKey)
+		mergeSingletons(merged.DuplicateIDs, r.SingletonIDs)
+		mergeSingletons(merged.DuplicateRows, r.SingletonRows)
+		for key, singles := range r.SingletonIDsByKey {
+			if merged.DuplicateIDsByKey == nil {
+				merged.DuplicateIDsByKey = make(map[string]map[string][]LocationInfo)
+			}
+			if merged.DuplicateIDsByKey[key] == nil {
+				merged.DuplicateIDsByKey[key] = make(map[string][]LocationInfo)
+			}
+			mergeSingletons(merged.DuplicateIDsByKey[key], singles)
+		}
+		for name, sr := range r.Scopes {
+			if merged.Scopes == nil {
+				merged.Scopes = make(map[string]*ScopeReport)
+			}
+			msr := merged.Scopes[name]
+			if msr == nil {
+				msr = &ScopeReport{DuplicateIDs: make(map[string][]LocationInfo), DuplicateRows: make(map[string][]LocationInfo)}
+				merged.Scopes[name] = msr
+			}
+			mergeSets(msr.DuplicateIDs, sr.DuplicateIDs)
+			mergeSets(msr.DuplicateRows, sr.DuplicateRows)
+			msr.DuplicateIDsByKey = mergeKeySets(msr.DuplicateIDsByKey, sr.DuplicateIDsByKey)
+		}
+		for k, v := range r.RecordSamples {
+			if merged.RecordSamples == nil {
+				merged.RecordSamples = make(map[string]json.RawMessage, len(r.RecordSamples))
+			}
+			merged.RecordSamples[k] = v
+		}
+		for path, n := range r.OversizedLines {
+			if merged.OversizedLines == nil {
+				merged.OversizedLines = make(map[string]int64)
+			}
+			merged.OversizedLines[path] += n
+		}
+		for path, n := range r.ParseErrors {
+			if merged.ParseErrors == nil {
+				merged.ParseErrors = make(map[string]int64)
+			}
+			merged.ParseErrors[path] += n
+		}
+		for path, d := range r.FileDetails {
+			if merged.FileDetails == nil {
+				merged.FileDetails = make(map[string]FileDetail)
+			}
+			if !merged.FileDetails[path].Processed {
+				merged.FileDetails[path] = d
+			}
+		}
+		if len(r.FuzzyClusters) > 0 || len(r.Profile) > 0 {
+			droppedExtras = true
+		}
+	}
+
+	// Values still seen only once are not duplicates. They are kept only when
+	// every input was a shard, so the merged report can itself be merged.
+	merged.SingletonIDs = splitSingletons(merged.DuplicateIDs)
+	merged.SingletonRows = splitSingletons(merged.DuplicateRows)
+	for key, sets := range merged.DuplicateIDsByKey {
+		if singles := splitSingletons(sets); len(singles) > 0 {
+			if merged.SingletonIDsByKey == nil {
+				merged.SingletonIDsByKey = make(map[string]map[string]LocationInfo)
+			}
+			merged.SingletonIDsByKey[key] = singles
+		}
+	}
+	if !s.IsShardReport {
+		merged.SingletonIDs, merged.SingletonRows, merged.SingletonIDsByKey = nil, nil, nil
+	}
+
+	s.TotalElapsedTime = elapsed.String()
+	s.ProcessedDataSizeHuman = HumanSize(s.ProcessedDataSizeBytes)
+	s.TotalDataSizeOverallHuman = HumanSize(s.TotalDataSizeOverallBytes)
+
+	// The duplicate counts are rebuilt from the combined sets, the same way
+	// the analyser derives them, as sets spanning shards are counted once.
+	s.DuplicateIDsPerFolder = make(map[string]int)
+	s.DuplicateRowsPerFolder = make(map[string]int)
+	for path, d := range merged.FileDetails {
+		d.DuplicateIDs, d.DuplicateRows = 0, 0
+		merged.FileDetails[path] = d
+	}
+	countFile := func(path string, kind string) {
+		d, ok := merged.FileDetails[path]
+		if !ok {
+			return
+		}
+		if kind == "row" {
+			d.DuplicateRows++
+		} else {
+			d.DuplicateIDs++
+		}
+		merged.FileDetails[path] = d
+	}
+	merged.EachDuplicateSet(true, true, func(kind, key, scope, value string, locs []LocationInfo) {
+		switch {
+		case kind == "row":
+			s.DuplicateRowInstances += len(locs)
+			for _, loc := range locs {
+				s.DuplicateRowsPerFolder[filepath.Dir(loc.FilePath)]++
+				countFile(loc.FilePath, kind)
+			}
+		case key == s.UniqueKey:
+			s.UniqueKeysDuplicated++
+			for _, loc := range locs {
+				s.DuplicateIDsPerFolder[filepath.Dir(loc.FilePath)]++
+				countFile(loc.FilePath, kind)
+			}
+		default:
+			if s.AdditionalKeys == nil {
+				s.AdditionalKeys = make(map[string]KeySummary)
+			}
+			ks := s.AdditionalKeys[key]
+			ks.UniqueKeysDuplicated++
+			s.AdditionalKeys[key] = ks
+		}
+	})
+
+	for key, ks := range s.AdditionalKeys {
+		if ks.PrimaryKey {
+			ks.UniqueKeysDuplicated = s.UniqueKeysDuplicated
+			s.AdditionalKeys[key] = ks
+		}
+	}
+
+	merged.BuildMultiplicity()
+	merged.Normalise()
+
+	if s.FilesProcessed > 0 {
+		s.AverageRowsPerFile = float64(s.TotalRowsProcessed) / float64(s.FilesProcessed)
+	}
+	if len(s.FolderDetails) > 0 {
+		s.AverageFilesPerFolder = float64(s.TotalFiles) / float64(len(s.FolderDetails))
+	}
+
+	if len(reports) > 1 && !s.IsShardReport && s.Scope == "global" {
+		warnings = append(warnings, "some reports were not produced with -shard, so values seen only once in each of several shards are not reported as duplicates")
+	}
+	if droppedExtras {
+		warnings = append(warnings, "fuzzy clusters and field profiles cannot be combined across shards and were left out")
+	}
+	return merged, warnings, nil
+}
+
+// mergeSets adds the locations of every set in src to dst, skipping
+// locations already present so that overlapping shards are not counted twice.
+func mergeSets(dst, src map[string][]LocationInfo) {
+	for value, locs := range src {
+		existing := dst[value]
+		seen := make(map[LocationInfo]bool, len(existing))
+		for _, loc := range existing {
+			seen[loc] = true
+		}
+		for _, loc := range locs {
+			if !seen[loc] {
+				seen[loc] = true
+				existing = append(existing, loc)
+			}
+		}
+		dst[value] = existing
+	}
+}
+
+// mergeSingletons adds each singleton in src to the sets in dst.
+func mergeSingletons(dst map[string][]LocationInfo, src map[string]LocationInfo) {
+	for value, loc := range src {
+		if locs := dst[value]; !slices.Contains(locs, loc) {
+			dst[value] = append(locs, loc)
+		}
+	}
+}
+
+// splitSingletons removes the sets with a single location from sets and
+// returns them as singletons.
+func splitSingletons(sets map[string][]LocationInfo) map[string]LocationInfo {
+	var singles map[string]LocationInfo
+	for value, locs := range sets {
+		if len(locs) != 1 {
+			continue
+		}
+		if singles == nil {
+			singles = make(map[string]LocationInfo)
+		}
+		singles[value] = locs[0]
+		delete(sets, value)
+	}
+	return singles
+}
+
+// mergeKeySets merges the per-key sets of src into dst, allocating dst when
+// needed, and returns it.
+func mergeKeySets(dst, src map[string]map[string][]LocationInfo) map[string]map[string][]LocationInfo {
+	for key, sets := range src {
+		if dst == nil {
+			dst = make(map[string]map[string][]LocationInfo)
+		}
+		if dst[key] == nil {
+			dst[key] = make(map[string][]LocationInfo)
+		}
+		mergeSets(dst[key], sets)
+	}
+	return dst
+}
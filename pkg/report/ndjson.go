@@ -0,0 +1,105 @@
+// pkg/report/ndjson.go
+package report
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+// DuplicateRecord is one location of one duplicate set, as written to the
+// NDJSON export.
+type DuplicateRecord struct {
+	Type  string `json:"type"`
+	Key   string `json:"key,omitempty"`
+	Value string `json:"value"`
+	Scope string `json:"scope,omitempty"`
+	File  string `json:"file"`
+	Line  int    `json:"line"`
+	Count int    `json:"count"`
+}
+
+// WriteNDJSON writes every duplicate location as a line-delimited JSON
+// record, with the same fields as the CSV report, for loading into a
+// warehouse where the sets can be joined against other data.
+func (r *AnalysisReport) WriteNDJSON(w io.Writer, checkKey, checkRow bool) error {
+	bw := bufio.NewWriterSize(w, 256*1024)
+	enc := json.NewEncoder(bw)
+	if err := r.EachDuplicateRecord(checkKey, checkRow, func(rec DuplicateRecord) error {
+		return enc.Encode(rec)
+	}); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// EachDuplicateRecord calls fn for every duplicate location in the report, in
+// the same order as the NDJSON export, stopping at the first error.
+func (r *AnalysisReport) EachDuplicateRecord(checkKey, checkRow bool, fn func(DuplicateRecord) error) error {
+	var err error
+	r.EachDuplicateSet(checkKey, checkRow, func(kind, key, scope, value string, locs []LocationInfo) {
+		for _, loc := range locs {
+			if err != nil {
+				return
+			}
+			err = fn(DuplicateRecord{Type: kind, Key: key, Value: value, Scope: scope, File: loc.FilePath, Line: loc.LineNumber, Count: len(locs)})
+		}
+	})
+	return err
+}
+
+// EachDuplicateSet calls fn for every duplicate set in the report, in a stable
+// order: global sets before per-scope ones, and within each, the primary key,
+// the additional keys, then rows. Kind is "id" or "row", and key is empty for
+// rows.
+func (r *AnalysisReport) EachDuplicateSet(checkKey, checkRow bool, fn func(kind, key, scope, value string, locs []LocationInfo)) {
+	sets := func(kind, key, scope string, dupes map[string][]LocationInfo) {
+		for _, value := range sortedKeys(dupes) {
+			fn(kind, key, scope, value, dupes[value])
+		}
+	}
+	scoped := func(scope string, ids, rows map[string][]LocationInfo, idsByKey map[string]map[string][]LocationInfo) {
+		if checkKey {
+			sets("id", r.Summary.UniqueKey, scope, ids)
+			for _, key := range sortedKeys(idsByKey) {
+				sets("id", key, scope, idsByKey[key])
+			}
+		}
+		if checkRow {
+			sets("row", "", scope, rows)
+		}
+	}
+	scoped("", r.DuplicateIDs, r.DuplicateRows, r.DuplicateIDsByKey)
+	for _, scope := range sortedKeys(r.Scopes) {
+		sr := r.Scopes[scope]
+		scoped(scope, sr.DuplicateIDs, sr.DuplicateRows, sr.DuplicateIDsByKey)
+	}
+}
+
+// ExcessOccurrences returns, for each enabled check, the number of records
+// that repeat a value seen earlier: every occurrence of a duplicate set but
+// the first. Key checks are named by their key and the row check is "rows".
+func (r *AnalysisReport) ExcessOccurrences(checkKey, checkRow bool) map[string]int {
+	excess := make(map[string]int)
+	if checkKey {
+		excess[r.Summary.UniqueKey] = 0
+		for key := range r.Summary.AdditionalKeys {
+			excess[key] = 0
+		}
+	}
+	if checkRow {
+		excess["rows"] = 0
+	}
+	r.EachDuplicateSet(checkKey, checkRow, func(kind, key, scope, value string, locs []LocationInfo) {
+		if kind == "row" {
+			key = "rows"
+		}
+		excess[key] += len(locs) - 1
+	})
+	for key, ks := range r.Summary.AdditionalKeys {
+		if ks.PrimaryKey && checkKey {
+			excess[key] = excess[r.Summary.UniqueKey]
+		}
+	}
+	return excess
+}
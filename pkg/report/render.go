@@ -0,0 +1,76 @@
+// pkg/report/render.go
+package report
+
+import (
+	"fmt"
+	"io"
+	"text/template"
+)
+
+// Formats are the formats Render writes, in the order they are listed to
+// users.
+var Formats = []string{"txt", "json", "csv", "html", "md", "sarif", "junit", "ndjson", "sql"}
+
+// formatContentTypes are the media types of the Formats, for serving them.
+var formatContentTypes = map[string]string{
+	"txt":    "text/plain; charset=utf-8",
+	"json":   "application/json",
+	"csv":    "text/csv; charset=utf-8",
+	"html":   "text/html; charset=utf-8",
+	"md":     "text/markdown; charset=utf-8",
+	"sarif":  "application/sarif+json",
+	"junit":  "application/xml",
+	"ndjson": "application/x-ndjson",
+	"sql":    "application/sql",
+}
+
+// ValidFormat reports whether format is one of the Formats.
+func ValidFormat(format string) bool {
+	_, ok := formatContentTypes[format]
+	return ok
+}
+
+// ContentType returns the media type of format, which must be valid.
+func ContentType(format string) string {
+	return formatContentTypes[format]
+}
+
+// Render writes the report to w in format, one of the Formats. tmpl, when
+// set, replaces the built-in layout of the txt format; the folder breakdown
+// is shown in the txt and md formats only.
+func (r *AnalysisReport) Render(w io.Writer, format string, tmpl *template.Template, checkKey, checkRow, showFolderBreakdown bool) error {
+	switch format {
+	case "txt":
+		if tmpl != nil {
+			return r.WriteTemplate(w, tmpl, true, checkKey, checkRow, showFolderBreakdown)
+		}
+		return r.WriteText(w, true, checkKey, checkRow, showFolderBreakdown)
+	case "json":
+		return r.WriteJSON(w)
+	case "csv":
+		return r.WriteCSV(w, checkKey, checkRow)
+	case "html":
+		return r.WriteHTML(w, checkKey, checkRow)
+	case "md":
+		return r.WriteMarkdown(w, checkKey, checkRow, showFolderBreakdown)
+	case "sarif":
+		return r.WriteSARIF(w, checkKey, checkRow)
+	case "junit":
+		return r.WriteJUnit(w, checkKey, checkRow)
+	case "ndjson":
+		return r.WriteNDJSON(w, checkKey, checkRow)
+	case "sql":
+		return r.WriteSQL(w, checkKey, checkRow)
+	}
+	return fmt.Errorf("unknown report format %q", format)
+}
+
+// ChecksRun returns the checks recorded in the report's summary. Reports
+// written before the checks were recorded list both.
+func (r *AnalysisReport) ChecksRun() (checkKey, checkRow bool) {
+	checkKey, checkRow = r.Summary.KeyChecked, r.Summary.RowChecked
+	if !checkKey && !checkRow {
+		return true, true
+	}
+	return checkKey, checkRow
+}
@@ -0,0 +1,887 @@
+// pkg/report/report.go
+
+// Package report holds the result of an analysis and writes it in each of
+// the report formats.
+package report
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// LocationInfo holds the location of a piece of data.
+type LocationInfo struct {
+	FilePath   string `json:"filePath"`
+	LineNumber int    `json:"lineNumber"`
+}
+
+// Key returns a compact "path:line" identifier for the location.
+func (l LocationInfo) Key() string {
+	return fmt.Sprintf("%s:%d", l.FilePath, l.LineNumber)
+}
+
+// JSONData is a generic type for a single JSON object.
+type JSONData map[string]interface{}
+
+// FolderDetail holds aggregated metrics for a single folder/prefix.
+type FolderDetail struct {
+	ProcessedSizeBytes int64 `json:"processedSizeBytes"`
+	TotalSizeBytes     int64 `json:"totalSizeBytes"`
+	FilesProcessed     int   `json:"filesProcessed"`
+	TotalFiles         int   `json:"totalFiles"`
+	KeysFound          int   `json:"keysFound"`
+	RowsProcessed      int   `json:"rowsProcessed"`
+	ParseErrors        int64 `json:"parseErrors,omitempty"`
+}
+
+// FileDetail holds the metrics of a single file, for the optional per-file
+// breakdown. DuplicateIDs and DuplicateRows count the file's locations in
+// duplicate sets.
+type FileDetail struct {
+	SizeBytes        int64 `json:"sizeBytes"`
+	Processed        bool  `json:"processed"`
+	RowsProcessed    int64 `json:"rowsProcessed"`
+	KeysFound        int64 `json:"keysFound"`
+	DuplicateIDs     int   `json:"duplicateIds"`
+	DuplicateRows    int   `json:"duplicateRows"`
+	ParseErrors      int64 `json:"parseErrors"`
+	OversizedLines   int64 `json:"oversizedLines,omitempty"`
+	ProcessingMillis int64 `json:"processingMs"`
+}
+
+// FuzzyCluster groups distinct values of the fuzzy field that were judged
+// similar enough to be probable duplicates of one another. Scope is the file
+// or folder the values were clustered within, and empty in global scope.
+type FuzzyCluster struct {
+	Scope     string         `json:"scope,omitempty"`
+	Values    []string       `json:"values"`
+	Locations []LocationInfo `json:"locations"`
+}
+
+// KeyCandidate describes how suitable a top-level field is as a primary key,
+// based on the rows sampled during key discovery.
+type KeyCandidate struct {
+	Field           string  `json:"field"`
+	RowsPresent     int64   `json:"rowsPresent"`
+	DistinctCount   int64   `json:"distinctCount"`
+	UniquenessRatio float64 `json:"uniquenessRatio"`
+	Coverage        float64 `json:"coverage"`
+	IsCandidate     bool    `json:"isCandidate"`
+}
+
+// ValueCount is a single value of a field and how many times it was seen.
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// FieldProfile describes the cardinality and value distribution of a field.
+// NullRate counts rows where the field is either null or missing entirely.
+type FieldProfile struct {
+	Field          string       `json:"field"`
+	RowsPresent    int64        `json:"rowsPresent"`
+	NullCount      int64        `json:"nullCount"`
+	NullRate       float64      `json:"nullRate"`
+	DistinctCount  int64        `json:"distinctCount"`
+	DistinctCapped bool         `json:"distinctCapped,omitempty"`
+	TopValues      []ValueCount `json:"topValues"`
+}
+
+// ScopeReport holds the duplicates found within a single scope (e.g. one file)
+// when the analysis does not compare records globally.
+type ScopeReport struct {
+	DuplicateIDs      map[string][]LocationInfo            `json:"duplicateIds"`
+	DuplicateRows     map[string][]LocationInfo            `json:"duplicateRows"`
+	DuplicateIDsByKey map[string]map[string][]LocationInfo `json:"duplicateIdsByKey,omitempty"`
+}
+
+// KeySummary contains the duplicate metrics for one additional key or
+// composite constraint. PrimaryKey is set for a constraint on the primary key
+// alone, whose duplicate sets are those of DuplicateIDs.
+type KeySummary struct {
+	Fields               []string `json:"fields"`
+	TotalKeyOccurrences  int      `json:"totalKeyOccurrences"`
+	UniqueKeysDuplicated int      `json:"uniqueKeysDuplicated"`
+	PrimaryKey           bool     `json:"primaryKey,omitempty"`
+}
+
+// Label returns how a constraint should be named in text output, including
+// its fields when they differ from the name.
+func (ks KeySummary) Label(name string) string {
+	if len(ks.Fields) == 0 || (len(ks.Fields) == 1 && ks.Fields[0] == name) {
+		return fmt.Sprintf("'%s'", name)
+	}
+	return fmt.Sprintf("'%s' (%s)", name, strings.Join(ks.Fields, ", "))
+}
+
+// AnalysisReport is the top-level structure for the entire analysis result.
+type AnalysisReport struct {
+	// SchemaVersion is the JSON layout version; see Normalise.
+	SchemaVersion int                       `json:"schemaVersion"`
+	Summary       SummaryReport             `json:"summary"`
+	DuplicateIDs  map[string][]LocationInfo `json:"duplicateIds"`
+	DuplicateRows map[string][]LocationInfo `json:"duplicateRows"`
+	// DuplicateIDsByKey holds the duplicate sets of each additional key,
+	// keyed by key name then value.
+	DuplicateIDsByKey map[string]map[string][]LocationInfo `json:"duplicateIdsByKey,omitempty"`
+	Scopes            map[string]*ScopeReport              `json:"scopes,omitempty"`
+	FuzzyClusters     []FuzzyCluster                       `json:"fuzzyClusters,omitempty"`
+	KeyCandidates     []KeyCandidate                       `json:"keyCandidates,omitempty"`
+	Profile           []FieldProfile                       `json:"profile,omitempty"`
+	// RecordSamples holds the raw JSON of the first few members of each
+	// duplicate set, keyed by LocationInfo.Key().
+	RecordSamples map[string]json.RawMessage `json:"recordSamples,omitempty"`
+	// OversizedLines counts, per file, the lines skipped for exceeding the
+	// maximum line size.
+	OversizedLines map[string]int64 `json:"oversizedLines,omitempty"`
+	// ParseErrors counts, per file, the lines that were not valid JSON.
+	ParseErrors map[string]int64 `json:"parseErrors,omitempty"`
+	// FileDetails is the optional per-file breakdown, keyed by path.
+	FileDetails map[string]FileDetail `json:"fileDetails,omitempty"`
+	// SingletonIDs, SingletonRows and SingletonIDsByKey hold the values seen
+	// exactly once. Only shard runs record them, for Merge to find duplicates
+	// that span shards.
+	SingletonIDs      map[string]LocationInfo            `json:"singletonIds,omitempty"`
+	SingletonRows     map[string]LocationInfo            `json:"singletonRows,omitempty"`
+	SingletonIDsByKey map[string]map[string]LocationInfo `json:"singletonIdsByKey,omitempty"`
+	// Limits caps the duplicate details listed by the text and HTML reports.
+	Limits DetailLimits `json:"-"`
+}
+
+// SummaryReport contains aggregated metrics from the analysis.
+type SummaryReport struct {
+	IsValidationReport        bool                    `json:"isValidationReport"`
+	IsKeyDiscoveryReport      bool                    `json:"isKeyDiscoveryReport,omitempty"`
+	IsShardReport             bool                    `json:"isShardReport,omitempty"`
+	IsPartialReport           bool                    `json:"isPartialReport"`
+	FilesProcessed            int32                   `json:"filesProcessed"`
+	TotalFiles                int                     `json:"totalFiles"`
+	ProcessedDataSizeBytes    int64                   `json:"processedDataSizeBytes"`
+	TotalDataSizeOverallBytes int64                   `json:"totalDataSizeOverallBytes"`
+	ProcessedDataSizeHuman    string                  `json:"processedDataSizeHuman"`
+	TotalDataSizeOverallHuman string                  `json:"totalDataSizeOverallHuman"`
+	TotalElapsedTime          string                  `json:"totalElapsedTime"`
+	TotalRowsProcessed        int64                   `json:"totalRowsProcessed"`
+	UniqueKey                 string                  `json:"uniqueKey"`
+	// Inputs are the paths the run was given, and KeyChecked and RowChecked
+	// the checks it made, so the run can be repeated from its report.
+	Inputs                    []string                `json:"inputs,omitempty"`
+	KeyChecked                bool                    `json:"keyChecked,omitempty"`
+	RowChecked                bool                    `json:"rowChecked,omitempty"`
+	Scope                     string                  `json:"scope,omitempty"`
+	Workers                   int                     `json:"workers,omitempty"`
+	WorkersNote               string                  `json:"workersNote,omitempty"`
+	MemoryBudgetNotes         []string                `json:"memoryBudgetNotes,omitempty"`
+	OversizedLinesSkipped     int64                   `json:"oversizedLinesSkipped,omitempty"`
+	ParseErrors               int64                   `json:"parseErrors,omitempty"`
+	KeysMasked                string                  `json:"keysMasked,omitempty"`
+	CachedFiles               int                     `json:"cachedFiles,omitempty"`
+	TotalKeyOccurrences       int                     `json:"totalKeyOccurrences"`
+	UniqueKeysDuplicated      int                     `json:"uniqueKeysDuplicated"`
+	AdditionalKeys            map[string]KeySummary   `json:"additionalKeys,omitempty"`
+	DuplicateRowInstances     int                     `json:"duplicateRowInstances"`
+	IDMultiplicity            []MultiplicityBucket    `json:"idMultiplicity,omitempty"`
+	RowMultiplicity           []MultiplicityBucket    `json:"rowMultiplicity,omitempty"`
+	FuzzyField                string                  `json:"fuzzyField,omitempty"`
+	FuzzyThreshold            float64                 `json:"fuzzyThreshold,omitempty"`
+	FuzzyClusterCount         int                     `json:"fuzzyClusterCount,omitempty"`
+	AverageRowsPerFile        float64                 `json:"averageRowsPerFile"`
+	AverageFilesPerFolder     float64                 `json:"averageFilesPerFolder"`
+	DuplicateIDsPerFolder     map[string]int          `json:"duplicateIDsPerFolder"`
+	DuplicateRowsPerFolder    map[string]int          `json:"duplicateRowsPerFolder"`
+	FolderDetails             map[string]FolderDetail `json:"folderDetails"`
+}
+
+var (
+	reportStyle      = lipgloss.NewStyle().Padding(0, 1).Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("63"))
+	headerStyle      = lipgloss.NewStyle().Bold(true).MarginBottom(1).Underline(true)
+	tableHeaderStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("212"))
+)
+
+// HumanSize returns a human-readable string for a given byte size.
+func HumanSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+// String formats the report for display. Large reports should be written
+// with WriteText instead.
+func (r *AnalysisReport) String(isFullReport bool, checkKey, checkRow, showFolderBreakdown bool) string {
+	var b strings.Builder
+	_ = r.WriteText(&b, isFullReport, checkKey, checkRow, showFolderBreakdown)
+	return b.String()
+}
+
+// WriteText writes the formatted report to w. The duplicate details are
+// streamed set by set rather than built up in memory first.
+func (r *AnalysisReport) WriteText(w io.Writer, isFullReport bool, checkKey, checkRow, showFolderBreakdown bool) error {
+	bw := bufio.NewWriterSize(w, 256*1024)
+	switch {
+	case r.Summary.IsValidationReport:
+		bw.WriteString(r.validationReportString(showFolderBreakdown))
+	case r.Summary.IsKeyDiscoveryReport:
+		bw.WriteString(r.keyDiscoveryReportString())
+	default:
+		bw.WriteString(r.analysisSummaryString(checkKey, checkRow, showFolderBreakdown))
+		if isFullReport {
+			r.writeDetails(bw, checkKey, checkRow)
+		}
+	}
+	return bw.Flush()
+}
+
+func (r *AnalysisReport) validationReportString(showFolderBreakdown bool) string {
+	s := r.Summary
+	var b strings.Builder
+
+	b.WriteString(headerStyle.Render("--- Key Validation Summary ---") + "\n")
+	filesAnalysedStr := fmt.Sprintf("%d", s.FilesProcessed)
+	if s.IsPartialReport {
+		filesAnalysedStr = fmt.Sprintf("%d of %d", s.FilesProcessed, s.TotalFiles)
+	}
+
+	summaryContent := fmt.Sprintf(
+		"Key to Find:                  '%s'\nTotal Files Analysed:           %s\nTotal Rows Processed:           %d\nTotal Keys Found:             %d\nTotal Elapsed Time:           %s",
+		s.UniqueKey, filesAnalysedStr, s.TotalRowsProcessed, s.TotalKeyOccurrences, s.TotalElapsedTime,
+	)
+	if s.ParseErrors > 0 {
+		summaryContent += fmt.Sprintf("\nLines Not Valid JSON:         %d", s.ParseErrors)
+	}
+	b.WriteString(reportStyle.Render(summaryContent))
+
+	if len(r.ParseErrors) > 0 {
+		b.WriteString("\n\n" + headerStyle.Render("--- Errors: Lines Not Valid JSON ---") + "\n")
+		b.WriteString(reportStyle.Render(r.parseErrorList()))
+	}
+
+	if showFolderBreakdown && len(s.FolderDetails) > 0 {
+		var sortedFolders []string
+		for path := range s.FolderDetails {
+			sortedFolders = append(sortedFolders, path)
+		}
+		sort.Strings(sortedFolders)
+
+		var tableContent strings.Builder
+		headers := []string{"Path", "Files Checked", "Rows Processed", "Keys Found"}
+
+		type formattedRow struct{ path, files, rows, keys string }
+		var rows []formattedRow
+		maxWidths := make([]int, len(headers))
+		for i, h := range headers {
+			maxWidths[i] = len(h)
+		}
+
+		for _, folder := range sortedFolders {
+			detail := s.FolderDetails[folder]
+			var filesStr string
+			if s.IsPartialReport {
+				filesStr = fmt.Sprintf("%d / %d", detail.FilesProcessed, detail.TotalFiles)
+			} else {
+				filesStr = fmt.Sprintf("%d", detail.TotalFiles)
+			}
+			row := formattedRow{
+				path:  folder,
+				files: filesStr,
+				rows:  fmt.Sprintf("%d", detail.RowsProcessed),
+				keys:  fmt.Sprintf("%d", detail.KeysFound),
+			}
+			rows = append(rows, row)
+
+			if len(row.path) > maxWidths[0] { maxWidths[0] = len(row.path) }
+			if len(row.files) > maxWidths[1] { maxWidths[1] = len(row.files) }
+			if len(row.rows) > maxWidths[2] { maxWidths[2] = len(row.rows) }
+			if len(row.keys) > maxWidths[3] { maxWidths[3] = len(row.keys) }
+		}
+
+		headerFormat := fmt.Sprintf("%%-%ds | %%-%ds | %%-%ds | %%-%ds", maxWidths[0], maxWidths[1], maxWidths[2], maxWidths[3])
+		headerLine := fmt.Sprintf(headerFormat, headers[0], headers[1], headers[2], headers[3])
+		tableContent.WriteString(tableHeaderStyle.Render(headerLine) + "\n")
+
+		rowFormat := fmt.Sprintf("%%-%ds | %%-%ds | %%-%ds | %%-%ds", maxWidths[0], maxWidths[1], maxWidths[2], maxWidths[3])
+		for _, row := range rows {
+			tableContent.WriteString(fmt.Sprintf(rowFormat, row.path, row.files, row.rows, row.keys) + "\n")
+		}
+
+		b.WriteString("\n\n" + headerStyle.Render("--- Per-Folder Breakdown ---") + "\n")
+		b.WriteString(reportStyle.Render(strings.TrimRight(tableContent.String(), "\n")))
+	}
+
+	return b.String()
+}
+
+func (r *AnalysisReport) keyDiscoveryReportString() string {
+	s := r.Summary
+	var b strings.Builder
+
+	b.WriteString(headerStyle.Render("--- Candidate Key Discovery ---") + "\n")
+	summaryContent := fmt.Sprintf(
+		"Files Sampled:                %d\nRows Sampled:                 %d\nFields Profiled:              %d\nTotal Elapsed Time:           %s",
+		s.FilesProcessed, s.TotalRowsProcessed, len(r.KeyCandidates), s.TotalElapsedTime,
+	)
+	b.WriteString(reportStyle.Render(summaryContent))
+
+	if len(r.KeyCandidates) == 0 {
+		return b.String()
+	}
+
+	var tableContent strings.Builder
+	headers := []string{"Field", "Distinct", "Present", "Uniqueness", "Coverage", "Candidate"}
+	rows := make([][]string, 0, len(r.KeyCandidates))
+	maxWidths := make([]int, len(headers))
+	for i, h := range headers {
+		maxWidths[i] = len(h)
+	}
+	for _, c := range r.KeyCandidates {
+		candidate := ""
+		if c.IsCandidate {
+			candidate = "yes"
+		}
+		row := []string{
+			c.Field,
+			fmt.Sprintf("%d", c.DistinctCount),
+			fmt.Sprintf("%d", c.RowsPresent),
+			fmt.Sprintf("%.2f%%", c.UniquenessRatio*100),
+			fmt.Sprintf("%.2f%%", c.Coverage*100),
+			candidate,
+		}
+		rows = append(rows, row)
+		for i, cell := range row {
+			if len(cell) > maxWidths[i] {
+				maxWidths[i] = len(cell)
+			}
+		}
+	}
+
+	rowFormat := fmt.Sprintf("%%-%ds | %%-%ds | %%-%ds | %%-%ds | %%-%ds | %%-%ds", maxWidths[0], maxWidths[1], maxWidths[2], maxWidths[3], maxWidths[4], maxWidths[5])
+	tableContent.WriteString(tableHeaderStyle.Render(fmt.Sprintf(rowFormat, headers[0], headers[1], headers[2], headers[3], headers[4], headers[5])) + "\n")
+	for _, row := range rows {
+		tableContent.WriteString(fmt.Sprintf(rowFormat, row[0], row[1], row[2], row[3], row[4], row[5]) + "\n")
+	}
+
+	b.WriteString("\n\n" + headerStyle.Render("--- Fields Ranked By Uniqueness ---") + "\n")
+	b.WriteString(reportStyle.Render(strings.TrimRight(tableContent.String(), "\n")))
+
+	if top := r.KeyCandidates[0]; top.IsCandidate {
+		b.WriteString(fmt.Sprintf("\n\nSuggested key: -key %s", top.Field))
+	} else {
+		b.WriteString("\n\nNo single field is unique and present on every sampled row; consider a composite key.")
+	}
+	return b.String()
+}
+
+// analysisSummaryString renders the summary, tables and small sections of an
+// analysis report; the duplicate details are written by writeDetails.
+func (r *AnalysisReport) analysisSummaryString(checkKey, checkRow, showFolderBreakdown bool) string {
+	s := r.Summary
+	var b strings.Builder
+
+	b.WriteString(headerStyle.Render("--- Analysis Summary ---") + "\n")
+	filesAnalysedStr := fmt.Sprintf("%d", s.FilesProcessed)
+	dataAnalysedStr := s.ProcessedDataSizeHuman
+	if s.IsPartialReport {
+		filesAnalysedStr = fmt.Sprintf("%d of %d", s.FilesProcessed, s.TotalFiles)
+		dataAnalysedStr = fmt.Sprintf("%s of %s", s.ProcessedDataSizeHuman, s.TotalDataSizeOverallHuman)
+	}
+
+	summaryContent := fmt.Sprintf(
+		"Total Elapsed Time:           %s\nTotal Files Analysed:         %s\nTotal Data Analysed:          %s\nAverage Rows Per File (Global): %.2f\nAverage Files Per Folder:     %.2f",
+		s.TotalElapsedTime, filesAnalysedStr, dataAnalysedStr, s.AverageRowsPerFile, s.AverageFilesPerFolder,
+	)
+	if s.Workers > 0 {
+		summaryContent += fmt.Sprintf("\nWorkers:                      %d", s.Workers)
+		if s.WorkersNote != "" {
+			summaryContent += fmt.Sprintf(" (%s)", s.WorkersNote)
+		}
+	}
+	if s.CachedFiles > 0 {
+		summaryContent += fmt.Sprintf("\nFiles Reused From Cache:      %d", s.CachedFiles)
+	}
+	if s.OversizedLinesSkipped > 0 {
+		summaryContent += fmt.Sprintf("\nOversized Lines Skipped:      %d", s.OversizedLinesSkipped)
+	}
+	if s.ParseErrors > 0 {
+		summaryContent += fmt.Sprintf("\nLines Not Valid JSON:         %d", s.ParseErrors)
+	}
+	if s.KeysMasked != "" {
+		summaryContent += fmt.Sprintf("\nKey Values Masked:            %s", s.KeysMasked)
+	}
+	for _, note := range s.MemoryBudgetNotes {
+		summaryContent += fmt.Sprintf("\nMemory Budget:                %s", note)
+	}
+	if s.Scope != "" && s.Scope != "global" {
+		summaryContent += fmt.Sprintf("\nDuplicate Scope:              per %s (%d scopes with duplicates)", s.Scope, len(r.Scopes))
+	}
+	if checkKey {
+		summaryContent += fmt.Sprintf("\nTotal Occurrences of '%s':  %d\nUnique '%s's with Duplicates: %d", s.UniqueKey, s.TotalKeyOccurrences, s.UniqueKey, s.UniqueKeysDuplicated)
+		for _, key := range sortedKeys(s.AdditionalKeys) {
+			ks := s.AdditionalKeys[key]
+			summaryContent += fmt.Sprintf("\nTotal Occurrences of %s:  %d\nUnique %s Values with Duplicates: %d", ks.Label(key), ks.TotalKeyOccurrences, ks.Label(key), ks.UniqueKeysDuplicated)
+		}
+	}
+	if checkRow {
+		summaryContent += fmt.Sprintf("\nTotal Duplicate Row Instances:  %d", s.DuplicateRowInstances)
+	}
+	if s.FuzzyField != "" {
+		summaryContent += fmt.Sprintf("\nFuzzy Clusters on '%s' (>= %.2f): %d", s.FuzzyField, s.FuzzyThreshold, s.FuzzyClusterCount)
+	}
+	b.WriteString(reportStyle.Render(summaryContent))
+
+	if len(r.ParseErrors) > 0 {
+		b.WriteString("\n\n" + headerStyle.Render("--- Errors: Lines Not Valid JSON ---") + "\n")
+		b.WriteString(reportStyle.Render(r.parseErrorList()))
+	}
+
+	if checkKey && len(s.IDMultiplicity) > 0 {
+		b.WriteString("\n\n" + headerStyle.Render(fmt.Sprintf("--- How Often Duplicate '%s's Occur ---", s.UniqueKey)) + "\n")
+		b.WriteString(reportStyle.Render(multiplicityTable(s.IDMultiplicity)))
+	}
+	if checkRow && len(s.RowMultiplicity) > 0 {
+		b.WriteString("\n\n" + headerStyle.Render("--- How Often Duplicate Rows Occur ---") + "\n")
+		b.WriteString(reportStyle.Render(multiplicityTable(s.RowMultiplicity)))
+	}
+
+	if showFolderBreakdown && len(s.FolderDetails) > 0 {
+		var sortedFolders []string
+		for path := range s.FolderDetails {
+			sortedFolders = append(sortedFolders, path)
+		}
+		sort.Strings(sortedFolders)
+
+		var tableContent strings.Builder
+		headers := []string{"Path", "Data Analysed", "Files Analysed", "Avg Rows/File", "Rows Processed", "Keys Found", "Duplicate IDs", "Duplicate Rows"}
+
+		type formattedRow struct {
+			path, data, files, avgRows, rows, keys, dupeIDs, dupeRows string
+		}
+		var rows []formattedRow
+		maxWidths := make([]int, len(headers))
+		for i, h := range headers {
+			maxWidths[i] = len(h)
+		}
+
+		for _, folder := range sortedFolders {
+			detail := s.FolderDetails[folder]
+			
+			var dataStr, filesStr string
+			if s.IsPartialReport {
+				dataStr = fmt.Sprintf("%s / %s", HumanSize(detail.ProcessedSizeBytes), HumanSize(detail.TotalSizeBytes))
+				filesStr = fmt.Sprintf("%d / %d", detail.FilesProcessed, detail.TotalFiles)
+			} else {
+				dataStr = HumanSize(detail.TotalSizeBytes)
+				filesStr = fmt.Sprintf("%d", detail.TotalFiles)
+			}
+
+			var avgRowsPerFile float64
+			if detail.FilesProcessed > 0 {
+				avgRowsPerFile = float64(detail.RowsProcessed) / float64(detail.FilesProcessed)
+			}
+
+			idCount := s.DuplicateIDsPerFolder[folder]
+			rowCount := s.DuplicateRowsPerFolder[folder]
+
+			row := formattedRow{
+				path:     folder,
+				data:     dataStr,
+				files:    filesStr,
+				avgRows:  fmt.Sprintf("%.2f", avgRowsPerFile),
+				rows:     fmt.Sprintf("%d", detail.RowsProcessed),
+				keys:     fmt.Sprintf("%d", detail.KeysFound),
+				dupeIDs:  fmt.Sprintf("%d", idCount),
+				dupeRows: fmt.Sprintf("%d", rowCount),
+			}
+			rows = append(rows, row)
+			
+			if len(row.path) > maxWidths[0] { maxWidths[0] = len(row.path) }
+			if len(row.data) > maxWidths[1] { maxWidths[1] = len(row.data) }
+			if len(row.files) > maxWidths[2] { maxWidths[2] = len(row.files) }
+			if len(row.avgRows) > maxWidths[3] { maxWidths[3] = len(row.avgRows) }
+			if len(row.rows) > maxWidths[4] { maxWidths[4] = len(row.rows) }
+			if len(row.keys) > maxWidths[5] { maxWidths[5] = len(row.keys) }
+			if len(row.dupeIDs) > maxWidths[6] { maxWidths[6] = len(row.dupeIDs) }
+			if len(row.dupeRows) > maxWidths[7] { maxWidths[7] = len(row.dupeRows) }
+		}
+		
+		headerFormat := fmt.Sprintf("%%-%ds | %%-%ds | %%-%ds | %%-%ds | %%-%ds | %%-%ds | %%-%ds | %%-%ds", maxWidths[0], maxWidths[1], maxWidths[2], maxWidths[3], maxWidths[4], maxWidths[5], maxWidths[6], maxWidths[7])
+		headerLine := fmt.Sprintf(headerFormat, headers[0], headers[1], headers[2], headers[3], headers[4], headers[5], headers[6], headers[7])
+		tableContent.WriteString(tableHeaderStyle.Render(headerLine) + "\n")
+
+		rowFormat := fmt.Sprintf("%%-%ds | %%-%ds | %%-%ds | %%-%ds | %%-%ds | %%-%ds | %%-%ds | %%-%ds", maxWidths[0], maxWidths[1], maxWidths[2], maxWidths[3], maxWidths[4], maxWidths[5], maxWidths[6], maxWidths[7])
+		for _, row := range rows {
+			tableContent.WriteString(fmt.Sprintf(rowFormat, row.path, row.data, row.files, row.avgRows, row.rows, row.keys, row.dupeIDs, row.dupeRows) + "\n")
+		}
+
+		b.WriteString("\n\n" + headerStyle.Render("--- Per-Folder Breakdown ---") + "\n")
+		b.WriteString(reportStyle.Render(strings.TrimRight(tableContent.String(), "\n")))
+	}
+
+	if len(r.FileDetails) > 0 {
+		b.WriteString("\n\n" + headerStyle.Render("--- Per-File Breakdown ---") + "\n")
+		b.WriteString(reportStyle.Render(r.fileTable(checkKey, checkRow)))
+	}
+
+	if len(r.Profile) > 0 {
+		b.WriteString("\n\n" + headerStyle.Render("--- Field Profile ---") + "\n")
+		b.WriteString(reportStyle.Render(r.profileTable()))
+	}
+
+	if len(r.OversizedLines) > 0 {
+		b.WriteString("\n\n" + headerStyle.Render("--- Oversized Lines Skipped ---"))
+		for _, path := range sortedKeys(r.OversizedLines) {
+			b.WriteString(fmt.Sprintf("\n  - File: %s, Lines: %d", path, r.OversizedLines[path]))
+		}
+	}
+
+	return b.String()
+}
+
+// writeDetails writes the full duplicate details sections to w, one set at a
+// time, so that huge reports never need to be held in memory as a string.
+func (r *AnalysisReport) writeDetails(w io.Writer, checkKey, checkRow bool) {
+	s := r.Summary
+	if checkKey && len(r.DuplicateIDs) > 0 {
+		io.WriteString(w, "\n\n"+headerStyle.Render("--- Full Duplicate ID Details ---"))
+		writeIDDetails(w, s.UniqueKey, r.DuplicateIDs, r.Limits)
+	}
+	for _, key := range sortedKeys(r.DuplicateIDsByKey) {
+		if checkKey && len(r.DuplicateIDsByKey[key]) > 0 {
+			io.WriteString(w, "\n\n"+headerStyle.Render(fmt.Sprintf("--- Full Duplicate ID Details (%s) ---", s.AdditionalKeys[key].Label(key))))
+			writeIDDetails(w, key, r.DuplicateIDsByKey[key], r.Limits)
+		}
+	}
+	if checkRow && len(r.DuplicateRows) > 0 {
+		io.WriteString(w, "\n\n"+headerStyle.Render("--- Full Duplicate Row Details ---"))
+		writeRowDetails(w, r.DuplicateRows, r.Limits)
+	}
+	if len(r.Scopes) > 0 {
+		scopes := make([]string, 0, len(r.Scopes))
+		for scope := range r.Scopes {
+			scopes = append(scopes, scope)
+		}
+		sort.Strings(scopes)
+		io.WriteString(w, "\n\n"+headerStyle.Render(fmt.Sprintf("--- Duplicate Details Per %s ---", strings.ToUpper(s.Scope[:1])+s.Scope[1:])))
+		for _, scope := range scopes {
+			sr := r.Scopes[scope]
+			fmt.Fprintf(w, "\n[%s] %d duplicate ID(s), %d duplicate row set(s)\n", scope, len(sr.DuplicateIDs), len(sr.DuplicateRows))
+			if checkKey && len(sr.DuplicateIDs) > 0 {
+				writeIDDetails(w, s.UniqueKey, sr.DuplicateIDs, r.Limits)
+			}
+			for _, key := range sortedKeys(sr.DuplicateIDsByKey) {
+				if checkKey {
+					writeIDDetails(w, key, sr.DuplicateIDsByKey[key], r.Limits)
+				}
+			}
+			if checkRow && len(sr.DuplicateRows) > 0 {
+				writeRowDetails(w, sr.DuplicateRows, r.Limits)
+			}
+		}
+	}
+	if len(r.FuzzyClusters) > 0 {
+		io.WriteString(w, "\n\n"+headerStyle.Render("--- Fuzzy Match Clusters ---"))
+		for _, cluster := range r.FuzzyClusters {
+			io.WriteString(w, "\n")
+			if cluster.Scope != "" {
+				fmt.Fprintf(w, "[%s] ", cluster.Scope)
+			}
+			fmt.Fprintf(w, "'%s' values %q (%d records):\n", s.FuzzyField, cluster.Values, len(cluster.Locations))
+			for _, loc := range cluster.Locations {
+				fmt.Fprintf(w, "  - File: %s, Row: %d\n", loc.FilePath, loc.LineNumber)
+			}
+		}
+	}
+}
+
+// profileTable renders the field profile as an aligned table.
+func (r *AnalysisReport) profileTable() string {
+	headers := []string{"Field", "Distinct", "Null Rate", "Top Values"}
+	rows := make([][]string, 0, len(r.Profile))
+	maxWidths := make([]int, len(headers))
+	for i, h := range headers {
+		maxWidths[i] = len(h)
+	}
+	for _, p := range r.Profile {
+		distinct := fmt.Sprintf("%d", p.DistinctCount)
+		if p.DistinctCapped {
+			distinct = ">=" + distinct
+		}
+		topValues := make([]string, 0, len(p.TopValues))
+		for _, v := range p.TopValues {
+			value := v.Value
+			if len(value) > 24 {
+				value = value[:21] + "..."
+			}
+			topValues = append(topValues, fmt.Sprintf("%s (%d)", value, v.Count))
+		}
+		row := []string{p.Field, distinct, fmt.Sprintf("%.2f%%", p.NullRate*100), strings.Join(topValues, ", ")}
+		rows = append(rows, row)
+		for i, cell := range row {
+			if len(cell) > maxWidths[i] {
+				maxWidths[i] = len(cell)
+			}
+		}
+	}
+
+	var tableContent strings.Builder
+	rowFormat := fmt.Sprintf("%%-%ds | %%-%ds | %%-%ds | %%-%ds", maxWidths[0], maxWidths[1], maxWidths[2], maxWidths[3])
+	tableContent.WriteString(tableHeaderStyle.Render(fmt.Sprintf(rowFormat, headers[0], headers[1], headers[2], headers[3])) + "\n")
+	for _, row := range rows {
+		tableContent.WriteString(fmt.Sprintf(rowFormat, row[0], row[1], row[2], row[3]) + "\n")
+	}
+	return strings.TrimRight(tableContent.String(), "\n")
+}
+
+// sortedKeys returns the keys of a string-keyed map in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeIDDetails writes the duplicate ID sets, sorted by ID, within limits.
+func writeIDDetails(w io.Writer, uniqueKey string, dupes map[string][]LocationInfo, limits DetailLimits) {
+	ids, omittedSets := limits.sets(dupes)
+	for _, id := range ids {
+		fmt.Fprintf(w, "\nID '%s': %s (appears %d times)\n", uniqueKey, id, len(dupes[id]))
+		locs, omitted := limits.locations(dupes[id])
+		for _, loc := range locs {
+			fmt.Fprintf(w, "  - File: %s, Row: %d\n", loc.FilePath, loc.LineNumber)
+		}
+		writeOmittedLocations(w, omitted)
+	}
+	writeOmittedSets(w, omittedSets)
+}
+
+// writeRowDetails writes the duplicate row sets, sorted by hash, within
+// limits.
+func writeRowDetails(w io.Writer, dupes map[string][]LocationInfo, limits DetailLimits) {
+	hashes, omittedSets := limits.sets(dupes)
+	for _, hash := range hashes {
+		fmt.Fprintf(w, "\nRow (Hash: %s) found %d times:\n", hash, len(dupes[hash]))
+		locs, omitted := limits.locations(dupes[hash])
+		for _, loc := range locs {
+			fmt.Fprintf(w, "  - File: %s, Row: %d\n", loc.FilePath, loc.LineNumber)
+		}
+		writeOmittedLocations(w, omitted)
+	}
+	writeOmittedSets(w, omittedSets)
+}
+
+// ToJSON converts the report to a JSON string. Large reports should be
+// written with WriteJSON instead.
+func (r *AnalysisReport) ToJSON() (string, error) {
+	var b strings.Builder
+	if err := r.WriteJSON(&b); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// SaveOptions selects which report files are written and what they include.
+type SaveOptions struct {
+	Txt                 bool
+	JSON                bool
+	CSV                 bool
+	HTML                bool
+	Markdown            bool
+	SARIF               bool
+	JUnit               bool
+	NDJSON              bool
+	SQLite              bool
+	CheckKey            bool
+	CheckRow            bool
+	ShowFolderBreakdown bool
+	// Template, when set, renders the text reports in place of the built-in
+	// layout.
+	Template *template.Template
+	// Retention is applied to the log path by SaveAndLog after saving.
+	Retention RetentionPolicy
+	// Compress gzips the details text, JSON, CSV and NDJSON files, the ones
+	// that grow with the number of duplicates, adding .gz to their names.
+	Compress bool
+}
+
+// gz adds .gz to name when the options compress the larger report files.
+func (o SaveOptions) gz(name string) string {
+	if o.Compress {
+		return name + ".gz"
+	}
+	return name
+}
+
+// WriteText writes the text report selected by o, using o.Template when set.
+func (o SaveOptions) WriteText(w io.Writer, r *AnalysisReport, isFullReport bool) error {
+	if o.Template != nil {
+		return r.WriteTemplate(w, o.Template, isFullReport, o.CheckKey, o.CheckRow, o.ShowFolderBreakdown)
+	}
+	return r.WriteText(w, isFullReport, o.CheckKey, o.CheckRow, o.ShowFolderBreakdown)
+}
+
+// Extensions lists the extensions of the enabled report files.
+func (o SaveOptions) Extensions() []string {
+	var exts []string
+	if o.Txt {
+		exts = append(exts, ".txt")
+	}
+	if o.JSON {
+		exts = append(exts, o.gz(".json"))
+	}
+	if o.CSV {
+		exts = append(exts, o.gz(".csv"))
+	}
+	if o.HTML {
+		exts = append(exts, ".html")
+	}
+	if o.Markdown {
+		exts = append(exts, ".md")
+	}
+	if o.SARIF {
+		exts = append(exts, ".sarif")
+	}
+	if o.JUnit {
+		exts = append(exts, ".junit.xml")
+	}
+	if o.NDJSON {
+		exts = append(exts, o.gz(".ndjson"))
+	}
+	if o.SQLite {
+		exts = append(exts, ".db")
+	}
+	return exts
+}
+
+// Save saves the report to disk based on configuration.
+func (r *AnalysisReport) Save(baseFilename string, opts SaveOptions) {
+	if opts.Txt {
+		summaryFilename := baseFilename + "_summary.txt"
+		detailsFilename := opts.gz(baseFilename + "_details.txt")
+		if err := writeFile(summaryFilename, func(w io.Writer) error {
+			return opts.WriteText(w, r, false)
+		}); err != nil {
+			slog.Error("Failed to save TXT summary report", "path", summaryFilename, "error", err)
+		}
+		if err := writeFile(detailsFilename, func(w io.Writer) error {
+			return opts.WriteText(w, r, true)
+		}); err != nil {
+			slog.Error("Failed to save TXT details report", "path", detailsFilename, "error", err)
+		}
+	}
+	if opts.JSON {
+		filename := opts.gz(baseFilename + ".json")
+		if err := writeFile(filename, r.WriteJSON); err != nil {
+			slog.Error("Failed to save JSON report", "path", filename, "error", err)
+		}
+	}
+	if opts.CSV {
+		filename := opts.gz(baseFilename + ".csv")
+		if err := writeFile(filename, func(w io.Writer) error {
+			return r.WriteCSV(w, opts.CheckKey, opts.CheckRow)
+		}); err != nil {
+			slog.Error("Failed to save CSV report", "path", filename, "error", err)
+		}
+	}
+	if opts.HTML {
+		filename := baseFilename + ".html"
+		if err := writeFile(filename, func(w io.Writer) error {
+			return r.WriteHTML(w, opts.CheckKey, opts.CheckRow)
+		}); err != nil {
+			slog.Error("Failed to save HTML report", "path", filename, "error", err)
+		}
+	}
+	if opts.Markdown {
+		filename := baseFilename + ".md"
+		if err := writeFile(filename, func(w io.Writer) error {
+			return r.WriteMarkdown(w, opts.CheckKey, opts.CheckRow, opts.ShowFolderBreakdown)
+		}); err != nil {
+			slog.Error("Failed to save Markdown report", "path", filename, "error", err)
+		}
+	}
+	if opts.SARIF {
+		filename := baseFilename + ".sarif"
+		if err := writeFile(filename, func(w io.Writer) error {
+			return r.WriteSARIF(w, opts.CheckKey, opts.CheckRow)
+		}); err != nil {
+			slog.Error("Failed to save SARIF report", "path", filename, "error", err)
+		}
+	}
+	if opts.JUnit {
+		filename := baseFilename + ".junit.xml"
+		if err := writeFile(filename, func(w io.Writer) error {
+			return r.WriteJUnit(w, opts.CheckKey, opts.CheckRow)
+		}); err != nil {
+			slog.Error("Failed to save JUnit report", "path", filename, "error", err)
+		}
+	}
+	if opts.NDJSON {
+		filename := opts.gz(baseFilename + ".ndjson")
+		if err := writeFile(filename, func(w io.Writer) error {
+			return r.WriteNDJSON(w, opts.CheckKey, opts.CheckRow)
+		}); err != nil {
+			slog.Error("Failed to save NDJSON export", "path", filename, "error", err)
+		}
+	}
+	if opts.SQLite {
+		filename := baseFilename + ".db"
+		if err := r.saveSQLite(filename, opts.CheckKey, opts.CheckRow); err != nil {
+			slog.Error("Failed to save SQLite database", "path", filename, "error", err)
+		}
+	}
+}
+
+// writeFile creates filename and streams its contents from write, gzipping
+// them when filename ends in .gz.
+func writeFile(filename string, write func(io.Writer) error) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if !strings.HasSuffix(filename, ".gz") {
+		if err := write(f); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+	zw := gzip.NewWriter(f)
+	if err := write(zw); err != nil {
+		f.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// SaveAndLog generates a timestamped filename inside the given logPath, saves the
+// report, removes old reports under opts.Retention, and returns the base
+// filename.
+func SaveAndLog(rep *AnalysisReport, logPath string, opts SaveOptions) string {
+	baseName := "report-" + time.Now().Format("2006-01-02_15-04-05")
+	fullPathBase := filepath.Join(logPath, baseName)
+	rep.Save(fullPathBase, opts)
+	opts.Retention.Apply(logPath, fullPathBase)
+	return fullPathBase
+}
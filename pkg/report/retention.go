@@ -0,0 +1,148 @@
+// pkg/report/retention.go
+package report
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// RetentionPolicy limits how many saved reports are kept in the log path.
+// Each run's files, such as report-<timestamp>.json and its _summary.txt, are
+// kept or removed together. The zero value keeps everything.
+type RetentionPolicy struct {
+	// KeepLast keeps only the newest runs.
+	KeepLast int
+	// MaxAge removes runs older than this.
+	MaxAge time.Duration
+	// MaxSize removes the oldest runs once the reports together exceed this
+	// many bytes.
+	MaxSize int64
+}
+
+// IsZero reports whether the policy keeps every report.
+func (p RetentionPolicy) IsZero() bool {
+	return p.KeepLast <= 0 && p.MaxAge <= 0 && p.MaxSize <= 0
+}
+
+// reportRunPattern matches the files written by SaveAndLog for one run,
+// capturing the base name and its timestamp.
+var reportRunPattern = regexp.MustCompile(`^((?:report|comparison)-(\d{4}-\d{2}-\d{2}_\d{2}-\d{2}-\d{2}))[._]`)
+
+type reportRun struct {
+	base  string
+	time  time.Time
+	files []string
+	size  int64
+}
+
+// Apply removes the runs in logPath that fall outside the policy, oldest
+// first, and returns the paths removed. The run saved as current is always
+// kept. Files that are not reports are never touched.
+func (p RetentionPolicy) Apply(logPath, current string) []string {
+	if p.IsZero() {
+		return nil
+	}
+	runs, err := listRuns(logPath)
+	if err != nil {
+		slog.Error("Could not apply report retention", "path", logPath, "error", err)
+		return nil
+	}
+
+	currentBase := filepath.Base(current)
+	now := time.Now()
+	var newerSize int64
+	var removed []string
+	for i, run := range runs {
+		newerSize += run.size
+		keep := run.base == currentBase ||
+			((p.KeepLast <= 0 || i < p.KeepLast) &&
+				(p.MaxAge <= 0 || now.Sub(run.time) <= p.MaxAge) &&
+				(p.MaxSize <= 0 || newerSize <= p.MaxSize))
+		if keep {
+			continue
+		}
+		for _, file := range run.files {
+			if err := os.Remove(file); err != nil {
+				slog.Error("Could not remove old report", "path", file, "error", err)
+				continue
+			}
+			removed = append(removed, file)
+		}
+	}
+	if len(removed) > 0 {
+		slog.Info("Removed old reports under the retention policy", "files", len(removed))
+	}
+	return removed
+}
+
+// listRuns groups the reports in logPath by run, newest first.
+func listRuns(logPath string) ([]*reportRun, error) {
+	entries, err := os.ReadDir(logPath)
+	if err != nil {
+		return nil, err
+	}
+	runsByBase := make(map[string]*reportRun)
+	for _, entry := range entries {
+		match := reportRunPattern.FindStringSubmatch(entry.Name())
+		if match == nil || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		run := runsByBase[match[1]]
+		if run == nil {
+			t, err := time.ParseInLocation("2006-01-02_15-04-05", match[2], time.Local)
+			if err != nil {
+				continue
+			}
+			run = &reportRun{base: match[1], time: t}
+			runsByBase[match[1]] = run
+		}
+		run.files = append(run.files, filepath.Join(logPath, entry.Name()))
+		run.size += info.Size()
+	}
+
+	runs := make([]*reportRun, 0, len(runsByBase))
+	for _, run := range runsByBase {
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		if !runs[i].time.Equal(runs[j].time) {
+			return runs[i].time.After(runs[j].time)
+		}
+		return runs[i].base > runs[j].base
+	})
+	return runs, nil
+}
+
+// SavedReport is a run in the log path that saved a JSON report.
+type SavedReport struct {
+	Name     string
+	Time     time.Time
+	JSONPath string
+}
+
+// ListSaved returns the runs in logPath that saved a JSON report, newest
+// first.
+func ListSaved(logPath string) ([]SavedReport, error) {
+	runs, err := listRuns(logPath)
+	if err != nil {
+		return nil, err
+	}
+	var saved []SavedReport
+	for _, run := range runs {
+		for _, file := range run.files {
+			if name := filepath.Base(file); name == run.base+".json" || name == run.base+".json.gz" {
+				saved = append(saved, SavedReport{Name: run.base, Time: run.time, JSONPath: file})
+				break
+			}
+		}
+	}
+	return saved, nil
+}
@@ -0,0 +1,136 @@
+// pkg/report/sarif.go
+package report
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	// SARIF rule IDs for the two kinds of finding.
+	sarifRuleDuplicateKey = "duplicate-key"
+	sarifRuleDuplicateRow = "duplicate-row"
+)
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	Name             string       `json:"name"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	FullDescription  sarifMessage `json:"fullDescription"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region struct {
+			StartLine int `json:"startLine"`
+		} `json:"region"`
+	} `json:"physicalLocation"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+}
+
+// WriteSARIF writes every duplicate set as a SARIF 2.1.0 result so that code
+// scanning tools can annotate the data files in pull requests. Each result
+// lists every location of the set; local paths under the working directory
+// are made relative to it, as GitHub code scanning expects repository-relative
+// paths. Results are written one at a time to keep memory flat.
+func (r *AnalysisReport) WriteSARIF(w io.Writer, checkKey, checkRow bool) error {
+	bw := bufio.NewWriterSize(w, 256*1024)
+	rules := []sarifRule{
+		{
+			ID:               sarifRuleDuplicateKey,
+			Name:             "DuplicateKey",
+			ShortDescription: sarifMessage{"Duplicate key value"},
+			FullDescription:  sarifMessage{"A value of a field that should be unique appears in more than one record."},
+		},
+		{
+			ID:               sarifRuleDuplicateRow,
+			Name:             "DuplicateRow",
+			ShortDescription: sarifMessage{"Duplicate row"},
+			FullDescription:  sarifMessage{"An identical record appears more than once."},
+		},
+	}
+	encodedRules, err := json.Marshal(rules)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(bw, `{"$schema":%q,"version":%q,"runs":[{"tool":{"driver":{"name":"dupe-analyser","informationUri":"https://github.com/benjaminwestern/dupe-analyser","rules":%s}},"results":[`,
+		sarifSchema, sarifVersion, encodedRules)
+
+	cwd, _ := os.Getwd()
+	first := true
+	r.EachDuplicateSet(checkKey, checkRow, func(kind, key, scope, value string, locs []LocationInfo) {
+		if err != nil {
+			return
+		}
+		ruleID := sarifRuleDuplicateKey
+		if kind == "row" {
+			ruleID = sarifRuleDuplicateRow
+		}
+		result := sarifResult{
+			RuleID:              ruleID,
+			Level:               "warning",
+			Locations:           make([]sarifLocation, len(locs)),
+			PartialFingerprints: map[string]string{"duplicateSet/v1": key + ":" + scope + ":" + value},
+		}
+		if ruleID == sarifRuleDuplicateRow {
+			result.Message.Text = fmt.Sprintf("Row (hash %s) appears %d times.", value, len(locs))
+		} else {
+			result.Message.Text = fmt.Sprintf("'%s' value %s appears %d times.", key, value, len(locs))
+		}
+		for i, loc := range locs {
+			result.Locations[i].PhysicalLocation.ArtifactLocation.URI = sarifURI(cwd, loc.FilePath)
+			result.Locations[i].PhysicalLocation.Region.StartLine = loc.LineNumber
+		}
+		var encoded []byte
+		if encoded, err = json.Marshal(result); err != nil {
+			return
+		}
+		if !first {
+			bw.WriteByte(',')
+		}
+		first = false
+		bw.Write(encoded)
+	})
+	if err != nil {
+		return err
+	}
+	bw.WriteString("]}]}\n")
+	return bw.Flush()
+}
+
+// sarifURI returns the artifact URI for a file: relative to cwd when the file
+// is beneath it, a file:// URI for other local paths, and unchanged for remote
+// objects such as gs:// paths.
+func sarifURI(cwd, path string) string {
+	if strings.Contains(path, "://") {
+		return path
+	}
+	if cwd != "" {
+		if rel, err := filepath.Rel(cwd, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
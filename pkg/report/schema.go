@@ -0,0 +1,58 @@
+// pkg/report/schema.go
+package report
+
+import (
+	"cmp"
+	"slices"
+)
+
+// SchemaVersion is the version of the JSON report layout, saved as
+// schemaVersion. It is raised when a field is removed or renamed or its
+// meaning changes; adding an optional field does not raise it. Reports saved
+// before the layout was versioned read back as version 0.
+const SchemaVersion = 1
+
+// Normalise stamps the report with SchemaVersion and sorts every list whose
+// order would otherwise depend on worker scheduling, so that the same input
+// always produces the same JSON. Object members are already written in key
+// order by WriteJSON.
+func (r *AnalysisReport) Normalise() {
+	r.SchemaVersion = SchemaVersion
+	sortLocations(r.DuplicateIDs)
+	sortLocations(r.DuplicateRows)
+	for _, sets := range r.DuplicateIDsByKey {
+		sortLocations(sets)
+	}
+	for _, sr := range r.Scopes {
+		sortLocations(sr.DuplicateIDs)
+		sortLocations(sr.DuplicateRows)
+		for _, sets := range sr.DuplicateIDsByKey {
+			sortLocations(sets)
+		}
+	}
+	for _, cluster := range r.FuzzyClusters {
+		slices.Sort(cluster.Values)
+		slices.SortFunc(cluster.Locations, compareLocations)
+	}
+	slices.SortFunc(r.FuzzyClusters, func(a, b FuzzyCluster) int {
+		if c := cmp.Compare(a.Scope, b.Scope); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Values[0], b.Values[0])
+	})
+}
+
+// sortLocations orders the locations of each duplicate set by file path and
+// then line number.
+func sortLocations(sets map[string][]LocationInfo) {
+	for _, locs := range sets {
+		slices.SortFunc(locs, compareLocations)
+	}
+}
+
+func compareLocations(a, b LocationInfo) int {
+	if c := cmp.Compare(a.FilePath, b.FilePath); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.LineNumber, b.LineNumber)
+}
@@ -0,0 +1,194 @@
+// pkg/report/sqlite.go
+package report
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const sqliteSchema = `CREATE TABLE summary (
+  unique_key TEXT,
+  scope TEXT,
+  is_partial INTEGER,
+  files_processed INTEGER,
+  total_files INTEGER,
+  processed_bytes INTEGER,
+  total_bytes INTEGER,
+  rows_processed INTEGER,
+  elapsed TEXT,
+  key_occurrences INTEGER,
+  unique_keys_duplicated INTEGER,
+  duplicate_row_instances INTEGER
+);
+CREATE TABLE folders (
+  path TEXT PRIMARY KEY,
+  processed_bytes INTEGER,
+  total_bytes INTEGER,
+  files_processed INTEGER,
+  total_files INTEGER,
+  rows_processed INTEGER,
+  keys_found INTEGER,
+  duplicate_ids INTEGER,
+  duplicate_rows INTEGER
+);
+CREATE TABLE duplicate_sets (
+  id INTEGER PRIMARY KEY,
+  type TEXT NOT NULL,
+  key TEXT,
+  value TEXT NOT NULL,
+  scope TEXT,
+  count INTEGER NOT NULL
+);
+CREATE TABLE duplicate_locations (
+  set_id INTEGER NOT NULL REFERENCES duplicate_sets(id),
+  file TEXT NOT NULL,
+  line INTEGER NOT NULL
+);
+`
+
+// Indexes are created after the rows are inserted, which is much faster than
+// maintaining them during the load.
+const sqliteIndexes = `CREATE INDEX duplicate_sets_value ON duplicate_sets(type, key, value);
+CREATE INDEX duplicate_locations_set ON duplicate_locations(set_id);
+CREATE INDEX duplicate_locations_file ON duplicate_locations(file, line);
+`
+
+// WriteSQL writes the summary, folder details and every duplicate set and
+// location as a SQLite script that creates and fills the tables summary,
+// folders, duplicate_sets and duplicate_locations, then indexes them.
+func (r *AnalysisReport) WriteSQL(w io.Writer, checkKey, checkRow bool) error {
+	s := r.Summary
+	bw := bufio.NewWriterSize(w, 256*1024)
+	bw.WriteString("BEGIN TRANSACTION;\n" + sqliteSchema)
+	fmt.Fprintf(bw, "INSERT INTO summary VALUES (%s, %s, %d, %d, %d, %d, %d, %d, %s, %d, %d, %d);\n",
+		sqlString(s.UniqueKey), sqlString(s.Scope), sqlBool(s.IsPartialReport), s.FilesProcessed, s.TotalFiles,
+		s.ProcessedDataSizeBytes, s.TotalDataSizeOverallBytes, s.TotalRowsProcessed, sqlString(s.TotalElapsedTime),
+		s.TotalKeyOccurrences, s.UniqueKeysDuplicated, s.DuplicateRowInstances)
+	for _, path := range sortedKeys(s.FolderDetails) {
+		d := s.FolderDetails[path]
+		fmt.Fprintf(bw, "INSERT INTO folders VALUES (%s, %d, %d, %d, %d, %d, %d, %d, %d);\n",
+			sqlString(path), d.ProcessedSizeBytes, d.TotalSizeBytes, d.FilesProcessed, d.TotalFiles,
+			d.RowsProcessed, d.KeysFound, s.DuplicateIDsPerFolder[path], s.DuplicateRowsPerFolder[path])
+	}
+
+	setID := 0
+	r.EachDuplicateSet(checkKey, checkRow, func(kind, key, scope, value string, locs []LocationInfo) {
+		setID++
+		fmt.Fprintf(bw, "INSERT INTO duplicate_sets VALUES (%d, %s, %s, %s, %s, %d);\n",
+			setID, sqlString(kind), sqlString(key), sqlString(value), sqlString(scope), len(locs))
+		for _, loc := range locs {
+			fmt.Fprintf(bw, "INSERT INTO duplicate_locations VALUES (%d, %s, %d);\n", setID, sqlString(loc.FilePath), loc.LineNumber)
+		}
+	})
+	bw.WriteString(sqliteIndexes + "COMMIT;\n")
+	return bw.Flush()
+}
+
+// saveSQLite writes the report to a SQLite database at dbPath. The database
+// is built in a temporary file beside dbPath and renamed over it once
+// complete, so a failed save leaves any earlier database in place.
+func (r *AnalysisReport) saveSQLite(dbPath string, checkKey, checkRow bool) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(dbPath), filepath.Base(dbPath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer func() {
+		if err != nil {
+			os.Remove(tmpPath)
+		}
+	}()
+	db, err := sql.Open("sqlite3", tmpPath)
+	if err != nil {
+		return err
+	}
+	if err := r.loadSQLite(db, checkKey, checkRow); err != nil {
+		db.Close()
+		return err
+	}
+	if err := db.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, dbPath)
+}
+
+// loadSQLite creates and fills the tables of WriteSQL in db in one
+// transaction, then indexes them.
+func (r *AnalysisReport) loadSQLite(db *sql.DB, checkKey, checkRow bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(sqliteSchema); err != nil {
+		return err
+	}
+	s := r.Summary
+	if _, err := tx.Exec("INSERT INTO summary VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		s.UniqueKey, s.Scope, sqlBool(s.IsPartialReport), s.FilesProcessed, s.TotalFiles,
+		s.ProcessedDataSizeBytes, s.TotalDataSizeOverallBytes, s.TotalRowsProcessed, s.TotalElapsedTime,
+		s.TotalKeyOccurrences, s.UniqueKeysDuplicated, s.DuplicateRowInstances); err != nil {
+		return err
+	}
+	for _, path := range sortedKeys(s.FolderDetails) {
+		d := s.FolderDetails[path]
+		if _, err := tx.Exec("INSERT INTO folders VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			path, d.ProcessedSizeBytes, d.TotalSizeBytes, d.FilesProcessed, d.TotalFiles,
+			d.RowsProcessed, d.KeysFound, s.DuplicateIDsPerFolder[path], s.DuplicateRowsPerFolder[path]); err != nil {
+			return err
+		}
+	}
+
+	insertSet, err := tx.Prepare("INSERT INTO duplicate_sets VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insertSet.Close()
+	insertLoc, err := tx.Prepare("INSERT INTO duplicate_locations VALUES (?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insertLoc.Close()
+	setID := 0
+	r.EachDuplicateSet(checkKey, checkRow, func(kind, key, scope, value string, locs []LocationInfo) {
+		if err != nil {
+			return
+		}
+		setID++
+		if _, err = insertSet.Exec(setID, kind, key, value, scope, len(locs)); err != nil {
+			return
+		}
+		for _, loc := range locs {
+			if _, err = insertLoc.Exec(setID, loc.FilePath, loc.LineNumber); err != nil {
+				return
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(sqliteIndexes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// sqlString quotes s as a SQL string literal.
+func sqlString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func sqlBool(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
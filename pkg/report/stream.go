@@ -0,0 +1,142 @@
+// pkg/report/stream.go
+package report
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+const jsonIndent = "  "
+
+// WriteJSON writes the report to w as indented JSON. The output is identical
+// to marshalling the whole report, but each duplicate set is encoded on its
+// own, so the document never has to be held in memory at once.
+func (r *AnalysisReport) WriteJSON(w io.Writer) error {
+	jw := &jsonWriter{w: bufio.NewWriterSize(w, 256*1024)}
+	jw.write("{")
+	jw.key(1, "schemaVersion", true)
+	jw.value(1, r.SchemaVersion)
+	jw.key(1, "summary", false)
+	jw.value(1, r.Summary)
+	jw.key(1, "duplicateIds", false)
+	streamMap(jw, 1, r.DuplicateIDs, func(locs []LocationInfo) { jw.value(2, locs) })
+	jw.key(1, "duplicateRows", false)
+	streamMap(jw, 1, r.DuplicateRows, func(locs []LocationInfo) { jw.value(2, locs) })
+	if len(r.DuplicateIDsByKey) > 0 {
+		jw.key(1, "duplicateIdsByKey", false)
+		streamMap(jw, 1, r.DuplicateIDsByKey, func(dupes map[string][]LocationInfo) {
+			streamMap(jw, 2, dupes, func(locs []LocationInfo) { jw.value(3, locs) })
+		})
+	}
+	if len(r.Scopes) > 0 {
+		jw.key(1, "scopes", false)
+		streamMap(jw, 1, r.Scopes, func(sr *ScopeReport) { jw.value(2, sr) })
+	}
+	if len(r.FuzzyClusters) > 0 {
+		jw.key(1, "fuzzyClusters", false)
+		jw.value(1, r.FuzzyClusters)
+	}
+	if len(r.KeyCandidates) > 0 {
+		jw.key(1, "keyCandidates", false)
+		jw.value(1, r.KeyCandidates)
+	}
+	if len(r.Profile) > 0 {
+		jw.key(1, "profile", false)
+		jw.value(1, r.Profile)
+	}
+	if len(r.RecordSamples) > 0 {
+		jw.key(1, "recordSamples", false)
+		streamMap(jw, 1, r.RecordSamples, func(sample json.RawMessage) { jw.value(2, sample) })
+	}
+	if len(r.OversizedLines) > 0 {
+		jw.key(1, "oversizedLines", false)
+		jw.value(1, r.OversizedLines)
+	}
+	if len(r.ParseErrors) > 0 {
+		jw.key(1, "parseErrors", false)
+		jw.value(1, r.ParseErrors)
+	}
+	if len(r.FileDetails) > 0 {
+		jw.key(1, "fileDetails", false)
+		streamMap(jw, 1, r.FileDetails, func(d FileDetail) { jw.value(2, d) })
+	}
+	if len(r.SingletonIDs) > 0 {
+		jw.key(1, "singletonIds", false)
+		streamMap(jw, 1, r.SingletonIDs, func(loc LocationInfo) { jw.value(2, loc) })
+	}
+	if len(r.SingletonRows) > 0 {
+		jw.key(1, "singletonRows", false)
+		streamMap(jw, 1, r.SingletonRows, func(loc LocationInfo) { jw.value(2, loc) })
+	}
+	if len(r.SingletonIDsByKey) > 0 {
+		jw.key(1, "singletonIdsByKey", false)
+		streamMap(jw, 1, r.SingletonIDsByKey, func(singles map[string]LocationInfo) {
+			streamMap(jw, 2, singles, func(loc LocationInfo) { jw.value(3, loc) })
+		})
+	}
+	jw.write("\n}")
+	return jw.flush()
+}
+
+// jsonWriter writes indented JSON piece by piece, keeping the first error.
+type jsonWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (jw *jsonWriter) write(s string) {
+	if jw.err == nil {
+		_, jw.err = jw.w.WriteString(s)
+	}
+}
+
+// key writes the separator, indentation and name of an object member nested
+// depth levels deep.
+func (jw *jsonWriter) key(depth int, name string, first bool) {
+	if !first {
+		jw.write(",")
+	}
+	encoded, _ := json.Marshal(name)
+	jw.write("\n" + strings.Repeat(jsonIndent, depth) + string(encoded) + ": ")
+}
+
+// value writes v as indented JSON for a member nested depth levels deep.
+func (jw *jsonWriter) value(depth int, v any) {
+	if jw.err != nil {
+		return
+	}
+	encoded, err := json.MarshalIndent(v, strings.Repeat(jsonIndent, depth), jsonIndent)
+	if err != nil {
+		jw.err = err
+		return
+	}
+	_, jw.err = jw.w.Write(encoded)
+}
+
+func (jw *jsonWriter) flush() error {
+	if jw.err != nil {
+		return jw.err
+	}
+	return jw.w.Flush()
+}
+
+// streamMap writes m as an object nested depth levels deep, calling
+// writeValue for each member in sorted key order as encoding/json does.
+func streamMap[V any](jw *jsonWriter, depth int, m map[string]V, writeValue func(V)) {
+	if m == nil {
+		jw.write("null")
+		return
+	}
+	if len(m) == 0 {
+		jw.write("{}")
+		return
+	}
+	jw.write("{")
+	for i, k := range sortedKeys(m) {
+		jw.key(depth+1, k, i == 0)
+		writeValue(m[k])
+	}
+	jw.write("\n" + strings.Repeat(jsonIndent, depth) + "}")
+}
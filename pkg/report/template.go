@@ -0,0 +1,77 @@
+// pkg/report/template.go
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// TemplateSet is one duplicate set as seen by report templates.
+type TemplateSet struct {
+	Type      string
+	Key       string
+	Scope     string
+	Value     string
+	Locations []LocationInfo
+}
+
+// TemplateData is the value a report template is executed with. Maps such
+// as .Summary.FolderDetails are ranged over in key order by text/template.
+type TemplateData struct {
+	Report              *AnalysisReport
+	Summary             SummaryReport
+	Full                bool
+	CheckKey            bool
+	CheckRow            bool
+	ShowFolderBreakdown bool
+}
+
+// DuplicateSets returns the duplicate sets enabled by the checks, in the same
+// order as the other outputs.
+func (d TemplateData) DuplicateSets() []TemplateSet {
+	var sets []TemplateSet
+	d.Report.EachDuplicateSet(d.CheckKey, d.CheckRow, func(kind, key, scope, value string, locs []LocationInfo) {
+		sets = append(sets, TemplateSet{Type: kind, Key: key, Scope: scope, Value: value, Locations: locs})
+	})
+	return sets
+}
+
+var templateFuncs = template.FuncMap{
+	"humanSize": HumanSize,
+	"join":      strings.Join,
+	"upper":     strings.ToUpper,
+	"lower":     strings.ToLower,
+}
+
+// LoadTemplate parses a text/template file for rendering reports in place of
+// the built-in text layout. Besides the standard functions, templates can use
+// humanSize, join, upper and lower.
+func LoadTemplate(path string) (*template.Template, error) {
+	tmpl, err := template.New(filepath.Base(path)).Funcs(templateFuncs).ParseFiles(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse report template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// WriteTemplate renders the report with tmpl. isFullReport is passed to the
+// template as .Full, so one template can serve both the summary and the
+// details file.
+func (r *AnalysisReport) WriteTemplate(w io.Writer, tmpl *template.Template, isFullReport bool, checkKey, checkRow, showFolderBreakdown bool) error {
+	bw := bufio.NewWriterSize(w, 256*1024)
+	if err := tmpl.Execute(bw, TemplateData{
+		Report:              r,
+		Summary:             r.Summary,
+		Full:                isFullReport,
+		CheckKey:            checkKey,
+		CheckRow:            checkRow,
+		ShowFolderBreakdown: showFolderBreakdown,
+	}); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,133 @@
+// pkg/source/browse.go
+package source
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// ProjectEnv names the environment variable holding the Google Cloud project
+// whose buckets are listed by ListGCSBuckets.
+const ProjectEnv = "GOOGLE_CLOUD_PROJECT"
+
+// MaxCountedObjects caps the objects read to count those under each prefix
+// listed by ListGCSPrefix.
+const MaxCountedObjects = 10000
+
+// GCSEntry is a bucket, prefix or object listed while browsing GCS.
+type GCSEntry struct {
+	// Path is the gs:// path of the entry; prefixes and buckets end in "/".
+	Path     string
+	Name     string
+	IsPrefix bool
+	// Objects counts the objects under a prefix, up to MaxCountedObjects
+	// for the whole listing.
+	Objects int
+	Size    int64
+}
+
+// ListGCSBuckets lists the buckets of the project named by ProjectEnv.
+func ListGCSBuckets(ctx context.Context) ([]GCSEntry, error) {
+	project := os.Getenv(ProjectEnv)
+	if project == "" {
+		return nil, fmt.Errorf("set %s to list its buckets, or type a gs://bucket path to browse it", ProjectEnv)
+	}
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %w. Ensure you are authenticated", err)
+	}
+	defer client.Close()
+
+	var entries []GCSEntry
+	it := client.Buckets(ctx, project)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the buckets of project '%s': %w", project, err)
+		}
+		entries = append(entries, GCSEntry{Path: "gs://" + attrs.Name + "/", Name: attrs.Name, IsPrefix: true})
+	}
+	return entries, nil
+}
+
+// ListGCSPrefix lists the prefixes and objects directly under a gs://
+// bucket/prefix path, with the number of objects under each prefix. It
+// reports whether the counts stopped at MaxCountedObjects.
+func ListGCSPrefix(ctx context.Context, path string) ([]GCSEntry, bool, error) {
+	bucketName, prefix, _ := strings.Cut(strings.TrimPrefix(path, "gs://"), "/")
+	if bucketName == "" {
+		return nil, false, fmt.Errorf("invalid GCS path: bucket name cannot be empty in '%s'", path)
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create GCS client: %w. Ensure you are authenticated", err)
+	}
+	defer client.Close()
+	bucket := client.Bucket(bucketName)
+	base := "gs://" + bucketName + "/"
+
+	var entries []GCSEntry
+	byPrefix := make(map[string]int)
+	query := &storage.Query{Prefix: prefix, Delimiter: "/"}
+	if err := query.SetAttrSelection([]string{"Name", "Size"}); err != nil {
+		return nil, false, err
+	}
+	it := bucket.Objects(ctx, query)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to list 'gs://%s/%s': %w", bucketName, prefix, err)
+		}
+		if attrs.Prefix != "" {
+			byPrefix[attrs.Prefix] = len(entries)
+			entries = append(entries, GCSEntry{Path: base + attrs.Prefix, Name: strings.TrimPrefix(attrs.Prefix, prefix), IsPrefix: true})
+		} else if attrs.Name != prefix {
+			entries = append(entries, GCSEntry{Path: base + attrs.Name, Name: strings.TrimPrefix(attrs.Name, prefix), Size: attrs.Size})
+		}
+	}
+
+	// Count the objects under each prefix from one listing of everything
+	// below this one, stopping at MaxCountedObjects.
+	truncated := false
+	if len(byPrefix) > 0 {
+		query := &storage.Query{Prefix: prefix}
+		if err := query.SetAttrSelection([]string{"Name"}); err != nil {
+			return nil, false, err
+		}
+		it := bucket.Objects(ctx, query)
+		for counted := 0; ; counted++ {
+			if counted == MaxCountedObjects {
+				truncated = true
+				break
+			}
+			attrs, err := it.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return nil, false, fmt.Errorf("failed to count the objects in 'gs://%s/%s': %w", bucketName, prefix, err)
+			}
+			sub, _, found := strings.Cut(strings.TrimPrefix(attrs.Name, prefix), "/")
+			if i, ok := byPrefix[prefix+sub+"/"]; ok && found {
+				entries[i].Objects++
+			}
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].IsPrefix && !entries[j].IsPrefix })
+	return entries, truncated, nil
+}
@@ -0,0 +1,90 @@
+// pkg/source/chunk.go
+package source
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Chunk is a newline-aligned byte range of a file. The line number of its
+// first line is not known until the chunks before it have been scanned.
+type Chunk struct {
+	Offset int64
+	Length int64
+}
+
+// ChunkedSource is implemented by sources that support random access, allowing
+// a single large file to be split and scanned by several workers at once.
+type ChunkedSource interface {
+	InputSource
+	// Chunks splits the source into newline-aligned chunks of roughly
+	// chunkSize bytes.
+	Chunks(chunkSize int64) ([]Chunk, error)
+	// OpenChunk returns a reader over a single chunk.
+	OpenChunk(c Chunk) (io.ReadCloser, error)
+}
+
+// chunkReader is an io.ReadCloser over a section of an open file.
+type chunkReader struct {
+	*io.SectionReader
+	file *os.File
+}
+
+func (c chunkReader) Close() error { return c.file.Close() }
+
+// OpenChunk returns a reader over a single chunk of the file.
+func (lfs LocalFileSource) OpenChunk(c Chunk) (io.ReadCloser, error) {
+	f, err := os.Open(lfs.filePath)
+	if err != nil {
+		return nil, err
+	}
+	return chunkReader{SectionReader: io.NewSectionReader(f, c.Offset, c.Length), file: f}, nil
+}
+
+// Chunks splits the file on newline boundaries, reading only as far past each
+// split point as the next newline.
+func (lfs LocalFileSource) Chunks(chunkSize int64) ([]Chunk, error) {
+	f, err := os.Open(lfs.filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var chunks []Chunk
+	offset := int64(0)
+	for offset < lfs.size {
+		end := offset + chunkSize
+		if end >= lfs.size {
+			end = lfs.size
+		} else if end, err = nextLineStart(f, end, lfs.size); err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, Chunk{Offset: offset, Length: end - offset})
+		offset = end
+	}
+	return chunks, nil
+}
+
+// nextLineStart returns the offset just past the first newline at or after
+// from, or size if there is none.
+func nextLineStart(f *os.File, from, size int64) (int64, error) {
+	br := bufio.NewReaderSize(io.NewSectionReader(f, from, size-from), 64*1024)
+	pos := from
+	for {
+		chunk, err := br.ReadSlice('\n')
+		pos += int64(len(chunk))
+		switch {
+		case err == nil:
+			return pos, nil
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case errors.Is(err, io.EOF):
+			return size, nil
+		default:
+			return 0, fmt.Errorf("could not find line boundary in %s: %w", f.Name(), err)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// pkg/source/lines.go
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxLineSize is the longest line, in bytes, that is read in full
+// unless a different limit is configured.
+const DefaultMaxLineSize = 4 * 1024 * 1024
+
+// LineReader reads newline-delimited records, growing its buffer as needed up
+// to a maximum line size. Unlike bufio.Scanner, a line over the limit does not
+// end the stream: it is discarded and reported so reading can continue.
+type LineReader struct {
+	br          *bufio.Reader
+	maxLineSize int
+	buf         []byte
+	// data holds the input when reading from memory; lines are then sliced
+	// from it directly rather than copied.
+	data   []byte
+	offset int64
+}
+
+// NewLineReader returns a LineReader that reads from r. Lines longer than
+// maxLineSize bytes, excluding the line ending, are reported as oversized.
+func NewLineReader(r io.Reader, maxLineSize int) *LineReader {
+	if maxLineSize <= 0 {
+		maxLineSize = DefaultMaxLineSize
+	}
+	return &LineReader{br: bufio.NewReaderSize(r, 64*1024), maxLineSize: maxLineSize}
+}
+
+// NewBytesLineReader returns a LineReader over data already in memory, such
+// as a MappedFile, that returns lines without copying them.
+func NewBytesLineReader(data []byte, maxLineSize int) *LineReader {
+	if maxLineSize <= 0 {
+		maxLineSize = DefaultMaxLineSize
+	}
+	return &LineReader{data: data, maxLineSize: maxLineSize}
+}
+
+// Offset returns the number of bytes consumed so far.
+func (l *LineReader) Offset() int64 { return l.offset }
+
+// Next returns the next line without its line ending. When the line exceeds
+// the maximum size, oversized is true and line is nil. The returned slice is
+// only valid until the next call. At the end of the input Next returns io.EOF.
+func (l *LineReader) Next() (line []byte, oversized bool, err error) {
+	if l.br == nil {
+		return l.nextFromBytes()
+	}
+	l.buf = l.buf[:0]
+	readAny := false
+	for {
+		chunk, err := l.br.ReadSlice('\n')
+		l.offset += int64(len(chunk))
+		if len(chunk) > 0 {
+			readAny = true
+			if !oversized {
+				// Allow for a trailing "\r\n" before judging the length.
+				if len(l.buf)+len(chunk) > l.maxLineSize+2 {
+					oversized = true
+					l.buf = l.buf[:0]
+				} else {
+					l.buf = append(l.buf, chunk...)
+				}
+			}
+		}
+		switch {
+		case err == nil:
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case errors.Is(err, io.EOF):
+			if !readAny {
+				return nil, false, io.EOF
+			}
+		default:
+			return nil, false, err
+		}
+		break
+	}
+
+	if oversized {
+		return nil, true, nil
+	}
+	line = bytes.TrimSuffix(l.buf, []byte("\n"))
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if len(line) > l.maxLineSize {
+		return nil, true, nil
+	}
+	return line, false, nil
+}
+
+func (l *LineReader) nextFromBytes() ([]byte, bool, error) {
+	rest := l.data[l.offset:]
+	if len(rest) == 0 {
+		return nil, false, io.EOF
+	}
+	line := rest
+	if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+		line = rest[:i]
+		l.offset += int64(i) + 1
+	} else {
+		l.offset += int64(len(rest))
+	}
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if len(line) > l.maxLineSize {
+		return nil, true, nil
+	}
+	return line, false, nil
+}
+
+// ReadLines streams a source and calls fn for every requested 1-based line
+// number, using the same line numbering as the analyser. The data passed to fn
+// is only valid for the duration of the call. Requested lines longer than
+// maxLineSize are skipped. Reading stops once every requested line has been
+// seen.
+func ReadLines(ctx context.Context, src InputSource, lines map[int]bool, maxLineSize int, fn func(lineNumber int, data []byte)) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	reader, err := src.Open(ctx)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %w", src.Path(), err)
+	}
+	defer reader.Close()
+
+	lr := NewLineReader(reader, maxLineSize)
+	remaining := len(lines)
+	lineNumber := 0
+	for remaining > 0 {
+		if lineNumber%1000 == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		line, oversized, err := lr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("could not read %s: %w", src.Path(), err)
+		}
+		lineNumber++
+		if lines[lineNumber] {
+			if !oversized {
+				fn(lineNumber, line)
+			}
+			remaining--
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+// pkg/source/list.go
+package source
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// listStatWorkers is how many GCS objects FromList looks up at once.
+const listStatWorkers = 16
+
+// ReadList reads a list of files to analyse from the file at name, or from
+// standard input when name is "-". Each line holds one local path or gs://
+// URI; blank lines and lines starting with # are skipped.
+func ReadList(name string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, fmt.Errorf("could not open file list: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	var files []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		files = append(files, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read file list %s: %w", name, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files listed in %s", name)
+	}
+	return files, nil
+}
+
+// FromList returns a source for each of files without discovering anything:
+// local files are only stat'ed and GCS objects only have their attributes
+// read, so a long list costs no directory walks or bucket listings. Listed
+// files are analysed whatever their extension or content type. A file listed
+// more than once is only returned once, and a file that cannot be found is
+// an error.
+func FromList(ctx context.Context, files []string) ([]InputSource, error) {
+	ctx, span := tracer.Start(ctx, "discover.list")
+	defer span.End()
+	sources := make([]InputSource, len(files))
+	seen := make(map[string]bool, len(files))
+	var objects []int
+	for i, file := range files {
+		if strings.HasPrefix(file, "gs://") {
+			objects = append(objects, i)
+			continue
+		}
+		src, err := listedLocalFile(file)
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+		sources[i] = src
+	}
+	if len(objects) > 0 {
+		if err := listedGCSObjects(ctx, files, objects, sources); err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+	}
+
+	unique := sources[:0]
+	for _, src := range sources {
+		if !seen[src.Path()] {
+			seen[src.Path()] = true
+			unique = append(unique, src)
+		}
+	}
+	span.SetAttributes(attribute.Int("files", len(unique)), attribute.Int64("bytes", TotalSize(unique)))
+	return unique, nil
+}
+
+// listedLocalFile returns the source for a listed local file.
+func listedLocalFile(file string) (InputSource, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, fmt.Errorf("listed file: %w", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("listed path is a directory, not a file: %s", file)
+	}
+	absPath, err := filepath.Abs(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not get absolute path for %s: %w", file, err)
+	}
+	return LocalFileSource{filePath: absPath, size: info.Size(), modTime: info.ModTime()}, nil
+}
+
+// listedGCSObjects reads the attributes of the objects at the given indexes
+// of files, listStatWorkers at a time, and stores their sources at the same
+// indexes of sources.
+func listedGCSObjects(ctx context.Context, files []string, objects []int, sources []InputSource) error {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create GCS client: %w. Ensure you are authenticated", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	indexes := make(chan int)
+	for range min(listStatWorkers, len(objects)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				src, err := listedGCSObject(ctx, client, files[i])
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				sources[i] = src
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, i := range objects {
+		if ctx.Err() != nil {
+			break
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// listedGCSObject returns the source for a listed gs://bucket/object URI.
+func listedGCSObject(ctx context.Context, client *storage.Client, uri string) (InputSource, error) {
+	bucketName, name, _ := strings.Cut(strings.TrimPrefix(uri, "gs://"), "/")
+	if bucketName == "" || name == "" || strings.HasSuffix(name, "/") {
+		return nil, fmt.Errorf("listed GCS path must name an object: %s", uri)
+	}
+	bucket := client.Bucket(bucketName)
+	attrs, err := bucket.Object(name).Attrs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listed object %s: %w", uri, err)
+	}
+	return GCSObjectSource{bucket: bucket, object: attrs}, nil
+}
@@ -0,0 +1,40 @@
+// pkg/source/mmap.go
+package source
+
+import "errors"
+
+// ErrMmapUnsupported is returned by OpenMapped on platforms without
+// memory-mapped file support.
+var ErrMmapUnsupported = errors.New("memory-mapped reading is not supported on this platform")
+
+// MappedSource is implemented by sources whose contents can be memory-mapped,
+// letting lines be sliced straight out of the page cache instead of being
+// copied through a read buffer.
+type MappedSource interface {
+	InputSource
+	OpenMapped() (*MappedFile, error)
+}
+
+// MappedFile is a read-only memory mapping of a whole file.
+type MappedFile struct {
+	data  []byte
+	unmap func([]byte) error
+}
+
+// Bytes returns the mapped contents. The slice must not be used after Close.
+func (m *MappedFile) Bytes() []byte { return m.data }
+
+// Close releases the mapping.
+func (m *MappedFile) Close() error {
+	if m.unmap == nil || m.data == nil {
+		return nil
+	}
+	data := m.data
+	m.data = nil
+	return m.unmap(data)
+}
+
+// OpenMapped memory-maps the file for reading.
+func (lfs LocalFileSource) OpenMapped() (*MappedFile, error) {
+	return mapFile(lfs.filePath)
+}
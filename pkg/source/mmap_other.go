@@ -0,0 +1,8 @@
+//go:build !unix
+
+// pkg/source/mmap_other.go
+package source
+
+func mapFile(string) (*MappedFile, error) {
+	return nil, ErrMmapUnsupported
+}
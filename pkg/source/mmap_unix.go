@@ -0,0 +1,35 @@
+//go:build unix
+
+// pkg/source/mmap_unix.go
+package source
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+func mapFile(path string) (*MappedFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	// Mapping an empty file fails, and there is nothing to read anyway.
+	if info.Size() == 0 {
+		return &MappedFile{}, nil
+	}
+	if int64(int(info.Size())) != info.Size() {
+		return nil, fmt.Errorf("%s is too large to map into memory", path)
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("could not map %s: %w", path, err)
+	}
+	return &MappedFile{data: data, unmap: syscall.Munmap}, nil
+}
@@ -1,4 +1,7 @@
-// internal/source/source.go
+// pkg/source/source.go
+
+// Package source discovers the NDJSON files to analyse under local paths and
+// gs:// prefixes, and opens them for reading.
 package source
 
 import (
@@ -8,24 +11,44 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/storage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/api/iterator"
 )
 
+var tracer = otel.Tracer("github.com/benjaminwestern/dupe-analyser/pkg/source")
+
 // InputSource defines an abstract source for data, providing a way to get
-// a streaming reader for the content, its path, and its size.
+// a streaming reader for the content, its path, its size, and a fingerprint
+// that changes whenever the content does.
 type InputSource interface {
 	Path() string
 	Open(ctx context.Context) (io.ReadCloser, error)
 	Dir() string
 	Size() int64
+	Fingerprint() string
+}
+
+// TotalSize returns the combined size in bytes of the given sources.
+func TotalSize(sources []InputSource) int64 {
+	var total int64
+	for _, s := range sources {
+		total += s.Size()
+	}
+	return total
 }
 
 // DiscoverAll iterates through a list of path strings, calls Discover for each,
 // and aggregates the results, ensuring no source is included more than once.
 // It returns an error if any path is invalid.
 func DiscoverAll(ctx context.Context, paths []string) ([]InputSource, error) {
+	ctx, span := tracer.Start(ctx, "discover")
+	defer span.End()
 	var uniqueSources []InputSource
 	discoveredPaths := make(map[string]bool)
 
@@ -34,8 +57,9 @@ func DiscoverAll(ctx context.Context, paths []string) ([]InputSource, error) {
 		if p == "" {
 			continue
 		}
-		sources, err := Discover(ctx, p)
+		sources, err := discoverTraced(ctx, p)
 		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
 			return nil, fmt.Errorf("error in path '%s': %w", p, err)
 		}
 
@@ -48,32 +72,81 @@ func DiscoverAll(ctx context.Context, paths []string) ([]InputSource, error) {
 		}
 	}
 
+	span.SetAttributes(attribute.Int("files", len(uniqueSources)), attribute.Int64("bytes", TotalSize(uniqueSources)))
 	if len(uniqueSources) == 0 {
+		span.SetStatus(codes.Error, "no processable files found")
 		return nil, fmt.Errorf("no processable files found in any of the provided paths")
 	}
 	return uniqueSources, nil
 }
 
+// discoverTraced wraps Discover in a span per path, so a slow bucket listing
+// stands out from the rest of discovery.
+func discoverTraced(ctx context.Context, path string) ([]InputSource, error) {
+	ctx, span := tracer.Start(ctx, "discover.path", trace.WithAttributes(attribute.String("path", path)))
+	defer span.End()
+	sources, err := Discover(ctx, path)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("files", len(sources)))
+	return sources, nil
+}
+
 // Discover finds all relevant sources at a given path, dispatching to the correct
 // implementation based on the path prefix (e.g., "gs://").
 func Discover(ctx context.Context, path string) ([]InputSource, error) {
 	if strings.HasPrefix(path, "gs://") {
 		return discoverGCSObjects(ctx, path)
 	}
-	info, err := os.Stat(path)
-	if err != nil {
+	if _, err := os.Stat(path); err != nil {
 		return nil, fmt.Errorf("invalid path: %w", err)
 	}
-	if !info.IsDir() {
-		return nil, fmt.Errorf("local path is not a directory: %s", path)
-	}
 	return discoverLocalFiles(ctx, path)
 }
 
+// Check reports whether path can be discovered without listing all of it: a
+// local path must be a directory or file, and a GCS path must name a bucket
+// that can be read with at least one object under its prefix.
+func Check(ctx context.Context, path string) error {
+	if !strings.HasPrefix(path, "gs://") {
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("invalid path: %w", err)
+		}
+		return nil
+	}
+	bucketName, prefix, _ := strings.Cut(strings.TrimPrefix(path, "gs://"), "/")
+	if bucketName == "" {
+		return fmt.Errorf("invalid GCS path: bucket name cannot be empty in '%s'", path)
+	}
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create GCS client: %w. Ensure you are authenticated", err)
+	}
+	defer client.Close()
+	bucket := client.Bucket(bucketName)
+	if _, err := bucket.Attrs(ctx); err != nil {
+		return fmt.Errorf("GCS bucket '%s' not found or access denied: %w", bucketName, err)
+	}
+	query := &storage.Query{Prefix: prefix}
+	if err := query.SetAttrSelection([]string{"Name"}); err != nil {
+		return err
+	}
+	if _, err := bucket.Objects(ctx, query).Next(); err == iterator.Done {
+		return fmt.Errorf("no objects found in 'gs://%s' with prefix '%s'", bucketName, prefix)
+	} else if err != nil {
+		return fmt.Errorf("failed to list GCS objects in bucket '%s': %w", bucketName, err)
+	}
+	return nil
+}
+
 // LocalFileSource implements InputSource for the local filesystem.
 type LocalFileSource struct {
 	filePath string
 	size     int64
+	modTime  time.Time
 }
 
 // Path returns the full file path.
@@ -88,6 +161,11 @@ func (lfs LocalFileSource) Dir() string { return filepath.Dir(lfs.filePath) }
 // Size returns the size of the file in bytes.
 func (lfs LocalFileSource) Size() int64 { return lfs.size }
 
+// Fingerprint combines the file's size and modification time.
+func (lfs LocalFileSource) Fingerprint() string {
+	return fmt.Sprintf("%d-%d", lfs.size, lfs.modTime.UnixNano())
+}
+
 // GCSObjectSource implements InputSource for Google Cloud Storage objects.
 type GCSObjectSource struct {
 	bucket *storage.BucketHandle
@@ -112,6 +190,11 @@ func (gcs GCSObjectSource) Size() int64 {
 	return gcs.object.Size
 }
 
+// Fingerprint combines the object's size, generation and CRC32C checksum.
+func (gcs GCSObjectSource) Fingerprint() string {
+	return fmt.Sprintf("%d-%d-%08x", gcs.object.Size, gcs.object.Generation, gcs.object.CRC32C)
+}
+
 func discoverGCSObjects(ctx context.Context, path string) ([]InputSource, error) {
 	client, err := storage.NewClient(ctx)
 	if err != nil {
@@ -187,7 +270,7 @@ func discoverLocalFiles(ctx context.Context, dirPath string) ([]InputSource, err
 			if err != nil {
 				return fmt.Errorf("could not get absolute path for %s: %w", path, err)
 			}
-			sources = append(sources, LocalFileSource{filePath: absPath, size: info.Size()})
+			sources = append(sources, LocalFileSource{filePath: absPath, size: info.Size(), modTime: info.ModTime()})
 		}
 		return nil
 	})